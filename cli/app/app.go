@@ -99,6 +99,7 @@ func ProjectDown(p project.APIProject, c *cli.Context) error {
 		RemoveVolume:  c.Bool("volumes"),
 		RemoveImages:  options.ImageType(c.String("rmi")),
 		RemoveOrphans: c.Bool("remove-orphans"),
+		KeepNetworks:  c.Bool("keep-networks"),
 	}
 	err := p.Down(context.Background(), options, c.Args()...)
 	if err != nil {
@@ -127,6 +128,7 @@ func ProjectCreate(p project.APIProject, c *cli.Context) error {
 		NoRecreate:    c.Bool("no-recreate"),
 		ForceRecreate: c.Bool("force-recreate"),
 		NoBuild:       c.Bool("no-build"),
+		PullPolicy:    c.String("pull"),
 	}
 	err := p.Create(context.Background(), options, c.Args()...)
 	if err != nil {
@@ -137,16 +139,22 @@ func ProjectCreate(p project.APIProject, c *cli.Context) error {
 
 // ProjectUp brings all services up.
 func ProjectUp(p project.APIProject, c *cli.Context) error {
-	options := options.Up{
+	upOptions := options.Up{
 		Create: options.Create{
 			NoRecreate:    c.Bool("no-recreate"),
 			ForceRecreate: c.Bool("force-recreate"),
 			NoBuild:       c.Bool("no-build"),
 			ForceBuild:    c.Bool("build"),
+			PullPolicy:    c.String("pull"),
 		},
+		RenewAnonymousVolumes: c.Bool("renew-anon-volumes"),
+		RemoveOrphans:         c.Bool("remove-orphans"),
+	}
+	if c.Bool("always-recreate-deps") {
+		upOptions.RecreateDeps = "always"
 	}
 	ctx, cancelFun := context.WithCancel(context.Background())
-	err := p.Up(ctx, options, c.Args()...)
+	err := p.Up(ctx, upOptions, c.Args()...)
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -156,7 +164,7 @@ func ProjectUp(p project.APIProject, c *cli.Context) error {
 		signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 		errChan := make(chan error)
 		go func() {
-			errChan <- p.Log(ctx, true, c.Args()...)
+			errChan <- p.Log(ctx, options.Log{Follow: true}, c.Args()...)
 		}()
 		go func() {
 			select {
@@ -218,7 +226,13 @@ func ProjectRestart(p project.APIProject, c *cli.Context) error {
 
 // ProjectLog gets services logs.
 func ProjectLog(p project.APIProject, c *cli.Context) error {
-	err := p.Log(context.Background(), c.Bool("follow"), c.Args()...)
+	logOptions := options.Log{
+		Follow:     c.Bool("follow"),
+		Tail:       c.String("tail"),
+		Since:      c.String("since"),
+		Timestamps: c.Bool("timestamps"),
+	}
+	err := p.Log(context.Background(), logOptions, c.Args()...)
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -227,7 +241,11 @@ func ProjectLog(p project.APIProject, c *cli.Context) error {
 
 // ProjectPull pulls images for services.
 func ProjectPull(p project.APIProject, c *cli.Context) error {
-	err := p.Pull(context.Background(), c.Args()...)
+	pullOptions := options.Pull{
+		Parallelism:    c.Int("parallel"),
+		IgnoreFailures: c.Bool("ignore-pull-failures"),
+	}
+	err := p.Pull(context.Background(), pullOptions, c.Args()...)
 	if err != nil && !c.Bool("ignore-pull-failures") {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -236,9 +254,6 @@ func ProjectPull(p project.APIProject, c *cli.Context) error {
 
 // ProjectDelete deletes services.
 func ProjectDelete(p project.APIProject, c *cli.Context) error {
-	options := options.Delete{
-		RemoveVolume: c.Bool("v"),
-	}
 	if !c.Bool("force") {
 		stoppedContainers, err := p.Containers(context.Background(), project.Filter{
 			State: project.Stopped,
@@ -260,7 +275,7 @@ func ProjectDelete(p project.APIProject, c *cli.Context) error {
 			return nil
 		}
 	}
-	err := p.Delete(context.Background(), options, c.Args()...)
+	err := p.RemoveStopped(context.Background(), c.Bool("v"), c.Args()...)
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -297,6 +312,22 @@ func ProjectPause(p project.APIProject, c *cli.Context) error {
 	return nil
 }
 
+// ProjectWatch watches the services files until interrupted.
+func ProjectWatch(p project.APIProject, c *cli.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signalChan
+		cancel()
+	}()
+	err := p.Watch(ctx, options.Watch{}, c.Args()...)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
 // ProjectUnpause unpauses service containers.
 func ProjectUnpause(p project.APIProject, c *cli.Context) error {
 	err := p.Unpause(context.Background(), c.Args()...)
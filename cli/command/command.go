@@ -20,13 +20,6 @@ func Populate(context *project.Context, c *cli.Context) {
 		context.ComposeFiles = append(context.ComposeFiles, strings.Split(v, string(os.PathListSeparator))...)
 	}
 
-	if len(context.ComposeFiles) == 0 {
-		context.ComposeFiles = []string{"docker-compose.yml"}
-		if _, err := os.Stat("docker-compose.override.yml"); err == nil {
-			context.ComposeFiles = append(context.ComposeFiles, "docker-compose.override.yml")
-		}
-	}
-
 	context.ProjectName = c.GlobalString("project-name")
 }
 
@@ -49,6 +42,10 @@ func CreateCommand(factory app.ProjectFactory) cli.Command {
 				Name:  "no-build",
 				Usage: "Don't build an image, even if it's missing.",
 			},
+			cli.StringFlag{
+				Name:  "pull",
+				Usage: "Pull images before creating containers (\"always\"|\"missing\"|\"never\"|\"build\").",
+			},
 		},
 	}
 }
@@ -154,6 +151,22 @@ func UpCommand(factory app.ProjectFactory) cli.Command {
 				Name:  "build",
 				Usage: "Build images before starting containers.",
 			},
+			cli.BoolFlag{
+				Name:  "renew-anon-volumes, V",
+				Usage: "Recreate anonymous volumes instead of retrieving data from the previous containers.",
+			},
+			cli.BoolFlag{
+				Name:  "always-recreate-deps",
+				Usage: "Recreate dependent containers even if their configuration didn't change.",
+			},
+			cli.StringFlag{
+				Name:  "pull",
+				Usage: "Pull images before creating containers (\"always\"|\"missing\"|\"never\"|\"build\").",
+			},
+			cli.BoolFlag{
+				Name:  "remove-orphans",
+				Usage: "Remove containers for services not defined in the Compose file",
+			},
 		},
 	}
 }
@@ -199,6 +212,10 @@ func PullCommand(factory app.ProjectFactory) cli.Command {
 				Name:  "ignore-pull-failures",
 				Usage: "Pull what it can and ignores images with pull failures.",
 			},
+			cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Maximum number of images to pull at the same time (defaults to the number of CPUs).",
+			},
 		},
 	}
 }
@@ -214,6 +231,19 @@ func LogsCommand(factory app.ProjectFactory) cli.Command {
 				Name:  "follow",
 				Usage: "Follow log output.",
 			},
+			cli.StringFlag{
+				Name:  "tail",
+				Usage: "Number of lines to show from the end of the logs for each container.",
+				Value: "all",
+			},
+			cli.StringFlag{
+				Name:  "since",
+				Usage: "Show logs since timestamp (e.g. 2013-01-02T13:23:37) or relative (e.g. 42m for 42 minutes).",
+			},
+			cli.BoolFlag{
+				Name:  "timestamps,t",
+				Usage: "Show timestamps.",
+			},
 		},
 	}
 }
@@ -267,6 +297,10 @@ func DownCommand(factory app.ProjectFactory) cli.Command {
 				Name:  "remove-orphans",
 				Usage: "Remove containers for services not defined in the Compose file",
 			},
+			cli.BoolFlag{
+				Name:  "keep-networks",
+				Usage: "Don't remove the networks of the project",
+			},
 		},
 	}
 }
@@ -290,12 +324,12 @@ func ScaleCommand(factory app.ProjectFactory) cli.Command {
 func RmCommand(factory app.ProjectFactory) cli.Command {
 	return cli.Command{
 		Name:   "rm",
-		Usage:  "Delete services",
+		Usage:  "Remove stopped service containers",
 		Action: app.WithProject(factory, app.ProjectDelete),
 		Flags: []cli.Flag{
 			cli.BoolFlag{
 				Name:  "force,f",
-				Usage: "Allow deletion of all services",
+				Usage: "Don't ask to confirm removal",
 			},
 			cli.BoolFlag{
 				Name:  "v",
@@ -331,6 +365,15 @@ func PauseCommand(factory app.ProjectFactory) cli.Command {
 	}
 }
 
+// WatchCommand defines the libcompose watch subcommand.
+func WatchCommand(factory app.ProjectFactory) cli.Command {
+	return cli.Command{
+		Name:   "watch",
+		Usage:  "Watch the develop.watch paths of services and sync, rebuild or restart them on changes.",
+		Action: app.WithProject(factory, app.ProjectWatch),
+	}
+}
+
 // UnpauseCommand defines the libcompose unpause subcommand.
 func UnpauseCommand(factory app.ProjectFactory) cli.Command {
 	return cli.Command{
@@ -379,7 +422,7 @@ func CommonFlags() []cli.Flag {
 		},
 		cli.StringSliceFlag{
 			Name:   "file,f",
-			Usage:  "Specify one or more alternate compose files (default: docker-compose.yml)",
+			Usage:  "Specify one or more alternate compose files (default: docker-compose.yml or compose.yaml)",
 			Value:  &cli.StringSlice{},
 			EnvVar: "COMPOSE_FILE",
 		},
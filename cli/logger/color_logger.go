@@ -42,7 +42,7 @@ func (c *ColorLoggerFactory) CreateBuildLogger(name string) logger.Logger {
 
 // CreatePullLogger implements logger.Factory.CreatePullLogger.
 func (c *ColorLoggerFactory) CreatePullLogger(name string) logger.Logger {
-	return &logger.NullLogger{}
+	return c.create(name)
 }
 
 // CreateBuildLogger implements logger.Factory.CreateContainerLogger.
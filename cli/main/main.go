@@ -70,6 +70,7 @@ Options:
 		command.UnpauseCommand(factory),
 		command.UpCommand(factory),
 		command.VersionCommand(factory),
+		command.WatchCommand(factory),
 	}
 
 	app.Run(os.Args)
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is an error found while validating a compose file. It
+// locates the offending value by service and field and, when the source of
+// the compose file is known, by line.
+type ValidationError struct {
+	// Service is the name of the offending service, empty for errors that
+	// are not related to a service.
+	Service string
+	// Field is the dotted path of the offending value in the service (e.g.
+	// ports.0), empty if the service itself is at fault.
+	Field string
+	// Line is the 1-based line of the offending value in the compose file,
+	// 0 if unknown.
+	Line int
+	// Message describes the error.
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+	}
+	return e.Message
+}
+
+// ValidationErrors holds all the errors found while validating a compose
+// file.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// ConfigWarning is a non fatal issue found while parsing a compose file,
+// typically a key that is ignored because it is not supported.
+type ConfigWarning struct {
+	// Service is the name of the service concerned, empty for warnings that
+	// are not related to a service.
+	Service string
+	// Field is the dotted path of the concerned value in the service (e.g.
+	// deploy.placement), empty if the warning is about the service itself.
+	Field string
+	// Message describes the warning.
+	Message string
+}
+
+func (w ConfigWarning) String() string {
+	return w.Message
+}
+
+// locateValidationErrors sets the line of the specified validation error(s)
+// from the source of the compose file. Other errors are returned as is.
+func locateValidationErrors(err error, source []byte, major int) error {
+	switch e := err.(type) {
+	case *ValidationError:
+		e.Line = serviceLine(source, major, e.Service, e.Field)
+	case ValidationErrors:
+		for _, validationError := range e {
+			validationError.Line = serviceLine(source, major, validationError.Service, validationError.Field)
+		}
+	}
+	return err
+}
+
+// serviceLine returns the line of the specified field of a service in the
+// source of a compose file (services are at the root of v1 files).
+func serviceLine(source []byte, major int, service, field string) int {
+	if service == "" {
+		return 0
+	}
+	path := []string{service}
+	if major >= 2 {
+		path = append([]string{"services"}, path...)
+	}
+	if field != "" {
+		path = append(path, strings.Split(field, ".")...)
+	}
+	return yamlLine(source, path)
+}
+
+// yamlLine returns the 1-based line of the value at the specified path (of
+// keys and sequence indexes) in the specified yaml source. If the path can't
+// be followed until the end (e.g. flow style values), the line of the deepest
+// value found is returned, 0 if there is none. Only block style mappings and
+// sequences are walked, which is what compose files are mostly made of.
+func yamlLine(source []byte, path []string) int {
+	lines := strings.Split(string(source), "\n")
+	line := 0
+	start, end := 0, len(lines)
+	for _, key := range path {
+		index, err := strconv.Atoi(key)
+		isIndex := err == nil
+
+		found, indent, count := -1, -1, 0
+		for i := start; i < end && found == -1; i++ {
+			text := strings.TrimLeft(lines[i], " ")
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			lineIndent := len(lines[i]) - len(text)
+			if indent == -1 {
+				indent = lineIndent
+			}
+			if lineIndent != indent {
+				continue
+			}
+			if isIndex && (text == "-" || strings.HasPrefix(text, "- ")) {
+				if count == index {
+					found = i
+				}
+				count++
+			} else if !isIndex && yamlKey(text) == key {
+				found = i
+			}
+		}
+		if found == -1 {
+			break
+		}
+		line = found + 1
+
+		if isIndex {
+			// The item content starts on the same line as its dash
+			lines[found] = lines[found][:indent] + " " + lines[found][indent+1:]
+			start, end = found, blockEnd(lines, found, indent, false)
+		} else {
+			start, end = found+1, blockEnd(lines, found, indent, true)
+		}
+	}
+	return line
+}
+
+// blockEnd returns the index of the first line after the specified one that
+// is not indented more than it. Sequences can be indented as much as their
+// key, which is allowed if sequence is set.
+func blockEnd(lines []string, from, indent int, sequence bool) int {
+	for i := from + 1; i < len(lines); i++ {
+		text := strings.TrimLeft(lines[i], " ")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		lineIndent := len(lines[i]) - len(text)
+		if lineIndent > indent || (sequence && lineIndent == indent && strings.HasPrefix(text, "-")) {
+			continue
+		}
+		return i
+	}
+	return len(lines)
+}
+
+// yamlKey returns the key of the specified mapping entry line, without
+// quotes, or an empty string if the line is not a mapping entry.
+func yamlKey(text string) string {
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.Trim(text[:i], `"'`)
+		}
+	}
+	return ""
+}
+
+// unsupportedKeysWarnings returns a warning for each key of the specified
+// services that is not supported by the specified compose file version, and
+// would thus be silently ignored. Extension keys (x-) are skipped.
+func unsupportedKeysWarnings(services RawServiceMap, major int) []ConfigWarning {
+	supported := supportedServiceKeys(major)
+
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	warnings := []ConfigWarning{}
+	for _, name := range names {
+		keys := []string{}
+		for key := range services[name] {
+			if !supported[key] && !strings.HasPrefix(key, "x-") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			warnings = append(warnings, ConfigWarning{
+				Service: name,
+				Field:   key,
+				Message: fmt.Sprintf("Unsupported config option for %s service: '%s', it is ignored", name, key),
+			})
+		}
+	}
+	return warnings
+}
+
+// supportedServiceKeys returns the service keys supported by the specified
+// compose file version, as defined by the yaml tags of the service config.
+func supportedServiceKeys(major int) map[string]bool {
+	var serviceType reflect.Type
+	if major < 2 {
+		serviceType = reflect.TypeOf(ServiceConfigV1{})
+	} else {
+		serviceType = reflect.TypeOf(ServiceConfig{})
+	}
+
+	keys := map[string]bool{}
+	for i := 0; i < serviceType.NumField(); i++ {
+		tag := strings.Split(serviceType.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != "" && tag != "-" {
+			keys[tag] = true
+		}
+	}
+	if major >= 3 {
+		// Translated into their v2 counterparts, see MergeServicesV3
+		keys["deploy"] = true
+		keys["configs"] = true
+		keys["secrets"] = true
+	}
+	return keys
+}
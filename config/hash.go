@@ -18,7 +18,27 @@ func GetServiceHash(name string, config *ServiceConfig) string {
 	hash := sha1.New()
 
 	io.WriteString(hash, name)
+	writeServiceConfig(hash, config)
 
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Hash returns a content hash of the service configuration, which only
+// changes when the configuration does: it doesn't depend on the order of
+// the keys of maps (labels, environment…) nor on the order of the values of
+// unordered lists (ports, dns…). Unlike GetServiceHash, the name of the
+// service is not part of it, so that it can be used to detect drift between
+// deployments.
+func (c *ServiceConfig) Hash() string {
+	hash := sha1.New()
+	writeServiceConfig(hash, c)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// writeServiceConfig writes a normalized representation of the specified
+// service configuration, sorting the unordered values (on copies, the
+// configuration is left untouched).
+func writeServiceConfig(hash io.Writer, config *ServiceConfig) {
 	//Get values of Service through reflection
 	val := reflect.ValueOf(config).Elem()
 
@@ -74,26 +94,101 @@ func GetServiceHash(name string, config *ServiceConfig) string {
 				io.WriteString(hash, fmt.Sprintf("%s, ", sliceKey))
 			}
 		case yaml.Stringorslice:
+			s = append(yaml.Stringorslice{}, s...)
 			sort.Strings(s)
 
 			for _, sliceKey := range s {
 				io.WriteString(hash, fmt.Sprintf("%s, ", sliceKey))
 			}
 		case []string:
-			sliceKeys := s
+			sliceKeys := append([]string{}, s...)
 			sort.Strings(sliceKeys)
 
 			for _, sliceKey := range sliceKeys {
 				io.WriteString(hash, fmt.Sprintf("%s, ", sliceKey))
 			}
+		case yaml.DependsOn:
+			dependencies := []string{}
+			for _, dependency := range s {
+				if dependency.Restart {
+					dependencies = append(dependencies, dependency.Service+"(restart)")
+				} else {
+					dependencies = append(dependencies, dependency.Service)
+				}
+			}
+			sort.Strings(dependencies)
+
+			for _, dependency := range dependencies {
+				io.WriteString(hash, fmt.Sprintf("%s, ", dependency))
+			}
 		case *yaml.Networks:
 			io.WriteString(hash, fmt.Sprintf("%s, ", s.HashString()))
 		case *yaml.Volumes:
 			io.WriteString(hash, fmt.Sprintf("%s, ", s.HashString()))
+		case *bool:
+			if s != nil {
+				io.WriteString(hash, fmt.Sprintf("%v, ", *s))
+			}
+		case *yaml.StringorInt:
+			if s != nil {
+				io.WriteString(hash, fmt.Sprintf("%v, ", *s))
+			}
+		case yaml.Build:
+			io.WriteString(hash, fmt.Sprintf("%v, ", buildHashValue(s)))
 		default:
 			io.WriteString(hash, fmt.Sprintf("%v, ", serviceValue))
 		}
 	}
+}
 
-	return hex.EncodeToString(hash.Sum(nil))
+// buildHashValue returns the build section with its values dereferenced, so
+// that it is printed the same way whatever their addresses. The no_cache and
+// pull flags only affect how the image is built, not the container, they are
+// left out.
+func buildHashValue(b yaml.Build) interface{} {
+	return struct {
+		Context    string
+		Dockerfile string
+		Args       map[string]string
+		CacheFrom  []string
+		Labels     map[string]string
+		ShmSize    yaml.MemStringorInt
+		Target     string
+		Network    string
+		Platform   string
+	}{
+		Context:    b.Context,
+		Dockerfile: b.Dockerfile,
+		Args:       dereferenceValues(b.Args),
+		CacheFrom:  dereferenceSlice(b.CacheFrom),
+		Labels:     dereferenceValues(b.Labels),
+		ShmSize:    b.ShmSize,
+		Target:     b.Target,
+		Network:    b.Network,
+		Platform:   b.Platform,
+	}
+}
+
+func dereferenceValues(values map[string]*string) map[string]string {
+	result := map[string]string{}
+	for key, value := range values {
+		if value == nil {
+			result[key] = "<nil>"
+		} else {
+			result[key] = *value
+		}
+	}
+	return result
+}
+
+func dereferenceSlice(values []*string) []string {
+	result := []string{}
+	for _, value := range values {
+		if value == nil {
+			result = append(result, "<nil>")
+		} else {
+			result = append(result, *value)
+		}
+	}
+	return result
 }
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/zengchen221/libcompose/yaml"
+)
+
+func TestServiceConfigHash(t *testing.T) {
+	value := func(s string) *string { return &s }
+	newConfig := func() *ServiceConfig {
+		return &ServiceConfig{
+			Image:  "busybox",
+			DNS:    yaml.Stringorslice{"8.8.8.8", "8.8.4.4"},
+			Ports:  []string{"80", "443"},
+			Labels: yaml.SliceorMap{"a": "1", "b": "2", "c": "3"},
+			Build: yaml.Build{
+				Context:   ".",
+				Args:      map[string]*string{"VERSION": value("1.0"), "EMPTY": nil},
+				CacheFrom: []*string{value("busybox")},
+			},
+		}
+	}
+
+	config := newConfig()
+	hash := config.Hash()
+	for i := 0; i < 10; i++ {
+		if other := newConfig().Hash(); other != hash {
+			t.Fatalf("Expected the same hash for the same configuration, got %s and %s", hash, other)
+		}
+	}
+	if !reflect.DeepEqual([]string(config.DNS), []string{"8.8.8.8", "8.8.4.4"}) || !reflect.DeepEqual(config.Ports, []string{"80", "443"}) {
+		t.Fatalf("Hashing changed the configuration: %v, %v", config.DNS, config.Ports)
+	}
+
+	reordered := newConfig()
+	reordered.Ports = []string{"443", "80"}
+	if reordered.Hash() != hash {
+		t.Fatal("Expected the order of the ports not to change the hash")
+	}
+
+	changed := newConfig()
+	*changed.Build.Args["VERSION"] = "2.0"
+	if changed.Hash() == hash {
+		t.Fatal("Expected a build argument change to change the hash")
+	}
+
+	if GetServiceHash("web", config) == GetServiceHash("db", config) {
+		t.Fatal("Expected the service name to be part of the service hash")
+	}
+}
+
+func TestBuildHashValue(t *testing.T) {
+	build := yaml.Build{Context: ".", Dockerfile: "Dockerfile", Target: "prod"}
+	expected := "{. Dockerfile map[] [] map[] 0 prod  }"
+	if value := fmt.Sprintf("%v", buildHashValue(build)); value != expected {
+		t.Fatalf("Expected %v, got %v", expected, value)
+	}
+	// The build-time flags don't recreate the containers
+	enabled := true
+	flagged := build
+	flagged.NoCache, flagged.Pull = &enabled, &enabled
+	if value := fmt.Sprintf("%v", buildHashValue(flagged)); value != expected {
+		t.Fatalf("Expected %v, got %v", expected, value)
+	}
+	// Every other field of the build section is hashed
+	if reflect.TypeOf(buildHashValue(build)).NumField() != reflect.TypeOf(build).NumField()-2 {
+		t.Fatal("Expected every field of the build section but no_cache and pull to be hashed")
+	}
+}
@@ -2,22 +2,40 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 )
 
-var defaultValues = make(map[string]string)
+// NoInterpolateKey is the service key listing the keys of the service that
+// interpolation must leave untouched, so that their values can hold `${}`
+// templates meant for another tool. The values of these keys are kept
+// verbatim: neither variables nor `$$` escapes are replaced.
+//
+//	services:
+//	  web:
+//	    x-no-interpolate: [command, labels]
+//	    image: ${IMAGE}
+//	    command: echo ${RENDERED_LATER}
+const NoInterpolateKey = "x-no-interpolate"
+
+// errInvalidInterpolation is returned by the parser for malformed
+// references.
+var errInvalidInterpolation = errors.New("invalid interpolation format")
+
+// variableMapping returns the value of a variable and whether it is set
+// (possibly to an empty value).
+type variableMapping func(name string) (string, bool)
 
 func isNum(c uint8) bool {
 	return c >= '0' && c <= '9'
 }
 
-func validVariableDefault(c uint8, line string, pos int) bool {
-	return (c == ':' && line[pos+1] == '-') || (c == '-')
-}
-
 func validVariableNameChar(c uint8) bool {
 	return c == '_' ||
 		c >= 'A' && c <= 'Z' ||
@@ -25,7 +43,17 @@ func validVariableNameChar(c uint8) bool {
 		isNum(c)
 }
 
-func parseVariable(line string, pos int, mapping func(string) string) (string, int, bool) {
+// lookupVariable returns the value of a plain reference to a variable,
+// warning if it is not set.
+func lookupVariable(name string, mapping variableMapping) string {
+	value, ok := mapping(name)
+	if !ok {
+		logrus.Warnf("The %s variable is not set. Substituting a blank string.", name)
+	}
+	return value
+}
+
+func parseVariable(line string, pos int, mapping variableMapping) (string, int, error) {
 	var buffer bytes.Buffer
 
 	for ; pos < len(line); pos++ {
@@ -35,38 +63,37 @@ func parseVariable(line string, pos int, mapping func(string) string) (string, i
 		case validVariableNameChar(c):
 			buffer.WriteByte(c)
 		default:
-			return mapping(buffer.String()), pos - 1, true
+			return lookupVariable(buffer.String(), mapping), pos - 1, nil
 		}
 	}
 
-	return mapping(buffer.String()), pos, true
+	return lookupVariable(buffer.String(), mapping), pos, nil
 }
 
-func parseDefaultValue(line string, pos int) (string, int, bool) {
-	var buffer bytes.Buffer
-
-	// only skip :, :- and - at the beginning
-	for ; pos < len(line); pos++ {
-		c := line[pos]
-		if c == ':' || c == '-' {
-			continue
-		}
-		break
-	}
-	for ; pos < len(line); pos++ {
-		c := line[pos]
-		if c == '}' {
-			return buffer.String(), pos - 1, true
-		}
-		err := buffer.WriteByte(c)
-		if err != nil {
-			return "", pos, false
+// parseOperand returns the operand of a ${VAR:-default} or ${VAR:?message}
+// expression starting at the specified position, along with the position of
+// the closing brace. The operand may contain any character, including nested
+// ${} expressions.
+func parseOperand(line string, pos int) (string, int, error) {
+	depth := 0
+	for i := pos; i < len(line); i++ {
+		switch {
+		case line[i] == '$' && i+1 < len(line) && line[i+1] == '$':
+			i++
+		case line[i] == '$' && i+1 < len(line) && line[i+1] == '{':
+			depth++
+			i++
+		case line[i] == '}':
+			if depth == 0 {
+				return line[pos:i], i, nil
+			}
+			depth--
 		}
 	}
-	return "", 0, false
+	return "", 0, errInvalidInterpolation
 }
 
-func parseVariableWithBraces(line string, pos int, mapping func(string) string) (string, int, bool) {
+func parseVariableWithBraces(line string, pos int, mapping variableMapping) (string, int, error) {
 	var buffer bytes.Buffer
 
 	for ; pos < len(line); pos++ {
@@ -74,43 +101,72 @@ func parseVariableWithBraces(line string, pos int, mapping func(string) string)
 
 		switch {
 		case c == '}':
-			bufferString := buffer.String()
-
-			if bufferString == "" {
-				return "", 0, false
+			if buffer.Len() == 0 {
+				return "", 0, errInvalidInterpolation
 			}
-			return mapping(buffer.String()), pos, true
+			return lookupVariable(buffer.String(), mapping), pos, nil
 		case validVariableNameChar(c):
 			buffer.WriteByte(c)
-		case validVariableDefault(c, line, pos):
-			defaultValue := ""
-			defaultValue, pos, _ = parseDefaultValue(line, pos)
-			defaultValues[buffer.String()] = defaultValue
+		case c == ':' || c == '-' || c == '?':
+			name := buffer.String()
+			if name == "" {
+				return "", 0, errInvalidInterpolation
+			}
+			// The colon is optional, unset and empty variables being
+			// handled the same way.
+			if c == ':' {
+				pos++
+				if pos >= len(line) || (line[pos] != '-' && line[pos] != '?') {
+					return "", 0, errInvalidInterpolation
+				}
+			}
+			operator := line[pos]
+			operand, end, err := parseOperand(line, pos+1)
+			if err != nil {
+				return "", 0, err
+			}
+			if value, _ := mapping(name); value != "" {
+				return value, end, nil
+			}
+			operand, err = parseLine(operand, mapping)
+			if err != nil {
+				return "", 0, err
+			}
+			if operator == '?' {
+				if operand == "" {
+					return "", 0, fmt.Errorf("Required variable %s is missing a value", name)
+				}
+				return "", 0, fmt.Errorf("Required variable %s is missing a value: %s", name, operand)
+			}
+			return operand, end, nil
 		default:
-			return "", 0, false
+			return "", 0, errInvalidInterpolation
 		}
 	}
 
-	return "", 0, false
+	return "", 0, errInvalidInterpolation
 }
 
-func parseInterpolationExpression(line string, pos int, mapping func(string) string) (string, int, bool) {
+func parseInterpolationExpression(line string, pos int, mapping variableMapping) (string, int, error) {
+	if pos >= len(line) {
+		return "", 0, errInvalidInterpolation
+	}
 	c := line[pos]
 
 	switch {
 	case c == '$':
-		return "$", pos, true
+		return "$", pos, nil
 	case c == '{':
 		return parseVariableWithBraces(line, pos+1, mapping)
 	case !isNum(c) && validVariableNameChar(c):
 		// Variables can't start with a number
 		return parseVariable(line, pos, mapping)
 	default:
-		return "", 0, false
+		return "", 0, errInvalidInterpolation
 	}
 }
 
-func parseLine(line string, mapping func(string) string) (string, bool) {
+func parseLine(line string, mapping variableMapping) (string, error) {
 	var buffer bytes.Buffer
 
 	for pos := 0; pos < len(line); pos++ {
@@ -118,12 +174,12 @@ func parseLine(line string, mapping func(string) string) (string, bool) {
 		switch {
 		case c == '$':
 			var replaced string
-			var success bool
+			var err error
 
-			replaced, pos, success = parseInterpolationExpression(line, pos+1, mapping)
+			replaced, pos, err = parseInterpolationExpression(line, pos+1, mapping)
 
-			if !success {
-				return "", false
+			if err != nil {
+				return "", err
 			}
 
 			buffer.WriteString(replaced)
@@ -132,18 +188,31 @@ func parseLine(line string, mapping func(string) string) (string, bool) {
 		}
 	}
 
-	return buffer.String(), true
+	return buffer.String(), nil
+}
+
+// containsVariable returns whether the specified value references at least
+// one variable, i.e. would be changed by interpolation.
+func containsVariable(value string) bool {
+	found := false
+	parseLine(value, func(string) (string, bool) {
+		found = true
+		return "", true
+	})
+	return found
 }
 
-func parseConfig(key string, data *interface{}, mapping func(string) string) error {
+func parseConfig(key string, data *interface{}, mapping variableMapping) error {
 	switch typedData := (*data).(type) {
 	case string:
-		var success bool
+		var err error
 
-		*data, success = parseLine(typedData, mapping)
+		*data, err = parseLine(typedData, mapping)
 
-		if !success {
+		if err == errInvalidInterpolation {
 			return fmt.Errorf("Invalid interpolation format for key \"%s\": \"%s\"", key, typedData)
+		} else if err != nil {
+			return fmt.Errorf("Failed to interpolate key \"%s\": %v", key, err)
 		}
 	case []interface{}:
 		for k, v := range typedData {
@@ -170,23 +239,42 @@ func parseConfig(key string, data *interface{}, mapping func(string) string) err
 	return nil
 }
 
-// Interpolate replaces variables in a map entry
+// Interpolate replaces variables in a map entry. Besides plain ${VAR} and
+// $VAR references, ${VAR:-default} substitutes default when the variable is
+// unset or empty, and ${VAR:?message} fails with message in that case. $$ is
+// an escaped $.
 func Interpolate(key string, data *interface{}, environmentLookup EnvironmentLookup) error {
-	return parseConfig(key, data, func(s string) string {
+	return parseConfig(key, data, lookupMapping(environmentLookup))
+}
+
+// InterpolateServiceValue interpolates the value of the specified key of a
+// service, like Interpolate. Substituted strings are then converted back to
+// a number or a boolean where the service configuration expects one, so that
+// `replicas: ${N}` is an integer as if the value had been written literally.
+// Strings that don't hold a valid number or boolean are left as is, for the
+// validation to report them.
+func InterpolateServiceValue(key string, data *interface{}, environmentLookup EnvironmentLookup) error {
+	root, path := reflect.TypeOf(ServiceConfig{}), []string{key}
+	if key == "deploy" {
+		root, path = reflect.TypeOf(deployConfig{}), nil
+	}
+	return interpolateTyped(key, root, path, data, lookupMapping(environmentLookup))
+}
+
+// interpolateResource interpolates the specified top-level volume or network
+// (or any other resource) like InterpolateServiceValue, root being the type
+// of its configuration, e.g. so that `internal: ${INTERNAL}` is a boolean.
+// Nested values (driver_opts, ipam…) are interpolated too.
+func interpolateResource(name string, root reflect.Type, data *interface{}, environmentLookup EnvironmentLookup) error {
+	return interpolateTyped(name, root, nil, data, lookupMapping(environmentLookup))
+}
+
+func lookupMapping(environmentLookup EnvironmentLookup) variableMapping {
+	return func(s string) (string, bool) {
 		values := environmentLookup.Lookup(s, nil)
 
 		if len(values) == 0 {
-			if val, ok := defaultValues[s]; ok {
-				return val
-			}
-			logrus.Warnf("The %s variable is not set. Substituting a blank string.", s)
-			return ""
-		}
-
-		if strings.SplitN(values[0], "=", 2)[1] == "" {
-			if val, ok := defaultValues[s]; ok {
-				return val
-			}
+			return "", false
 		}
 
 		// Use first result if many are given
@@ -194,6 +282,141 @@ func Interpolate(key string, data *interface{}, environmentLookup EnvironmentLoo
 
 		// Environment variables come in key=value format
 		// Return everything past first '='
-		return strings.SplitN(value, "=", 2)[1]
-	})
+		return strings.SplitN(value, "=", 2)[1], true
+	}
+}
+
+// interpolateTyped interpolates the specified data, found at the specified
+// path of the root type, substituted strings being converted to the scalar
+// type expected there. Errors are reported for the specified key.
+func interpolateTyped(key string, root reflect.Type, path []string, data *interface{}, mapping variableMapping) error {
+	switch typedData := (*data).(type) {
+	case string:
+		if err := parseConfig(key, data, mapping); err != nil {
+			return err
+		}
+		if containsVariable(typedData) {
+			*data = coerceScalar(root, path, (*data).(string))
+		}
+	case []interface{}:
+		for k, v := range typedData {
+			if err := interpolateTyped(key, root, appendPath(path, strconv.Itoa(k)), &v, mapping); err != nil {
+				return err
+			}
+			typedData[k] = v
+		}
+	case map[interface{}]interface{}:
+		for k, v := range typedData {
+			if err := interpolateTyped(key, root, appendPath(path, fmt.Sprint(k)), &v, mapping); err != nil {
+				return err
+			}
+			typedData[k] = v
+		}
+	}
+	return nil
+}
+
+func appendPath(path []string, key string) []string {
+	return append(path[:len(path):len(path)], key)
+}
+
+var yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// coerceScalar converts the specified value to the number or boolean
+// expected at the specified path of the root type, if any.
+func coerceScalar(root reflect.Type, path []string, value string) interface{} {
+	switch scalarKind(root, path) {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// scalarKind returns the kind of the plain scalar expected at the specified
+// path (of yaml keys and sequence indexes) of the specified type, or
+// reflect.Invalid if there is none. Types with their own yaml unmarshaling
+// handle strings themselves and are thus not considered scalars.
+func scalarKind(t reflect.Type, path []string) reflect.Kind {
+	for _, key := range path {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if unmarshalsYAML(t) {
+			return reflect.Invalid
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByYAMLKey(t, key)
+			if !ok {
+				return reflect.Invalid
+			}
+			t = field.Type
+		case reflect.Slice, reflect.Map:
+			t = t.Elem()
+		default:
+			return reflect.Invalid
+		}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if unmarshalsYAML(t) {
+		return reflect.Invalid
+	}
+	return t.Kind()
+}
+
+func unmarshalsYAML(t reflect.Type) bool {
+	return t.Implements(yamlUnmarshalerType) || reflect.PtrTo(t).Implements(yamlUnmarshalerType)
+}
+
+func fieldByYAMLKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0] == key {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// noInterpolateKeys returns the keys of the specified raw service listed in
+// its NoInterpolateKey key.
+func noInterpolateKeys(service RawService) (map[string]bool, error) {
+	keys := map[string]bool{}
+	value, ok := service[NoInterpolateKey]
+	if !ok {
+		return keys, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Invalid %s value %v: must be a list of keys", NoInterpolateKey, value)
+	}
+	for _, key := range list {
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("Invalid %s value %v: must be a list of keys", NoInterpolateKey, value)
+		}
+		keys[name] = true
+	}
+	return keys, nil
+}
+
+// interpolateString interpolates a single string value.
+func interpolateString(key, value string, environmentLookup EnvironmentLookup) (string, error) {
+	var data interface{} = value
+	if err := Interpolate(key, &data, environmentLookup); err != nil {
+		return "", err
+	}
+	return data.(string), nil
 }
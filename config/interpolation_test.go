@@ -11,25 +11,26 @@ import (
 )
 
 func testInterpolatedLine(t *testing.T, expectedLine, interpolatedLine string, envVariables map[string]string) {
-	interpolatedLine, _ = parseLine(interpolatedLine, func(s string) string {
-		return envVariables[s]
+	interpolatedLine, _ = parseLine(interpolatedLine, func(s string) (string, bool) {
+		value, ok := envVariables[s]
+		return value, ok
 	})
 
 	assert.Equal(t, expectedLine, interpolatedLine)
 }
 
 func testInvalidInterpolatedLine(t *testing.T, line string) {
-	_, success := parseLine(line, func(string) string {
-		return ""
+	_, err := parseLine(line, func(string) (string, bool) {
+		return "", false
 	})
 
-	assert.Equal(t, false, success)
+	assert.Equal(t, errInvalidInterpolation, err)
 }
 
 func testInterpolatedDefault(t *testing.T, line string, delim string, expectedVar string, expectedVal string) {
-	envVar, _ := parseLine(line, func(env string) string { return env })
+	envVar, _ := parseLine(line, func(env string) (string, bool) { return env, true })
 	pos := strings.Index(line, delim)
-	envDefault, _, _ := parseDefaultValue(line, pos)
+	envDefault, _, _ := parseOperand(line, pos+len(delim))
 	assert.Equal(t, expectedVal, envDefault)
 	assert.Equal(t, expectedVar, envVar)
 }
@@ -94,6 +95,33 @@ func TestParseLine(t *testing.T) {
 	testInvalidInterpolatedLine(t, "${ A}")
 	testInvalidInterpolatedLine(t, "${A!}")
 	testInvalidInterpolatedLine(t, "$!")
+	testInvalidInterpolatedLine(t, "${A:-unterminated")
+	testInvalidInterpolatedLine(t, "${:-nameless}")
+}
+
+func TestParseLineOperators(t *testing.T) {
+	variables := map[string]string{
+		"A":     "ABC",
+		"EMPTY": "",
+		"PATH":  "/usr/bin",
+	}
+
+	testInterpolatedLine(t, "ABC", "${A:-default}", variables)
+	testInterpolatedLine(t, "8080", "${PORT:-8080}", variables)
+	testInterpolatedLine(t, "fallback", "${EMPTY:-fallback}", variables)
+	testInterpolatedLine(t, "http://host:80/path", "${URL:-http://host:80/path}", variables)
+	testInterpolatedLine(t, "/usr/bin:/opt", "${UNSET:-${PATH}:/opt}", variables)
+	testInterpolatedLine(t, "$literal", "${UNSET:-$$literal}", variables)
+	testInterpolatedLine(t, "ABC-suffix", "${A:?must be set}-suffix", variables)
+
+	mapping := func(s string) (string, bool) {
+		value, ok := variables[s]
+		return value, ok
+	}
+	_, err := parseLine("${DB:?must be set}", mapping)
+	assert.EqualError(t, err, "Required variable DB is missing a value: must be set")
+	_, err = parseLine("${EMPTY:?}", mapping)
+	assert.EqualError(t, err, "Required variable EMPTY is missing a value")
 }
 
 type MockEnvironmentLookup struct {
@@ -279,3 +307,201 @@ func TestInterpolate(t *testing.T) {
   labels:
     mylabel: "${ LABEL_VALUE}"`)
 }
+
+func TestNoInterpolateKeys(t *testing.T) {
+	services := RawServiceMap{
+		"web": RawService{
+			NoInterpolateKey: []interface{}{"command"},
+			"image":          "${IMAGE}",
+			"command":        "echo ${LATER} $$HOME",
+		},
+	}
+	err := InterpolateRawServiceMap(&services, MockEnvironmentLookup{map[string]string{"IMAGE": "busybox", "LATER": "now"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "busybox", services["web"]["image"])
+	assert.Equal(t, "echo ${LATER} $$HOME", services["web"]["command"])
+
+	services = RawServiceMap{
+		"web": RawService{
+			NoInterpolateKey: "command",
+		},
+	}
+	err = InterpolateRawServiceMap(&services, MockEnvironmentLookup{})
+	assert.NotNil(t, err)
+}
+
+func TestSkipInterpolationKeys(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), hostEnvironmentLookup{"IMAGE": "busybox"}, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: ${IMAGE}
+    command: sh -c 'echo $HOME ${USER:-nobody}'
+    entrypoint: ["sh", "-c", "$$0"]
+`), &ParseOptions{
+		Interpolate:           true,
+		SkipInterpolationKeys: []string{"command", "entrypoint"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := configs["web"]
+	assert.Equal(t, "busybox", web.Image)
+	assert.Equal(t, []string{"sh", "-c", "echo $HOME ${USER:-nobody}"}, []string(web.Command))
+	assert.Equal(t, []string{"sh", "-c", "$$0"}, []string(web.Entrypoint))
+}
+
+func TestInterpolateVolumesAndNetworks(t *testing.T) {
+	_, _, volumes, networks, err := Merge(NewServiceConfigs(), hostEnvironmentLookup{
+		"ENV":      "staging",
+		"NFS":      "10.0.0.5",
+		"SUBNET":   "172.28.0.0/16",
+		"INTERNAL": "true",
+	}, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: nginx
+volumes:
+  data:
+    name: ${ENV}-data
+    driver: local
+    driver_opts:
+      type: nfs
+      o: addr=${NFS},rw
+      device: ":/exports/${ENV}"
+networks:
+  back:
+    name: ${ENV}-back
+    internal: ${INTERNAL}
+    driver_opts:
+      com.docker.network.bridge.name: br-${ENV}
+    ipam:
+      config:
+        - subnet: ${SUBNET}
+`), &ParseOptions{Interpolate: true, Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := volumes["data"]
+	assert.Equal(t, "staging-data", data.Name)
+	assert.Equal(t, map[string]string{"type": "nfs", "o": "addr=10.0.0.5,rw", "device": ":/exports/staging"}, data.DriverOpts)
+
+	back := networks["back"]
+	assert.Equal(t, "staging-back", back.Name)
+	assert.True(t, back.Internal)
+	assert.Equal(t, map[string]string{"com.docker.network.bridge.name": "br-staging"}, back.DriverOpts)
+	assert.Equal(t, "172.28.0.0/16", back.Ipam.Config[0].Subnet)
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), hostEnvironmentLookup{}, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: nginx
+volumes:
+  data:
+    driver_opts:
+      device: ":/exports/${ENV:?ENV must be set}"
+`), &ParseOptions{Interpolate: true})
+	assert.EqualError(t, err, `Failed to interpolate key "data": Required variable ENV is missing a value: ENV must be set`)
+}
+
+func TestInterpolateRequiredVariable(t *testing.T) {
+	services := RawServiceMap{}
+	if err := yaml.Unmarshal([]byte(`
+web:
+  image: ${IMAGE:?an image is required}
+`), &services); err != nil {
+		t.Fatal(err)
+	}
+
+	err := InterpolateRawServiceMap(&services, hostEnvironmentLookup{})
+	assert.EqualError(t, err, `Failed to interpolate key "image": Required variable IMAGE is missing a value: an image is required`)
+
+	err = InterpolateRawServiceMap(&services, hostEnvironmentLookup{"IMAGE": "busybox"})
+	assert.Nil(t, err)
+	assert.Equal(t, "busybox", services["web"]["image"])
+}
+
+func TestInterpolatedRaw(t *testing.T) {
+	services, err := InterpolatedRaw([]byte(`
+version: '2'
+services:
+  web:
+    image: ${IMAGE}:${TAG:-latest}
+    privileged: ${PRIVILEGED}
+    x-no-interpolate: [command]
+    command: echo ${HOME}
+`), hostEnvironmentLookup{"IMAGE": "nginx", "PRIVILEGED": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := services["web"]
+	assert.Equal(t, "nginx:latest", web["image"])
+	assert.Equal(t, true, web["privileged"])
+	assert.Equal(t, "echo ${HOME}", web["command"])
+
+	services, err = InterpolatedRaw([]byte(`
+web:
+  image: ${IMAGE}
+`), hostEnvironmentLookup{"IMAGE": "busybox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "busybox", services["web"]["image"])
+
+	_, err = InterpolatedRaw([]byte(`
+version: '2'
+services:
+  web:
+    image: ${IMAGE:?an image is required}
+`), hostEnvironmentLookup{})
+	assert.EqualError(t, err, `Failed to interpolate key "image": Required variable IMAGE is missing a value: an image is required`)
+}
+
+func TestInterpolateTypedValues(t *testing.T) {
+	services := RawServiceMap{}
+	if err := yaml.Unmarshal([]byte(`
+web:
+  image: "${NUMBER}"
+  privileged: ${BOOL}
+  read_only: "${BOOL}"
+  cpus: ${FLOAT}
+  healthcheck:
+    retries: "${NUMBER}"
+  deploy:
+    replicas: ${NUMBER}
+  tty: ${NOT_A_BOOL}
+  environment:
+    COUNT: ${NUMBER}
+  labels:
+    - "count=${NUMBER}"
+`), &services); err != nil {
+		t.Fatal(err)
+	}
+
+	err := InterpolateRawServiceMap(&services, MockEnvironmentLookup{map[string]string{
+		"NUMBER":     "3",
+		"BOOL":       "true",
+		"FLOAT":      "0.5",
+		"NOT_A_BOOL": "maybe",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := services["web"]
+	// Quoted or not, substituted values follow the type of their key
+	assert.Equal(t, "3", web["image"])
+	assert.Equal(t, true, web["privileged"])
+	assert.Equal(t, true, web["read_only"])
+	assert.Equal(t, 0.5, web["cpus"])
+	assert.Equal(t, 3, web["healthcheck"].(map[interface{}]interface{})["retries"])
+	assert.Equal(t, 3, web["deploy"].(map[interface{}]interface{})["replicas"])
+	assert.Equal(t, "maybe", web["tty"])
+	assert.Equal(t, "3", web["environment"].(map[interface{}]interface{})["COUNT"])
+	assert.Equal(t, []interface{}{"count=3"}, web["labels"])
+}
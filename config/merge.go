@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -27,6 +29,22 @@ var (
 	}
 )
 
+// DefaultParseOptions returns the options Merge uses when none are given:
+// interpolation and validation are enabled.
+func DefaultParseOptions() ParseOptions {
+	return defaultParseOptions
+}
+
+// warn reports the specified warning to the Warn function of the options, or
+// logs it if there is none.
+func (o *ParseOptions) warn(warning ConfigWarning) {
+	if o != nil && o.Warn != nil {
+		o.Warn(warning)
+		return
+	}
+	logrus.Warn(warning.Message)
+}
+
 func getComposeMajorVersion(version string) (int, error) {
 	if version == "" {
 		return 1, nil
@@ -48,15 +66,43 @@ func getComposeMajorVersion(version string) (int, error) {
 // This function only handles parsing YAML in the general case. Any other file
 // format validation should be handled by the caller.
 func CreateConfig(bytes []byte) (*Config, error) {
+	return createConfig(bytes, nil)
+}
+
+// createConfig is like CreateConfig, but interpolates the version and the
+// name of the file with the specified lookup (if any) before using them.
+func createConfig(bytes []byte, environmentLookup EnvironmentLookup) (*Config, error) {
 	var config Config
 	if err := yaml.Unmarshal(bytes, &config); err != nil {
 		return nil, err
 	}
 
+	if environmentLookup != nil {
+		version, err := interpolateString("version", config.Version, environmentLookup)
+		if err != nil {
+			return nil, err
+		}
+		config.Version = version
+	}
+
 	major, err := getComposeMajorVersion(config.Version)
 	if err != nil {
 		return nil, err
 	}
+	if major >= 2 {
+		var named struct {
+			Name string `yaml:"name,omitempty"`
+		}
+		if err := yaml.Unmarshal(bytes, &named); err != nil {
+			return nil, err
+		}
+		config.Name = named.Name
+		if environmentLookup != nil {
+			if config.Name, err = interpolateString("name", config.Name, environmentLookup); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if major < 2 {
 		var baseRawServices RawServiceMap
 		if err := yaml.Unmarshal(bytes, &baseRawServices); err != nil {
@@ -75,13 +121,20 @@ func CreateConfig(bytes []byte) (*Config, error) {
 	return &config, nil
 }
 
-// Merge merges a compose file into an existing set of service configs
+// Merge merges a compose file into an existing set of service configs.
+// YAML anchors, aliases and merge keys (<<) are resolved when the file is
+// decoded, so services get their own copy of the shared values before the
+// interpolation.
 func Merge(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup, resourceLookup ResourceLookup, file string, bytes []byte, options *ParseOptions) (string, map[string]*ServiceConfig, map[string]*VolumeConfig, map[string]*NetworkConfig, error) {
 	if options == nil {
 		options = &defaultParseOptions
 	}
 
-	config, err := CreateConfig(bytes)
+	var lookup EnvironmentLookup
+	if options.Interpolate {
+		lookup = environmentLookup
+	}
+	config, err := createConfig(bytes, lookup)
 	if err != nil {
 		return "", nil, nil, nil, err
 	}
@@ -100,23 +153,32 @@ func Merge(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup
 	}
 
 	if options.Interpolate {
-		if err := InterpolateRawServiceMap(&baseRawServices, environmentLookup); err != nil {
+		if err := interpolateRawServiceMap(&baseRawServices, environmentLookup, options.SkipInterpolationKeys); err != nil {
 			return "", nil, nil, nil, err
 		}
 
 		for k, v := range config.Volumes {
-			if err := Interpolate(k, &v, environmentLookup); err != nil {
+			if err := interpolateResource(k, reflect.TypeOf(VolumeConfig{}), &v, environmentLookup); err != nil {
 				return "", nil, nil, nil, err
 			}
 			config.Volumes[k] = v
 		}
 
 		for k, v := range config.Networks {
-			if err := Interpolate(k, &v, environmentLookup); err != nil {
+			if err := interpolateResource(k, reflect.TypeOf(NetworkConfig{}), &v, environmentLookup); err != nil {
 				return "", nil, nil, nil, err
 			}
 			config.Networks[k] = v
 		}
+
+		for _, objects := range []map[string]interface{}{config.Configs, config.Secrets} {
+			for k, v := range objects {
+				if err := Interpolate(k, &v, environmentLookup); err != nil {
+					return "", nil, nil, nil, err
+				}
+				objects[k] = v
+			}
+		}
 	}
 
 	if options.Preprocess != nil {
@@ -132,20 +194,31 @@ func Merge(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup
 		return "", nil, nil, nil, err
 	}
 
+	if !options.Validate {
+		// Unsupported keys are reported as errors when validating
+		for _, warning := range unsupportedKeysWarnings(baseRawServices, major) {
+			options.warn(warning)
+		}
+	}
+
 	var serviceConfigs map[string]*ServiceConfig
 	switch major {
 	case 3:
-		logrus.Fatal("Note: Compose file version 3 is not yet implemented")
+		var err error
+		serviceConfigs, err = MergeServicesV3(existingServices, environmentLookup, resourceLookup, file, baseRawServices, config.Configs, config.Secrets, options)
+		if err != nil {
+			return "", nil, nil, nil, locateValidationErrors(err, bytes, major)
+		}
 	case 2:
 		var err error
 		serviceConfigs, err = MergeServicesV2(existingServices, environmentLookup, resourceLookup, file, baseRawServices, options)
 		if err != nil {
-			return "", nil, nil, nil, err
+			return "", nil, nil, nil, locateValidationErrors(err, bytes, major)
 		}
 	default:
 		serviceConfigsV1, err := MergeServicesV1(existingServices, environmentLookup, resourceLookup, file, baseRawServices, options)
 		if err != nil {
-			return "", nil, nil, nil, err
+			return "", nil, nil, nil, locateValidationErrors(err, bytes, major)
 		}
 		serviceConfigs, err = ConvertServices(serviceConfigsV1)
 		if err != nil {
@@ -155,6 +228,63 @@ func Merge(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup
 
 	adjustValues(serviceConfigs)
 
+	if lookup != nil {
+		for _, serviceConfig := range serviceConfigs {
+			resolveEnvironment(serviceConfig, lookup)
+		}
+	}
+
+	for _, warning := range serviceWarnings(serviceConfigs) {
+		options.warn(warning)
+	}
+
+	if options.Validate {
+		// Values still holding variables can't be checked until interpolated
+		uninterpolated := func(value string) bool {
+			return !options.Interpolate && containsVariable(value)
+		}
+		invalid := func(name, key string, err error) error {
+			return locateValidationErrors(&ValidationError{
+				Service: name,
+				Field:   key,
+				Message: fmt.Sprintf("Service '%s' configuration key '%s' is invalid: %v", name, key, err),
+			}, bytes, major)
+		}
+		for name, serviceConfig := range serviceConfigs {
+			if err := ValidateRestartPolicy(serviceConfig.Restart); err != nil && !uninterpolated(serviceConfig.Restart) {
+				return "", nil, nil, nil, invalid(name, "restart", err)
+			}
+			if err := ValidateIpcMode(serviceConfig.Ipc); err != nil && !uninterpolated(serviceConfig.Ipc) {
+				return "", nil, nil, nil, invalid(name, "ipc", err)
+			}
+			if err := ValidatePullPolicy(serviceConfig.PullPolicy); err != nil && !uninterpolated(serviceConfig.PullPolicy) {
+				return "", nil, nil, nil, invalid(name, "pull_policy", err)
+			}
+			if err := ValidateIsolation(serviceConfig.Isolation); err != nil && !uninterpolated(serviceConfig.Isolation) {
+				return "", nil, nil, nil, invalid(name, "isolation", err)
+			}
+			if err := ValidateUser(serviceConfig.User); err != nil && !uninterpolated(serviceConfig.User) {
+				return "", nil, nil, nil, invalid(name, "user", err)
+			}
+			if err := ValidateHealthCheck(serviceConfig.HealthCheck); err != nil {
+				return "", nil, nil, nil, invalid(name, "healthcheck", err)
+			}
+			if err := ValidateMemoryLimits(serviceConfig); err != nil {
+				return "", nil, nil, nil, locateValidationErrors(&ValidationError{
+					Service: name,
+					Message: fmt.Sprintf("Service '%s' memory configuration is invalid: %v", name, err),
+				}, bytes, major)
+			}
+			if err := ValidateBlkioConfig(serviceConfig.BlkioConfig); err != nil && !uninterpolated(blkioPaths(serviceConfig.BlkioConfig)) {
+				return "", nil, nil, nil, invalid(name, "blkio_config", err)
+			}
+		}
+	}
+
+	if err := validateContainerNames(existingServices, serviceConfigs); err != nil {
+		return "", nil, nil, nil, locateValidationErrors(err, bytes, major)
+	}
+
 	if options.Postprocess != nil {
 		var err error
 		serviceConfigs, err = options.Postprocess(serviceConfigs)
@@ -175,11 +305,49 @@ func Merge(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup
 	return config.Version, serviceConfigs, volumes, networks, nil
 }
 
+// InterpolatedRaw returns the services of the specified compose file once
+// interpolated with the specified lookup, as Merge interpolates them before
+// validating and converting them. It is meant to inspect how variables are
+// resolved: extends, env files and the other file references are left as is.
+func InterpolatedRaw(bytes []byte, environmentLookup EnvironmentLookup) (RawServiceMap, error) {
+	if environmentLookup == nil {
+		return nil, fmt.Errorf("An environment lookup is required to interpolate")
+	}
+	config, err := createConfig(bytes, environmentLookup)
+	if err != nil {
+		return nil, err
+	}
+	services := config.Services
+	if services == nil {
+		services = RawServiceMap{}
+	}
+	if err := InterpolateRawServiceMap(&services, environmentLookup); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
 // InterpolateRawServiceMap replaces varialbse in raw service map struct based on environment lookup
 func InterpolateRawServiceMap(baseRawServices *RawServiceMap, environmentLookup EnvironmentLookup) error {
+	return interpolateRawServiceMap(baseRawServices, environmentLookup, nil)
+}
+
+// interpolateRawServiceMap is like InterpolateRawServiceMap, but leaves the
+// specified keys untouched in every service.
+func interpolateRawServiceMap(baseRawServices *RawServiceMap, environmentLookup EnvironmentLookup, skipKeys []string) error {
 	for k, v := range *baseRawServices {
+		skipped, err := noInterpolateKeys(v)
+		if err != nil {
+			return fmt.Errorf("Service '%s': %v", k, err)
+		}
+		for _, key := range skipKeys {
+			skipped[key] = true
+		}
 		for k2, v2 := range v {
-			if err := Interpolate(k2, &v2, environmentLookup); err != nil {
+			if k2 == NoInterpolateKey || skipped[k2] {
+				continue
+			}
+			if err := InterpolateServiceValue(k2, &v2, environmentLookup); err != nil {
 				return err
 			}
 			(*baseRawServices)[k][k2] = v2
@@ -197,12 +365,120 @@ func adjustValues(configs map[string]*ServiceConfig) {
 	}
 }
 
-func readEnvFile(resourceLookup ResourceLookup, inFile string, serviceData RawService) (RawService, error) {
+// resolveEnvironment replaces the environment variables of the specified
+// service that have no value (e.g. `FOO:` or `- FOO`) with their value in the
+// environment lookup. Variables that are not set there are dropped.
+func resolveEnvironment(serviceConfig *ServiceConfig, environmentLookup EnvironmentLookup) {
+	if len(serviceConfig.Environment) == 0 {
+		return
+	}
+	environment := make(composeYaml.MaporEqualSlice, 0, len(serviceConfig.Environment))
+	for _, env := range serviceConfig.Environment {
+		if strings.Contains(env, "=") {
+			environment = append(environment, env)
+			continue
+		}
+		environment = append(environment, environmentLookup.Lookup(env, serviceConfig)...)
+	}
+	serviceConfig.Environment = environment
+}
+
+// serviceWarnings returns the warnings about service configurations that are
+// valid but most likely not what the user intended.
+func serviceWarnings(configs map[string]*ServiceConfig) []ConfigWarning {
+	names := []string{}
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	warnings := []ConfigWarning{}
+	for _, name := range names {
+		serviceConfig := configs[name]
+		if readOnlyWithoutWritableMounts(serviceConfig) {
+			warnings = append(warnings, ConfigWarning{
+				Service: name,
+				Field:   "read_only",
+				Message: fmt.Sprintf("Service '%s' has a read-only root filesystem without any tmpfs mount or volume, consider adding a tmpfs for the directories it writes to (e.g. /tmp)", name),
+			})
+		}
+		if buildPlatform := serviceConfig.Build.Platform; buildPlatform != "" && serviceConfig.Platform != "" && !strings.EqualFold(buildPlatform, serviceConfig.Platform) {
+			warnings = append(warnings, ConfigWarning{
+				Service: name,
+				Field:   "platform",
+				Message: fmt.Sprintf("Service '%s' is built for platform %s but runs on platform %s, the built image may not be able to run", name, buildPlatform, serviceConfig.Platform),
+			})
+		}
+	}
+	return warnings
+}
+
+// blkioPaths returns the device paths of the specified blkio_config, joined
+// so that they can be checked for variables at once.
+func blkioPaths(blkio BlkioConfig) string {
+	paths := []string{}
+	for _, device := range blkio.WeightDevice {
+		paths = append(paths, device.Path)
+	}
+	for _, device := range blkio.DeviceReadBps {
+		paths = append(paths, device.Path)
+	}
+	for _, device := range blkio.DeviceWriteBps {
+		paths = append(paths, device.Path)
+	}
+	for _, device := range blkio.DeviceReadIOps {
+		paths = append(paths, device.Path)
+	}
+	for _, device := range blkio.DeviceWriteIOps {
+		paths = append(paths, device.Path)
+	}
+	return strings.Join(paths, " ")
+}
+
+// readOnlyWithoutWritableMounts returns whether the service has a read-only
+// root filesystem and neither tmpfs mounts nor volumes, i.e. no writable
+// directory at all (not even /tmp). This is allowed, but rarely intended.
+func readOnlyWithoutWritableMounts(serviceConfig *ServiceConfig) bool {
+	if !serviceConfig.ReadOnly || len(serviceConfig.Tmpfs) > 0 {
+		return false
+	}
+	return serviceConfig.Volumes == nil || len(serviceConfig.Volumes.Volumes) == 0
+}
+
+// dropTypedVariables removes from the specified service the values that still
+// hold variables (i.e. that were not interpolated) but can't be converted to
+// the field of the specified config struct they are meant for, e.g. a
+// "${PRIVILEGED}" boolean.
+func dropTypedVariables(service RawService, newConfig func() interface{}) RawService {
+	for key, value := range service {
+		s, ok := value.(string)
+		if !ok || !containsVariable(s) {
+			continue
+		}
+		bytes, err := yaml.Marshal(RawService{key: value})
+		if err == nil {
+			err = yaml.Unmarshal(bytes, newConfig())
+		}
+		if err != nil {
+			logrus.Debugf("Ignoring the uninterpolated value of %s: %s", key, s)
+			delete(service, key)
+		}
+	}
+	return service
+}
+
+// readEnvFile loads the variables of the env_file(s) of the specified service
+// and merges them with its environment, the latter taking precedence. The
+// values read are interpolated like inline ones, variables of the inline
+// environment taking precedence over the environment lookup, unless the
+// environment is excluded from interpolation by skipKeys or the
+// x-no-interpolate key of the service.
+func readEnvFile(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup, inFile string, serviceData RawService, skipKeys []string) (RawService, error) {
 	if _, ok := serviceData["env_file"]; !ok {
 		return serviceData, nil
 	}
 
-	var envFiles composeYaml.Stringorslice
+	var envFiles composeYaml.EnvFiles
 
 	if err := utils.Convert(serviceData["env_file"], &envFiles); err != nil {
 		return nil, err
@@ -224,62 +500,382 @@ func readEnvFile(resourceLookup ResourceLookup, inFile string, serviceData RawSe
 		}
 	}
 
+	mapping, err := envFileMapping(environmentLookup, serviceData, vars, skipKeys)
+	if err != nil {
+		return nil, err
+	}
+
 	for i := len(envFiles) - 1; i >= 0; i-- {
-		envFile := envFiles[i]
+		envFile := envFiles[i].Path
 		content, _, err := resourceLookup.Lookup(envFile, inFile)
 		if err != nil {
+			if !envFiles[i].Required && os.IsNotExist(err) {
+				logrus.Debugf("Skipping missing optional env file %s", envFile)
+				continue
+			}
 			return nil, err
 		}
 
+		lines, err := readKeyValueLines(content)
 		if err != nil {
 			return nil, err
 		}
 
-		scanner := bufio.NewScanner(bytes.NewBuffer(content))
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
+		for _, line := range lines {
+			key, value := parseEnvLine(line)
 
-			if len(line) > 0 && !strings.HasPrefix(line, "#") {
-				key := strings.SplitAfter(line, "=")[0]
-
-				found := false
-				for _, v := range vars {
-					if strings.HasPrefix(v, key) {
-						found = true
-						break
-					}
+			found := false
+			for _, v := range vars {
+				if strings.SplitN(v, "=", 2)[0] == key {
+					found = true
+					break
 				}
+			}
 
-				if !found {
-					vars = append(vars, line)
+			if found {
+				continue
+			}
+			if mapping != nil {
+				if value, err = parseLine(value, mapping); err != nil {
+					return nil, fmt.Errorf("Invalid interpolation format for %s in env file %s: %v", key, envFile, err)
 				}
 			}
+			vars = append(vars, key+"="+value)
+		}
+	}
+
+	serviceData["environment"] = vars
+
+	return serviceData, nil
+}
+
+// envFileMapping returns the mapping used to interpolate the values of the
+// env files of a service: the variables set in its inline environment, then
+// the ones of the environment lookup. It returns nil if the values must not
+// be interpolated.
+func envFileMapping(environmentLookup EnvironmentLookup, serviceData RawService, inline []string, skipKeys []string) (variableMapping, error) {
+	if environmentLookup == nil {
+		return nil, nil
+	}
+	skipped, err := noInterpolateKeys(serviceData)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range skipKeys {
+		skipped[key] = true
+	}
+	if skipped["environment"] || skipped["env_file"] {
+		return nil, nil
+	}
+
+	vars := map[string]string{}
+	for _, v := range inline {
+		if parts := strings.SplitN(v, "=", 2); len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	lookup := lookupMapping(environmentLookup)
+	return func(name string) (string, bool) {
+		if value, ok := vars[name]; ok {
+			return value, true
+		}
+		return lookup(name)
+	}, nil
+}
+
+// readLabelFile loads the labels of the label_file(s) of the specified
+// service and merges them with its labels, the latter taking precedence.
+// Label files have the syntax of env files.
+func readLabelFile(resourceLookup ResourceLookup, inFile string, serviceData RawService) (RawService, error) {
+	if _, ok := serviceData["label_file"]; !ok {
+		return serviceData, nil
+	}
+
+	var labelFiles composeYaml.Stringorslice
+
+	if err := utils.Convert(serviceData["label_file"], &labelFiles); err != nil {
+		return nil, err
+	}
+
+	if len(labelFiles) == 0 {
+		return serviceData, nil
+	}
+
+	if resourceLookup == nil {
+		return nil, fmt.Errorf("Can not use label_file in file %s no mechanism provided to load files", inFile)
+	}
+
+	labels := map[interface{}]interface{}{}
+
+	for _, labelFile := range labelFiles {
+		content, _, err := resourceLookup.Lookup(labelFile, inFile)
+		if err != nil {
+			return nil, err
+		}
+
+		vars, err := ParseEnvFile(content)
+		if err != nil {
+			return nil, err
 		}
 
-		if scanner.Err() != nil {
-			return nil, scanner.Err()
+		for key, value := range vars {
+			labels[key] = value
 		}
 	}
 
-	serviceData["environment"] = vars
+	if _, ok := serviceData["labels"]; ok {
+		var inline composeYaml.SliceorMap
+		if err := utils.Convert(serviceData["labels"], &inline); err != nil {
+			return nil, err
+		}
+		for k, v := range inline {
+			labels[k] = v
+		}
+	}
+
+	serviceData["labels"] = labels
 
 	return serviceData, nil
 }
 
-func mergeConfig(baseService, serviceData RawService) RawService {
+// readKeyValueLines returns the key=value lines of the specified file
+// content, skipping blank lines and comments.
+func readKeyValueLines(content []byte) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(bytes.NewBuffer(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// ParseEnvFile returns the variables set by the specified env file content,
+// with the syntax supported by env_file: blank lines and comments are
+// skipped, lines may start with export and values may be quoted.
+func ParseEnvFile(content []byte) (map[string]string, error) {
+	lines, err := readKeyValueLines(content)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{}
+	for _, line := range lines {
+		key, value := parseEnvLine(line)
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// parseEnvLine returns the variable set by the specified env file line. As
+// in shell files, the line may start with export and the value may be
+// surrounded by single or double quotes. A line without = sets the variable
+// to an empty string.
+func parseEnvLine(line string) (string, string) {
+	if strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export\t") {
+		line = strings.TrimSpace(line[len("export"):])
+	}
+
+	parts := strings.SplitN(line, "=", 2)
+	key := strings.TrimSpace(parts[0])
+	if len(parts) == 1 {
+		return key, ""
+	}
+
+	value := strings.TrimSpace(parts[1])
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	}
+	return key, value
+}
+
+// mergeConfig merges a service into the same service of a previous compose
+// file, lists being merged according to the specified policy.
+func mergeConfig(baseService, serviceData RawService, policy ListMergePolicy) RawService {
 	for k, v := range serviceData {
 		existing, ok := baseService[k]
-		if ok {
-			baseService[k] = merge(existing, v)
-		} else {
+		if !ok {
+			baseService[k] = v
+			continue
+		}
+		if _, isList := v.([]interface{}); isList && policy.forKey(k) == ListMergeReplace {
 			baseService[k] = v
+			continue
 		}
+		baseService[k] = merge(existing, v)
 	}
 
 	return baseService
 }
 
+// extendsOf returns the file and the service extended by the specified
+// service, if any. The file is empty for services of the same file.
+func extendsOf(serviceData RawService) (string, string) {
+	switch extends := serviceData["extends"].(type) {
+	case string:
+		return "", extends
+	case map[interface{}]interface{}:
+		return asString(extends["file"]), asString(extends["service"])
+	}
+	return "", ""
+}
+
+// extendsLink returns how the specified service appears in a chain of
+// extended services.
+func extendsLink(file, service string) string {
+	if file == "" {
+		return service
+	}
+	return fmt.Sprintf("%s (%s)", service, file)
+}
+
+// extendsChain returns the specified chain of extended services with the
+// specified service appended, or an error if it is already part of it.
+func extendsChain(chain []string, file, service string) ([]string, error) {
+	link := extendsLink(file, service)
+	for _, l := range chain {
+		if l == link {
+			return nil, fmt.Errorf("Circular reference in extends: %s", strings.Join(append(chain, link), " extends "))
+		}
+	}
+	return append(append([]string{}, chain...), link), nil
+}
+
+// mergeExtendedConfig merges a service into the service it extends, following
+// compose's extends semantics (which differ from the override files ones):
+// ports and expose are appended as is, duplicates included, volumes and
+// devices override the entries of the base service mounted at the same
+// container path, and command and entrypoint are replaced as a whole.
+func mergeExtendedConfig(baseService, serviceData RawService) RawService {
+	for k, v := range serviceData {
+		existing, ok := baseService[k]
+		if !ok {
+			baseService[k] = v
+			continue
+		}
+		switch k {
+		case "volumes", "devices":
+			baseService[k] = mergeByContainerPath(existing, v)
+		case "command", "entrypoint":
+			baseService[k] = v
+		default:
+			baseService[k] = merge(existing, v)
+		}
+	}
+
+	return baseService
+}
+
+// mergeByContainerPath merges two lists of path mappings (host:container[:mode]),
+// the entries of value replacing the ones of existing with the same container
+// path.
+func mergeByContainerPath(existing, value interface{}) interface{} {
+	left, lok := existing.([]interface{})
+	right, rok := value.([]interface{})
+	if !lok || !rok {
+		return merge(existing, value)
+	}
+
+	overridden := map[string]bool{}
+	for _, v := range right {
+		overridden[containerPath(v)] = true
+	}
+
+	result := []interface{}{}
+	for _, v := range left {
+		if !overridden[containerPath(v)] {
+			result = append(result, v)
+		}
+	}
+	return append(result, right...)
+}
+
+func containerPath(mapping interface{}) string {
+	switch long := mapping.(type) {
+	case map[interface{}]interface{}:
+		return asString(long["target"])
+	case map[string]interface{}:
+		return asString(long["target"])
+	}
+	parts := strings.Split(asString(mapping), ":")
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[1]
+}
+
 // IsValidRemote checks if the specified string is a valid remote (for builds)
 func IsValidRemote(remote string) bool {
 	return urlutil.IsGitURL(remote) || urlutil.IsURL(remote)
 }
+
+// resolveVolumes resolves the relative host paths of the bind mounts of the
+// specified service against the directory of the compose file it is defined
+// in, so that services merged from compose files of different directories
+// each get theirs right. Only sources starting with "." are considered host
+// paths here, the others being left for the conversion to resolve.
+func resolveVolumes(resourceLookup ResourceLookup, inFile string, serviceData RawService) RawService {
+	volumes, ok := serviceData["volumes"].([]interface{})
+	if !ok || resourceLookup == nil {
+		return serviceData
+	}
+	for i, volume := range volumes {
+		switch v := volume.(type) {
+		case string:
+			parts := strings.SplitN(v, ":", 2)
+			if len(parts) != 2 || !strings.HasPrefix(parts[0], ".") {
+				continue
+			}
+			if resolved := resourceLookup.ResolvePath(v, inFile); resolved != "" {
+				volumes[i] = resolved
+			}
+		case map[interface{}]interface{}:
+			if source, ok := resolveVolumeSource(resourceLookup, inFile, asString(v["source"]), asString(v["target"])); ok {
+				v["source"] = source
+			}
+		case map[string]interface{}:
+			if source, ok := resolveVolumeSource(resourceLookup, inFile, asString(v["source"]), asString(v["target"])); ok {
+				v["source"] = source
+			}
+		}
+	}
+	return serviceData
+}
+
+// resolveVolumeSource resolves the source of a long syntax volume, returning
+// false if it isn't a relative host path.
+func resolveVolumeSource(resourceLookup ResourceLookup, inFile, source, target string) (string, bool) {
+	if !strings.HasPrefix(source, ".") {
+		return "", false
+	}
+	resolved := resourceLookup.ResolvePath(source+":"+target, inFile)
+	if resolved == "" {
+		return "", false
+	}
+	return strings.SplitN(resolved, ":", 2)[0], true
+}
+
+// resolveSecurityOpts resolves the relative seccomp profile paths of the
+// security_opt of the specified service against the directory of the compose
+// file it is defined in, the same way as the bind mounts sources.
+func resolveSecurityOpts(resourceLookup ResourceLookup, inFile string, serviceData RawService) RawService {
+	opts, ok := serviceData["security_opt"].([]interface{})
+	if !ok || resourceLookup == nil {
+		return serviceData
+	}
+	for i, opt := range opts {
+		key, value, ok := SplitSecurityOpt(asString(opt))
+		if !ok || key != "seccomp" || value == "unconfined" {
+			continue
+		}
+		if resolved := resourceLookup.ResolvePath(value+":/", inFile); resolved != "" {
+			opts[i] = key + "=" + strings.SplitN(resolved, ":", 2)[0]
+		}
+	}
+	return serviceData
+}
@@ -1,8 +1,17 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/zengchen221/libcompose/yaml"
 )
 
 type NullLookup struct {
@@ -247,6 +256,142 @@ services:
 	}
 }
 
+func TestMergesShellEnvFile(t *testing.T) {
+	_, config, _, _, err := Merge(NewServiceConfigs(), nil, &FileLookup{}, "", []byte(`
+version: '2'
+services:
+  test:
+    image: foo
+    environment:
+      - INLINE=overridden
+    env_file:
+      - testdata/shell.env
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := yaml.MaporEqualSlice{
+		"INLINE=overridden",
+		"FOO=foo",
+		"TAB=tab",
+		"PASSWORD=a=b",
+		"SINGLE=single quoted",
+		"UNQUOTED=a=b",
+		"EMPTY=",
+	}
+	if !reflect.DeepEqual(config["test"].Environment, expected) {
+		t.Fatalf("Expected %v, got %v", expected, config["test"].Environment)
+	}
+}
+
+func TestInterpolatesEnvFile(t *testing.T) {
+	files := mapLookup{
+		"base.env":     "API_URL=${HOST}/api\nLEVEL=${LOG_LEVEL:-info}\nNAME=base\nLITERAL=$$HOME\n",
+		"override.env": "NAME=${HOST}\n",
+	}
+	_, configs, _, _, err := Merge(NewServiceConfigs(), hostEnvironmentLookup{"HOST": "host.local", "LOG_LEVEL": "debug"}, files, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    environment:
+      HOST: example.com
+    env_file:
+      - base.env
+      - override.env
+  raw:
+    image: foo
+    x-no-interpolate: [environment]
+    env_file: base.env
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := yaml.MaporEqualSlice{
+		"HOST=example.com",
+		"NAME=example.com",
+		"API_URL=example.com/api",
+		"LEVEL=debug",
+		"LITERAL=$HOME",
+	}
+	if !reflect.DeepEqual(configs["web"].Environment, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].Environment)
+	}
+
+	expected = yaml.MaporEqualSlice{
+		"API_URL=${HOST}/api",
+		"LEVEL=${LOG_LEVEL:-info}",
+		"NAME=base",
+		"LITERAL=$$HOME",
+	}
+	if !reflect.DeepEqual(configs["raw"].Environment, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["raw"].Environment)
+	}
+}
+
+// relativeFileLookup reads files relative to the file referencing them.
+type relativeFileLookup struct{}
+
+func (relativeFileLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
+	file = filepath.Join(filepath.Dir(relativeTo), file)
+	bytes, err := ioutil.ReadFile(file)
+	return bytes, file, err
+}
+
+func (relativeFileLookup) ResolvePath(path, inFile string) string {
+	return path
+}
+
+func TestOptionalEnvFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range []string{"app", "shared"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "shared", "common.env"), []byte("COMMON=shared\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	composeFile := filepath.Join(dir, "app", "docker-compose.yml")
+
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, relativeFileLookup{}, composeFile, []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    env_file:
+      - ../shared/common.env
+      - path: local.env
+        required: false
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := yaml.MaporEqualSlice{"COMMON=shared"}
+	if !reflect.DeepEqual(configs["web"].Environment, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].Environment)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, relativeFileLookup{}, composeFile, []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    env_file:
+      - ../shared/common.env
+      - path: local.env
+`), nil)
+	if err == nil || !os.IsNotExist(err) {
+		t.Fatalf("Expected a missing required env file to fail, got %v", err)
+	}
+}
+
 func TestMergesEnvFile(t *testing.T) {
 	_, configV1, _, _, err := Merge(NewServiceConfigs(), nil, &FileLookup{}, "", []byte(`
 test:
@@ -440,3 +585,2004 @@ services:
 		}
 	}
 }
+
+func TestAnnotations(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  map:
+    image: foo
+    labels:
+      foo: label
+    annotations:
+      foo: annotation
+  list:
+    image: foo
+    annotations:
+      - org.opencontainers.image.title=web
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configs["map"].Annotations["foo"] != "annotation" || configs["map"].Labels["foo"] != "label" {
+		t.Fatalf("Invalid annotations %#v, labels %#v", configs["map"].Annotations, configs["map"].Labels)
+	}
+	if configs["list"].Annotations["org.opencontainers.image.title"] != "web" {
+		t.Fatalf("Invalid annotations %#v", configs["list"].Annotations)
+	}
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    post_start:
+      - command: curl -s http://localhost/warmup
+        user: root
+        environment:
+          - FOO=bar
+    pre_stop:
+      - command: ["./drain.sh", "--wait"]
+        working_dir: /app
+        privileged: true
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := configs["web"]
+	if len(web.PostStart) != 1 || len(web.PreStop) != 1 {
+		t.Fatalf("Invalid hooks %#v, %#v", web.PostStart, web.PreStop)
+	}
+	postStart := web.PostStart[0]
+	if len(postStart.Command) != 3 || postStart.Command[0] != "curl" || postStart.User != "root" || postStart.Environment[0] != "FOO=bar" {
+		t.Fatalf("Invalid post_start hook %#v", postStart)
+	}
+	preStop := web.PreStop[0]
+	if len(preStop.Command) != 2 || preStop.WorkingDir != "/app" || !preStop.Privileged {
+		t.Fatalf("Invalid pre_stop hook %#v", preStop)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    post_start:
+      - user: root
+`), nil)
+	if err == nil {
+		t.Fatal("Expected an error for a hook without command")
+	}
+}
+
+func TestInterpolatedVersion(t *testing.T) {
+	version, configs, _, _, err := Merge(NewServiceConfigs(), MockEnvironmentLookup{
+		map[string]string{"COMPOSE_VERSION": "2", "IMAGE": "foo"},
+	}, &NullLookup{}, "", []byte(`
+version: "${COMPOSE_VERSION}"
+services:
+  web:
+    image: ${IMAGE}
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "2" {
+		t.Fatalf("Invalid version %q", version)
+	}
+	if configs["web"].Image != "foo" {
+		t.Fatalf("Invalid image %q", configs["web"].Image)
+	}
+}
+
+func TestInterpolatedName(t *testing.T) {
+	config, err := createConfig([]byte(`
+version: "2"
+name: ${PROJECT}
+services:
+  web:
+    image: foo
+`), MockEnvironmentLookup{map[string]string{"PROJECT": "myproject"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Name != "myproject" {
+		t.Fatalf("Invalid name %q", config.Name)
+	}
+}
+
+func TestInvalidRestartPolicy(t *testing.T) {
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    restart: sometimes
+`), nil)
+	if err == nil {
+		t.Fatal("Expected an error for an invalid restart policy")
+	}
+}
+
+func TestGPUs(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  all:
+    image: foo
+    gpus: all
+  two:
+    image: foo
+    gpus: 2
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configs["all"].GPUs != yaml.AllGPUs || configs["two"].GPUs != 2 {
+		t.Fatalf("Invalid gpus %v, %v", configs["all"].GPUs, configs["two"].GPUs)
+	}
+}
+
+func TestMergesLabelFile(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &FileLookup{}, "", []byte(`
+version: '2'
+services:
+  files:
+    image: foo
+    label_file:
+      - testdata/labels
+      - testdata/labels2
+  inline:
+    image: foo
+    label_file: testdata/labels
+    labels:
+      - com.example.team=web
+  quoted:
+    image: foo
+    label_file: testdata/labels3
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quoted := configs["quoted"].Labels
+	if len(quoted) != 2 || quoted["com.example.owner"] != "Jane Doe" || quoted["com.example.note"] != "a=b" {
+		t.Fatal("label_file should have the env_file syntax", quoted)
+	}
+
+	files := configs["files"].Labels
+	if len(files) != 2 || files["com.example.team"] != "core" || files["com.example.tier"] != "frontend" {
+		t.Fatal("label_file is not merged", files)
+	}
+
+	inline := configs["inline"].Labels
+	if len(inline) != 2 || inline["com.example.team"] != "web" || inline["com.example.tier"] != "backend" {
+		t.Fatal("Inline labels should take precedence", inline)
+	}
+}
+
+func TestDevelopWatch(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "/project/docker-compose.yml", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    develop:
+      watch:
+        - path: ./src
+          action: sync
+          target: /app/src
+          ignore:
+            - node_modules/
+        - path: /abs/package.json
+          action: rebuild
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watch := configs["web"].Develop.Watch
+	if len(watch) != 2 {
+		t.Fatalf("Invalid watch rules %#v", watch)
+	}
+	if watch[0].Path != "/project/src" || watch[0].Action != "sync" || watch[0].Target != "/app/src" || len(watch[0].Ignore) != 1 {
+		t.Fatalf("Invalid watch rule %#v", watch[0])
+	}
+	if watch[1].Path != "/abs/package.json" || watch[1].Action != "rebuild" {
+		t.Fatalf("Invalid watch rule %#v", watch[1])
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    develop:
+      watch:
+        - path: ./src
+          action: teleport
+`), nil)
+	if err == nil {
+		t.Fatal("Expected an error for an invalid watch action")
+	}
+}
+
+func TestAttach(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+  db:
+    image: foo
+    attach: false
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configs["web"].Attach != nil {
+		t.Fatalf("Expected attach to be unset, got %v", *configs["web"].Attach)
+	}
+	if configs["db"].Attach == nil || *configs["db"].Attach {
+		t.Fatalf("Expected attach to be false, got %v", configs["db"].Attach)
+	}
+}
+
+func TestMemoryReservationAboveLimit(t *testing.T) {
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    mem_limit: 512m
+    mem_reservation: 1g
+`), &ParseOptions{Validate: true})
+	if err == nil || !strings.Contains(err.Error(), "mem_reservation") {
+		t.Fatalf("Expected a mem_reservation error, got %v", err)
+	}
+
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    mem_limit: 1gi
+    mem_reservation: 512mi
+`), &ParseOptions{Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs["web"].MemLimit != 1024*1024*1024 || configs["web"].MemReservation != 512*1024*1024 {
+		t.Fatalf("Invalid memory settings %v, %v", configs["web"].MemLimit, configs["web"].MemReservation)
+	}
+}
+
+func TestReadOnlyWarnings(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  tmpfs:
+    image: foo
+    read_only: true
+    tmpfs: /tmp
+  volume:
+    image: foo
+    read_only: true
+    volumes:
+      - /data
+  writable:
+    image: foo
+  nowhere:
+    image: foo
+    read_only: true
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := serviceWarnings(configs)
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "'nowhere'") {
+		t.Fatalf("Expected a single warning for nowhere, got %v", warnings)
+	}
+	if !configs["tmpfs"].ReadOnly || len(configs["tmpfs"].Tmpfs) != 1 || configs["tmpfs"].Tmpfs[0] != "/tmp" {
+		t.Fatalf("Invalid read_only %v or tmpfs %v", configs["tmpfs"].ReadOnly, configs["tmpfs"].Tmpfs)
+	}
+}
+
+func TestMergeWarnings(t *testing.T) {
+	warnings := []ConfigWarning{}
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '3'
+services:
+  web:
+    image: busybox
+    read_only: true
+    deploy:
+      replicas: 2
+      placement:
+        constraints: [node.role == manager]
+`), &ParseOptions{
+		Interpolate: true,
+		Validate:    true,
+		Warn: func(warning ConfigWarning) {
+			warnings = append(warnings, warning)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := []string{}
+	for _, warning := range warnings {
+		if warning.Service != "web" || warning.Message == "" {
+			t.Fatalf("Invalid warning %#v", warning)
+		}
+		fields = append(fields, warning.Field)
+	}
+	sort.Strings(fields)
+	expected := []string{"deploy.placement", "deploy.replicas", "read_only"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("Expected warnings about %v, got %v", expected, warnings)
+	}
+
+	warnings = []ConfigWarning{}
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    privilege: true
+`), &ParseOptions{
+		Warn: func(warning ConfigWarning) {
+			warnings = append(warnings, warning)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Service != "web" || warnings[0].Field != "privilege" {
+		t.Fatalf("Expected a warning about the unknown privilege key, got %v", warnings)
+	}
+}
+
+func TestBuildAndRunPlatforms(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  cross:
+    build:
+      context: .
+      platform: linux/arm64
+    platform: linux/amd64
+  same:
+    build:
+      context: .
+      platform: linux/amd64
+    platform: linux/AMD64
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configs["cross"].Build.Platform != "linux/arm64" || configs["cross"].Platform != "linux/amd64" {
+		t.Fatalf("Invalid platforms, build: %s, run: %s", configs["cross"].Build.Platform, configs["cross"].Platform)
+	}
+	warnings := serviceWarnings(configs)
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "'cross'") {
+		t.Fatalf("Expected a single warning for cross, got %v", warnings)
+	}
+}
+
+func TestMergeInterpolatedNumbers(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), hostEnvironmentLookup{"CPUS": "1.5", "PRIVILEGED": "true"}, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    cpus: ${CPUS}
+    privileged: "${PRIVILEGED}"
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs["web"].CPUs != 1.5 || !configs["web"].Privileged {
+		t.Fatalf("Invalid interpolated values %v and %v", configs["web"].CPUs, configs["web"].Privileged)
+	}
+}
+
+func TestMergeDNS(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  single:
+    image: busybox
+    dns: 8.8.8.8
+    dns_search: example.com
+    dns_opt: timeout:2
+  list:
+    image: busybox
+    dns:
+      - 8.8.8.8
+      - 2001:4860:4860::8888
+    dns_search:
+      - example.com
+      - example.org
+    dns_opt:
+      - timeout:2
+      - attempts:3
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, list := configs["single"], configs["list"]
+	if !reflect.DeepEqual([]string(single.DNS), []string{"8.8.8.8"}) || !reflect.DeepEqual([]string(single.DNSSearch), []string{"example.com"}) || !reflect.DeepEqual([]string(single.DNSOpts), []string{"timeout:2"}) {
+		t.Fatalf("Invalid dns settings %v, %v and %v", single.DNS, single.DNSSearch, single.DNSOpts)
+	}
+	if len(list.DNS) != 2 || len(list.DNSSearch) != 2 || !reflect.DeepEqual([]string(list.DNSOpts), []string{"timeout:2", "attempts:3"}) {
+		t.Fatalf("Invalid dns settings %v, %v and %v", list.DNS, list.DNSSearch, list.DNSOpts)
+	}
+
+	for _, c := range []struct {
+		dns   string
+		field string
+		line  int
+	}{
+		{"dns: dns.google", "dns", 5},
+		{"dns:\n      - 8.8.8.8\n      - 8.8.4.4.1", "dns.1", 7},
+	} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte("version: '2'\nservices:\n  web:\n    image: busybox\n    "+c.dns+"\n"), nil)
+		validationError, ok := err.(*ValidationError)
+		if !ok || validationError.Field != c.field || validationError.Line != c.line {
+			t.Fatalf("Expected a dns error on line %d, got %#v", c.line, err)
+		}
+	}
+}
+
+func TestMacAddress(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    mac_address: 02:42:ac:11:00:02
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs["web"].MacAddress != "02:42:ac:11:00:02" {
+		t.Fatal("Invalid mac address", configs["web"].MacAddress)
+	}
+
+	for _, address := range []string{"02:42:ac:11:00", "not-a-mac", "00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01"} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+web:
+  image: busybox
+  mac_address: "`+address+`"
+`), nil)
+		validationError, ok := err.(*ValidationError)
+		if !ok || validationError.Field != "mac_address" || validationError.Line != 4 {
+			t.Fatalf("Expected a located mac_address error for %s, got %v", address, err)
+		}
+	}
+}
+
+func TestSecurityOpt(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    security_opt:
+      - seccomp:unconfined
+      - apparmor=myprofile
+      - no-new-privileges:true
+      - label:disable
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"seccomp:unconfined", "apparmor=myprofile", "no-new-privileges:true", "label:disable"}
+	if !reflect.DeepEqual(configs["web"].SecurityOpt, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].SecurityOpt)
+	}
+
+	for _, opt := range []string{"seccomp", "selinux:type", "no-new-privileges:maybe", "apparmor="} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    security_opt:
+      - no-new-privileges
+      - "`+opt+`"
+`), nil)
+		validationError, ok := err.(*ValidationError)
+		if !ok || validationError.Field != "security_opt.1" || validationError.Line != 8 {
+			t.Fatalf("Expected a located security_opt error for %s, got %v", opt, err)
+		}
+	}
+}
+
+func TestExternalLinks(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    links:
+      - cache
+    external_links:
+      - legacy_db:db
+      - monitoring
+  cache:
+    image: redis
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"legacy_db:db", "monitoring"}
+	if !reflect.DeepEqual(configs["web"].ExternalLinks, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].ExternalLinks)
+	}
+	if !reflect.DeepEqual([]string(configs["web"].Links), []string{"cache"}) {
+		t.Fatalf("Expected the links to be kept apart, got %v", configs["web"].Links)
+	}
+
+	for _, link := range []string{"legacy_db:", ":db", "legacy_db:db:extra", "legacy db"} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    external_links:
+      - monitoring
+      - "`+link+`"
+`), nil)
+		validationError, ok := err.(*ValidationError)
+		if !ok || validationError.Field != "external_links.1" || validationError.Line != 8 {
+			t.Fatalf("Expected a located external_links error for %s, got %v", link, err)
+		}
+	}
+}
+
+func TestDurations(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    stop_grace_period: 1h30s
+    healthcheck:
+      test: [CMD, "true"]
+      interval: 500ms
+      timeout: 5
+      start_period: 1.5
+  worker:
+    image: busybox
+    stop_grace_period: 0s
+  db:
+    image: busybox
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := configs["web"]
+	if web.StopGracePeriod == nil || time.Duration(*web.StopGracePeriod) != time.Hour+30*time.Second {
+		t.Fatal("Invalid stop_grace_period", web.StopGracePeriod)
+	}
+	if worker := configs["worker"]; worker.StopGracePeriod == nil || *worker.StopGracePeriod != 0 {
+		t.Fatal("Invalid zero stop_grace_period", worker.StopGracePeriod)
+	}
+	if configs["db"].StopGracePeriod != nil {
+		t.Fatal("Unexpected stop_grace_period", configs["db"].StopGracePeriod)
+	}
+	if time.Duration(web.HealthCheck.Interval) != 500*time.Millisecond || time.Duration(web.HealthCheck.Timeout) != 5*time.Second || time.Duration(web.HealthCheck.StartPeriod) != 1500*time.Millisecond {
+		t.Fatalf("Invalid healthcheck durations %#v", web.HealthCheck)
+	}
+
+	for _, c := range []struct {
+		key     string
+		service string
+		line    int
+	}{
+		{"stop_grace_period", "    stop_grace_period: soon\n", 5},
+		{"healthcheck.interval", "    healthcheck:\n      interval: 10 s\n", 6},
+	} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte("version: '2'\nservices:\n  web:\n    image: busybox\n"+c.service), nil)
+		validationError, ok := err.(*ValidationError)
+		if !ok || validationError.Field != c.key || validationError.Line != c.line {
+			t.Fatalf("Expected a %s error on line %d, got %#v", c.key, c.line, err)
+		}
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '3'
+services:
+  web:
+    image: busybox
+    deploy:
+      restart_policy:
+        condition: on-failure
+        delay: later
+`), nil)
+	if err == nil || !strings.Contains(err.Error(), "'deploy.restart_policy.delay' is invalid") {
+		t.Fatal("Expected an invalid deploy.restart_policy.delay error, got", err)
+	}
+}
+
+func TestInvalidPlatforms(t *testing.T) {
+	for _, platform := range []string{"amd64", "linux/arm/v7/extra", "linux//amd64", "linux/amd 64"} {
+		for _, c := range []struct {
+			key     string
+			service string
+			line    int
+		}{
+			{"platform", "    image: busybox\n    platform: %s\n", 5},
+			{"build.platform", "    build:\n      context: .\n      platform: %s\n", 6},
+		} {
+			_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte("version: '2'\nservices:\n  web:\n"+fmt.Sprintf(c.service, platform)), nil)
+			validationError, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Expected a validation error for %s %q, got %v", c.key, platform, err)
+			}
+			if validationError.Field != c.key || validationError.Line != c.line {
+				t.Fatalf("Invalid location of %v: %s line %d", err, validationError.Field, validationError.Line)
+			}
+		}
+	}
+
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    platform: linux/arm/v7
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInit(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  default:
+    image: foo
+  enabled:
+    image: foo
+    init: true
+  disabled:
+    image: foo
+    init: false
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configs["default"].Init != nil {
+		t.Fatalf("Expected init to be unset, got %v", *configs["default"].Init)
+	}
+	if configs["enabled"].Init == nil || !*configs["enabled"].Init {
+		t.Fatalf("Expected init to be true, got %v", configs["enabled"].Init)
+	}
+	if configs["disabled"].Init == nil || *configs["disabled"].Init {
+		t.Fatalf("Expected init to be false, got %v", configs["disabled"].Init)
+	}
+}
+
+func TestValidateWithoutInterpolation(t *testing.T) {
+	options := &ParseOptions{Interpolate: false, Validate: true}
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: ${IMAGE}:${TAG:-latest}
+    ports:
+      - ${PORT}:80
+    mem_limit: ${MEM}
+    privileged: ${PRIVILEGED}
+    cpu_count: ${CPUS}
+    restart: ${RESTART}
+    ipc: ${IPC}
+`), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := configs["web"]
+	if web.Image != "${IMAGE}:${TAG:-latest}" || len(web.Ports) != 1 || web.Ports[0] != "${PORT}:80" || web.Restart != "${RESTART}" {
+		t.Fatalf("Expected placeholders to be kept, got image %s, ports %v, restart %s", web.Image, web.Ports, web.Restart)
+	}
+	if web.Privileged || web.MemLimit != 0 {
+		t.Fatalf("Expected uninterpolated typed values to be ignored, got privileged %v, mem_limit %v", web.Privileged, web.MemLimit)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: ${IMAGE}
+    privileged: yes-please
+    restart: sometimes
+`), options)
+	if err == nil || !strings.Contains(err.Error(), "privileged") {
+		t.Fatalf("Expected a privileged validation error, got %v", err)
+	}
+}
+
+func TestBlkioConfig(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    blkio_config:
+      weight: 300
+      weight_device:
+        - path: /dev/sda
+          weight: 400
+      device_read_bps:
+        - path: /dev/sda
+          rate: 12mb
+      device_write_bps:
+        - path: /dev/sdb
+          rate: 1024
+      device_read_iops:
+        - path: /dev/sda
+          rate: 120
+      device_write_iops:
+        - path: /dev/sdb
+          rate: 30
+`), &ParseOptions{Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blkio := configs["web"].BlkioConfig
+	if len(blkio.WeightDevice) != 1 || blkio.WeightDevice[0].Path != "/dev/sda" || blkio.WeightDevice[0].Weight != 400 {
+		t.Fatalf("Invalid weight_device %v", blkio.WeightDevice)
+	}
+	if len(blkio.DeviceReadBps) != 1 || blkio.DeviceReadBps[0].Rate != 12*1024*1024 {
+		t.Fatalf("Invalid device_read_bps %v", blkio.DeviceReadBps)
+	}
+	if len(blkio.DeviceWriteBps) != 1 || blkio.DeviceWriteBps[0].Path != "/dev/sdb" || blkio.DeviceWriteBps[0].Rate != 1024 {
+		t.Fatalf("Invalid device_write_bps %v", blkio.DeviceWriteBps)
+	}
+	if blkio.Weight != 300 {
+		t.Fatalf("Invalid weight %d", blkio.Weight)
+	}
+	if len(blkio.DeviceReadIOps) != 1 || blkio.DeviceReadIOps[0].Path != "/dev/sda" || blkio.DeviceReadIOps[0].Rate != 120 {
+		t.Fatalf("Invalid device_read_iops %v", blkio.DeviceReadIOps)
+	}
+	if len(blkio.DeviceWriteIOps) != 1 || blkio.DeviceWriteIOps[0].Path != "/dev/sdb" || blkio.DeviceWriteIOps[0].Rate != 30 {
+		t.Fatalf("Invalid device_write_iops %v", blkio.DeviceWriteIOps)
+	}
+}
+
+func TestInvalidBlkioConfig(t *testing.T) {
+	invalids := map[string]string{
+		`
+      weight_device:
+        - path: sda
+          weight: 400`: "weight_device entry 'sda:400'",
+		`
+      weight_device:
+        - path: /dev/sda
+          weight: 5`: "weight_device entry '/dev/sda:5'",
+		`
+      device_read_bps:
+        - path: /dev/sda
+          rate: 0`: "device_read_bps entry '/dev/sda:0'",
+		`
+      device_write_bps:
+        - rate: 1024`: "path is required",
+		`
+      weight: 1001`: "Invalid weight 1001",
+		`
+      device_write_iops:
+        - path: /dev/sda
+          rate: 0`: "device_write_iops entry '/dev/sda:0'",
+		`
+      device_read_iops:
+        - path: /dev/sda
+          rate: 10mb`: "'rate' contains an invalid type, it should be an integer",
+	}
+
+	for blkio, expected := range invalids {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    blkio_config:`+blkio+`
+`), &ParseOptions{Validate: true})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected an error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+type hostEnvironmentLookup map[string]string
+
+func (h hostEnvironmentLookup) Lookup(key string, config *ServiceConfig) []string {
+	if value, ok := h[key]; ok {
+		return []string{key + "=" + value}
+	}
+	return []string{}
+}
+
+func TestEnvironmentPassThrough(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), hostEnvironmentLookup{"FOO": "foo", "EMPTY": ""}, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  map:
+    image: foo
+    environment:
+      FOO:
+      EMPTY:
+      UNSET:
+      BAR: bar
+  list:
+    image: foo
+    environment:
+      - FOO
+      - UNSET
+      - BAR=bar
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	environment := configs["map"].Environment.ToMap()
+	if len(environment) != 3 || environment["FOO"] != "foo" || environment["BAR"] != "bar" {
+		t.Fatalf("Invalid environment %v", configs["map"].Environment)
+	}
+	if value, ok := environment["EMPTY"]; !ok || value != "" {
+		t.Fatalf("Expected EMPTY to be passed as an empty value, got %v", configs["map"].Environment)
+	}
+	if _, ok := environment["UNSET"]; ok {
+		t.Fatalf("Expected UNSET to be omitted, got %v", configs["map"].Environment)
+	}
+
+	if !reflect.DeepEqual(configs["list"].Environment, yaml.MaporEqualSlice{"FOO=foo", "BAR=bar"}) {
+		t.Fatalf("Invalid environment %v", configs["list"].Environment)
+	}
+}
+
+func TestDependsOnRestart(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  config:
+    image: foo
+  app:
+    image: foo
+    depends_on:
+      config:
+        condition: service_healthy
+        restart: true
+  migrate:
+    image: foo
+  web:
+    image: foo
+    depends_on:
+      app:
+        condition: service_started
+      migrate:
+        condition: service_completed_successfully
+`), &ParseOptions{Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(configs["app"].DependsOn, yaml.DependsOn{{Service: "config", Condition: yaml.ConditionServiceHealthy, Restart: true}}) {
+		t.Fatalf("Invalid depends_on %v", configs["app"].DependsOn)
+	}
+	if !reflect.DeepEqual(configs["web"].DependsOn, yaml.DependsOn{{Service: "app", Condition: yaml.ConditionServiceStarted}, {Service: "migrate", Condition: yaml.ConditionServiceCompletedSuccessfully}}) {
+		t.Fatalf("Invalid depends_on %v", configs["web"].DependsOn)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  app:
+    image: foo
+    depends_on:
+      config:
+        restart: sometimes
+`), &ParseOptions{Validate: true})
+	if err == nil {
+		t.Fatal("Expected an error for an invalid depends_on restart value")
+	}
+}
+
+func TestNoInterpolate(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), MockEnvironmentLookup{map[string]string{"IMAGE": "busybox"}}, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    x-no-interpolate: [command]
+    image: ${IMAGE}
+    command: echo {{ .Values }} ${RENDERED_LATER}
+`), &ParseOptions{Interpolate: true, Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configs["web"].Image != "busybox" {
+		t.Fatalf("Invalid image %q", configs["web"].Image)
+	}
+	if !reflect.DeepEqual(configs["web"].Command, yaml.Command{"echo", "{{", ".Values", "}}", "${RENDERED_LATER}"}) {
+		t.Fatalf("Invalid command %v", configs["web"].Command)
+	}
+}
+
+func TestPullPolicy(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    pull_policy: every_12h
+`), &ParseOptions{Validate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs["web"].PullPolicy != "every_12h" {
+		t.Fatalf("Invalid pull policy %q", configs["web"].PullPolicy)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    pull_policy: hourly
+`), &ParseOptions{Validate: true})
+	if err == nil || !strings.Contains(err.Error(), "pull_policy") {
+		t.Fatalf("Expected a pull_policy error, got %v", err)
+	}
+}
+
+func TestExtendsVolumesByContainerPath(t *testing.T) {
+	_, config, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  parent:
+    image: foo
+    volumes:
+      - /data
+      - /host/config:/etc/config:ro
+    devices:
+      - /dev/sda:/dev/xvda
+  child:
+    extends:
+      service: parent
+    volumes:
+      - /other/config:/etc/config
+      - /logs:/var/log
+    devices:
+      - /dev/sdb:/dev/xvda
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	child := config["child"]
+	expectedVolumes := []string{"/data", "/other/config:/etc/config", "/logs:/var/log"}
+	var volumes []string
+	for _, volume := range child.Volumes.Volumes {
+		volumes = append(volumes, volume.String())
+	}
+	if !reflect.DeepEqual(volumes, expectedVolumes) {
+		t.Fatalf("Invalid volumes, expected %v, got %v", expectedVolumes, volumes)
+	}
+	if !reflect.DeepEqual(child.Devices, []string{"/dev/sdb:/dev/xvda"}) {
+		t.Fatal("Invalid devices", child.Devices)
+	}
+	if len(config["parent"].Volumes.Volumes) != 2 {
+		t.Fatal("Invalid parent volumes", config["parent"].Volumes)
+	}
+}
+
+func TestExtendsAppendsPorts(t *testing.T) {
+	_, configV1, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+parent:
+  image: foo
+  ports:
+    - 80:80
+  expose:
+    - "3000"
+  command: [run, --verbose]
+child:
+  extends:
+    service: parent
+  ports:
+    - 80:80
+    - 443:443
+  expose:
+    - "4000"
+  command: [serve]
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, configV2, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  parent:
+    image: foo
+    ports:
+      - 80:80
+    expose:
+      - "3000"
+    command: [run, --verbose]
+  child:
+    extends:
+      service: parent
+    ports:
+      - 80:80
+      - 443:443
+    expose:
+      - "4000"
+    command: [serve]
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, config := range []map[string]*ServiceConfig{configV1, configV2} {
+		child := config["child"]
+		// Like compose, identical entries are kept
+		if !reflect.DeepEqual(child.Ports, []string{"80:80", "80:80", "443:443"}) {
+			t.Fatal("Invalid ports", child.Ports)
+		}
+		if !reflect.DeepEqual(child.Expose, []string{"3000", "4000"}) {
+			t.Fatal("Invalid expose", child.Expose)
+		}
+		if !reflect.DeepEqual(child.Command, yaml.Command{"serve"}) {
+			t.Fatal("Invalid command", child.Command)
+		}
+	}
+}
+
+func TestMergeLongSyntaxVolumes(t *testing.T) {
+	_, config, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  base:
+    image: foo
+    volumes:
+      - ./old:/data
+      - /logs
+  web:
+    extends:
+      service: base
+    volumes:
+      - type: bind
+        source: ./data
+        target: /data
+        read_only: true
+        bind:
+          propagation: rshared
+      - type: volume
+        source: cache
+        target: /cache
+        volume:
+          nocopy: true
+      - type: tmpfs
+        target: /tmp
+        tmpfs:
+          size: 10M
+volumes:
+  cache: {}
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mounts []string
+	for _, volume := range config["web"].Volumes.Volumes {
+		mounts = append(mounts, volume.Type+" "+volume.String())
+	}
+	expected := []string{
+		" /logs",
+		"bind ./data:/data:ro,rshared",
+		"volume cache:/cache:nocopy",
+		"tmpfs /tmp",
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("Invalid mounts, expected %v, got %v", expected, mounts)
+	}
+	if size := config["web"].Volumes.Volumes[3].TmpfsSize; size != 10*1024*1024 {
+		t.Fatal("Invalid tmpfs size", size)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    volumes:
+      - type: mount
+        target: /data
+`), nil)
+	if err == nil {
+		t.Fatal("Expected an error for an invalid volume type")
+	}
+}
+
+func TestMergeV3(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	tokenFile.Close()
+
+	version, config, volumes, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "compose/docker-compose.yml", []byte(strings.Replace(`
+version: '3.4'
+services:
+  web:
+    image: foo
+    deploy:
+      mode: replicated
+      replicas: 1
+      resources:
+        limits:
+          cpus: '0.5'
+          memory: 50M
+        reservations:
+          memory: 20M
+      restart_policy:
+        condition: on-failure
+        max_attempts: 3
+    configs:
+      - nginx
+      - source: nginx
+        target: /etc/nginx/nginx.conf
+    secrets:
+      - token
+      - source: token
+        target: api_token
+        uid: '103'
+        mode: 0440
+    volumes:
+      - data:/data
+configs:
+  nginx:
+    file: ./nginx.conf
+secrets:
+  token:
+    file: TOKEN_FILE
+volumes:
+  data: {}
+`, "TOKEN_FILE", tokenFile.Name(), 1)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "3.4" {
+		t.Fatal("Invalid version", version)
+	}
+	if _, ok := volumes["data"]; !ok {
+		t.Fatal("Missing data volume", volumes)
+	}
+
+	web := config["web"]
+	if web.CPUs != 0.5 {
+		t.Fatal("Invalid cpus", web.CPUs)
+	}
+	if web.MemLimit != 50*1024*1024 || web.MemReservation != 20*1024*1024 {
+		t.Fatal("Invalid memory", web.MemLimit, web.MemReservation)
+	}
+	if web.Restart != "on-failure:3" {
+		t.Fatal("Invalid restart policy", web.Restart)
+	}
+
+	var mounts []string
+	for _, volume := range web.Volumes.Volumes {
+		mounts = append(mounts, volume.String())
+	}
+	expected := []string{
+		"data:/data",
+		"./compose/nginx.conf:/nginx:ro",
+		"./compose/nginx.conf:/etc/nginx/nginx.conf:ro",
+		tokenFile.Name() + ":/run/secrets/token:ro",
+		tokenFile.Name() + ":/run/secrets/api_token:ro",
+	}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Fatalf("Invalid mounts, expected %v, got %v", expected, mounts)
+	}
+}
+
+func TestMergeV3SwarmSettings(t *testing.T) {
+	_, config, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '3'
+services:
+  web:
+    image: foo
+    deploy:
+      replicas: 3
+      placement:
+        constraints: [node.role == manager]
+      update_config:
+        parallelism: 2
+      resources:
+        limits:
+          cpus: 1.5
+          memory: 512M
+        reservations:
+          cpus: '0.25'
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := config["web"]
+	if web.CPUs != 1.5 || web.MemLimit != 512*1024*1024 {
+		t.Fatal("Invalid resource limits", web.CPUs, web.MemLimit)
+	}
+}
+
+func TestMergeV3GPUReservations(t *testing.T) {
+	_, config, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '3.8'
+services:
+  counted:
+    image: foo
+    runtime: nvidia
+    deploy:
+      resources:
+        reservations:
+          devices:
+            - driver: nvidia
+              count: 2
+              capabilities: [gpu]
+  all:
+    image: foo
+    deploy:
+      resources:
+        reservations:
+          devices:
+            - capabilities: [gpu]
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config["counted"].GPUs != 2 || config["counted"].Runtime != "nvidia" {
+		t.Fatal("Invalid gpus or runtime", config["counted"].GPUs, config["counted"].Runtime)
+	}
+	if config["all"].GPUs != yaml.AllGPUs {
+		t.Fatal("Invalid gpus", config["all"].GPUs)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '3.8'
+services:
+  web:
+    image: foo
+    deploy:
+      resources:
+        reservations:
+          devices:
+            - capabilities: [gpu]
+              device_ids: ['0', '3']
+`), nil)
+	if err == nil || !strings.Contains(err.Error(), "'deploy.resources.reservations.devices' is not supported") {
+		t.Fatal("Expected an unsupported device reservation error, got", err)
+	}
+}
+
+func TestRuntime(t *testing.T) {
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    runtime: ""
+`), nil)
+	validationError, ok := err.(*ValidationError)
+	if !ok || validationError.Field != "runtime" || validationError.Line != 6 {
+		t.Fatal("Expected a located runtime error, got", err)
+	}
+}
+
+func TestTtyAndStdinOpen(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  shell:
+    image: busybox
+    tty: true
+    stdin_open: true
+  web:
+    image: nginx
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !configs["shell"].Tty || !configs["shell"].StdinOpen {
+		t.Fatalf("Expected tty and stdin_open to be set, got %v and %v", configs["shell"].Tty, configs["shell"].StdinOpen)
+	}
+	if configs["web"].Tty || configs["web"].StdinOpen {
+		t.Fatalf("Expected tty and stdin_open to be unset, got %v and %v", configs["web"].Tty, configs["web"].StdinOpen)
+	}
+}
+
+func TestMergeV3Unsupported(t *testing.T) {
+	for _, test := range []struct {
+		compose  string
+		expected string
+	}{
+		{
+			compose: `
+version: '3'
+services:
+  web:
+    image: foo
+    deploy:
+      endpoint: vip
+`,
+			expected: "Service 'web' configuration key 'deploy.endpoint' is not supported",
+		},
+		{
+			compose: `
+version: '3'
+services:
+  web:
+    image: foo
+    deploy:
+      mode: global
+`,
+			expected: "Service 'web' configuration key 'deploy.mode' is not supported: global",
+		},
+		{
+			compose: `
+version: '3'
+services:
+  web:
+    image: foo
+    secrets:
+      - token
+secrets:
+  token:
+    external: true
+`,
+			expected: "External secret 'token' is not supported",
+		},
+		{
+			compose: `
+version: '3.3'
+services:
+  web:
+    image: foo
+    configs:
+      - nginx
+configs:
+  nginx:
+    external:
+      name: nginx_conf
+`,
+			expected: "External config 'nginx' is not supported",
+		},
+		{
+			compose: `
+version: '3'
+services:
+  web:
+    image: foo
+    configs:
+      - missing
+`,
+			expected: "Service 'web' references undefined config 'missing'",
+		},
+	} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(test.compose), nil)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("Expected error %q, got %v", test.expected, err)
+		}
+	}
+
+	for _, kind := range []string{"config", "secret"} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &FileLookup{}, "", []byte(fmt.Sprintf(`
+version: '3.3'
+services:
+  web:
+    image: foo
+    %[1]ss:
+      - token
+%[1]ss:
+  token:
+    file: /does/not/exist
+`, kind)), nil)
+		expected := fmt.Sprintf("Invalid %s 'token': open /does/not/exist: no such file or directory", kind)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected error %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestMergeYAMLAnchors(t *testing.T) {
+	environmentLookup := MockEnvironmentLookup{Variables: map[string]string{"TAG": "1.0"}}
+	_, configs, _, _, err := Merge(NewServiceConfigs(), environmentLookup, &NullLookup{}, "", []byte(`
+version: '2'
+x-labels: &labels
+  com.example.tag: ${TAG}
+  com.example.team: core
+x-defaults: &defaults
+  image: busybox:${TAG}
+  restart: always
+  labels:
+    <<: *labels
+services:
+  web:
+    <<: *defaults
+    image: nginx
+    labels:
+      <<: *labels
+      com.example.team: web
+    x-no-interpolate:
+      - labels
+  db:
+    <<: *defaults
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := configs["web"]
+	if web.Image != "nginx" || web.Restart != "always" {
+		t.Fatalf("expected the anchored restart policy and the overridden image, got %q and %q", web.Restart, web.Image)
+	}
+	if web.Labels["com.example.team"] != "web" || web.Labels["com.example.tag"] != "${TAG}" {
+		t.Fatalf("expected the overridden and uninterpolated labels, got %v", web.Labels)
+	}
+
+	db := configs["db"]
+	if db.Image != "busybox:1.0" || db.Restart != "always" {
+		t.Fatalf("expected the anchored and interpolated image and restart policy, got %q and %q", db.Image, db.Restart)
+	}
+	if db.Labels["com.example.team"] != "core" || db.Labels["com.example.tag"] != "1.0" {
+		t.Fatalf("expected the nested anchored labels to be interpolated, got %v", db.Labels)
+	}
+}
+
+type mapLookup map[string]string
+
+func (m mapLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
+	content, ok := m[file]
+	if !ok {
+		return nil, "", fmt.Errorf("no such file %s", file)
+	}
+	return []byte(content), file, nil
+}
+
+func (m mapLookup) ResolvePath(path, inFile string) string {
+	return path
+}
+
+func TestExtendsAcrossFiles(t *testing.T) {
+	resourceLookup := mapLookup{
+		"common.yml": `
+version: '2'
+services:
+  base:
+    image: busybox
+    environment:
+      - A=a
+    links:
+      - db
+    volumes_from:
+      - db
+  db:
+    image: postgres
+  shortform:
+    extends: base
+    command: top
+`,
+	}
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, resourceLookup, "docker-compose.yml", []byte(`
+version: '2'
+services:
+  web:
+    extends:
+      file: common.yml
+      service: shortform
+    environment:
+      - B=b
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := configs["web"]
+	if web.Image != "busybox" || len(web.Command) != 1 || web.Command[0] != "top" {
+		t.Fatalf("expected the image and command of the extended services, got %q and %v", web.Image, web.Command)
+	}
+	if !reflect.DeepEqual(web.Environment, yaml.MaporEqualSlice{"A=a", "B=b"}) {
+		t.Fatalf("expected the merged environment, got %v", web.Environment)
+	}
+	if len(web.Links) != 0 || len(web.VolumesFrom) != 0 {
+		t.Fatalf("expected links and volumes_from not to be inherited, got %v and %v", web.Links, web.VolumesFrom)
+	}
+}
+
+func TestExtendsCircular(t *testing.T) {
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "docker-compose.yml", []byte(`
+version: '2'
+services:
+  a:
+    image: busybox
+    extends: b
+  b:
+    extends:
+      service: c
+  c:
+    extends:
+      service: a
+`), nil)
+	if err == nil || !strings.Contains(err.Error(), "Circular reference in extends") {
+		t.Fatalf("expected a circular reference error, got %v", err)
+	}
+
+	resourceLookup := mapLookup{
+		"other.yml": `
+version: '2'
+services:
+  base:
+    extends:
+      file: docker-compose.yml
+      service: web
+`,
+		"docker-compose.yml": `
+version: '2'
+services:
+  web:
+    image: busybox
+    extends:
+      file: other.yml
+      service: base
+`,
+	}
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, resourceLookup, "docker-compose.yml", []byte(resourceLookup["docker-compose.yml"]), nil)
+	if err == nil || !strings.Contains(err.Error(), "web (docker-compose.yml) extends base (other.yml) extends web (docker-compose.yml)") {
+		t.Fatalf("expected a circular reference error, got %v", err)
+	}
+}
+
+func TestMergeLogging(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), MockEnvironmentLookup{map[string]string{"LOG_SIZE": "10m"}}, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    logging:
+      driver: json-file
+      options:
+        max-size: ${LOG_SIZE}
+        max-file: 3
+  db:
+    image: busybox
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Log{
+		Driver: "json-file",
+		Options: map[string]string{
+			"max-size": "10m",
+			"max-file": "3",
+		},
+	}
+	if !reflect.DeepEqual(configs["web"].Logging, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].Logging)
+	}
+	if !reflect.DeepEqual(configs["db"].Logging, Log{}) {
+		t.Fatalf("Expected the daemon default logging, got %v", configs["db"].Logging)
+	}
+}
+
+func TestMergeSysctls(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  map:
+    image: busybox
+    sysctls:
+      net.core.somaxconn: 1024
+      net.ipv4.tcp_syncookies: "0"
+  list:
+    image: busybox
+    sysctls:
+      - net.core.somaxconn=1024
+      - net.ipv4.tcp_syncookies=0
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := yaml.SliceorMap{
+		"net.core.somaxconn":      "1024",
+		"net.ipv4.tcp_syncookies": "0",
+	}
+	for _, name := range []string{"map", "list"} {
+		if !reflect.DeepEqual(configs[name].Sysctls, expected) {
+			t.Fatalf("Expected %v for %s, got %v", expected, name, configs[name].Sysctls)
+		}
+	}
+}
+
+func TestMergeExtraHosts(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  map:
+    image: busybox
+    extra_hosts:
+      somehost: 162.242.195.82
+  list:
+    image: busybox
+    extra_hosts:
+      - somehost:162.242.195.82
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"map", "list"} {
+		if !reflect.DeepEqual(configs[name].ExtraHosts, yaml.MaporColonSlice{"somehost:162.242.195.82"}) {
+			t.Fatalf("Expected the extra host of %s, got %v", name, configs[name].ExtraHosts)
+		}
+	}
+}
+
+func TestMergeTmpfsLongSyntax(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    tmpfs:
+      - /tmp
+      - target: /run
+        tmpfs:
+          size: 100m
+          mode: 01777
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := yaml.Tmpfs{"/tmp", "/run:size=104857600,mode=1777"}
+	if !reflect.DeepEqual(configs["web"].Tmpfs, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].Tmpfs)
+	}
+}
+
+func TestMergeResourceLimits(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    mem_limit: 512m
+    memswap_limit: 1g
+    cpu_shares: 512
+    cpuset: "0,1"
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := configs["web"]
+	if web.MemLimit != 512*1024*1024 || web.MemSwapLimit != 1024*1024*1024 {
+		t.Fatalf("Invalid memory limits %d and %d", web.MemLimit, web.MemSwapLimit)
+	}
+	if web.CPUShares != 512 || web.CPUSet != "0,1" {
+		t.Fatalf("Invalid cpu settings %d and %s", web.CPUShares, web.CPUSet)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    mem_limit: 512m
+    memswap_limit: 256m
+`), nil)
+	if err == nil || !strings.Contains(err.Error(), "memswap_limit (268435456 bytes) must be greater than or equal to mem_limit (536870912 bytes)") {
+		t.Fatalf("Expected a memswap_limit error, got %v", err)
+	}
+}
+
+func TestMergeShmSize(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  db:
+    image: postgres
+    shm_size: 256m
+  cache:
+    image: redis
+    shm_size: 1g
+  raw:
+    image: busybox
+    shm_size: 1024
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]yaml.MemStringorInt{"db": 256 * 1024 * 1024, "cache": 1024 * 1024 * 1024, "raw": 1024} {
+		if configs[name].ShmSize != expected {
+			t.Fatalf("Expected a shm_size of %d for %s, got %d", expected, name, configs[name].ShmSize)
+		}
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  db:
+    image: postgres
+    shm_size: lots
+`), nil)
+	validationError, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if validationError.Service != "db" || validationError.Field != "shm_size" || validationError.Line != 6 {
+		t.Fatalf("Expected the error to locate the shm_size of db, got %#v", validationError)
+	}
+}
+
+func TestMergeReadOnlyAndInit(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  hardened:
+    image: busybox
+    read_only: true
+    init: true
+  noinit:
+    image: busybox
+    init: false
+  default:
+    image: busybox
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !configs["hardened"].ReadOnly || configs["hardened"].Init == nil || !*configs["hardened"].Init {
+		t.Fatalf("Expected read_only and init to be enabled, got %v and %v", configs["hardened"].ReadOnly, configs["hardened"].Init)
+	}
+	if configs["noinit"].Init == nil || *configs["noinit"].Init {
+		t.Fatalf("Expected init to be explicitly disabled, got %v", configs["noinit"].Init)
+	}
+	if configs["default"].ReadOnly || configs["default"].Init != nil {
+		t.Fatalf("Expected the defaults, got %v and %v", configs["default"].ReadOnly, configs["default"].Init)
+	}
+}
+
+func TestMergeOomAndSwappiness(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  tuned:
+    image: busybox
+    oom_kill_disable: true
+    oom_score_adj: -500
+    mem_swappiness: 0
+  default:
+    image: busybox
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tuned := configs["tuned"]
+	if tuned.OomKillDisable == nil || !*tuned.OomKillDisable || tuned.OomScoreAdj != -500 {
+		t.Fatalf("Expected oom_kill_disable and oom_score_adj to be set, got %v and %v", tuned.OomKillDisable, tuned.OomScoreAdj)
+	}
+	if tuned.MemSwappiness == nil || *tuned.MemSwappiness != 0 {
+		t.Fatalf("Expected mem_swappiness to be explicitly 0, got %v", tuned.MemSwappiness)
+	}
+	if configs["default"].OomKillDisable != nil || configs["default"].MemSwappiness != nil {
+		t.Fatalf("Expected the defaults, got %v and %v", configs["default"].OomKillDisable, configs["default"].MemSwappiness)
+	}
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    mem_swappiness: 101
+`), nil)
+	if err == nil || !strings.Contains(err.Error(), "configuration key 'mem_swappiness' is invalid: 101 is greater than the maximum 100") {
+		t.Fatalf("Expected an out of range mem_swappiness to be rejected, got %v", err)
+	}
+}
+
+func TestMergePidsLimit(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  limited:
+    image: busybox
+    privileged: true
+    cgroup_parent: /mygroup
+    pids_limit: 100
+  unlimited:
+    image: busybox
+    pids_limit: -1
+  default:
+    image: busybox
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limited := configs["limited"]
+	if !limited.Privileged || limited.CgroupParent != "/mygroup" || limited.PidsLimit == nil || *limited.PidsLimit != 100 {
+		t.Fatalf("Expected privileged, cgroup_parent and pids_limit to be set, got %v, %q and %v", limited.Privileged, limited.CgroupParent, limited.PidsLimit)
+	}
+	if configs["unlimited"].PidsLimit == nil || *configs["unlimited"].PidsLimit != -1 {
+		t.Fatalf("Expected an unlimited pids_limit, got %v", configs["unlimited"].PidsLimit)
+	}
+	if configs["default"].PidsLimit != nil {
+		t.Fatalf("Expected no pids_limit, got %v", *configs["default"].PidsLimit)
+	}
+}
+
+func TestMergeGroupAdd(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  player:
+    image: busybox
+    group_add:
+      - audio
+      - 1001
+      - "1002"
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"audio", "1001", "1002"}
+	if !reflect.DeepEqual(configs["player"].GroupAdd, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["player"].GroupAdd)
+	}
+}
+
+func TestMergeLabelsForms(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  map:
+    image: busybox
+    labels:
+      com.example.foo: bar
+      com.example.empty: ""
+  list:
+    image: busybox
+    labels:
+      - com.example.foo=bar
+      - com.example.empty
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := yaml.SliceorMap{"com.example.foo": "bar", "com.example.empty": ""}
+	for _, name := range []string{"map", "list"} {
+		if !reflect.DeepEqual(configs[name].Labels, expected) {
+			t.Fatalf("Expected %v for %s, got %v", expected, name, configs[name].Labels)
+		}
+	}
+}
+
+func TestMergeDuplicateContainerNames(t *testing.T) {
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    container_name: myapp
+  worker:
+    image: busybox
+    container_name: myapp
+`), nil)
+	validationError, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if validationError.Service != "worker" || validationError.Line != 9 || !strings.Contains(err.Error(), "'web' and 'worker' use the same container name 'myapp'") {
+		t.Fatalf("Unexpected error %#v", validationError)
+	}
+
+	// Across compose files, a service keeps its container name when overridden
+	existingServices := NewServiceConfigs()
+	existingServices.Add("web", &ServiceConfig{Image: "busybox", ContainerName: "myapp"})
+	_, _, _, _, err = Merge(existingServices, nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  web:
+    command: top
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, _, err = Merge(existingServices, nil, &NullLookup{}, "", []byte(`
+version: '2'
+services:
+  worker:
+    image: busybox
+    container_name: myapp
+`), nil)
+	if err == nil {
+		t.Fatal("Expected an error for a container name already used by the existing services")
+	}
+}
+
+func TestMergeListMergePolicy(t *testing.T) {
+	override := []byte(`
+version: '2'
+services:
+  web:
+    dns: [8.8.4.4]
+    environment:
+      - DEBUG=1
+    ports:
+      - "443:443"
+`)
+	merged := func(policy ListMergePolicy) *ServiceConfig {
+		existingServices := NewServiceConfigs()
+		existingServices.Add("web", &ServiceConfig{
+			Image:       "busybox",
+			DNS:         yaml.Stringorslice{"8.8.8.8"},
+			Environment: yaml.MaporEqualSlice{"MODE=production"},
+			Ports:       []string{"80:80"},
+		})
+		_, configs, _, _, err := Merge(existingServices, nil, &NullLookup{}, "", override, &ParseOptions{
+			Interpolate:     true,
+			Validate:        true,
+			ListMergePolicy: policy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return configs["web"]
+	}
+
+	expect := func(field string, actual, expected interface{}) {
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("Expected %s to be %v, got %v", field, expected, actual)
+		}
+	}
+
+	web := merged(ListMergePolicy{})
+	expect("DNS", web.DNS, yaml.Stringorslice{"8.8.8.8", "8.8.4.4"})
+	expect("Environment", web.Environment, yaml.MaporEqualSlice{"MODE=production", "DEBUG=1"})
+	expect("Ports", web.Ports, []string{"80:80", "443:443"})
+
+	web = merged(ListMergePolicy{Default: ListMergeReplace, Keys: map[string]ListMerge{"environment": ListMergeAppend}})
+	expect("DNS", web.DNS, yaml.Stringorslice{"8.8.4.4"})
+	expect("Environment", web.Environment, yaml.MaporEqualSlice{"MODE=production", "DEBUG=1"})
+	expect("Ports", web.Ports, []string{"443:443"})
+	expect("Image", web.Image, "busybox")
+
+	web = merged(ListMergePolicy{Keys: map[string]ListMerge{"ports": ListMergeReplace}})
+	expect("DNS", web.DNS, yaml.Stringorslice{"8.8.8.8", "8.8.4.4"})
+	expect("Ports", web.Ports, []string{"443:443"})
+}
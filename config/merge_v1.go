@@ -11,13 +11,13 @@ import (
 // MergeServicesV1 merges a v1 compose file into an existing set of service configs
 func MergeServicesV1(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup, resourceLookup ResourceLookup, file string, datas RawServiceMap, options *ParseOptions) (map[string]*ServiceConfigV1, error) {
 	if options.Validate {
-		if err := validate(datas); err != nil {
+		if err := validate(datas, !options.Interpolate); err != nil {
 			return nil, err
 		}
 	}
 
 	for name, data := range datas {
-		data, err := parseV1(resourceLookup, environmentLookup, file, data, datas, options)
+		data, err := parseV1(resourceLookup, environmentLookup, file, data, datas, options, extendsLink(file, name))
 		if err != nil {
 			logrus.Errorf("Failed to parse service %s: %v", name, err)
 			return nil, err
@@ -29,7 +29,7 @@ func MergeServicesV1(existingServices *ServiceConfigs, environmentLookup Environ
 				return nil, err
 			}
 
-			data = mergeConfigV1(rawExistingService, data)
+			data = mergeConfigV1(rawExistingService, data, options.ListMergePolicy)
 		}
 
 		datas[name] = data
@@ -44,6 +44,30 @@ func MergeServicesV1(existingServices *ServiceConfigs, environmentLookup Environ
 		}
 	}
 
+	if !options.Interpolate {
+		for name, data := range datas {
+			datas[name] = dropTypedVariables(data, func() interface{} { return &ServiceConfigV1{} })
+		}
+	}
+
+	for name, data := range datas {
+		if err := validateByteQuantities(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateMacAddress(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateSecurityOpts(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateExternalLinks(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateDNS(name, data); err != nil {
+			return nil, err
+		}
+	}
+
 	serviceConfigs := make(map[string]*ServiceConfigV1)
 	if err := utils.Convert(datas, &serviceConfigs); err != nil {
 		return nil, err
@@ -52,21 +76,20 @@ func MergeServicesV1(existingServices *ServiceConfigs, environmentLookup Environ
 	return serviceConfigs, nil
 }
 
-func parseV1(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup, inFile string, serviceData RawService, datas RawServiceMap, options *ParseOptions) (RawService, error) {
-	serviceData, err := readEnvFile(resourceLookup, inFile, serviceData)
+// parseV1 resolves the file references and the extends of the specified
+// service. chain holds the services being extended, to detect cycles.
+func parseV1(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup, inFile string, serviceData RawService, datas RawServiceMap, options *ParseOptions, chain ...string) (RawService, error) {
+	serviceData, err := readEnvFile(resourceLookup, environmentLookup, inFile, serviceData, options.SkipInterpolationKeys)
 	if err != nil {
 		return nil, err
 	}
 
 	serviceData = resolveContextV1(inFile, serviceData)
+	serviceData = resolveVolumes(resourceLookup, inFile, serviceData)
+	serviceData = resolveSecurityOpts(resourceLookup, inFile, serviceData)
 
-	value, ok := serviceData["extends"]
-	if !ok {
-		return serviceData, nil
-	}
-
-	mapValue, ok := value.(map[interface{}]interface{})
-	if !ok {
+	file, service := extendsOf(serviceData)
+	if service == "" {
 		return serviceData, nil
 	}
 
@@ -74,18 +97,18 @@ func parseV1(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup,
 		return nil, fmt.Errorf("Can not use extends in file %s no mechanism provided to files", inFile)
 	}
 
-	file := asString(mapValue["file"])
-	service := asString(mapValue["service"])
-
-	if service == "" {
-		return serviceData, nil
-	}
-
 	var baseService RawService
 
 	if file == "" {
 		if serviceData, ok := datas[service]; ok {
-			baseService, err = parseV1(resourceLookup, environmentLookup, inFile, serviceData, datas, options)
+			link, err := extendsChain(chain, inFile, service)
+			if err != nil {
+				return nil, err
+			}
+			baseService, err = parseV1(resourceLookup, environmentLookup, inFile, serviceData, datas, options, link...)
+			if err != nil {
+				return nil, err
+			}
 		} else {
 			return nil, fmt.Errorf("Failed to find service %s to extend", service)
 		}
@@ -103,7 +126,7 @@ func parseV1(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup,
 		baseRawServices := config.Services
 
 		if options.Interpolate {
-			if err = InterpolateRawServiceMap(&baseRawServices, environmentLookup); err != nil {
+			if err = interpolateRawServiceMap(&baseRawServices, environmentLookup, options.SkipInterpolationKeys); err != nil {
 				return nil, err
 			}
 		}
@@ -117,38 +140,37 @@ func parseV1(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup,
 		}
 
 		if options.Validate {
-			if err := validate(baseRawServices); err != nil {
+			if err := validate(baseRawServices, !options.Interpolate); err != nil {
 				return nil, err
 			}
 		}
 
+		var ok bool
 		baseService, ok = baseRawServices[service]
 		if !ok {
 			return nil, fmt.Errorf("Failed to find service %s in file %s", service, file)
 		}
 
-		baseService, err = parseV1(resourceLookup, environmentLookup, resolved, baseService, baseRawServices, options)
-	}
-
-	if err != nil {
-		return nil, err
+		link, err := extendsChain(chain, resolved, service)
+		if err != nil {
+			return nil, err
+		}
+		baseService, err = parseV1(resourceLookup, environmentLookup, resolved, baseService, baseRawServices, options, link...)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	baseService = clone(baseService)
 
 	logrus.Debugf("Merging %#v, %#v", baseService, serviceData)
 
+	// Links to other services are never inherited
 	for _, k := range noMerge {
-		if _, ok := baseService[k]; ok {
-			source := file
-			if source == "" {
-				source = inFile
-			}
-			return nil, fmt.Errorf("Cannot extend service '%s' in %s: services with '%s' cannot be extended", service, source, k)
-		}
+		delete(baseService, k)
 	}
 
-	baseService = mergeConfigV1(baseService, serviceData)
+	baseService = mergeExtendedConfig(dropImageOrBuild(baseService, serviceData), serviceData)
 
 	logrus.Debugf("Merged result %#v", baseService)
 
@@ -178,20 +200,18 @@ func resolveContextV1(inFile string, serviceData RawService) RawService {
 	return serviceData
 }
 
-func mergeConfigV1(baseService, serviceData RawService) RawService {
-	for k, v := range serviceData {
-		// Image and build are mutually exclusive in merge
-		if k == "image" {
-			delete(baseService, "build")
-		} else if k == "build" {
-			delete(baseService, "image")
-		}
-		existing, ok := baseService[k]
-		if ok {
-			baseService[k] = merge(existing, v)
-		} else {
-			baseService[k] = v
-		}
+func mergeConfigV1(baseService, serviceData RawService, policy ListMergePolicy) RawService {
+	return mergeConfig(dropImageOrBuild(baseService, serviceData), serviceData, policy)
+}
+
+// dropImageOrBuild removes build (resp. image) from the base service if the
+// service sets image (resp. build), as they are mutually exclusive in v1.
+func dropImageOrBuild(baseService, serviceData RawService) RawService {
+	if _, ok := serviceData["image"]; ok {
+		delete(baseService, "build")
+	}
+	if _, ok := serviceData["build"]; ok {
+		delete(baseService, "image")
 	}
 
 	return baseService
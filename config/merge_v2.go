@@ -12,13 +12,13 @@ import (
 // MergeServicesV2 merges a v2 compose file into an existing set of service configs
 func MergeServicesV2(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup, resourceLookup ResourceLookup, file string, datas RawServiceMap, options *ParseOptions) (map[string]*ServiceConfig, error) {
 	if options.Validate {
-		if err := validateV2(datas); err != nil {
+		if err := validateV2(datas, !options.Interpolate); err != nil {
 			return nil, err
 		}
 	}
 
 	for name, data := range datas {
-		data, err := parseV2(resourceLookup, environmentLookup, file, data, datas, options)
+		data, err := parseV2(resourceLookup, environmentLookup, file, data, datas, options, extendsLink(file, name))
 		if err != nil {
 			logrus.Errorf("Failed to parse service %s: %v", name, err)
 			return nil, err
@@ -30,7 +30,7 @@ func MergeServicesV2(existingServices *ServiceConfigs, environmentLookup Environ
 				return nil, err
 			}
 
-			data = mergeConfig(rawExistingService, data)
+			data = mergeConfig(rawExistingService, data, options.ListMergePolicy)
 		}
 
 		datas[name] = data
@@ -49,6 +49,39 @@ func MergeServicesV2(existingServices *ServiceConfigs, environmentLookup Environ
 		}
 	}
 
+	if !options.Interpolate {
+		for name, data := range datas {
+			datas[name] = dropTypedVariables(data, func() interface{} { return &ServiceConfig{} })
+		}
+	}
+
+	for name, data := range datas {
+		if err := validateByteQuantities(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateDurations(name, data); err != nil {
+			return nil, err
+		}
+		if err := validatePlatforms(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateRuntime(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateMacAddress(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateSecurityOpts(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateExternalLinks(name, data); err != nil {
+			return nil, err
+		}
+		if err := validateDNS(name, data); err != nil {
+			return nil, err
+		}
+	}
+
 	serviceConfigs := make(map[string]*ServiceConfig)
 	if err := utils.Convert(datas, &serviceConfigs); err != nil {
 		return nil, err
@@ -57,21 +90,29 @@ func MergeServicesV2(existingServices *ServiceConfigs, environmentLookup Environ
 	return serviceConfigs, nil
 }
 
-func parseV2(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup, inFile string, serviceData RawService, datas RawServiceMap, options *ParseOptions) (RawService, error) {
-	serviceData, err := readEnvFile(resourceLookup, inFile, serviceData)
+// parseV2 resolves the file references and the extends of the specified
+// service. chain holds the services being extended, to detect cycles.
+func parseV2(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup, inFile string, serviceData RawService, datas RawServiceMap, options *ParseOptions, chain ...string) (RawService, error) {
+	serviceData, err := readEnvFile(resourceLookup, environmentLookup, inFile, serviceData, options.SkipInterpolationKeys)
 	if err != nil {
 		return nil, err
 	}
 
-	serviceData = resolveContextV2(inFile, serviceData)
+	serviceData, err = readLabelFile(resourceLookup, inFile, serviceData)
+	if err != nil {
+		return nil, err
+	}
 
-	value, ok := serviceData["extends"]
-	if !ok {
-		return serviceData, nil
+	serviceData = resolveContextV2(inFile, serviceData)
+	serviceData = resolveVolumes(resourceLookup, inFile, serviceData)
+	serviceData = resolveSecurityOpts(resourceLookup, inFile, serviceData)
+	serviceData, err = resolveDevelopV2(inFile, serviceData)
+	if err != nil {
+		return nil, err
 	}
 
-	mapValue, ok := value.(map[interface{}]interface{})
-	if !ok {
+	file, service := extendsOf(serviceData)
+	if service == "" {
 		return serviceData, nil
 	}
 
@@ -79,18 +120,18 @@ func parseV2(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup,
 		return nil, fmt.Errorf("Can not use extends in file %s no mechanism provided to files", inFile)
 	}
 
-	file := asString(mapValue["file"])
-	service := asString(mapValue["service"])
-
-	if service == "" {
-		return serviceData, nil
-	}
-
 	var baseService RawService
 
 	if file == "" {
 		if serviceData, ok := datas[service]; ok {
-			baseService, err = parseV2(resourceLookup, environmentLookup, inFile, serviceData, datas, options)
+			link, err := extendsChain(chain, inFile, service)
+			if err != nil {
+				return nil, err
+			}
+			baseService, err = parseV2(resourceLookup, environmentLookup, inFile, serviceData, datas, options, link...)
+			if err != nil {
+				return nil, err
+			}
 		} else {
 			return nil, fmt.Errorf("Failed to find service %s to extend", service)
 		}
@@ -108,44 +149,43 @@ func parseV2(resourceLookup ResourceLookup, environmentLookup EnvironmentLookup,
 		baseRawServices := config.Services
 
 		if options.Interpolate {
-			if err = InterpolateRawServiceMap(&baseRawServices, environmentLookup); err != nil {
+			if err = interpolateRawServiceMap(&baseRawServices, environmentLookup, options.SkipInterpolationKeys); err != nil {
 				return nil, err
 			}
 		}
 
 		if options.Validate {
-			if err := validateV2(baseRawServices); err != nil {
+			if err := validateV2(baseRawServices, !options.Interpolate); err != nil {
 				return nil, err
 			}
 		}
 
+		var ok bool
 		baseService, ok = baseRawServices[service]
 		if !ok {
 			return nil, fmt.Errorf("Failed to find service %s in file %s", service, file)
 		}
 
-		baseService, err = parseV2(resourceLookup, environmentLookup, resolved, baseService, baseRawServices, options)
-	}
-
-	if err != nil {
-		return nil, err
+		link, err := extendsChain(chain, resolved, service)
+		if err != nil {
+			return nil, err
+		}
+		baseService, err = parseV2(resourceLookup, environmentLookup, resolved, baseService, baseRawServices, options, link...)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	baseService = clone(baseService)
 
 	logrus.Debugf("Merging %#v, %#v", baseService, serviceData)
 
+	// Links to other services are never inherited
 	for _, k := range noMerge {
-		if _, ok := baseService[k]; ok {
-			source := file
-			if source == "" {
-				source = inFile
-			}
-			return nil, fmt.Errorf("Cannot extend service '%s' in %s: services with '%s' cannot be extended", service, source, k)
-		}
+		delete(baseService, k)
 	}
 
-	baseService = mergeConfig(baseService, serviceData)
+	baseService = mergeExtendedConfig(baseService, serviceData)
 
 	logrus.Debugf("Merged result %#v", baseService)
 
@@ -190,3 +230,22 @@ func resolveContextV2(inFile string, serviceData RawService) RawService {
 
 	return serviceData
 }
+
+// resolveDevelopV2 makes the develop.watch paths relative to the directory of
+// the compose file they are defined in.
+func resolveDevelopV2(inFile string, serviceData RawService) (RawService, error) {
+	if _, ok := serviceData["develop"]; !ok {
+		return serviceData, nil
+	}
+	var develop DevelopConfig
+	if err := utils.Convert(serviceData["develop"], &develop); err != nil {
+		return nil, err
+	}
+	for i, rule := range develop.Watch {
+		if rule.Path != "" && !path.IsAbs(rule.Path) {
+			develop.Watch[i].Path = path.Join(path.Dir(inFile), rule.Path)
+		}
+	}
+	serviceData["develop"] = develop
+	return serviceData, nil
+}
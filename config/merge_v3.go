@@ -0,0 +1,357 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/zengchen221/libcompose/utils"
+	"github.com/zengchen221/libcompose/yaml"
+)
+
+var (
+	// supportedDeployKeys are the deploy settings that make sense for
+	// containers run on a single engine, others are swarm specific.
+	supportedDeployKeys = map[string]bool{
+		"mode":           true,
+		"replicas":       true,
+		"resources":      true,
+		"restart_policy": true,
+	}
+	// swarmDeployKeys are the deploy settings that only make sense for a
+	// swarm, they are ignored with a warning.
+	swarmDeployKeys = map[string]bool{
+		"endpoint_mode":   true,
+		"labels":          true,
+		"placement":       true,
+		"rollback_config": true,
+		"update_config":   true,
+	}
+	supportedRestartPolicyKeys = map[string]bool{
+		"condition":    true,
+		"max_attempts": true,
+	}
+	// swarmRestartPolicyKeys are the durations of a restart policy that only
+	// make sense for a swarm, they are checked and ignored with a warning.
+	swarmRestartPolicyKeys = map[string]bool{
+		"delay":  true,
+		"window": true,
+	}
+)
+
+// deployConfig holds the supported subset of the v3 deploy section of a
+// service.
+type deployConfig struct {
+	Mode      string `yaml:"mode,omitempty"`
+	Replicas  *int   `yaml:"replicas,omitempty"`
+	Resources struct {
+		Limits struct {
+			CPUs   string      `yaml:"cpus,omitempty"`
+			Memory interface{} `yaml:"memory,omitempty"`
+		} `yaml:"limits,omitempty"`
+		Reservations struct {
+			CPUs    string              `yaml:"cpus,omitempty"`
+			Memory  interface{}         `yaml:"memory,omitempty"`
+			Devices []deviceReservation `yaml:"devices,omitempty"`
+		} `yaml:"reservations,omitempty"`
+	} `yaml:"resources,omitempty"`
+	RestartPolicy struct {
+		Condition   string `yaml:"condition,omitempty"`
+		MaxAttempts *int   `yaml:"max_attempts,omitempty"`
+	} `yaml:"restart_policy,omitempty"`
+}
+
+// deviceReservation holds a device of the deploy.resources.reservations
+// section of a service. Only GPUs, which translate into the gpus key, are
+// supported.
+type deviceReservation struct {
+	Capabilities []string          `yaml:"capabilities,omitempty"`
+	Driver       string            `yaml:"driver,omitempty"`
+	Count        interface{}       `yaml:"count,omitempty"`
+	DeviceIDs    []string          `yaml:"device_ids,omitempty"`
+	Options      map[string]string `yaml:"options,omitempty"`
+}
+
+// fileReference holds the long syntax of a config or secret granted to a
+// service.
+type fileReference struct {
+	Source string      `yaml:"source,omitempty"`
+	Target string      `yaml:"target,omitempty"`
+	UID    string      `yaml:"uid,omitempty"`
+	GID    string      `yaml:"gid,omitempty"`
+	Mode   interface{} `yaml:"mode,omitempty"`
+}
+
+// MergeServicesV3 merges a v3 compose file into an existing set of service
+// configs. The v3 specific service keys are translated into their v2
+// counterparts: the supported deploy settings become resource limits and a
+// restart policy, and configs and secrets (which must be defined with a file
+// in the configs and secrets top-level sections) are bind mounted read-only
+// into the containers. The swarm scheduling settings (replicas, placement…)
+// are ignored with a warning, anything else that can't be honored without a
+// swarm is reported as an error. The result is then merged as a v2 file.
+func MergeServicesV3(existingServices *ServiceConfigs, environmentLookup EnvironmentLookup, resourceLookup ResourceLookup, file string, datas RawServiceMap, configs, secrets map[string]interface{}, options *ParseOptions) (map[string]*ServiceConfig, error) {
+	configFiles, err := parseFileObjects(resourceLookup, "config", file, configs)
+	if err != nil {
+		return nil, err
+	}
+	secretFiles, err := parseFileObjects(resourceLookup, "secret", file, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, data := range datas {
+		if err := translateDeploy(name, data, options); err != nil {
+			return nil, err
+		}
+		if err := mountFileReferences(name, data, "configs", configFiles, "/", options); err != nil {
+			return nil, err
+		}
+		if err := mountFileReferences(name, data, "secrets", secretFiles, "/run/secrets/", options); err != nil {
+			return nil, err
+		}
+	}
+
+	return MergeServicesV2(existingServices, environmentLookup, resourceLookup, file, datas, options)
+}
+
+// parseFileObjects returns the files of the specified top-level configs or
+// secrets by name, relative to the directory of the compose file. The files
+// must exist, as looked up with the resource lookup, so that a missing one
+// isn't silently replaced by an empty directory when bind mounted.
+func parseFileObjects(resourceLookup ResourceLookup, kind, inFile string, objects map[string]interface{}) (map[string]string, error) {
+	files := map[string]string{}
+	for name, data := range objects {
+		var object ConfigConfig
+		if err := utils.Convert(data, &object); err != nil {
+			return nil, fmt.Errorf("Invalid %s '%s': %v", kind, name, err)
+		}
+		if object.External.External || object.External.Name != "" {
+			return nil, fmt.Errorf("External %s '%s' is not supported, only %ss defined with a file are", kind, name, kind)
+		}
+		if object.File == "" {
+			return nil, fmt.Errorf("Invalid %s '%s': a file must be specified", kind, name)
+		}
+		file := object.File
+		if !path.IsAbs(file) {
+			file = path.Join(path.Dir(inFile), file)
+			if !path.IsAbs(file) && !strings.HasPrefix(file, ".") {
+				file = "./" + file
+			}
+		}
+		if resourceLookup != nil && !isRemoteFile(inFile) {
+			if _, _, err := resourceLookup.Lookup(object.File, inFile); err != nil {
+				return nil, fmt.Errorf("Invalid %s '%s': %v", kind, name, err)
+			}
+		}
+		files[name] = file
+	}
+	return files, nil
+}
+
+// translateDeploy replaces the deploy section of the specified service by
+// the equivalent v2 keys.
+func translateDeploy(name string, service RawService, options *ParseOptions) error {
+	value, ok := service["deploy"]
+	if !ok {
+		return nil
+	}
+	delete(service, "deploy")
+
+	if raw, ok := value.(map[interface{}]interface{}); ok {
+		for key, value := range raw {
+			if swarmDeployKeys[asString(key)] {
+				options.warn(ConfigWarning{
+					Service: name,
+					Field:   fmt.Sprintf("deploy.%v", key),
+					Message: fmt.Sprintf("Service '%s' configuration key 'deploy.%v' only applies to swarms, it is ignored", name, key),
+				})
+				delete(raw, key)
+				continue
+			}
+			if !supportedDeployKeys[asString(key)] {
+				return fmt.Errorf("Service '%s' configuration key 'deploy.%v' is not supported", name, key)
+			}
+			if key == "restart_policy" {
+				policy, _ := value.(map[interface{}]interface{})
+				for policyKey, policyValue := range policy {
+					if swarmRestartPolicyKeys[asString(policyKey)] {
+						if duration, ok := policyValue.(string); ok {
+							if _, err := yaml.ParseDuration(duration); err != nil {
+								return fmt.Errorf("Service '%s' configuration key 'deploy.restart_policy.%v' is invalid: %v", name, policyKey, err)
+							}
+						}
+						options.warn(ConfigWarning{
+							Service: name,
+							Field:   fmt.Sprintf("deploy.restart_policy.%v", policyKey),
+							Message: fmt.Sprintf("Service '%s' configuration key 'deploy.restart_policy.%v' only applies to swarms, it is ignored", name, policyKey),
+						})
+						delete(policy, policyKey)
+						continue
+					}
+					if !supportedRestartPolicyKeys[asString(policyKey)] {
+						return fmt.Errorf("Service '%s' configuration key 'deploy.restart_policy.%v' is not supported", name, policyKey)
+					}
+				}
+			}
+		}
+	}
+
+	var deploy deployConfig
+	if err := utils.Convert(value, &deploy); err != nil {
+		return fmt.Errorf("Service '%s' configuration key 'deploy' is invalid: %v", name, err)
+	}
+
+	switch deploy.Mode {
+	case "", "replicated":
+	default:
+		return fmt.Errorf("Service '%s' configuration key 'deploy.mode' is not supported: %s", name, deploy.Mode)
+	}
+	if deploy.Replicas != nil && *deploy.Replicas != 1 {
+		options.warn(ConfigWarning{
+			Service: name,
+			Field:   "deploy.replicas",
+			Message: fmt.Sprintf("Service '%s' configuration key 'deploy.replicas' is ignored, use scale instead", name),
+		})
+	}
+
+	limits := deploy.Resources.Limits
+	if limits.CPUs != "" {
+		cpus, err := strconv.ParseFloat(limits.CPUs, 64)
+		if err != nil || cpus <= 0 {
+			return fmt.Errorf("Service '%s' configuration key 'deploy.resources.limits.cpus' is invalid: %s", name, limits.CPUs)
+		}
+		service["cpus"] = cpus
+	}
+	if deploy.Resources.Reservations.CPUs != "" {
+		options.warn(ConfigWarning{
+			Service: name,
+			Field:   "deploy.resources.reservations.cpus",
+			Message: fmt.Sprintf("Service '%s' configuration key 'deploy.resources.reservations.cpus' only applies to swarms, it is ignored", name),
+		})
+	}
+	if limits.Memory != nil {
+		service["mem_limit"] = limits.Memory
+	}
+	if memory := deploy.Resources.Reservations.Memory; memory != nil {
+		service["mem_reservation"] = memory
+	}
+	if devices := deploy.Resources.Reservations.Devices; len(devices) > 0 {
+		gpus, err := reservedGPUs(devices)
+		if err != nil {
+			return fmt.Errorf("Service '%s' configuration key 'deploy.resources.reservations.devices' is not supported: %v", name, err)
+		}
+		service["gpus"] = gpus
+	}
+
+	policy := deploy.RestartPolicy
+	switch policy.Condition {
+	case "":
+	case "none":
+		service["restart"] = "no"
+	case "any":
+		service["restart"] = "always"
+	case "on-failure":
+		service["restart"] = "on-failure"
+		if policy.MaxAttempts != nil {
+			service["restart"] = fmt.Sprintf("on-failure:%d", *policy.MaxAttempts)
+		}
+	default:
+		return fmt.Errorf("Service '%s' configuration key 'deploy.restart_policy.condition' is invalid: %s", name, policy.Condition)
+	}
+
+	return nil
+}
+
+// reservedGPUs returns the gpus value (a count or "all") equivalent to the
+// specified device reservations, which must be a single reservation of GPUs
+// by count. Without a count, all the GPUs are reserved.
+func reservedGPUs(devices []deviceReservation) (interface{}, error) {
+	if len(devices) != 1 {
+		return nil, fmt.Errorf("only a single reservation of GPUs is supported")
+	}
+	device := devices[0]
+	if len(device.Capabilities) != 1 || device.Capabilities[0] != "gpu" {
+		return nil, fmt.Errorf("only the gpu capability is supported, got %v", device.Capabilities)
+	}
+	if device.Driver != "" && device.Driver != "nvidia" {
+		return nil, fmt.Errorf("only the nvidia driver is supported, got %s", device.Driver)
+	}
+	if len(device.DeviceIDs) > 0 || len(device.Options) > 0 {
+		return nil, fmt.Errorf("device_ids and options are not supported, only a count of GPUs is")
+	}
+	switch count := device.Count.(type) {
+	case nil:
+		return "all", nil
+	case int:
+		if count <= 0 {
+			return nil, fmt.Errorf("invalid count %d", count)
+		}
+		return count, nil
+	case string:
+		if count == "all" {
+			return count, nil
+		}
+		if n, err := strconv.Atoi(count); err == nil && n > 0 {
+			return n, nil
+		}
+		return nil, fmt.Errorf("invalid count %q, expected \"all\" or a number of GPUs", count)
+	default:
+		return nil, fmt.Errorf("invalid count %v, expected \"all\" or a number of GPUs", count)
+	}
+}
+
+// mountFileReferences replaces the configs or secrets granted to the
+// specified service by read-only bind mounts of their files, under
+// targetDir unless an absolute target is given.
+func mountFileReferences(name string, service RawService, key string, files map[string]string, targetDir string, options *ParseOptions) error {
+	value, ok := service[key]
+	if !ok {
+		return nil
+	}
+	delete(service, key)
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("Service '%s' configuration key '%s' is invalid: must be a list", name, key)
+	}
+
+	volumes, _ := service["volumes"].([]interface{})
+	for _, item := range list {
+		var reference fileReference
+		if source, ok := item.(string); ok {
+			reference.Source = source
+		} else if err := utils.Convert(item, &reference); err != nil {
+			return fmt.Errorf("Service '%s' configuration key '%s' is invalid: %v", name, key, err)
+		}
+		if reference.UID != "" || reference.GID != "" || reference.Mode != nil {
+			options.warn(ConfigWarning{
+				Service: name,
+				Field:   key,
+				Message: fmt.Sprintf("Service '%s' configuration key '%s': uid, gid and mode can't be set on the bind mounted file of %s, they are ignored", name, key, reference.Source),
+			})
+		}
+		file, ok := files[reference.Source]
+		if !ok {
+			return fmt.Errorf("Service '%s' references undefined %s '%s'", name, strings.TrimSuffix(key, "s"), reference.Source)
+		}
+		target := reference.Target
+		if target == "" {
+			target = reference.Source
+		}
+		if !path.IsAbs(target) {
+			target = targetDir + target
+		}
+		volumes = append(volumes, fmt.Sprintf("%s:%s:ro", file, target))
+	}
+	service["volumes"] = volumes
+
+	return nil
+}
+
+// isRemoteFile returns whether the specified compose file was fetched over
+// http(s), in which case the files it references can't be checked.
+func isRemoteFile(file string) bool {
+	return strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://")
+}
@@ -36,6 +36,7 @@ var schemaDataV1 = `{
         "cpuset": {"type": "string"},
         "devices": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
         "dns": {"$ref": "#/definitions/string_or_list"},
+        "dns_opt": {"$ref": "#/definitions/string_or_list"},
         "dns_search": {"$ref": "#/definitions/string_or_list"},
         "dockerfile": {"type": "string"},
         "domainname": {"type": "string"},
@@ -80,6 +81,7 @@ var schemaDataV1 = `{
         "hostname": {"type": "string"},
         "image": {"type": "string"},
         "ipc": {"type": "string"},
+        "isolation": {"type": "string"},
         "labels": {"$ref": "#/definitions/list_or_dict"},
         "links": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
         "log_driver": {"type": "string"},
@@ -88,8 +90,10 @@ var schemaDataV1 = `{
         "mem_limit": {"type": ["number", "string"]},
         "mem_reservation": {"type": ["number", "string"]},
         "memswap_limit": {"type": ["number", "string"]},
-        "mem_swappiness": {"type": "integer"},
+        "mem_swappiness": {"type": "integer", "minimum": 0, "maximum": 100},
         "net": {"type": "string"},
+        "oom_kill_disable": {"type": "boolean"},
+        "oom_score_adj": {"type": "integer", "minimum": -1000, "maximum": 1000},
         "pid": {"type": ["string", "null"]},
 
         "ports": {
@@ -132,7 +136,8 @@ var schemaDataV1 = `{
         "volumes": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
         "volume_driver": {"type": "string"},
         "volumes_from": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
-        "working_dir": {"type": "string"}
+        "working_dir": {"type": "string"},
+        "x-no-interpolate": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
       },
 
       "dependencies": {
@@ -211,6 +216,20 @@ var servicesSchemaDataV2 = `{
       "type": "object",
 
       "properties": {
+        "annotations": {"$ref": "#/definitions/list_or_dict"},
+        "attach": {"type": "boolean"},
+        "blkio_config": {
+          "type": "object",
+          "properties": {
+            "weight": {"type": "integer"},
+            "weight_device": {"type": "array", "items": {"$ref": "#/definitions/blkio_weight"}},
+            "device_read_bps": {"type": "array", "items": {"$ref": "#/definitions/blkio_limit"}},
+            "device_write_bps": {"type": "array", "items": {"$ref": "#/definitions/blkio_limit"}},
+            "device_read_iops": {"type": "array", "items": {"$ref": "#/definitions/blkio_iops_limit"}},
+            "device_write_iops": {"type": "array", "items": {"$ref": "#/definitions/blkio_iops_limit"}}
+          },
+          "additionalProperties": false
+        },
         "build": {
           "oneOf": [
             {"type": "string"},
@@ -223,6 +242,10 @@ var servicesSchemaDataV2 = `{
                 "cache_from": {"$ref": "#/definitions/list_of_strings"},
                 "labels": {"$ref": "#/definitions/list_or_dict"},
                 "network": {"type": "string"},
+                "no_cache": {"type": "boolean"},
+                "platform": {"type": "string"},
+                "pull": {"type": "boolean"},
+                "shm_size": {"type": ["integer", "string"]},
                 "target": {"type": "string"}
               },
               "additionalProperties": false
@@ -239,12 +262,57 @@ var servicesSchemaDataV2 = `{
           ]
         },
         "container_name": {"type": "string"},
+        "cpu_count": {"type": "integer", "minimum": 0},
+        "cpu_percent": {"type": "integer", "minimum": 0, "maximum": 100},
         "cpu_shares": {"type": ["number", "string"]},
         "cpu_quota": {"type": ["number", "string"]},
+        "cpus": {"type": "number", "minimum": 0},
         "cpuset": {"type": "string"},
-        "depends_on": {"$ref": "#/definitions/list_of_strings"},
+        "depends_on": {
+          "oneOf": [
+            {"$ref": "#/definitions/list_of_strings"},
+            {
+              "type": "object",
+              "patternProperties": {
+                "^[a-zA-Z0-9._-]+$": {
+                  "type": ["object", "null"],
+                  "properties": {
+                    "condition": {"type": "string", "enum": ["service_started", "service_healthy", "service_completed_successfully"]},
+                    "restart": {"type": "boolean"}
+                  },
+                  "additionalProperties": false
+                }
+              },
+              "additionalProperties": false
+            }
+          ]
+        },
         "devices": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+        "device_cgroup_rules": {"$ref": "#/definitions/list_of_strings"},
+
+        "develop": {
+          "type": "object",
+          "properties": {
+            "watch": {
+              "type": "array",
+              "items": {
+                "type": "object",
+                "properties": {
+                  "path": {"type": "string"},
+                  "action": {"type": "string", "enum": ["rebuild", "sync", "restart", "sync+restart"]},
+                  "target": {"type": "string"},
+                  "ignore": {"$ref": "#/definitions/list_of_strings"}
+                },
+                "required": ["path", "action"],
+                "additionalProperties": false
+              }
+            }
+          },
+          "additionalProperties": false
+        },
+
         "dns": {"$ref": "#/definitions/string_or_list"},
+        "dns_opt": {"$ref": "#/definitions/string_or_list"},
         "dns_search": {"$ref": "#/definitions/string_or_list"},
         "domainname": {"type": "string"},
         "entrypoint": {
@@ -253,7 +321,28 @@ var servicesSchemaDataV2 = `{
             {"type": "array", "items": {"type": "string"}}
           ]
         },
-        "env_file": {"$ref": "#/definitions/string_or_list"},
+        "env_file": {
+          "oneOf": [
+            {"type": "string"},
+            {
+              "type": "array",
+              "items": {
+                "oneOf": [
+                  {"type": "string"},
+                  {
+                    "type": "object",
+                    "properties": {
+                      "path": {"type": "string"},
+                      "required": {"type": "boolean"}
+                    },
+                    "required": ["path"],
+                    "additionalProperties": false
+                  }
+                ]
+              }
+            }
+          ]
+        },
         "environment": {"$ref": "#/definitions/list_or_dict"},
 
         "expose": {
@@ -285,11 +374,16 @@ var servicesSchemaDataV2 = `{
 
         "external_links": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
         "extra_hosts": {"$ref": "#/definitions/list_or_dict"},
-        "group_add": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+        "gpus": {"type": ["string", "integer"]},
+        "group_add": {"type": "array", "items": {"type": ["string", "number"]}, "uniqueItems": true},
+        "healthcheck": {"$ref": "#/definitions/healthcheck"},
         "hostname": {"type": "string"},
         "image": {"type": "string"},
+        "init": {"type": "boolean"},
         "ipc": {"type": "string"},
+        "isolation": {"type": "string"},
         "labels": {"$ref": "#/definitions/list_or_dict"},
+        "label_file": {"$ref": "#/definitions/string_or_list"},
         "links": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
 
         "logging": {
@@ -306,7 +400,7 @@ var servicesSchemaDataV2 = `{
         "mem_limit": {"type": ["number", "string"]},
         "mem_reservation": {"type": ["number", "string"]},
         "memswap_limit": {"type": ["number", "string"]},
-        "mem_swappiness": {"type": "integer"},
+        "mem_swappiness": {"type": "integer", "minimum": 0, "maximum": 100},
         "network_mode": {"type": "string"},
 
         "networks": {
@@ -322,7 +416,8 @@ var servicesSchemaDataV2 = `{
                       "properties": {
                         "aliases": {"$ref": "#/definitions/list_of_strings"},
                         "ipv4_address": {"type": "string"},
-                        "ipv6_address": {"type": "string"}
+                        "ipv6_address": {"type": "string"},
+                        "link_local_ips": {"$ref": "#/definitions/list_of_strings"}
                       },
                       "additionalProperties": false
                     },
@@ -334,8 +429,11 @@ var servicesSchemaDataV2 = `{
             }
           ]
         },
+        "oom_kill_disable": {"type": "boolean"},
         "oom_score_adj": {"type": "integer", "minimum": -1000, "maximum": 1000},
         "pid": {"type": ["string", "null"]},
+        "pids_limit": {"type": ["number", "string"]},
+        "platform": {"type": "string"},
 
         "ports": {
           "type": "array",
@@ -346,15 +444,35 @@ var servicesSchemaDataV2 = `{
           "uniqueItems": true
         },
 
+        "post_start": {"type": "array", "items": {"$ref": "#/definitions/service_hook"}},
+        "pre_stop": {"type": "array", "items": {"$ref": "#/definitions/service_hook"}},
         "privileged": {"type": "boolean"},
+        "profiles": {"$ref": "#/definitions/list_of_strings"},
+        "pull_policy": {"type": "string"},
         "read_only": {"type": "boolean"},
         "restart": {"type": "string"},
+        "runtime": {"type": "string"},
         "security_opt": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
         "shm_size": {"type": ["number", "string"]},
         "stdin_open": {"type": "boolean"},
-        "stop_grace_period": {"type": "string"},
+        "stop_grace_period": {"type": ["string", "number"]},
         "stop_signal": {"type": "string"},
-        "tmpfs": {"$ref": "#/definitions/string_or_list"},
+        "sysctls": {"$ref": "#/definitions/list_or_dict"},
+        "tmpfs": {
+          "oneOf": [
+            {"type": "string"},
+            {"$ref": "#/definitions/tmpfs_mount"},
+            {
+              "type": "array",
+              "items": {
+                "oneOf": [
+                  {"type": "string"},
+                  {"$ref": "#/definitions/tmpfs_mount"}
+                ]
+              }
+            }
+          ]
+        },
         "tty": {"type": "boolean"},
         "ulimits": {
           "type": "object",
@@ -376,10 +494,47 @@ var servicesSchemaDataV2 = `{
           }
         },
         "user": {"type": "string"},
-        "volumes": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+        "userns_mode": {"type": "string"},
+        "volumes": {
+          "type": "array",
+          "items": {
+            "oneOf": [
+              {"type": "string"},
+              {
+                "type": "object",
+                "required": ["type", "target"],
+                "properties": {
+                  "type": {"type": "string", "enum": ["bind", "volume", "tmpfs"]},
+                  "source": {"type": "string"},
+                  "target": {"type": "string"},
+                  "read_only": {"type": "boolean"},
+                  "consistency": {"type": "string"},
+                  "bind": {
+                    "type": "object",
+                    "properties": {
+                      "propagation": {"type": "string"}
+                    },
+                    "additionalProperties": false
+                  },
+                  "volume": {
+                    "type": "object",
+                    "properties": {
+                      "nocopy": {"type": "boolean"}
+                    },
+                    "additionalProperties": false
+                  },
+                  "tmpfs": {"$ref": "#/definitions/tmpfs_options"}
+                },
+                "additionalProperties": false
+              }
+            ]
+          },
+          "uniqueItems": true
+        },
         "volume_driver": {"type": "string"},
         "volumes_from": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
-        "working_dir": {"type": "string"}
+        "working_dir": {"type": "string"},
+        "x-no-interpolate": {"type": "array", "items": {"type": "string"}, "uniqueItems": true}
       },
 
       "dependencies": {
@@ -416,7 +571,10 @@ var servicesSchemaDataV2 = `{
           },
           "additionalProperties": false
         },
-        "internal": {"type": "boolean"}
+        "internal": {"type": "boolean"},
+        "attachable": {"type": "boolean"},
+        "labels": {"$ref": "#/definitions/list_or_dict"},
+        "name": {"type": "string"}
       },
       "additionalProperties": false
     },
@@ -437,8 +595,93 @@ var servicesSchemaDataV2 = `{
           "properties": {
             "name": {"type": "string"}
           }
-        }
+        },
+        "labels": {"$ref": "#/definitions/list_or_dict"},
+        "name": {"type": "string"}
+      },
+      "additionalProperties": false
+    },
+
+    "blkio_weight": {
+      "id": "#/definitions/blkio_weight",
+      "type": "object",
+      "properties": {
+        "path": {"type": "string"},
+        "weight": {"type": "integer"}
+      },
+      "required": ["path", "weight"],
+      "additionalProperties": false
+    },
+
+    "blkio_limit": {
+      "id": "#/definitions/blkio_limit",
+      "type": "object",
+      "properties": {
+        "path": {"type": "string"},
+        "rate": {"type": ["integer", "string"]}
+      },
+      "required": ["path", "rate"],
+      "additionalProperties": false
+    },
+
+    "blkio_iops_limit": {
+      "id": "#/definitions/blkio_iops_limit",
+      "type": "object",
+      "properties": {
+        "path": {"type": "string"},
+        "rate": {"type": "integer"}
+      },
+      "required": ["path", "rate"],
+      "additionalProperties": false
+    },
+
+    "healthcheck": {
+      "id": "#/definitions/healthcheck",
+      "type": "object",
+      "properties": {
+        "disable": {"type": "boolean"},
+        "interval": {"type": ["string", "number"]},
+        "retries": {"type": "integer", "minimum": 0},
+        "start_period": {"type": ["string", "number"]},
+        "test": {"$ref": "#/definitions/string_or_list"},
+        "timeout": {"type": ["string", "number"]}
+      },
+      "additionalProperties": false
+    },
+
+    "tmpfs_mount": {
+      "id": "#/definitions/tmpfs_mount",
+      "type": "object",
+      "properties": {
+        "type": {"type": "string", "enum": ["tmpfs"]},
+        "target": {"type": "string"},
+        "tmpfs": {"$ref": "#/definitions/tmpfs_options"}
+      },
+      "required": ["target"],
+      "additionalProperties": false
+    },
+
+    "tmpfs_options": {
+      "id": "#/definitions/tmpfs_options",
+      "type": "object",
+      "properties": {
+        "size": {"type": ["integer", "string"]},
+        "mode": {"type": "integer"}
+      },
+      "additionalProperties": false
+    },
+
+    "service_hook": {
+      "id": "#/definitions/service_hook",
+      "type": "object",
+      "properties": {
+        "command": {"$ref": "#/definitions/string_or_list"},
+        "user": {"type": "string"},
+        "privileged": {"type": "boolean"},
+        "working_dir": {"type": "string"},
+        "environment": {"$ref": "#/definitions/list_or_dict"}
       },
+      "required": ["command"],
       "additionalProperties": false
     },
 
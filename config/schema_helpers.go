@@ -30,6 +30,7 @@ func init() {
 type (
 	environmentFormatChecker struct{}
 	portsFormatChecker       struct{}
+	exposeFormatChecker      struct{}
 )
 
 func (checker environmentFormatChecker) IsFormat(input interface{}) bool {
@@ -48,6 +49,21 @@ func (checker portsFormatChecker) IsFormat(input interface{}) bool {
 	return err == nil
 }
 
+// IsFormat accepts container ports and port ranges (e.g. 3000 or
+// 8000-8005/udp). Unlike ports, expose never publishes on the host, so host
+// ports and addresses are rejected instead of being silently dropped.
+func (checker exposeFormatChecker) IsFormat(input interface{}) bool {
+	in, ok := input.(string)
+	if !ok {
+		return true
+	}
+	if strings.Contains(in, ":") {
+		return false
+	}
+	_, _, err := nat.ParsePortSpecs([]string{in})
+	return err == nil
+}
+
 func setupSchemaLoaders(schemaData string, schema *map[string]interface{}, schemaLoader, constraintSchemaLoader *gojsonschema.JSONLoader) error {
 	if *schema != nil {
 		return nil
@@ -63,7 +79,7 @@ func setupSchemaLoaders(schemaData string, schema *map[string]interface{}, schem
 
 	gojsonschema.FormatCheckers.Add("environment", environmentFormatChecker{})
 	gojsonschema.FormatCheckers.Add("ports", portsFormatChecker{})
-	gojsonschema.FormatCheckers.Add("expose", portsFormatChecker{})
+	gojsonschema.FormatCheckers.Add("expose", exposeFormatChecker{})
 	*schemaLoader = gojsonschema.NewGoLoader(schemaRaw)
 
 	definitions := (*schema)["definitions"].(map[string]interface{})
@@ -92,18 +108,27 @@ func parseValidTypesFromSchema(schema map[string]interface{}, context string) []
 
 		for _, validCondition := range validConditions {
 			condition := validCondition.(map[string]interface{})
-			validTypes = append(validTypes, condition["type"].(string))
+			if conditionType, ok := condition["type"].(string); ok {
+				validTypes = append(validTypes, conditionType)
+			} else if reference, ok := condition["$ref"].(string); ok {
+				validTypes = append(validTypes, referenceTypes(reference)...)
+			}
 		}
 	} else if val, ok := property["$ref"]; ok {
-		reference := val.(string)
-		if reference == "#/definitions/string_or_list" {
-			return []string{"string", "array"}
-		} else if reference == "#/definitions/list_of_strings" {
-			return []string{"array"}
-		} else if reference == "#/definitions/list_or_dict" {
-			return []string{"array", "object"}
-		}
+		return referenceTypes(val.(string))
 	}
 
 	return validTypes
 }
+
+func referenceTypes(reference string) []string {
+	switch reference {
+	case "#/definitions/string_or_list":
+		return []string{"string", "array"}
+	case "#/definitions/list_of_strings":
+		return []string{"array"}
+	case "#/definitions/list_or_dict":
+		return []string{"array", "object"}
+	}
+	return nil
+}
@@ -30,12 +30,12 @@ type ServiceConfigV1 struct {
 	ContainerName  string               `yaml:"container_name,omitempty"`
 	Devices        []string             `yaml:"devices,omitempty"`
 	DNS            yaml.Stringorslice   `yaml:"dns,omitempty"`
-	DNSOpts        []string             `yaml:"dns_opt,omitempty"`
+	DNSOpts        yaml.Stringorslice   `yaml:"dns_opt,omitempty"`
 	DNSSearch      yaml.Stringorslice   `yaml:"dns_search,omitempty"`
 	Dockerfile     string               `yaml:"dockerfile,omitempty"`
 	DomainName     string               `yaml:"domainname,omitempty"`
 	Entrypoint     yaml.Command         `yaml:"entrypoint,flow,omitempty"`
-	EnvFile        yaml.Stringorslice   `yaml:"env_file,omitempty"`
+	EnvFile        yaml.EnvFiles        `yaml:"env_file,omitempty"`
 	Environment    yaml.MaporEqualSlice `yaml:"environment,omitempty"`
 	GroupAdd       []string             `yaml:"group_add,omitempty"`
 	Hostname       string               `yaml:"hostname,omitempty"`
@@ -47,10 +47,10 @@ type ServiceConfigV1 struct {
 	MacAddress     string               `yaml:"mac_address,omitempty"`
 	MemLimit       yaml.MemStringorInt  `yaml:"mem_limit,omitempty"`
 	MemSwapLimit   yaml.MemStringorInt  `yaml:"memswap_limit,omitempty"`
-	MemSwappiness  yaml.MemStringorInt  `yaml:"mem_swappiness,omitempty"`
+	MemSwappiness  *yaml.StringorInt    `yaml:"mem_swappiness,omitempty"`
 	Name           string               `yaml:"name,omitempty"`
 	Net            string               `yaml:"net,omitempty"`
-	OomKillDisable bool                 `yaml:"oom_kill_disable,omitempty"`
+	OomKillDisable *bool                `yaml:"oom_kill_disable,omitempty"`
 	OomScoreAdj    yaml.StringorInt     `yaml:"oom_score_adj,omitempty"`
 	Pid            string               `yaml:"pid,omitempty"`
 	Uts            string               `yaml:"uts,omitempty"`
@@ -63,7 +63,7 @@ type ServiceConfigV1 struct {
 	StdinOpen      bool                 `yaml:"stdin_open,omitempty"`
 	SecurityOpt    []string             `yaml:"security_opt,omitempty"`
 	StopSignal     string               `yaml:"stop_signal,omitempty"`
-	Tmpfs          yaml.Stringorslice   `yaml:"tmpfs,omitempty"`
+	Tmpfs          yaml.Tmpfs           `yaml:"tmpfs,omitempty"`
 	Tty            bool                 `yaml:"tty,omitempty"`
 	User           string               `yaml:"user,omitempty"`
 	VolumeDriver   string               `yaml:"volume_driver,omitempty"`
@@ -73,7 +73,7 @@ type ServiceConfigV1 struct {
 	Expose         []string             `yaml:"expose,omitempty"`
 	ExternalLinks  []string             `yaml:"external_links,omitempty"`
 	LogOpt         map[string]string    `yaml:"log_opt,omitempty"`
-	ExtraHosts     []string             `yaml:"extra_hosts,omitempty"`
+	ExtraHosts     yaml.MaporColonSlice `yaml:"extra_hosts,omitempty"`
 	Ulimits        yaml.Ulimits         `yaml:"ulimits,omitempty"`
 }
 
@@ -85,64 +85,165 @@ type Log struct {
 
 // ServiceConfig holds version 2 of libcompose service configuration
 type ServiceConfig struct {
-	Build           yaml.Build           `yaml:"build,omitempty"`
-	CapAdd          []string             `yaml:"cap_add,omitempty"`
-	CapDrop         []string             `yaml:"cap_drop,omitempty"`
-	CPUSet          string               `yaml:"cpuset,omitempty"`
-	CPUShares       yaml.StringorInt     `yaml:"cpu_shares,omitempty"`
-	CPUQuota        yaml.StringorInt     `yaml:"cpu_quota,omitempty"`
-	Command         yaml.Command         `yaml:"command,flow,omitempty"`
-	CgroupParent    string               `yaml:"cgroup_parent,omitempty"`
-	ContainerName   string               `yaml:"container_name,omitempty"`
-	Devices         []string             `yaml:"devices,omitempty"`
-	DependsOn       []string             `yaml:"depends_on,omitempty"`
-	DNS             yaml.Stringorslice   `yaml:"dns,omitempty"`
-	DNSOpts         []string             `yaml:"dns_opt,omitempty"`
-	DNSSearch       yaml.Stringorslice   `yaml:"dns_search,omitempty"`
-	DomainName      string               `yaml:"domainname,omitempty"`
-	Entrypoint      yaml.Command         `yaml:"entrypoint,flow,omitempty"`
-	EnvFile         yaml.Stringorslice   `yaml:"env_file,omitempty"`
-	Environment     yaml.MaporEqualSlice `yaml:"environment,omitempty"`
-	Expose          []string             `yaml:"expose,omitempty"`
-	Extends         yaml.MaporEqualSlice `yaml:"extends,omitempty"`
-	ExternalLinks   []string             `yaml:"external_links,omitempty"`
-	ExtraHosts      []string             `yaml:"extra_hosts,omitempty"`
-	GroupAdd        []string             `yaml:"group_add,omitempty"`
-	Image           string               `yaml:"image,omitempty"`
-	Isolation       string               `yaml:"isolation,omitempty"`
-	Hostname        string               `yaml:"hostname,omitempty"`
-	Ipc             string               `yaml:"ipc,omitempty"`
-	Labels          yaml.SliceorMap      `yaml:"labels,omitempty"`
-	Links           yaml.MaporColonSlice `yaml:"links,omitempty"`
-	Logging         Log                  `yaml:"logging,omitempty"`
-	MacAddress      string               `yaml:"mac_address,omitempty"`
-	MemLimit        yaml.MemStringorInt  `yaml:"mem_limit,omitempty"`
-	MemReservation  yaml.MemStringorInt  `yaml:"mem_reservation,omitempty"`
-	MemSwapLimit    yaml.MemStringorInt  `yaml:"memswap_limit,omitempty"`
-	MemSwappiness   yaml.MemStringorInt  `yaml:"mem_swappiness,omitempty"`
-	NetworkMode     string               `yaml:"network_mode,omitempty"`
-	Networks        *yaml.Networks       `yaml:"networks,omitempty"`
-	OomKillDisable  bool                 `yaml:"oom_kill_disable,omitempty"`
-	OomScoreAdj     yaml.StringorInt     `yaml:"oom_score_adj,omitempty"`
-	Pid             string               `yaml:"pid,omitempty"`
-	Ports           []string             `yaml:"ports,omitempty"`
-	Privileged      bool                 `yaml:"privileged,omitempty"`
-	SecurityOpt     []string             `yaml:"security_opt,omitempty"`
-	ShmSize         yaml.MemStringorInt  `yaml:"shm_size,omitempty"`
-	StopGracePeriod string               `yaml:"stop_grace_period,omitempty"`
-	StopSignal      string               `yaml:"stop_signal,omitempty"`
-	Tmpfs           yaml.Stringorslice   `yaml:"tmpfs,omitempty"`
-	VolumeDriver    string               `yaml:"volume_driver,omitempty"`
-	Volumes         *yaml.Volumes        `yaml:"volumes,omitempty"`
-	VolumesFrom     []string             `yaml:"volumes_from,omitempty"`
-	Uts             string               `yaml:"uts,omitempty"`
-	Restart         string               `yaml:"restart,omitempty"`
-	ReadOnly        bool                 `yaml:"read_only,omitempty"`
-	StdinOpen       bool                 `yaml:"stdin_open,omitempty"`
-	Tty             bool                 `yaml:"tty,omitempty"`
-	User            string               `yaml:"user,omitempty"`
-	WorkingDir      string               `yaml:"working_dir,omitempty"`
-	Ulimits         yaml.Ulimits         `yaml:"ulimits,omitempty"`
+	Annotations       yaml.SliceorMap      `yaml:"annotations,omitempty"`
+	Attach            *bool                `yaml:"attach,omitempty"`
+	BlkioConfig       BlkioConfig          `yaml:"blkio_config,omitempty"`
+	Build             yaml.Build           `yaml:"build,omitempty"`
+	CapAdd            []string             `yaml:"cap_add,omitempty"`
+	CapDrop           []string             `yaml:"cap_drop,omitempty"`
+	CPUCount          yaml.StringorInt     `yaml:"cpu_count,omitempty"`
+	CPUPercent        yaml.StringorInt     `yaml:"cpu_percent,omitempty"`
+	CPUs              float64              `yaml:"cpus,omitempty"`
+	CPUSet            string               `yaml:"cpuset,omitempty"`
+	CPUShares         yaml.StringorInt     `yaml:"cpu_shares,omitempty"`
+	CPUQuota          yaml.StringorInt     `yaml:"cpu_quota,omitempty"`
+	Command           yaml.Command         `yaml:"command,flow,omitempty"`
+	CgroupParent      string               `yaml:"cgroup_parent,omitempty"`
+	ContainerName     string               `yaml:"container_name,omitempty"`
+	Devices           []string             `yaml:"devices,omitempty"`
+	DeviceCgroupRules []string             `yaml:"device_cgroup_rules,omitempty"`
+	Develop           DevelopConfig        `yaml:"develop,omitempty"`
+	DependsOn         yaml.DependsOn       `yaml:"depends_on,omitempty"`
+	DNS               yaml.Stringorslice   `yaml:"dns,omitempty"`
+	DNSOpts           yaml.Stringorslice   `yaml:"dns_opt,omitempty"`
+	DNSSearch         yaml.Stringorslice   `yaml:"dns_search,omitempty"`
+	DomainName        string               `yaml:"domainname,omitempty"`
+	Entrypoint        yaml.Command         `yaml:"entrypoint,flow,omitempty"`
+	EnvFile           yaml.EnvFiles        `yaml:"env_file,omitempty"`
+	Environment       yaml.MaporEqualSlice `yaml:"environment,omitempty"`
+	Expose            []string             `yaml:"expose,omitempty"`
+	Extends           yaml.MaporEqualSlice `yaml:"extends,omitempty"`
+	ExternalLinks     []string             `yaml:"external_links,omitempty"`
+	ExtraHosts        yaml.MaporColonSlice `yaml:"extra_hosts,omitempty"`
+	GPUs              yaml.GPUs            `yaml:"gpus,omitempty"`
+	GroupAdd          []string             `yaml:"group_add,omitempty"`
+	HealthCheck       HealthCheck          `yaml:"healthcheck,omitempty"`
+	Image             string               `yaml:"image,omitempty"`
+	Isolation         string               `yaml:"isolation,omitempty"`
+	Hostname          string               `yaml:"hostname,omitempty"`
+	Init              *bool                `yaml:"init,omitempty"`
+	Ipc               string               `yaml:"ipc,omitempty"`
+	Labels            yaml.SliceorMap      `yaml:"labels,omitempty"`
+	LabelFile         yaml.Stringorslice   `yaml:"label_file,omitempty"`
+	Links             yaml.MaporColonSlice `yaml:"links,omitempty"`
+	Logging           Log                  `yaml:"logging,omitempty"`
+	MacAddress        string               `yaml:"mac_address,omitempty"`
+	MemLimit          yaml.MemStringorInt  `yaml:"mem_limit,omitempty"`
+	MemReservation    yaml.MemStringorInt  `yaml:"mem_reservation,omitempty"`
+	MemSwapLimit      yaml.MemStringorInt  `yaml:"memswap_limit,omitempty"`
+	MemSwappiness     *yaml.StringorInt    `yaml:"mem_swappiness,omitempty"`
+	NetworkMode       string               `yaml:"network_mode,omitempty"`
+	Networks          *yaml.Networks       `yaml:"networks,omitempty"`
+	OomKillDisable    *bool                `yaml:"oom_kill_disable,omitempty"`
+	OomScoreAdj       yaml.StringorInt     `yaml:"oom_score_adj,omitempty"`
+	Pid               string               `yaml:"pid,omitempty"`
+	PidsLimit         *yaml.StringorInt    `yaml:"pids_limit,omitempty"`
+	Platform          string               `yaml:"platform,omitempty"`
+	Ports             []string             `yaml:"ports,omitempty"`
+	PostStart         []ServiceHook        `yaml:"post_start,omitempty"`
+	PreStop           []ServiceHook        `yaml:"pre_stop,omitempty"`
+	Privileged        bool                 `yaml:"privileged,omitempty"`
+	Profiles          []string             `yaml:"profiles,omitempty"`
+	PullPolicy        string               `yaml:"pull_policy,omitempty"`
+	SecurityOpt       []string             `yaml:"security_opt,omitempty"`
+	ShmSize           yaml.MemStringorInt  `yaml:"shm_size,omitempty"`
+	StopGracePeriod   *yaml.Duration       `yaml:"stop_grace_period,omitempty"`
+	StopSignal        string               `yaml:"stop_signal,omitempty"`
+	Sysctls           yaml.SliceorMap      `yaml:"sysctls,omitempty"`
+	Tmpfs             yaml.Tmpfs           `yaml:"tmpfs,omitempty"`
+	VolumeDriver      string               `yaml:"volume_driver,omitempty"`
+	Volumes           *yaml.Volumes        `yaml:"volumes,omitempty"`
+	VolumesFrom       []string             `yaml:"volumes_from,omitempty"`
+	Uts               string               `yaml:"uts,omitempty"`
+	Restart           string               `yaml:"restart,omitempty"`
+	Runtime           string               `yaml:"runtime,omitempty"`
+	ReadOnly          bool                 `yaml:"read_only,omitempty"`
+	StdinOpen         bool                 `yaml:"stdin_open,omitempty"`
+	Tty               bool                 `yaml:"tty,omitempty"`
+	User              string               `yaml:"user,omitempty"`
+	UsernsMode        string               `yaml:"userns_mode,omitempty"`
+	WorkingDir        string               `yaml:"working_dir,omitempty"`
+	Ulimits           yaml.Ulimits         `yaml:"ulimits,omitempty"`
+}
+
+// Pull policies of a service, on top of the time-windowed every_<duration>
+// one.
+const (
+	PullPolicyAlways  = "always"
+	PullPolicyMissing = "missing"
+	PullPolicyNever   = "never"
+	PullPolicyBuild   = "build"
+	PullPolicyDaily   = "daily"
+	PullPolicyWeekly  = "weekly"
+)
+
+// BlkioConfig holds the block IO configuration of a service. Devices are
+// referenced by their path on the host (e.g. /dev/sda).
+type BlkioConfig struct {
+	// Weight is the relative block IO weight of the service (10 to 1000),
+	// WeightDevice overriding it for specific devices.
+	Weight          uint16                `yaml:"weight,omitempty"`
+	WeightDevice    []BlkioWeightDevice   `yaml:"weight_device,omitempty"`
+	DeviceReadBps   []BlkioThrottleDevice `yaml:"device_read_bps,omitempty"`
+	DeviceWriteBps  []BlkioThrottleDevice `yaml:"device_write_bps,omitempty"`
+	DeviceReadIOps  []BlkioIOpsDevice     `yaml:"device_read_iops,omitempty"`
+	DeviceWriteIOps []BlkioIOpsDevice     `yaml:"device_write_iops,omitempty"`
+}
+
+// BlkioWeightDevice holds the relative block IO weight of a device.
+type BlkioWeightDevice struct {
+	Path   string `yaml:"path,omitempty"`
+	Weight uint16 `yaml:"weight,omitempty"`
+}
+
+// BlkioThrottleDevice holds the rate limit of a device, in bytes per second
+// (a memory string like 10mb is accepted).
+type BlkioThrottleDevice struct {
+	Path string              `yaml:"path,omitempty"`
+	Rate yaml.MemStringorInt `yaml:"rate,omitempty"`
+}
+
+// BlkioIOpsDevice holds the rate limit of a device, in IO operations per
+// second.
+type BlkioIOpsDevice struct {
+	Path string `yaml:"path,omitempty"`
+	Rate int64  `yaml:"rate,omitempty"`
+}
+
+// HealthCheck holds the healthcheck configuration of a service. Disable
+// turns off any healthcheck, including the one of the image.
+type HealthCheck struct {
+	Test        yaml.HealthCheckTest `yaml:"test,omitempty"`
+	Interval    yaml.Duration        `yaml:"interval,omitempty"`
+	Timeout     yaml.Duration        `yaml:"timeout,omitempty"`
+	Retries     int                  `yaml:"retries,omitempty"`
+	StartPeriod yaml.Duration        `yaml:"start_period,omitempty"`
+	Disable     bool                 `yaml:"disable,omitempty"`
+}
+
+// DevelopConfig holds the development configuration of a service, used by
+// watch tooling.
+type DevelopConfig struct {
+	Watch []WatchRule `yaml:"watch,omitempty"`
+}
+
+// WatchRule holds a develop.watch rule: the action to perform when the files
+// under path change.
+type WatchRule struct {
+	Path   string   `yaml:"path,omitempty"`
+	Action string   `yaml:"action,omitempty"`
+	Target string   `yaml:"target,omitempty"`
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+// ServiceHook holds a command to run in a service container at a given
+// point of its lifecycle (post_start, pre_stop).
+type ServiceHook struct {
+	Command     yaml.Command         `yaml:"command,flow,omitempty"`
+	User        string               `yaml:"user,omitempty"`
+	Privileged  bool                 `yaml:"privileged,omitempty"`
+	WorkingDir  string               `yaml:"working_dir,omitempty"`
+	Environment yaml.MaporEqualSlice `yaml:"environment,omitempty"`
 }
 
 // VolumeConfig holds v2 volume configuration
@@ -150,6 +251,10 @@ type VolumeConfig struct {
 	Driver     string            `yaml:"driver,omitempty"`
 	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
 	External   yaml.External     `yaml:"external,omitempty"`
+	Labels     yaml.SliceorMap   `yaml:"labels,omitempty"`
+	// Name overrides the default project_volume name of the volume, so that
+	// it can be shared with other projects.
+	Name string `yaml:"name,omitempty"`
 }
 
 // Ipam holds v2 network IPAM information
@@ -172,14 +277,35 @@ type NetworkConfig struct {
 	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
 	External   yaml.External     `yaml:"external,omitempty"`
 	Ipam       Ipam              `yaml:"ipam,omitempty"`
+	Internal   bool              `yaml:"internal,omitempty"`
+	Attachable bool              `yaml:"attachable,omitempty"`
+	Labels     yaml.SliceorMap   `yaml:"labels,omitempty"`
+	// Name overrides the default project_network name of the network.
+	Name string `yaml:"name,omitempty"`
+}
+
+// ConfigConfig holds a v3 top-level config definition, secrets are defined
+// the same way. Without a swarm, only the ones backed by a file are
+// supported: the file is bind mounted read-only into the services that
+// reference them.
+type ConfigConfig struct {
+	File     string        `yaml:"file,omitempty"`
+	External yaml.External `yaml:"external,omitempty"`
+	Name     string        `yaml:"name,omitempty"`
 }
 
 // Config holds libcompose top level configuration
 type Config struct {
-	Version  string                 `yaml:"version,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	// Name is the optional project name of a v2+ file. It is not read from
+	// the root of v1 files as it would be a service there.
+	Name     string                 `yaml:"-"`
 	Services RawServiceMap          `yaml:"services,omitempty"`
 	Volumes  map[string]interface{} `yaml:"volumes,omitempty"`
 	Networks map[string]interface{} `yaml:"networks,omitempty"`
+	// Configs and Secrets are the v3 top-level configs and secrets.
+	Configs map[string]interface{} `yaml:"configs,omitempty"`
+	Secrets map[string]interface{} `yaml:"secrets,omitempty"`
 }
 
 // NewServiceConfigs initializes a new Configs struct
@@ -259,7 +385,51 @@ type RawServiceMap map[string]RawService
 // ParseOptions are a set of options to customize the parsing process
 type ParseOptions struct {
 	Interpolate bool
+	// SkipInterpolationKeys lists the service keys (e.g. command) that
+	// interpolation leaves untouched in every service, as NoInterpolateKey
+	// does for a single service.
+	SkipInterpolationKeys []string
+	// Validate checks the services against the compose file schema. The
+	// errors are returned as ValidationErrors (or a ValidationError), which
+	// locate them by service, field and line. Without validation, the
+	// unsupported keys are only logged as warnings.
 	Validate    bool
 	Preprocess  func(RawServiceMap) (RawServiceMap, error)
 	Postprocess func(map[string]*ServiceConfig) (map[string]*ServiceConfig, error)
+	// Warn is called with each warning found while parsing, e.g. keys that
+	// are ignored, instead of logging it.
+	Warn func(ConfigWarning)
+	// ListMergePolicy controls how the lists of a service defined in several
+	// compose files are merged. The zero value keeps the default behavior.
+	ListMergePolicy ListMergePolicy
+}
+
+// ListMerge tells how a list of a service overridden by another compose file
+// is merged.
+type ListMerge string
+
+// Ways to merge lists.
+const (
+	// ListMergeAppend appends the entries of the override to the list, the
+	// default. An override using another syntax (e.g. a map of environment
+	// variables overriding a list) still replaces the list.
+	ListMergeAppend ListMerge = "append"
+	// ListMergeReplace replaces the list with the override.
+	ListMergeReplace ListMerge = "replace"
+)
+
+// ListMergePolicy tells how the lists of services are merged across compose
+// files, by service key (e.g. environment), Default applying to the keys
+// that are not listed. Empty values mean ListMergeAppend.
+type ListMergePolicy struct {
+	Default ListMerge
+	Keys    map[string]ListMerge
+}
+
+// forKey returns how the lists of the specified key are merged.
+func (p ListMergePolicy) forKey(key string) ListMerge {
+	if merge, ok := p.Keys[key]; ok {
+		return merge
+	}
+	return p.Default
 }
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+)
+
+// Validate parses and validates the specified compose file in memory, as
+// Merge does, without any access to a docker daemon or to the disk. It
+// returns the warnings found along the way, which are also passed to the Warn
+// function of the options if any. Validation is always enabled; when the
+// options enable interpolation, variables are looked up in the environment
+// of the process.
+//
+// As no file can be read, env_file and label_file references are considered
+// empty and services extending services of other files can't be validated.
+func Validate(bytes []byte, opts *ParseOptions) ([]ConfigWarning, error) {
+	options := DefaultParseOptions()
+	if opts != nil {
+		options = *opts
+	}
+	options.Validate = true
+
+	warnings := []ConfigWarning{}
+	warn := options.Warn
+	options.Warn = func(warning ConfigWarning) {
+		warnings = append(warnings, warning)
+		if warn != nil {
+			warn(warning)
+		}
+	}
+
+	var environmentLookup EnvironmentLookup
+	if options.Interpolate {
+		environmentLookup = &osEnvironmentLookup{}
+	}
+
+	_, _, _, _, err := Merge(NewServiceConfigs(), environmentLookup, &emptyResourceLookup{}, "", bytes, &options)
+	return warnings, err
+}
+
+// osEnvironmentLookup looks variables up in the environment of the process.
+type osEnvironmentLookup struct{}
+
+func (o *osEnvironmentLookup) Lookup(key string, config *ServiceConfig) []string {
+	if value, ok := os.LookupEnv(key); ok {
+		return []string{key + "=" + value}
+	}
+	return []string{}
+}
+
+// emptyResourceLookup considers that every file exists and is empty.
+type emptyResourceLookup struct{}
+
+func (e *emptyResourceLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
+	return []byte{}, file, nil
+}
+
+func (e *emptyResourceLookup) ResolvePath(path, inFile string) string {
+	return path
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	os.Setenv("LIBCOMPOSE_TEST_RESTART", "always")
+	defer os.Unsetenv("LIBCOMPOSE_TEST_RESTART")
+
+	warned := []ConfigWarning{}
+	warnings, err := Validate([]byte(`
+version: '2'
+services:
+  web:
+    image: nginx
+    read_only: true
+    restart: ${LIBCOMPOSE_TEST_RESTART}
+    env_file: web.env
+`), &ParseOptions{
+		Interpolate: true,
+		Warn: func(warning ConfigWarning) {
+			warned = append(warned, warning)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Service != "web" || warnings[0].Field != "read_only" {
+		t.Fatalf("Expected a read_only warning, got %v", warnings)
+	}
+	if len(warned) != 1 {
+		t.Fatalf("Expected the warning to be passed to Warn, got %v", warned)
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	_, err := Validate([]byte(`
+version: '2'
+services:
+  web:
+    image: nginx
+    restart: sometimes
+`), nil)
+	validationError, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	if validationError.Service != "web" || validationError.Field != "restart" {
+		t.Fatalf("Expected the restart key of web to be invalid, got %v", validationError)
+	}
+
+	// Without interpolation, variables are validated as is
+	if _, err := Validate([]byte(`
+version: '2'
+services:
+  web:
+    image: nginx
+    restart: ${RESTART}
+`), &ParseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
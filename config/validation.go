@@ -2,10 +2,18 @@ package config
 
 import (
 	"fmt"
+	"math/big"
+	"net"
+	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/zengchen221/libcompose/utils"
+	"github.com/zengchen221/libcompose/yaml"
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -156,7 +164,10 @@ func invalidTypeMessage(service, key string, err gojsonschema.ResultError) strin
 	return fmt.Sprintf("Service '%s' configuration key '%s' contains an invalid type, it should be %s.", service, key, validTypesMsg)
 }
 
-func validate(serviceMap RawServiceMap) error {
+// validate validates the specified services against the v1 schema. If
+// allowVariables is set (i.e. the services were not interpolated), values
+// holding variables are considered valid whatever their expected type.
+func validate(serviceMap RawServiceMap, allowVariables bool) error {
 	serviceMap = convertServiceMapKeysToStrings(serviceMap)
 
 	dataLoader := gojsonschema.NewGoLoader(serviceMap)
@@ -166,10 +177,11 @@ func validate(serviceMap RawServiceMap) error {
 		return err
 	}
 
-	return generateErrorMessages(serviceMap, schemaV1, result)
+	return generateErrorMessages(serviceMap, schemaV1, result, allowVariables)
 }
 
-func validateV2(serviceMap RawServiceMap) error {
+// validateV2 is the v2 counterpart of validate.
+func validateV2(serviceMap RawServiceMap, allowVariables bool) error {
 	serviceMap = convertServiceMapKeysToStrings(serviceMap)
 
 	dataLoader := gojsonschema.NewGoLoader(serviceMap)
@@ -179,11 +191,11 @@ func validateV2(serviceMap RawServiceMap) error {
 		return err
 	}
 
-	return generateErrorMessages(serviceMap, schemaV2, result)
+	return generateErrorMessages(serviceMap, schemaV2, result, allowVariables)
 }
 
-func generateErrorMessages(serviceMap RawServiceMap, schema map[string]interface{}, result *gojsonschema.Result) error {
-	var validationErrors []string
+func generateErrorMessages(serviceMap RawServiceMap, schema map[string]interface{}, result *gojsonschema.Result, allowVariables bool) error {
+	var validationErrors ValidationErrors
 
 	// gojsonschema can create extraneous "additional_property_not_allowed" errors in some cases
 	// If this is set, and the error is at root level, skip over that error
@@ -198,49 +210,83 @@ func generateErrorMessages(serviceMap RawServiceMap, schema map[string]interface
 				continue
 			}
 
+			if value, ok := err.Value().(string); ok && allowVariables && containsVariable(value) {
+				if err.Type() == "number_one_of" {
+					i++
+				}
+				continue
+			}
+
 			if err.Context().String() == "(root)" {
 				switch err.Type() {
 				case "additional_property_not_allowed":
-					validationErrors = append(validationErrors, fmt.Sprintf("Invalid service name '%s' - only [a-zA-Z0-9\\._\\-] characters are allowed", err.Details()["property"]))
+					validationErrors = append(validationErrors, &ValidationError{
+						Service: fmt.Sprint(err.Details()["property"]),
+						Message: fmt.Sprintf("Invalid service name '%s' - only [a-zA-Z0-9\\._\\-] characters are allowed", err.Details()["property"]),
+					})
 				default:
-					validationErrors = append(validationErrors, err.Description())
+					validationErrors = append(validationErrors, &ValidationError{Message: err.Description()})
 				}
 			} else {
 				skipRootAdditionalPropertyError = true
 
 				serviceName := serviceNameFromErrorField(err.Field())
 				key := keyNameFromErrorField(err.Field())
+				validationError := &ValidationError{
+					Service: serviceName,
+					Field:   strings.TrimPrefix(strings.TrimPrefix(err.Field(), serviceName), "."),
+				}
 
 				switch err.Type() {
 				case "additional_property_not_allowed":
-					validationErrors = append(validationErrors, unsupportedConfigMessage(result.Errors()[i].Details()["property"].(string), result.Errors()[i]))
+					property := result.Errors()[i].Details()["property"].(string)
+					validationError.Field = strings.TrimPrefix(validationError.Field+"."+property, ".")
+					validationError.Message = unsupportedConfigMessage(property, result.Errors()[i])
 				case "number_one_of":
-					validationErrors = append(validationErrors, fmt.Sprintf("Service '%s' configuration key '%s' %s", serviceName, key, oneOfMessage(serviceMap, schema, err, result.Errors()[i+1])))
+					validationError.Message = fmt.Sprintf("Service '%s' configuration key '%s' %s", serviceName, key, oneOfMessage(serviceMap, schema, err, result.Errors()[i+1]))
 
 					// Next error handled in oneOfMessage, skip over it
 					i++
 				case "invalid_type":
-					validationErrors = append(validationErrors, invalidTypeMessage(serviceName, key, err))
+					validationError.Message = invalidTypeMessage(serviceName, key, err)
 				case "required":
-					validationErrors = append(validationErrors, fmt.Sprintf("Service '%s' option '%s' is invalid, %s", serviceName, key, err.Description()))
+					validationError.Message = fmt.Sprintf("Service '%s' option '%s' is invalid, %s", serviceName, key, err.Description())
 				case "missing_dependency":
 					dependency := err.Details()["dependency"].(string)
-					validationErrors = append(validationErrors, fmt.Sprintf("Invalid configuration for '%s' service: dependency '%s' is not satisfied", serviceName, dependency))
+					validationError.Message = fmt.Sprintf("Invalid configuration for '%s' service: dependency '%s' is not satisfied", serviceName, dependency)
 				case "unique":
 					contextWithDuplicates := getValue(serviceMap, err.Context().String())
-					validationErrors = append(validationErrors, fmt.Sprintf("Service '%s' configuration key '%s' value %s has non-unique elements", serviceName, key, contextWithDuplicates))
+					validationError.Message = fmt.Sprintf("Service '%s' configuration key '%s' value %s has non-unique elements", serviceName, key, contextWithDuplicates)
+				case "format":
+					validationError.Message = fmt.Sprintf("Service '%s' configuration key '%s' is invalid: '%v' is not a valid %s value", serviceName, validationError.Field, err.Value(), err.Details()["format"])
+				case "number_gte":
+					validationError.Message = fmt.Sprintf("Service '%s' configuration key '%s' is invalid: %v is lower than the minimum %s", serviceName, validationError.Field, err.Value(), ratString(err.Details()["min"]))
+				case "number_lte":
+					validationError.Message = fmt.Sprintf("Service '%s' configuration key '%s' is invalid: %v is greater than the maximum %s", serviceName, validationError.Field, err.Value(), ratString(err.Details()["max"]))
 				default:
-					validationErrors = append(validationErrors, fmt.Sprintf("Service '%s' configuration key %s value %s", serviceName, key, err.Description()))
+					validationError.Message = fmt.Sprintf("Service '%s' configuration key %s value %s", serviceName, key, err.Description())
 				}
+				validationErrors = append(validationErrors, validationError)
 			}
 		}
 
-		return fmt.Errorf(strings.Join(validationErrors, "\n"))
+		if len(validationErrors) > 0 {
+			return validationErrors
+		}
 	}
 
 	return nil
 }
 
+// ratString formats a schema bound, which gojsonschema holds as a *big.Rat,
+// as a plain number.
+func ratString(bound interface{}) string {
+	if r, ok := bound.(*big.Rat); ok {
+		return r.RatString()
+	}
+	return fmt.Sprint(bound)
+}
+
 func validateServiceConstraints(service RawService, serviceName string) error {
 	service = convertServiceKeysToStrings(service)
 
@@ -304,3 +350,480 @@ func validateServiceConstraintsv2(service RawService, serviceName string) error
 
 	return nil
 }
+
+// ValidateRestartPolicy checks that the specified restart policy is one of
+// no, always, unless-stopped or on-failure (with an optional maximum retry
+// count).
+func ValidateRestartPolicy(policy string) error {
+	parts := strings.SplitN(policy, ":", 2)
+	switch parts[0] {
+	case "", "no", "always", "unless-stopped":
+		if len(parts) == 1 {
+			return nil
+		}
+	case "on-failure":
+		if len(parts) == 1 {
+			return nil
+		}
+		if count, err := strconv.Atoi(parts[1]); err == nil && count >= 0 {
+			return nil
+		}
+		return fmt.Errorf("Invalid restart policy '%s': maximum retry count must be a positive integer", policy)
+	}
+	return fmt.Errorf("Invalid restart policy '%s': must be one of no, always, unless-stopped or on-failure[:max-retries]", policy)
+}
+
+// ValidateIpcMode checks that the specified ipc mode is one of host, none,
+// private, shareable, service:<name> or container:<name|id>.
+func ValidateIpcMode(ipc string) error {
+	switch ipc {
+	case "", "host", "none", "private", "shareable":
+		return nil
+	}
+	for _, prefix := range []string{"service:", "container:"} {
+		if strings.HasPrefix(ipc, prefix) {
+			if ipc == prefix {
+				return fmt.Errorf("Invalid ipc mode '%s': missing the %s name", ipc, strings.TrimSuffix(prefix, ":"))
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid ipc mode '%s': must be one of host, none, private, shareable, service:<name> or container:<name>", ipc)
+}
+
+// ValidateIsolation checks that the specified isolation technology is one of
+// default, process or hyperv.
+func ValidateIsolation(isolation string) error {
+	switch strings.ToLower(isolation) {
+	case "", "default", "process", "hyperv":
+		return nil
+	}
+	return fmt.Errorf("Invalid isolation '%s': must be one of default, process or hyperv", isolation)
+}
+
+// ValidateUser checks that the specified user is a user name or uid,
+// optionally followed by a group name or gid (user:group).
+func ValidateUser(user string) error {
+	if user == "" {
+		return nil
+	}
+	parts := strings.Split(user, ":")
+	if len(parts) > 2 {
+		return fmt.Errorf("Invalid user '%s': must be user or user:group", user)
+	}
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, " \t") {
+			return fmt.Errorf("Invalid user '%s': must be user or user:group", user)
+		}
+	}
+	return nil
+}
+
+// ValidateMemoryLimits checks that the memory settings of the specified
+// service are consistent, i.e. that mem_reservation (a soft limit) isn't
+// greater than mem_limit, and that memswap_limit (memory plus swap, -1 for
+// unlimited swap) isn't lower than it.
+func ValidateMemoryLimits(serviceConfig *ServiceConfig) error {
+	memLimit := int64(serviceConfig.MemLimit)
+	memReservation := int64(serviceConfig.MemReservation)
+	memSwapLimit := int64(serviceConfig.MemSwapLimit)
+
+	if memLimit > 0 && memReservation > memLimit {
+		return fmt.Errorf("mem_reservation (%d bytes) must be lower than or equal to mem_limit (%d bytes)", memReservation, memLimit)
+	}
+	if memLimit > 0 && memSwapLimit > 0 && memSwapLimit < memLimit {
+		return fmt.Errorf("memswap_limit (%d bytes) must be greater than or equal to mem_limit (%d bytes)", memSwapLimit, memLimit)
+	}
+	return nil
+}
+
+// validateContainerNames checks that no two services, in the specified
+// services or the existing ones they are merged into, use the same custom
+// container name, as docker requires container names to be unique.
+func validateContainerNames(existingServices *ServiceConfigs, serviceConfigs map[string]*ServiceConfig) error {
+	containerNames := map[string]string{}
+	for _, name := range existingServices.Keys() {
+		if _, ok := serviceConfigs[name]; ok {
+			continue
+		}
+		if serviceConfig, _ := existingServices.Get(name); serviceConfig.ContainerName != "" {
+			containerNames[serviceConfig.ContainerName] = name
+		}
+	}
+
+	names := []string{}
+	for name := range serviceConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		containerName := serviceConfigs[name].ContainerName
+		if containerName == "" {
+			continue
+		}
+		if other, ok := containerNames[containerName]; ok {
+			return &ValidationError{
+				Service: name,
+				Field:   "container_name",
+				Message: fmt.Sprintf("Services '%s' and '%s' use the same container name '%s', container names must be unique", other, name, containerName),
+			}
+		}
+		containerNames[containerName] = name
+	}
+	return nil
+}
+
+// validateByteQuantities checks that the byte quantities of the specified
+// service (e.g. shm_size: 256m) can be parsed, so that an invalid one is
+// reported along with the service and key instead of when decoding the
+// service config.
+func validateByteQuantities(name string, serviceData RawService) error {
+	build, _ := serviceData["build"].(map[interface{}]interface{})
+	for _, key := range []string{"mem_limit", "mem_reservation", "memswap_limit", "shm_size", "build.shm_size"} {
+		value := serviceData[key]
+		if key == "build.shm_size" {
+			value = build["shm_size"]
+		}
+		quantity, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if _, err := units.RAMInBytes(quantity); err != nil {
+			return &ValidationError{
+				Service: name,
+				Field:   key,
+				Message: fmt.Sprintf("Service '%s' configuration key '%s' is invalid: %v", name, key, err),
+			}
+		}
+	}
+	return nil
+}
+
+// validateDurations checks that the durations of the specified service (e.g.
+// stop_grace_period: 1m30s) can be parsed, so that an invalid one is reported
+// along with the service and key instead of when decoding the service config.
+func validateDurations(name string, serviceData RawService) error {
+	healthCheck, _ := serviceData["healthcheck"].(map[interface{}]interface{})
+	for _, key := range []string{"stop_grace_period", "healthcheck.interval", "healthcheck.timeout", "healthcheck.start_period"} {
+		value := serviceData[key]
+		if strings.HasPrefix(key, "healthcheck.") {
+			value = healthCheck[strings.TrimPrefix(key, "healthcheck.")]
+		}
+		duration, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if _, err := yaml.ParseDuration(duration); err != nil {
+			return &ValidationError{
+				Service: name,
+				Field:   key,
+				Message: fmt.Sprintf("Service '%s' configuration key '%s' is invalid: %v", name, key, err),
+			}
+		}
+	}
+	return nil
+}
+
+var platformPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)?$`)
+
+// validatePlatforms checks that the run and build platforms of the specified
+// service have the os/arch[/variant] shape (e.g. linux/arm64/v8).
+func validatePlatforms(name string, serviceData RawService) error {
+	build, _ := serviceData["build"].(map[interface{}]interface{})
+	for _, key := range []string{"platform", "build.platform"} {
+		value := serviceData[key]
+		if key == "build.platform" {
+			value = build["platform"]
+		}
+		platform, ok := value.(string)
+		if !ok || platformPattern.MatchString(platform) {
+			continue
+		}
+		return &ValidationError{
+			Service: name,
+			Field:   key,
+			Message: fmt.Sprintf("Service '%s' configuration key '%s' is invalid: '%s' is not a platform, it must be os/arch[/variant] (e.g. linux/amd64)", name, key, platform),
+		}
+	}
+	return nil
+}
+
+// validateRuntime checks that the runtime of the specified service, if set,
+// names one.
+func validateRuntime(name string, serviceData RawService) error {
+	runtime, ok := serviceData["runtime"].(string)
+	if !ok || strings.TrimSpace(runtime) != "" {
+		return nil
+	}
+	return &ValidationError{
+		Service: name,
+		Field:   "runtime",
+		Message: fmt.Sprintf("Service '%s' configuration key 'runtime' is invalid: the runtime name can't be empty", name),
+	}
+}
+
+// validateDNS checks that the dns servers of the specified service (a string
+// or a list) are ip addresses.
+func validateDNS(name string, serviceData RawService) error {
+	servers, isList := serviceData["dns"].([]interface{})
+	if !isList {
+		servers = []interface{}{serviceData["dns"]}
+	}
+	for i, value := range servers {
+		server, ok := value.(string)
+		if !ok || containsVariable(server) || net.ParseIP(server) != nil {
+			continue
+		}
+		field := "dns"
+		if isList {
+			field = fmt.Sprintf("dns.%d", i)
+		}
+		return &ValidationError{
+			Service: name,
+			Field:   field,
+			Message: fmt.Sprintf("Service '%s' configuration key 'dns' is invalid: '%s' is not an ip address", name, server),
+		}
+	}
+	return nil
+}
+
+// containerNamePattern matches the names (and link aliases) of containers,
+// as accepted by the daemon.
+var containerNamePattern = regexp.MustCompile(`^/?[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// validateExternalLinks checks that the external_links of the specified
+// service are in the name[:alias] form, name being a container that isn't
+// managed by the project.
+func validateExternalLinks(name string, serviceData RawService) error {
+	links, _ := serviceData["external_links"].([]interface{})
+	for i, value := range links {
+		link, ok := value.(string)
+		if !ok || containsVariable(link) {
+			continue
+		}
+		parts := strings.Split(link, ":")
+		valid := len(parts) <= 2
+		for _, part := range parts {
+			valid = valid && containerNamePattern.MatchString(part)
+		}
+		if !valid {
+			return &ValidationError{
+				Service: name,
+				Field:   fmt.Sprintf("external_links.%d", i),
+				Message: fmt.Sprintf("Service '%s' configuration key 'external_links' is invalid: '%s' should be in the container[:alias] form", name, link),
+			}
+		}
+	}
+	return nil
+}
+
+// validateMacAddress checks that the mac_address of the specified service is
+// a valid ethernet (48 bits) address, e.g. 02:42:ac:11:00:02.
+func validateMacAddress(name string, serviceData RawService) error {
+	address, ok := serviceData["mac_address"].(string)
+	if !ok || address == "" || containsVariable(address) {
+		return nil
+	}
+	if mac, err := net.ParseMAC(address); err != nil || len(mac) != 6 {
+		return &ValidationError{
+			Service: name,
+			Field:   "mac_address",
+			Message: fmt.Sprintf("Service '%s' configuration key 'mac_address' is invalid: '%s' is not an ethernet mac address", name, address),
+		}
+	}
+	return nil
+}
+
+// validateSecurityOpts checks that the security_opt entries of the specified
+// service are in one of the forms accepted by the daemon: label, apparmor,
+// seccomp or no-new-privileges options separated from their value by = (or
+// the deprecated :), bare no-new-privileges or disable.
+func validateSecurityOpts(name string, serviceData RawService) error {
+	opts, _ := serviceData["security_opt"].([]interface{})
+	for i, value := range opts {
+		opt, ok := value.(string)
+		if !ok || containsVariable(opt) {
+			continue
+		}
+		if err := ValidateSecurityOpt(opt); err != nil {
+			return &ValidationError{
+				Service: name,
+				Field:   fmt.Sprintf("security_opt.%d", i),
+				Message: fmt.Sprintf("Service '%s' configuration key 'security_opt' is invalid: %v", name, err),
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateSecurityOpt checks that the specified security option is one the
+// daemon accepts, see validateSecurityOpts.
+func ValidateSecurityOpt(opt string) error {
+	if opt == "no-new-privileges" || opt == "disable" {
+		return nil
+	}
+	key, value, ok := SplitSecurityOpt(opt)
+	if !ok {
+		return fmt.Errorf("'%s' is not in the key=value or key:value form", opt)
+	}
+	switch key {
+	case "label", "apparmor", "seccomp":
+		if value == "" {
+			return fmt.Errorf("'%s' has no value", opt)
+		}
+	case "no-new-privileges":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("'%s' must be set to true or false", opt)
+		}
+	default:
+		return fmt.Errorf("'%s' is not a supported option, must be one of label, apparmor, seccomp or no-new-privileges", opt)
+	}
+	return nil
+}
+
+// SplitSecurityOpt splits the specified security option in its key and value.
+// The = separator takes precedence over the : one, as for the daemon.
+func SplitSecurityOpt(opt string) (string, string, bool) {
+	parts := strings.SplitN(opt, "=", 2)
+	if len(parts) != 2 {
+		parts = strings.SplitN(opt, ":", 2)
+	}
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// ValidateBlkioConfig checks that the devices of the specified blkio_config
+// are absolute paths and that their weights and rates are in range. The error
+// names the offending entry.
+func ValidateBlkioConfig(blkio BlkioConfig) error {
+	if blkio.Weight != 0 && (blkio.Weight < 10 || blkio.Weight > 1000) {
+		return fmt.Errorf("Invalid weight %d: weight must be between 10 and 1000", blkio.Weight)
+	}
+	for _, device := range blkio.WeightDevice {
+		if err := validateBlkioDevicePath(device.Path); err != nil {
+			return fmt.Errorf("Invalid weight_device entry '%s:%d': %v", device.Path, device.Weight, err)
+		}
+		if device.Weight < 10 || device.Weight > 1000 {
+			return fmt.Errorf("Invalid weight_device entry '%s:%d': weight must be between 10 and 1000", device.Path, device.Weight)
+		}
+	}
+	throttleDevices := map[string][]BlkioThrottleDevice{
+		"device_read_bps":  blkio.DeviceReadBps,
+		"device_write_bps": blkio.DeviceWriteBps,
+	}
+	for _, key := range []string{"device_read_bps", "device_write_bps"} {
+		for _, device := range throttleDevices[key] {
+			if err := validateBlkioDevicePath(device.Path); err != nil {
+				return fmt.Errorf("Invalid %s entry '%s:%d': %v", key, device.Path, device.Rate, err)
+			}
+			if device.Rate <= 0 {
+				return fmt.Errorf("Invalid %s entry '%s:%d': rate must be a positive number of bytes", key, device.Path, device.Rate)
+			}
+		}
+	}
+	iopsDevices := map[string][]BlkioIOpsDevice{
+		"device_read_iops":  blkio.DeviceReadIOps,
+		"device_write_iops": blkio.DeviceWriteIOps,
+	}
+	for _, key := range []string{"device_read_iops", "device_write_iops"} {
+		for _, device := range iopsDevices[key] {
+			if err := validateBlkioDevicePath(device.Path); err != nil {
+				return fmt.Errorf("Invalid %s entry '%s:%d': %v", key, device.Path, device.Rate, err)
+			}
+			if device.Rate <= 0 {
+				return fmt.Errorf("Invalid %s entry '%s:%d': rate must be a positive number of operations", key, device.Path, device.Rate)
+			}
+		}
+	}
+	return nil
+}
+
+func validateBlkioDevicePath(devicePath string) error {
+	if devicePath == "" {
+		return fmt.Errorf("missing device path")
+	}
+	if !path.IsAbs(devicePath) {
+		return fmt.Errorf("device path must be absolute")
+	}
+	return nil
+}
+
+// ValidatePullPolicy checks that the specified pull policy is one of always,
+// missing, never, build, daily, weekly or every_<duration>.
+func ValidatePullPolicy(policy string) error {
+	switch policy {
+	case "", PullPolicyAlways, PullPolicyMissing, PullPolicyNever, PullPolicyBuild:
+		return nil
+	}
+	_, err := PullPolicyInterval(policy)
+	return err
+}
+
+// ValidateHealthCheck checks that the test of the specified healthcheck
+// starts with NONE, CMD or CMD-SHELL, that it isn't combined with disable
+// and that its durations are positive.
+func ValidateHealthCheck(healthCheck HealthCheck) error {
+	if len(healthCheck.Test) > 0 {
+		switch healthCheck.Test[0] {
+		case "NONE", "CMD", "CMD-SHELL":
+		default:
+			return fmt.Errorf("Invalid test %v: must start with NONE, CMD or CMD-SHELL", healthCheck.Test)
+		}
+		if healthCheck.Disable && healthCheck.Test[0] != "NONE" {
+			return fmt.Errorf("test and disable can't be set at the same time")
+		}
+	}
+	for name, duration := range map[string]yaml.Duration{
+		"interval":     healthCheck.Interval,
+		"timeout":      healthCheck.Timeout,
+		"start_period": healthCheck.StartPeriod,
+	} {
+		if duration < 0 {
+			return fmt.Errorf("Invalid %s %v: must be positive", name, time.Duration(duration))
+		}
+	}
+	return nil
+}
+
+// PullPolicyInterval returns the minimum interval between two pulls for the
+// time-windowed pull policies (daily, weekly and every_<duration>, where the
+// duration is a Go duration like 12h or a number of days or weeks like 3d or
+// 2w), and 0 for the other ones.
+func PullPolicyInterval(policy string) (time.Duration, error) {
+	switch policy {
+	case "", PullPolicyAlways, PullPolicyMissing, PullPolicyNever, PullPolicyBuild:
+		return 0, nil
+	case PullPolicyDaily:
+		return 24 * time.Hour, nil
+	case PullPolicyWeekly:
+		return 7 * 24 * time.Hour, nil
+	}
+	if !strings.HasPrefix(policy, "every_") {
+		return 0, fmt.Errorf("Invalid pull policy '%s': must be one of always, missing, never, build, daily, weekly or every_<duration>", policy)
+	}
+	interval, err := parsePullInterval(strings.TrimPrefix(policy, "every_"))
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("Invalid pull policy '%s': the duration must be positive, e.g. every_12h or every_2d", policy)
+	}
+	return interval, nil
+}
+
+func parsePullInterval(value string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		if strings.HasSuffix(value, suffix) {
+			count, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
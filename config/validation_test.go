@@ -3,10 +3,13 @@ package config
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zengchen221/libcompose/yaml"
 )
 
 func testValidSchemaV1(t *testing.T, serviceMap RawServiceMap) {
@@ -22,8 +25,8 @@ func testValidSchemaAll(t *testing.T, serviceMap RawServiceMap) {
 	testValidSchema(t, serviceMap, validateV2, nil)
 }
 
-func testValidSchema(t *testing.T, serviceMap RawServiceMap, validate func(RawServiceMap) error, validateServiceConstraints func(RawService, string) error) {
-	err := validate(serviceMap)
+func testValidSchema(t *testing.T, serviceMap RawServiceMap, validate func(RawServiceMap, bool) error, validateServiceConstraints func(RawService, string) error) {
+	err := validate(serviceMap, false)
 	assert.Nil(t, err)
 
 	if validateServiceConstraints != nil {
@@ -47,10 +50,10 @@ func testInvalidSchemaAll(t *testing.T, serviceMap RawServiceMap, errMsgs []stri
 	testInvalidSchema(t, serviceMap, errMsgs, errCount, validateV2, nil)
 }
 
-func testInvalidSchema(t *testing.T, serviceMap RawServiceMap, errMsgs []string, errCount int, validate func(RawServiceMap) error, validateServiceConstraints func(RawService, string) error) {
+func testInvalidSchema(t *testing.T, serviceMap RawServiceMap, errMsgs []string, errCount int, validate func(RawServiceMap, bool) error, validateServiceConstraints func(RawService, string) error) {
 	var combinedErrMsg bytes.Buffer
 
-	err := validate(serviceMap)
+	err := validate(serviceMap, false)
 	if err != nil {
 		combinedErrMsg.WriteString(err.Error())
 		combinedErrMsg.WriteRune('\n')
@@ -234,6 +237,24 @@ func TestValidConfigWhichAllowsTwoTypeDefinitions(t *testing.T) {
 	}
 }
 
+func TestConfigExpose(t *testing.T) {
+	testValidSchemaAll(t, RawServiceMap{
+		"web": map[string]interface{}{
+			"image":  "busybox",
+			"expose": []interface{}{"3000", "8000-8005", "53/udp", 9000},
+		},
+	})
+
+	for _, exposeValue := range []string{"8000:8000", "127.0.0.1::3000", "http"} {
+		testInvalidSchemaAll(t, RawServiceMap{
+			"web": map[string]interface{}{
+				"image":  "busybox",
+				"expose": []interface{}{exposeValue},
+			},
+		}, []string{"Service 'web' configuration key 'expose.0' is invalid: '" + exposeValue + "' is not a valid expose value"}, 1)
+	}
+}
+
 func TestValidConfigOneOfStringOrList(t *testing.T) {
 	entrypointValues := []interface{}{
 		[]interface{}{
@@ -382,3 +403,244 @@ func TestMultipleInvalidServicesForMultipleErrors(t *testing.T) {
 		"Service 'foo2' configuration key 'environment' contains non unique items, please remove duplicates from [KEY=VAL KEY=VAL]",
 	}, 4)
 }
+
+func TestValidateRestartPolicy(t *testing.T) {
+	valids := []string{"", "no", "always", "unless-stopped", "on-failure", "on-failure:5"}
+	for _, policy := range valids {
+		assert.Nil(t, ValidateRestartPolicy(policy), policy)
+	}
+
+	invalids := []string{"sometimes", "always:3", "on-failure:x", "on-failure:-1"}
+	for _, policy := range invalids {
+		assert.NotNil(t, ValidateRestartPolicy(policy), policy)
+	}
+}
+
+func TestValidateMemoryLimits(t *testing.T) {
+	valids := []*ServiceConfig{
+		{},
+		{MemLimit: 1024, MemReservation: 512},
+		{MemLimit: 1024, MemReservation: 1024},
+		{MemReservation: 512},
+		{MemLimit: 1024, MemSwapLimit: 2048},
+		{MemLimit: 1024, MemSwapLimit: 1024},
+		{MemLimit: 1024, MemSwapLimit: -1},
+	}
+	for _, serviceConfig := range valids {
+		assert.Nil(t, ValidateMemoryLimits(serviceConfig), "%#v", serviceConfig)
+	}
+
+	invalids := []*ServiceConfig{
+		{MemLimit: 512, MemReservation: 1024},
+		{MemLimit: 1, MemReservation: 2},
+		{MemLimit: 1024, MemSwapLimit: 512},
+	}
+	for _, serviceConfig := range invalids {
+		assert.NotNil(t, ValidateMemoryLimits(serviceConfig), "%#v", serviceConfig)
+	}
+}
+
+func TestValidateIsolation(t *testing.T) {
+	for _, isolation := range []string{"", "default", "process", "hyperv", "HyperV"} {
+		assert.Nil(t, ValidateIsolation(isolation), isolation)
+	}
+	for _, isolation := range []string{"vm", "host", "process,hyperv"} {
+		assert.NotNil(t, ValidateIsolation(isolation), isolation)
+	}
+}
+
+func TestValidateIpcMode(t *testing.T) {
+	valids := []string{"", "host", "none", "private", "shareable", "service:db", "container:abcdef"}
+	for _, ipc := range valids {
+		assert.Nil(t, ValidateIpcMode(ipc), ipc)
+	}
+
+	invalids := []string{"shared", "service:", "container:", "db"}
+	for _, ipc := range invalids {
+		assert.NotNil(t, ValidateIpcMode(ipc), ipc)
+	}
+}
+
+func TestPullPolicyInterval(t *testing.T) {
+	intervals := map[string]time.Duration{
+		"":            0,
+		"always":      0,
+		"missing":     0,
+		"never":       0,
+		"build":       0,
+		"daily":       24 * time.Hour,
+		"weekly":      7 * 24 * time.Hour,
+		"every_12h":   12 * time.Hour,
+		"every_1h30m": 90 * time.Minute,
+		"every_3d":    3 * 24 * time.Hour,
+		"every_2w":    14 * 24 * time.Hour,
+	}
+	for policy, expected := range intervals {
+		interval, err := PullPolicyInterval(policy)
+		assert.Nil(t, err, policy)
+		assert.Equal(t, expected, interval, policy)
+		assert.Nil(t, ValidatePullPolicy(policy), policy)
+	}
+
+	invalids := []string{"sometimes", "every_", "every_xd", "every_-1h", "every_0s", "hourly"}
+	for _, policy := range invalids {
+		assert.NotNil(t, ValidatePullPolicy(policy), policy)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	_, configs, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2.1'
+services:
+  web:
+    image: busybox
+    healthcheck:
+      test: curl -f http://localhost
+      interval: 1m30s
+      timeout: 10s
+      retries: 3
+      start_period: 40s
+  db:
+    image: busybox
+    healthcheck:
+      disable: true
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := HealthCheck{
+		Test:        yaml.HealthCheckTest{"CMD-SHELL", "curl -f http://localhost"},
+		Interval:    yaml.Duration(90 * time.Second),
+		Timeout:     yaml.Duration(10 * time.Second),
+		Retries:     3,
+		StartPeriod: yaml.Duration(40 * time.Second),
+	}
+	if !reflect.DeepEqual(configs["web"].HealthCheck, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].HealthCheck)
+	}
+	if !configs["db"].HealthCheck.Disable {
+		t.Fatal("Expected the db healthcheck to be disabled")
+	}
+}
+
+func TestInvalidHealthCheck(t *testing.T) {
+	for healthCheck, expected := range map[string]string{
+		"test: [CMD, echo]\n      disable: true": "test and disable can't be set at the same time",
+		"test: [echo]":                           "must start with NONE, CMD or CMD-SHELL",
+		"interval: soon":                         "Invalid duration",
+	} {
+		_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`
+version: '2.1'
+services:
+  web:
+    image: busybox
+    healthcheck:
+      `+healthCheck+`
+`), nil)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected error %q for %q, got %v", expected, healthCheck, err)
+		}
+	}
+}
+
+func TestValidationErrorLines(t *testing.T) {
+	_, _, _, _, err := Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`version: '2'
+services:
+  web:
+    image: busybox
+    ports:
+      - 80
+      - not_a_port: true
+    privilege: true
+  db:
+    image: postgres
+    restart: sometimes
+`), nil)
+	validationErrors, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+	lines := map[string]int{}
+	for _, e := range validationErrors {
+		lines[e.Service+"/"+e.Field] = e.Line
+	}
+	assert.Equal(t, map[string]int{"web/ports.1": 7, "web/privilege": 8}, lines)
+	assert.Contains(t, err.Error(), "line 8: Unsupported config option for web service: 'privilege' (did you mean 'privileged'?)")
+
+	_, _, _, _, err = Merge(NewServiceConfigs(), nil, &NullLookup{}, "", []byte(`version: '2'
+services:
+  db:
+    image: postgres
+    restart: sometimes
+`), nil)
+	validationError, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	assert.Equal(t, "db", validationError.Service)
+	assert.Equal(t, "restart", validationError.Field)
+	assert.Equal(t, 5, validationError.Line)
+}
+
+func TestYamlLine(t *testing.T) {
+	source := []byte(`# comment
+services:
+  web:
+    image: busybox
+    "labels": {a: b}
+    volumes:
+    - /data
+    - type: bind
+      source: .
+      target: /src
+  db:
+    image: postgres
+`)
+	cases := []struct {
+		path     []string
+		expected int
+	}{
+		{[]string{"services", "web"}, 3},
+		{[]string{"services", "web", "labels"}, 5},
+		{[]string{"services", "web", "labels", "a"}, 5},
+		{[]string{"services", "web", "volumes", "1"}, 8},
+		{[]string{"services", "web", "volumes", "1", "target"}, 10},
+		{[]string{"services", "db", "image"}, 12},
+		{[]string{"services", "web", "missing"}, 3},
+		{[]string{"missing"}, 0},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, yamlLine(source, c.path), "%v", c.path)
+	}
+}
+
+func TestUnsupportedKeysWarnings(t *testing.T) {
+	services := RawServiceMap{
+		"web": RawService{
+			"image":     "busybox",
+			"privilege": true,
+			"x-custom":  "value",
+		},
+		"app": RawService{
+			"image":  "busybox",
+			"deploy": map[interface{}]interface{}{},
+		},
+	}
+	assert.Equal(t, []ConfigWarning{
+		{Service: "app", Field: "deploy", Message: "Unsupported config option for app service: 'deploy', it is ignored"},
+		{Service: "web", Field: "privilege", Message: "Unsupported config option for web service: 'privilege', it is ignored"},
+	}, unsupportedKeysWarnings(services, 2))
+	assert.Equal(t, []ConfigWarning{
+		{Service: "web", Field: "privilege", Message: "Unsupported config option for web service: 'privilege', it is ignored"},
+	}, unsupportedKeysWarnings(services, 3))
+}
+
+func TestValidateUser(t *testing.T) {
+	for _, user := range []string{"", "root", "1000", "1000:1000", "www-data:www-data"} {
+		assert.Nil(t, ValidateUser(user), user)
+	}
+	for _, user := range []string{":1000", "1000:", "a:b:c", "some user"} {
+		assert.NotNil(t, ValidateUser(user), user)
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Variable is an environment variable referenced by interpolation in a
+// compose file.
+type Variable struct {
+	// Name is the name of the variable.
+	Name string
+	// HasDefault is true if every reference to the variable provides a
+	// default value (${VAR:-default} or ${VAR-default}), i.e. if the
+	// variable doesn't need to be set.
+	HasDefault bool
+}
+
+// ReferencedVariables statically scans the specified compose files for the
+// variables referenced by interpolation (${VAR} and $VAR) and returns them
+// sorted by name. Nothing is interpolated: the values subject to
+// interpolation are only inspected, keys listed in x-no-interpolate being
+// skipped.
+func ReferencedVariables(files [][]byte) ([]Variable, error) {
+	hasDefault := map[string]bool{}
+	found := func(name string, withDefault bool) {
+		if previous, ok := hasDefault[name]; ok {
+			withDefault = withDefault && previous
+		}
+		hasDefault[name] = withDefault
+	}
+
+	for _, bytes := range files {
+		config, err := CreateConfig(bytes)
+		if err != nil {
+			return nil, err
+		}
+		scanVariables(config.Version, found)
+		scanVariables(config.Name, found)
+		for name, service := range config.Services {
+			skipped, err := noInterpolateKeys(service)
+			if err != nil {
+				return nil, fmt.Errorf("Service '%s': %v", name, err)
+			}
+			for key, value := range service {
+				if key != NoInterpolateKey && !skipped[key] {
+					scanValueVariables(value, found)
+				}
+			}
+		}
+		for _, volume := range config.Volumes {
+			scanValueVariables(volume, found)
+		}
+		for _, network := range config.Networks {
+			scanValueVariables(network, found)
+		}
+	}
+
+	variables := []Variable{}
+	for name, withDefault := range hasDefault {
+		variables = append(variables, Variable{Name: name, HasDefault: withDefault})
+	}
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Name < variables[j].Name
+	})
+	return variables, nil
+}
+
+// RequiredVariables returns the sorted names of the variables referenced by
+// the specified compose files without a default value, i.e. the ones that
+// must be set for the project to be interpolated as intended. See
+// ReferencedVariables to get all of them along with whether they have a
+// default.
+func RequiredVariables(files [][]byte) ([]string, error) {
+	variables, err := ReferencedVariables(files)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, variable := range variables {
+		if !variable.HasDefault {
+			names = append(names, variable.Name)
+		}
+	}
+	return names, nil
+}
+
+func scanValueVariables(value interface{}, found func(string, bool)) {
+	switch typedValue := value.(type) {
+	case string:
+		scanVariables(typedValue, found)
+	case []interface{}:
+		for _, v := range typedValue {
+			scanValueVariables(v, found)
+		}
+	case map[interface{}]interface{}:
+		for _, v := range typedValue {
+			scanValueVariables(v, found)
+		}
+	}
+}
+
+// scanVariables calls found for each variable referenced by the specified
+// value, along with whether the reference provides a default value. Escaped
+// dollars ($$) and malformed references are ignored. The variables nested in
+// the default of a reference are only needed if it applies, they are
+// reported as having a default too.
+func scanVariables(value string, found func(string, bool)) {
+	for pos := 0; pos < len(value)-1; pos++ {
+		if value[pos] != '$' {
+			continue
+		}
+		pos++
+		switch c := value[pos]; {
+		case c == '{':
+			expression, end, err := parseOperand(value, pos+1)
+			if err != nil {
+				return
+			}
+			name, withDefault := expression, false
+			if i := strings.IndexAny(expression, ":-?"); i >= 0 {
+				name = expression[:i]
+				operator := strings.TrimPrefix(expression[i:], ":")
+				withDefault = strings.HasPrefix(operator, "-")
+				if operator != "" {
+					scanVariables(operator[1:], func(nested string, nestedDefault bool) {
+						found(nested, nestedDefault || withDefault)
+					})
+				}
+			}
+			if name != "" {
+				found(name, withDefault)
+			}
+			pos = end
+		case !isNum(c) && validVariableNameChar(c):
+			start := pos
+			for pos < len(value) && validVariableNameChar(value[pos]) {
+				pos++
+			}
+			found(value[start:pos], false)
+			pos--
+		}
+	}
+}
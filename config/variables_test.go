@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferencedVariables(t *testing.T) {
+	files := [][]byte{[]byte(`
+version: '2'
+services:
+  web:
+    image: ${IMAGE}:${TAG:-latest}
+    command: echo $$ESCAPED $GREETING
+    environment:
+      - PORT=${PORT-80}
+    x-no-interpolate: [labels]
+    labels:
+      rendered: ${LATER}
+volumes:
+  data:
+    driver: ${DRIVER}
+`), []byte(`
+version: '2'
+services:
+  web:
+    ports:
+      - ${PORT}:80
+`)}
+
+	variables, err := ReferencedVariables(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Variable{
+		{Name: "DRIVER"},
+		{Name: "GREETING"},
+		{Name: "IMAGE"},
+		{Name: "PORT"},
+		{Name: "TAG", HasDefault: true},
+	}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Fatalf("Expected %v, got %v", expected, variables)
+	}
+
+	required, err := RequiredVariables(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRequired := []string{"DRIVER", "GREETING", "IMAGE", "PORT"}
+	if !reflect.DeepEqual(required, expectedRequired) {
+		t.Fatalf("Expected %v, got %v", expectedRequired, required)
+	}
+}
+
+func TestReferencedVariablesV1(t *testing.T) {
+	variables, err := ReferencedVariables([][]byte{[]byte(`
+web:
+  image: busybox
+  working_dir: ${DIR:-/srv}
+`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Variable{{Name: "DIR", HasDefault: true}}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Fatalf("Expected %v, got %v", expected, variables)
+	}
+}
+
+func TestReferencedVariablesNestedDefaults(t *testing.T) {
+	variables, err := ReferencedVariables([][]byte{[]byte(`
+version: '2'
+services:
+  web:
+    image: ${IMAGE:-${REGISTRY}/web:${TAG-latest}}
+    working_dir: ${DIR:?${REASON} is required}
+    user: ${USER}
+`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Variable{
+		{Name: "DIR"},
+		{Name: "IMAGE", HasDefault: true},
+		{Name: "REASON"},
+		{Name: "REGISTRY", HasDefault: true},
+		{Name: "TAG", HasDefault: true},
+		{Name: "USER"},
+	}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Fatalf("Expected %v, got %v", expected, variables)
+	}
+}
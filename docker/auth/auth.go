@@ -5,6 +5,7 @@ import (
 	clitypes "github.com/docker/cli/cli/config/types"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/registry"
+	"github.com/sirupsen/logrus"
 )
 
 // Lookup defines a method for looking up authentication information
@@ -25,20 +26,38 @@ func NewConfigLookup(configfile *configfile.ConfigFile) *ConfigLookup {
 	}
 }
 
-// Lookup uses a Docker config file to lookup authentication information
+// Lookup uses a Docker config file to lookup authentication information for
+// the registry of the specified repository. The credentials are read from the
+// credential helper configured for the registry (credHelpers), from the
+// default credential store (credsStore) or from the config file itself.
 func (c *ConfigLookup) Lookup(repoInfo *registry.RepositoryInfo) types.AuthConfig {
 	if c.ConfigFile == nil || repoInfo == nil || repoInfo.Index == nil {
 		return types.AuthConfig{}
 	}
-	return registry.ResolveAuthConfig(convert(c.ConfigFile.AuthConfigs), repoInfo.Index)
+	server := repoInfo.Index.Name
+	if repoInfo.Index.Official {
+		server = registry.IndexServer
+	}
+	authConfig, err := c.ConfigFile.GetAuthConfig(server)
+	if err != nil {
+		logrus.Warnf("Failed to get the credentials of %s: %v", server, err)
+		return types.AuthConfig{}
+	}
+	return convertAuthConfig(authConfig)
 }
 
-// All uses a Docker config file to get all authentication information
+// All uses a Docker config file to get all authentication information,
+// including the one held by credential helpers.
 func (c *ConfigLookup) All() map[string]types.AuthConfig {
 	if c.ConfigFile == nil {
 		return map[string]types.AuthConfig{}
 	}
-	return convert(c.ConfigFile.AuthConfigs)
+	authConfigs, err := c.ConfigFile.GetAllCredentials()
+	if err != nil {
+		logrus.Warnf("Failed to get the credentials from the credential stores: %v", err)
+		return convert(c.ConfigFile.AuthConfigs)
+	}
+	return convert(authConfigs)
 }
 
 func convert(acs map[string]clitypes.AuthConfig) map[string]types.AuthConfig {
@@ -48,15 +67,19 @@ func convert(acs map[string]clitypes.AuthConfig) map[string]types.AuthConfig {
 
 	result := map[string]types.AuthConfig{}
 	for k, v := range acs {
-		result[k] = types.AuthConfig{
-			Username:      v.Username,
-			Password:      v.Password,
-			Auth:          v.Auth,
-			Email:         v.Email,
-			ServerAddress: v.ServerAddress,
-			IdentityToken: v.IdentityToken,
-			RegistryToken: v.RegistryToken,
-		}
+		result[k] = convertAuthConfig(v)
 	}
 	return result
 }
+
+func convertAuthConfig(ac clitypes.AuthConfig) types.AuthConfig {
+	return types.AuthConfig{
+		Username:      ac.Username,
+		Password:      ac.Password,
+		Auth:          ac.Auth,
+		Email:         ac.Email,
+		ServerAddress: ac.ServerAddress,
+		IdentityToken: ac.IdentityToken,
+		RegistryToken: ac.RegistryToken,
+	}
+}
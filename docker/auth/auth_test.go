@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker/cli/cli/config/configfile"
+	clitypes "github.com/docker/cli/cli/config/types"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func repositoryInfo(t *testing.T, image string) *registry.RepositoryInfo {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoInfo, err := registry.ParseRepositoryInfo(named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repoInfo
+}
+
+func TestConfigLookupFromConfigFile(t *testing.T) {
+	configFile := configfile.New("config.json")
+	configFile.AuthConfigs = map[string]clitypes.AuthConfig{
+		"https://index.docker.io/v1/": {Username: "hub-user", Password: "hub-password"},
+		"registry.example.com":        {Username: "user", Password: "password"},
+	}
+	lookup := NewConfigLookup(configFile)
+
+	authConfig := lookup.Lookup(repositoryInfo(t, "busybox"))
+	assert.Equal(t, "hub-user", authConfig.Username)
+
+	authConfig = lookup.Lookup(repositoryInfo(t, "registry.example.com/team/app:1.0"))
+	assert.Equal(t, "user", authConfig.Username)
+	assert.Equal(t, "password", authConfig.Password)
+
+	authConfig = lookup.Lookup(repositoryInfo(t, "other.example.com/app"))
+	assert.Equal(t, "", authConfig.Username)
+
+	assert.Len(t, lookup.All(), 2)
+}
+
+func TestConfigLookupFromCredentialHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake credential helper is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "credential-helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	helper := `#!/bin/sh
+read server
+echo "{\"ServerURL\":\"$server\",\"Username\":\"helper-user\",\"Secret\":\"helper-secret\"}"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-fake"), []byte(helper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	configFile := configfile.New("config.json")
+	configFile.CredentialHelpers = map[string]string{
+		"registry.example.com": "fake",
+	}
+	lookup := NewConfigLookup(configFile)
+
+	authConfig := lookup.Lookup(repositoryInfo(t, "registry.example.com/app"))
+	assert.Equal(t, "helper-user", authConfig.Username)
+	assert.Equal(t, "helper-secret", authConfig.Password)
+	assert.Equal(t, "helper-user", lookup.All()["registry.example.com"].Username)
+}
@@ -3,6 +3,7 @@ package builder
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
@@ -18,6 +19,7 @@ import (
 	"github.com/docker/docker/pkg/progress"
 	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/docker/docker/pkg/term"
+	"github.com/zengchen221/libcompose/config"
 	"github.com/zengchen221/libcompose/logger"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
@@ -32,10 +34,14 @@ type Builder interface {
 	Build(imageName string) error
 }
 
-// DaemonBuilder is the daemon "docker build" Builder implementation.
+// DaemonBuilder is the daemon "docker build" Builder implementation. The build
+// context is a tar of ContextDirectory, unless a ContextReader (a tar stream)
+// is provided. A ContextDirectory that is a git or http(s) url is a remote
+// context, fetched by the daemon itself.
 type DaemonBuilder struct {
 	Client           client.ImageAPIClient
 	ContextDirectory string
+	ContextReader    io.Reader
 	Dockerfile       string
 	AuthConfigs      map[string]types.AuthConfig
 	NoCache          bool
@@ -45,23 +51,45 @@ type DaemonBuilder struct {
 	CacheFrom        []string
 	Labels           map[string]*string
 	Network          string
+	Platform         string
+	ShmSize          int64
 	Target           string
 	LoggerFactory    logger.Factory
+	// LoggerName is the name of the build logger, the image name if empty.
+	LoggerName string
 }
 
 // Build implements Builder. It consumes the docker build API endpoint and sends
 // a tar of the specified service build context.
 func (d *DaemonBuilder) Build(ctx context.Context, imageName string) error {
-	buildCtx, err := CreateTar(d.ContextDirectory, d.Dockerfile)
-	if err != nil {
-		return err
+	var buildCtx io.ReadCloser
+	var remoteContext string
+	if d.ContextReader != nil {
+		buildCtx = ioutil.NopCloser(d.ContextReader)
+	} else if config.IsValidRemote(d.ContextDirectory) {
+		if err := validateRemoteDockerfile(d.ContextDirectory, d.Dockerfile); err != nil {
+			return err
+		}
+		remoteContext = d.ContextDirectory
+	} else {
+		var err error
+		buildCtx, err = CreateTar(d.ContextDirectory, d.Dockerfile)
+		if err != nil {
+			return err
+		}
+	}
+	if buildCtx != nil {
+		defer buildCtx.Close()
 	}
-	defer buildCtx.Close()
 	if d.LoggerFactory == nil {
 		d.LoggerFactory = &logger.NullLogger{}
 	}
 
-	l := d.LoggerFactory.CreateBuildLogger(imageName)
+	loggerName := d.LoggerName
+	if loggerName == "" {
+		loggerName = imageName
+	}
+	l := d.LoggerFactory.CreateBuildLogger(loggerName)
 
 	progBuff := &logger.Wrapper{
 		Err:    false,
@@ -78,10 +106,13 @@ func (d *DaemonBuilder) Build(ctx context.Context, imageName string) error {
 		Logger: l,
 	}
 
-	// Setup an upload progress bar
-	progressOutput := streamformatter.NewProgressOutput(progBuff)
+	var body io.Reader
+	if buildCtx != nil {
+		// Setup an upload progress bar
+		progressOutput := streamformatter.NewProgressOutput(progBuff)
 
-	var body io.Reader = progress.NewProgressReader(buildCtx, progressOutput, 0, "", "Sending build context to Docker daemon")
+		body = progress.NewProgressReader(buildCtx, progressOutput, 0, "", "Sending build context to Docker daemon")
+	}
 
 	logrus.Infof("Building %s...", imageName)
 
@@ -98,18 +129,21 @@ func (d *DaemonBuilder) Build(ctx context.Context, imageName string) error {
 	}
 
 	response, err := d.Client.ImageBuild(ctx, body, types.ImageBuildOptions{
-		Tags:        []string{imageName},
-		NoCache:     d.NoCache,
-		Remove:      true,
-		ForceRemove: d.ForceRemove,
-		PullParent:  d.Pull,
-		Dockerfile:  d.Dockerfile,
-		AuthConfigs: d.AuthConfigs,
-		BuildArgs:   d.BuildArgs,
-		CacheFrom:   d.CacheFrom,
-		Labels:      labels,
-		NetworkMode: d.Network,
-		Target:      d.Target,
+		Tags:          []string{imageName},
+		NoCache:       d.NoCache,
+		Remove:        true,
+		ForceRemove:   d.ForceRemove,
+		PullParent:    d.Pull,
+		Dockerfile:    d.Dockerfile,
+		AuthConfigs:   d.AuthConfigs,
+		BuildArgs:     d.BuildArgs,
+		CacheFrom:     d.CacheFrom,
+		Labels:        labels,
+		NetworkMode:   d.Network,
+		Target:        d.Target,
+		Platform:      d.Platform,
+		ShmSize:       d.ShmSize,
+		RemoteContext: remoteContext,
 	})
 	if err != nil {
 		return err
@@ -129,6 +163,65 @@ func (d *DaemonBuilder) Build(ctx context.Context, imageName string) error {
 	return err
 }
 
+// validateRemoteDockerfile checks that the specified Dockerfile can be found
+// by the daemon in the specified remote context: it must be relative to the
+// root of the context, and inside of it.
+func validateRemoteDockerfile(remoteContext, dockerfile string) error {
+	if dockerfile == "" {
+		return nil
+	}
+	if filepath.IsAbs(dockerfile) {
+		return fmt.Errorf("Cannot use the local Dockerfile %s with the remote build context %s: the Dockerfile must be relative to the root of the context", dockerfile, remoteContext)
+	}
+	if clean := path.Clean(filepath.ToSlash(dockerfile)); clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("Cannot use the Dockerfile %s with the remote build context %s: the Dockerfile must be inside the context", dockerfile, remoteContext)
+	}
+	return nil
+}
+
+// BaseImages returns the images the specified Dockerfile (relative to the
+// context directory, the default one if empty) builds from, as listed by its
+// FROM instructions. Build stages, scratch and images referencing build
+// arguments are skipped, as they can't be pulled beforehand.
+func BaseImages(contextDirectory, dockerfile string) ([]string, error) {
+	if dockerfile == "" {
+		dockerfile = DefaultDockerfileName
+	}
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(contextDirectory, dockerfile)
+	}
+	content, err := ioutil.ReadFile(dockerfile)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := map[string]bool{}
+	seen := map[string]bool{}
+	images := []string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		args := fields[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			continue
+		}
+		baseImage := args[0]
+		if !stages[strings.ToLower(baseImage)] && baseImage != "scratch" && !strings.Contains(baseImage, "$") && !seen[baseImage] {
+			seen[baseImage] = true
+			images = append(images, baseImage)
+		}
+		if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages[strings.ToLower(args[2])] = true
+		}
+	}
+	return images, nil
+}
+
 // CreateTar create a build context tar for the specified project and service name.
 func CreateTar(contextDirectory, dockerfile string) (io.ReadCloser, error) {
 	// This code was ripped off from docker/api/client/build.go
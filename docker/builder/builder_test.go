@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"golang.org/x/net/context"
@@ -22,13 +23,22 @@ import (
 
 type daemonClient struct {
 	client.Client
-	contextDir string
-	imageName  string
-	changes    int
-	message    jsonmessage.JSONMessage
+	contextDir    string
+	remoteContext string
+	imageName     string
+	changes       int
+	message       jsonmessage.JSONMessage
 }
 
 func (c *daemonClient) ImageBuild(ctx context.Context, context io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+	if c.remoteContext != "" {
+		if context != nil || options.RemoteContext != c.remoteContext {
+			return types.ImageBuildResponse{}, fmt.Errorf("expected remote context %q without body, got %q", c.remoteContext, options.RemoteContext)
+		}
+		return types.ImageBuildResponse{
+			Body: ioutil.NopCloser(strings.NewReader("{}")),
+		}, nil
+	}
 	if c.imageName != "" {
 		if len(options.Tags) != 1 || options.Tags[0] != c.imageName {
 			return types.ImageBuildResponse{}, fmt.Errorf("expected image %q, got %v", c.imageName, options.Tags)
@@ -357,3 +367,115 @@ func TestBuildWithErrorJSONMessage(t *testing.T) {
 		t.Fatalf("expected an error about %q, got %s", expectedError, err)
 	}
 }
+
+func TestBuildWithContextReader(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "daemonbuilder-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, DefaultDockerfileName), []byte("FROM busybox"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "afile"), []byte("another file"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	contextReader, err := archive.Tar(tmpDir, archive.Uncompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imageName := "image"
+	client := &daemonClient{
+		contextDir: tmpDir,
+		imageName:  imageName,
+	}
+	builder := &DaemonBuilder{
+		ContextDirectory: "/does/not/exist",
+		ContextReader:    contextReader,
+		Client:           client,
+	}
+
+	err = builder.Build(context.Background(), imageName)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildWithRemoteContext(t *testing.T) {
+	testCases := []struct {
+		remoteContext string
+		dockerfile    string
+		expectedError string
+	}{
+		{
+			remoteContext: "https://github.com/docker/compose.git",
+		},
+		{
+			remoteContext: "git://github.com/docker/compose.git#master:tests",
+			dockerfile:    "build/Dockerfile.test",
+		},
+		{
+			remoteContext: "https://example.com/context.tar.gz",
+			dockerfile:    "./Dockerfile",
+		},
+		{
+			remoteContext: "https://github.com/docker/compose.git",
+			dockerfile:    "/home/me/Dockerfile",
+			expectedError: "Cannot use the local Dockerfile /home/me/Dockerfile with the remote build context https://github.com/docker/compose.git: the Dockerfile must be relative to the root of the context",
+		},
+		{
+			remoteContext: "https://github.com/docker/compose.git",
+			dockerfile:    "sub/../../Dockerfile",
+			expectedError: "Cannot use the Dockerfile sub/../../Dockerfile with the remote build context https://github.com/docker/compose.git: the Dockerfile must be inside the context",
+		},
+	}
+	for _, c := range testCases {
+		builder := &DaemonBuilder{
+			ContextDirectory: c.remoteContext,
+			Dockerfile:       c.dockerfile,
+			Client: &daemonClient{
+				remoteContext: c.remoteContext,
+			},
+		}
+		err := builder.Build(context.Background(), "image")
+		if c.expectedError == "" && err != nil {
+			t.Errorf("expected no error for %s, got %v", c.remoteContext, err)
+		}
+		if c.expectedError != "" && (err == nil || err.Error() != c.expectedError) {
+			t.Errorf("expected error %q, got %v", c.expectedError, err)
+		}
+	}
+}
+
+func TestBaseImages(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "daemonbuilder-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dockerfile := `ARG VERSION=3.10
+FROM golang:1.12 AS builder
+RUN go build ./...
+from --platform=linux/amd64 alpine:${VERSION}
+FROM builder
+FROM scratch
+FROM golang:1.12
+FROM busybox
+`
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "Dockerfile"), []byte(dockerfile), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := BaseImages(tmpDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(images, []string{"golang:1.12", "busybox"}) {
+		t.Fatalf("Expected golang:1.12 and busybox, got %v", images)
+	}
+
+	if _, err := BaseImages(tmpDir, "missing.Dockerfile"); err == nil {
+		t.Fatal("Expected an error for a missing Dockerfile")
+	}
+}
@@ -15,7 +15,11 @@ import (
 )
 
 const (
-	// DefaultAPIVersion is the default docker API version set by libcompose
+	// DefaultAPIVersion is the docker API version libcompose used to pin
+	// clients to.
+	//
+	// Deprecated: the API version is now negotiated with the daemon unless
+	// Options.APIVersion or DOCKER_API_VERSION is set.
 	DefaultAPIVersion   = "v1.20"
 	defaultTrustKeyFile = "key.json"
 	defaultCaFile       = "ca.pem"
@@ -23,16 +27,6 @@ const (
 	defaultCertFile     = "cert.pem"
 )
 
-var (
-	dockerCertPath = os.Getenv("DOCKER_CERT_PATH")
-)
-
-func init() {
-	if dockerCertPath == "" {
-		dockerCertPath = cliconfig.Dir()
-	}
-}
-
 // Options holds docker client options (host, tls, ..)
 type Options struct {
 	TLS        bool
@@ -40,35 +34,62 @@ type Options struct {
 	TLSOptions tlsconfig.Options
 	TrustKey   string
 	Host       string
+	// HTTPClient is the http client used to reach the daemon (e.g. to go
+	// through a proxy, or to mock the daemon in tests). It replaces the one
+	// built from the tls options, which are then ignored.
+	HTTPClient *http.Client
+	// APIVersion pins the docker API version used by the client. If neither
+	// it nor DOCKER_API_VERSION is set, the version is negotiated with the
+	// daemon on the first request, so that older daemons are supported.
 	APIVersion string
 }
 
-// Create creates a docker client based on the specified options.
+// Create creates a docker client based on the specified options. Options
+// that are not set are read from the environment (DOCKER_HOST,
+// DOCKER_CERT_PATH, DOCKER_TLS_VERIFY and DOCKER_API_VERSION) like the docker
+// CLI does, explicit ones win. Unless pinned, the API version is negotiated
+// with the daemon.
 func Create(c Options) (client.APIClient, error) {
-	if c.Host == "" {
-		if os.Getenv("DOCKER_API_VERSION") == "" {
-			os.Setenv("DOCKER_API_VERSION", DefaultAPIVersion)
-		}
-		client, err := client.NewEnvClient()
+	c = withEnvironment(c)
+
+	customHeaders := map[string]string{}
+	customHeaders["User-Agent"] = fmt.Sprintf("Libcompose-Client/%s (%s)", version.VERSION, runtime.GOOS)
+
+	if c.Host == "" && !c.TLS && !c.TLSVerify {
+		client, err := client.NewClientWithOpts(
+			client.FromEnv,
+			client.WithHTTPClient(c.HTTPClient),
+			client.WithVersion(c.APIVersion),
+			client.WithAPIVersionNegotiation(),
+			client.WithHTTPHeaders(customHeaders),
+		)
 		if err != nil {
 			return nil, err
 		}
 		return client, nil
 	}
 
+	if c.Host == "" {
+		c.Host = client.DefaultDockerHost
+	}
+
 	apiVersion := c.APIVersion
 	if apiVersion == "" {
-		apiVersion = DefaultAPIVersion
+		apiVersion = os.Getenv("DOCKER_API_VERSION")
 	}
 
+	certPath := os.Getenv("DOCKER_CERT_PATH")
+	if certPath == "" {
+		certPath = cliconfig.Dir()
+	}
 	if c.TLSOptions.CAFile == "" {
-		c.TLSOptions.CAFile = filepath.Join(dockerCertPath, defaultCaFile)
+		c.TLSOptions.CAFile = filepath.Join(certPath, defaultCaFile)
 	}
 	if c.TLSOptions.CertFile == "" {
-		c.TLSOptions.CertFile = filepath.Join(dockerCertPath, defaultCertFile)
+		c.TLSOptions.CertFile = filepath.Join(certPath, defaultCertFile)
 	}
 	if c.TLSOptions.KeyFile == "" {
-		c.TLSOptions.KeyFile = filepath.Join(dockerCertPath, defaultKeyFile)
+		c.TLSOptions.KeyFile = filepath.Join(certPath, defaultKeyFile)
 	}
 	if c.TrustKey == "" {
 		c.TrustKey = filepath.Join(homedir.Get(), ".docker", defaultTrustKeyFile)
@@ -80,8 +101,8 @@ func Create(c Options) (client.APIClient, error) {
 		c.TLSOptions.InsecureSkipVerify = !c.TLSVerify
 	}
 
-	var httpClient *http.Client
-	if c.TLS {
+	httpClient := c.HTTPClient
+	if httpClient == nil && c.TLS {
 		config, err := tlsconfig.Client(c.TLSOptions)
 		if err != nil {
 			return nil, err
@@ -94,17 +115,48 @@ func Create(c Options) (client.APIClient, error) {
 		}
 	}
 
-	customHeaders := map[string]string{}
-	customHeaders["User-Agent"] = fmt.Sprintf("Libcompose-Client/%s (%s)", version.VERSION, runtime.GOOS)
-
-	client, err := client.NewClientWithOpts(
+	opts := []client.Opt{
 		client.WithHTTPClient(httpClient),
 		client.WithHost(c.Host),
+	}
+	if c.HTTPClient != nil {
+		// The transport of a custom client is left as is, the host is only
+		// used to build the request urls.
+		opts = []client.Opt{
+			client.WithHost(c.Host),
+			client.WithHTTPClient(httpClient),
+		}
+	}
+	client, err := client.NewClientWithOpts(append(opts,
 		client.WithVersion(apiVersion),
+		client.WithAPIVersionNegotiation(),
 		client.WithHTTPHeaders(customHeaders),
-	)
+	)...)
 	if err != nil {
 		return nil, err
 	}
 	return client, nil
 }
+
+// withEnvironment fills the host and tls settings of the specified options
+// from the environment when no host is set. As with the docker client, a
+// DOCKER_CERT_PATH implies tls, and DOCKER_TLS_VERIFY enables verification.
+// An explicit host only uses tls if requested by the options. The certificate
+// files are derived from DOCKER_CERT_PATH by Create. Options left empty with
+// no environment are handled by client.FromEnv.
+func withEnvironment(c Options) Options {
+	if c.Host != "" {
+		return c
+	}
+	c.Host = os.Getenv("DOCKER_HOST")
+	if c.Host == "" {
+		return c
+	}
+	if os.Getenv("DOCKER_CERT_PATH") != "" {
+		c.TLS = true
+	}
+	if !c.TLSVerify && os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		c.TLSVerify = true
+	}
+	return c
+}
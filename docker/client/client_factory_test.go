@@ -3,6 +3,8 @@ package client
 import (
 	"strings"
 	"testing"
+
+	"github.com/docker/docker/api"
 )
 
 func TestFactoryWithEnv(t *testing.T) {
@@ -13,14 +15,14 @@ func TestFactoryWithEnv(t *testing.T) {
 	}{
 		{
 			envs:            map[string]string{},
-			expectedVersion: "v1.20",
+			expectedVersion: api.DefaultVersion,
 		},
 		{
 			envs: map[string]string{
 				"DOCKER_CERT_PATH": "invalid/path",
 			},
 			expectedError:   "Could not load X509 key pair: open invalid/path/cert.pem: no such file or directory",
-			expectedVersion: "v1.20",
+			expectedVersion: api.DefaultVersion,
 		},
 		{
 			envs: map[string]string{
@@ -66,7 +68,7 @@ func TestFactoryWithOptions(t *testing.T) {
 			options: Options{
 				Host: "invalid://host",
 			},
-			expectedVersion: "v1.20",
+			expectedVersion: api.DefaultVersion,
 		},
 		{
 			options: Options{
@@ -2,12 +2,16 @@ package client
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
 
 	cliconfig "github.com/docker/cli/cli/config"
+	"github.com/docker/docker/api"
 	"github.com/docker/go-connections/tlsconfig"
+	"golang.org/x/net/context"
 )
 
 // TestCreateWithEnv creates client(s) using environment variables, using an empty Options.
@@ -19,7 +23,7 @@ func TestCreateWithEnv(t *testing.T) {
 	}{
 		{
 			envs:            map[string]string{},
-			expectedVersion: "v1.20",
+			expectedVersion: api.DefaultVersion,
 		},
 		{
 			envs: map[string]string{
@@ -37,7 +41,22 @@ func TestCreateWithEnv(t *testing.T) {
 			envs: map[string]string{
 				"DOCKER_HOST": "invalid://url",
 			},
-			expectedVersion: "v1.20",
+			expectedVersion: api.DefaultVersion,
+		},
+		{
+			envs: map[string]string{
+				"DOCKER_HOST":      "tcp://host",
+				"DOCKER_CERT_PATH": "invalid/path",
+			},
+			expectedError: "Could not load X509 key pair: open invalid/path/cert.pem: no such file or directory",
+		},
+		{
+			envs: map[string]string{
+				"DOCKER_HOST":       "tcp://host",
+				"DOCKER_CERT_PATH":  "fixtures",
+				"DOCKER_TLS_VERIFY": "1",
+			},
+			expectedVersion: api.DefaultVersion,
 		},
 		{
 			envs: map[string]string{
@@ -88,7 +107,7 @@ func TestCreateWithOptions(t *testing.T) {
 			options: Options{
 				Host: "invalid://host",
 			},
-			expectedVersion: "v1.20",
+			expectedVersion: api.DefaultVersion,
 		},
 		{
 			options: Options{
@@ -171,6 +190,92 @@ func TestCreateWithOptions(t *testing.T) {
 	}
 }
 
+func TestCreateOptionsOverrideEnv(t *testing.T) {
+	recoverEnvs := setupEnvs(t, map[string]string{
+		"DOCKER_HOST":      "host",
+		"DOCKER_CERT_PATH": "invalid/path",
+	})
+	defer recoverEnvs(t)
+
+	apiclient, err := Create(Options{
+		Host:      "tcp://host",
+		TLSVerify: true,
+		TLSOptions: tlsconfig.Options{
+			CertFile: "fixtures/cert.pem",
+			CAFile:   "fixtures/ca.pem",
+			KeyFile:  "fixtures/key.pem",
+		},
+		APIVersion: "v1.22",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apiclient.ClientVersion() != "v1.22" {
+		t.Errorf("expected v1.22, got %s", apiclient.ClientVersion())
+	}
+
+	// An explicit host doesn't pick the tls settings of the environment
+	if _, err := Create(Options{Host: "tcp://host"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateNegotiatesAPIVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.30")
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	apiclient, err := Create(Options{
+		Host: "tcp://" + strings.TrimPrefix(server.URL, "http://"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apiclient.Info(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if apiclient.ClientVersion() != "1.30" {
+		t.Errorf("expected the version to be negotiated to 1.30, got %s", apiclient.ClientVersion())
+	}
+}
+
+func TestCreateWithHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ID":"mocked"}`))
+	}))
+	defer server.Close()
+
+	requests := 0
+	apiclient, err := Create(Options{
+		Host: "tcp://" + strings.TrimPrefix(server.URL, "http://"),
+		HTTPClient: &http.Client{
+			Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				requests++
+				return http.DefaultTransport.RoundTrip(r)
+			}),
+		},
+		APIVersion: "1.30",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := apiclient.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "mocked" || requests != 1 {
+		t.Errorf("expected a single request through the custom client, got %d (%s)", requests, info.ID)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func setupEnvs(t *testing.T, envs map[string]string) func(*testing.T) {
 	oldEnvs := map[string]string{}
 	for key, value := range envs {
@@ -3,8 +3,10 @@ package container
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -22,6 +24,7 @@ import (
 	"github.com/zengchen221/libcompose/labels"
 	"github.com/zengchen221/libcompose/logger"
 	"github.com/zengchen221/libcompose/project"
+	"github.com/zengchen221/libcompose/project/options"
 	"github.com/sirupsen/logrus"
 )
 
@@ -124,8 +127,11 @@ func (c *Container) Remove(ctx context.Context, removeVolume bool) error {
 	})
 }
 
-// Stop stops the container.
+// Stop stops the container. If the container is not running, don't fail.
 func (c *Container) Stop(ctx context.Context, timeout int) error {
+	if !c.IsRunning(ctx) {
+		return nil
+	}
 	timeoutDuration := time.Duration(timeout) * time.Second
 	return c.client.ContainerStop(ctx, c.container.ID, &timeoutDuration)
 }
@@ -161,8 +167,11 @@ func (c *Container) updateInnerContainer(ctx context.Context) error {
 	return nil
 }
 
-// Kill kill the container.
+// Kill kill the container. If the container is not running, don't fail.
 func (c *Container) Kill(ctx context.Context, signal string) error {
+	if !c.IsRunning(ctx) {
+		return nil
+	}
 	return c.client.ContainerKill(ctx, c.container.ID, signal)
 }
 
@@ -171,6 +180,75 @@ func (c *Container) IsRunning(ctx context.Context) bool {
 	return c.container.State.Running
 }
 
+// WaitHealthy waits for the container to be healthy, polling its state at
+// the specified interval. It fails if the container has no healthcheck, is
+// unhealthy or isn't running anymore.
+func (c *Container) WaitHealthy(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := c.updateInnerContainer(ctx); err != nil {
+			return err
+		}
+		state := c.container.State
+		if state.Health == nil {
+			return fmt.Errorf("Container %s has no healthcheck configured", c.Name())
+		}
+		switch state.Health.Status {
+		case types.Healthy:
+			return nil
+		case types.Unhealthy:
+			return fmt.Errorf("Container %s is unhealthy", c.Name())
+		}
+		if !state.Running {
+			return fmt.Errorf("Container %s exited before being healthy", c.Name())
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WaitRunning waits for the container to be running, polling its state at
+// the specified interval.
+func (c *Container) WaitRunning(ctx context.Context, interval time.Duration) error {
+	for {
+		if err := c.updateInnerContainer(ctx); err != nil {
+			return err
+		}
+		if c.container.State.Running {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WaitExited waits for the container to exit, polling its state at the
+// specified interval, and returns its exit code.
+func (c *Container) WaitExited(ctx context.Context, interval time.Duration) (int, error) {
+	for {
+		if err := c.updateInnerContainer(ctx); err != nil {
+			return 0, err
+		}
+		state := c.container.State
+		if !state.Running && !state.Restarting && state.Status != "created" {
+			return state.ExitCode, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
 // Run creates, start and attach to the container based on the image name,
 // the specified configuration.
 // It will always create a new container.
@@ -315,20 +393,179 @@ func (c *Container) Restart(ctx context.Context, timeout int) error {
 	return c.client.ContainerRestart(ctx, c.container.ID, &timeoutDuration)
 }
 
-// Log forwards container logs to the project configured logger.
-func (c *Container) Log(ctx context.Context, l logger.Logger, follow bool) error {
+// Exec runs the specified command in the container, forwarding its output to
+// the specified logger, and waits for it to complete. It returns the exit
+// code of the command.
+func (c *Container) Exec(ctx context.Context, execConfig types.ExecConfig, l logger.Logger) (int, error) {
+	execConfig.AttachStdout = true
+	execConfig.AttachStderr = true
+	execConfig.Detach = false
+
+	exec, err := c.client.ContainerExecCreate(ctx, c.container.ID, execConfig)
+	if err != nil {
+		return -1, err
+	}
+
+	resp, err := c.client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{Tty: execConfig.Tty})
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Close()
+
+	if execConfig.Tty {
+		_, err = io.Copy(&logger.Wrapper{Logger: l}, resp.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(&logger.Wrapper{Logger: l}, &logger.Wrapper{Logger: l, Err: true}, resp.Reader)
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	inspect, err := c.client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return -1, err
+	}
+	return inspect.ExitCode, nil
+}
+
+// ExecCommand runs the specified command in the container. Unless detached,
+// the standard streams of the process are attached to it and its exit code
+// is returned once it completes.
+func (c *Container) ExecCommand(ctx context.Context, opts options.Exec) (int, error) {
+	execConfig := types.ExecConfig{
+		User:         opts.User,
+		Tty:          opts.Tty,
+		Detach:       opts.Detach,
+		AttachStdin:  !opts.Detach,
+		AttachStdout: !opts.Detach,
+		AttachStderr: !opts.Detach,
+		Cmd:          opts.Command,
+	}
+
+	exec, err := c.client.ContainerExecCreate(ctx, c.container.ID, execConfig)
+	if err != nil {
+		return -1, err
+	}
+
+	if opts.Detach {
+		return 0, c.client.ContainerExecStart(ctx, exec.ID, types.ExecStartCheck{Detach: true, Tty: opts.Tty})
+	}
+
+	resp, err := c.client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{Tty: opts.Tty})
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Close()
+
+	// Only an interactive stdin is put in raw mode, piped ones are left as is
+	if inFd, isTerminal := term.GetFdInfo(os.Stdin); opts.Tty && isTerminal {
+		state, err := term.SetRawTerminal(inFd)
+		if err != nil {
+			return -1, err
+		}
+		defer term.RestoreTerminal(inFd, state)
+	}
+
+	if err := holdHijackedConnection(opts.Tty, os.Stdin, os.Stdout, os.Stderr, resp); err != nil {
+		return -1, err
+	}
+
+	inspect, err := c.client.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return -1, err
+	}
+	return inspect.ExitCode, nil
+}
+
+// Top returns the processes running in the container.
+func (c *Container) Top(ctx context.Context) ([]project.ContainerProcess, error) {
+	top, err := c.client.ContainerTop(ctx, c.container.ID, []string{})
+	if err != nil {
+		return nil, err
+	}
+	processes := []project.ContainerProcess{}
+	for _, values := range top.Processes {
+		processes = append(processes, project.ContainerProcess{
+			Titles: top.Titles,
+			Values: values,
+		})
+	}
+	return processes, nil
+}
+
+// Inspect returns the current state of the container.
+func (c *Container) Inspect(ctx context.Context) (project.ContainerInfo, error) {
+	container, err := c.client.ContainerInspect(ctx, c.container.ID)
+	if err != nil {
+		return project.ContainerInfo{}, err
+	}
+	return containerInfo(&container), nil
+}
+
+func containerInfo(container *types.ContainerJSON) project.ContainerInfo {
+	info := project.ContainerInfo{
+		ID:   container.ID,
+		Name: strings.TrimPrefix(container.Name, "/"),
+	}
+	if container.Config != nil {
+		info.Service = container.Config.Labels[labels.SERVICE.Str()]
+		info.Image = container.Config.Image
+	}
+	info.Command = strings.Join(append([]string{container.Path}, container.Args...), " ")
+	if container.State != nil {
+		info.State = container.State.Status
+		if container.State.Health != nil {
+			info.Health = container.State.Health.Status
+		}
+	}
+
+	info.Ports = []project.ContainerPort{}
+	if container.NetworkSettings != nil {
+		for port, bindings := range container.NetworkSettings.Ports {
+			if len(bindings) == 0 {
+				info.Ports = append(info.Ports, project.ContainerPort{
+					ContainerPort: port.Int(),
+					Protocol:      port.Proto(),
+				})
+			}
+			for _, binding := range bindings {
+				hostPort, _ := strconv.Atoi(binding.HostPort)
+				info.Ports = append(info.Ports, project.ContainerPort{
+					ContainerPort: port.Int(),
+					Protocol:      port.Proto(),
+					HostIP:        binding.HostIP,
+					HostPort:      hostPort,
+				})
+			}
+		}
+	}
+	sort.Slice(info.Ports, func(i, j int) bool {
+		if info.Ports[i].ContainerPort != info.Ports[j].ContainerPort {
+			return info.Ports[i].ContainerPort < info.Ports[j].ContainerPort
+		}
+		if info.Ports[i].Protocol != info.Ports[j].Protocol {
+			return info.Ports[i].Protocol < info.Ports[j].Protocol
+		}
+		return info.Ports[i].HostIP < info.Ports[j].HostIP
+	})
+	return info
+}
+
+// CopyTo extracts the specified tar archive content in the specified path of
+// the container.
+func (c *Container) CopyTo(ctx context.Context, dstPath string, content io.Reader) error {
+	return c.client.CopyToContainer(ctx, c.container.ID, dstPath, content, types.CopyToContainerOptions{})
+}
+
+// Log forwards container logs to the project configured logger. When
+// following the logs, it returns once the context is cancelled.
+func (c *Container) Log(ctx context.Context, l logger.Logger, opts options.Log) error {
 	info, err := c.client.ContainerInspect(ctx, c.container.ID)
 	if err != nil {
 		return err
 	}
 
-	options := types.ContainerLogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Follow:     follow,
-		Tail:       "all",
-	}
-	responseBody, err := c.client.ContainerLogs(ctx, c.container.ID, options)
+	responseBody, err := c.client.ContainerLogs(ctx, c.container.ID, logsOptions(opts))
 	if err != nil {
 		return err
 	}
@@ -339,11 +576,84 @@ func (c *Container) Log(ctx context.Context, l logger.Logger, follow bool) error
 	} else {
 		_, err = stdcopy.StdCopy(&logger.Wrapper{Logger: l}, &logger.Wrapper{Logger: l, Err: true}, responseBody)
 	}
+	if ctx.Err() != nil {
+		// The stream has been interrupted on purpose
+		return nil
+	}
 	logrus.WithFields(logrus.Fields{"Logger": l, "err": err}).Debug("c.client.Logs() returned error")
 
 	return err
 }
 
+// logsOptions converts the specified log options to the docker ones. Both
+// stdout and stderr are shown if none is selected, and all the lines are
+// shown if no tail is specified.
+func logsOptions(opts options.Log) types.ContainerLogsOptions {
+	showStdout, showStderr := opts.Stdout, opts.Stderr
+	if !showStdout && !showStderr {
+		showStdout, showStderr = true, true
+	}
+	tail := opts.Tail
+	if tail == "" {
+		tail = "all"
+	}
+	return types.ContainerLogsOptions{
+		ShowStdout: showStdout,
+		ShowStderr: showStderr,
+		Timestamps: opts.Timestamps,
+		Follow:     opts.Follow,
+		Tail:       tail,
+		Since:      opts.Since,
+	}
+}
+
+// LogStream returns the logs of the container as a single stream. Unless the
+// container has a tty, the docker multiplexed stream is demultiplexed, keeping
+// only the selected streams (stdout and stderr if none is selected).
+func (c *Container) LogStream(ctx context.Context, opts options.Log) (io.ReadCloser, error) {
+	info, err := c.client.ContainerInspect(ctx, c.container.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	logsOpts := logsOptions(opts)
+	responseBody, err := c.client.ContainerLogs(ctx, c.container.ID, logsOpts)
+	if err != nil {
+		return nil, err
+	}
+	if info.Config.Tty {
+		return responseBody, nil
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer responseBody.Close()
+		stdout, stderr := io.Writer(ioutil.Discard), io.Writer(ioutil.Discard)
+		if logsOpts.ShowStdout {
+			stdout = writer
+		}
+		if logsOpts.ShowStderr {
+			stderr = writer
+		}
+		_, err := stdcopy.StdCopy(stdout, stderr, responseBody)
+		writer.CloseWithError(err)
+	}()
+	return &logStream{PipeReader: reader, body: responseBody}, nil
+}
+
+// logStream closes the underlying docker response when closed, so that a
+// followed stream doesn't leak.
+type logStream struct {
+	*io.PipeReader
+	body io.Closer
+}
+
+// Close implements io.Closer.
+func (l *logStream) Close() error {
+	l.body.Close()
+	return l.PipeReader.Close()
+}
+
 // Port returns the host port the specified port is mapped on.
 func (c *Container) Port(ctx context.Context, port string) (string, error) {
 	if bindings, ok := c.container.NetworkSettings.Ports[nat.Port(port)]; ok {
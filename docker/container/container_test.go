@@ -0,0 +1,100 @@
+package container
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/zengchen221/libcompose/project"
+	"github.com/zengchen221/libcompose/project/options"
+)
+
+func TestLogsOptions(t *testing.T) {
+	assert.Equal(t, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       "all",
+	}, logsOptions(options.Log{}))
+
+	assert.Equal(t, types.ContainerLogsOptions{
+		ShowStderr: true,
+		Timestamps: true,
+		Follow:     true,
+		Tail:       "100",
+		Since:      "42m",
+	}, logsOptions(options.Log{Follow: true, Stderr: true, Timestamps: true, Tail: "100", Since: "42m"}))
+}
+
+func TestContainerInfo(t *testing.T) {
+	info := containerInfo(&types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:   "abc",
+			Name: "/project_web_1",
+			Path: "nginx",
+			Args: []string{"-g", "daemon off;"},
+			State: &types.ContainerState{
+				Status: "running",
+				Health: &types.Health{Status: "healthy"},
+			},
+		},
+		Config: &container.Config{
+			Image:  "nginx:alpine",
+			Labels: map[string]string{"com.docker.compose.service": "web"},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{
+				Ports: nat.PortMap{
+					"443/tcp": nil,
+					"80/tcp":  {{HostIP: "0.0.0.0", HostPort: "8080"}},
+				},
+			},
+		},
+	})
+
+	assert.Equal(t, project.ContainerInfo{
+		ID:      "abc",
+		Name:    "project_web_1",
+		Service: "web",
+		Image:   "nginx:alpine",
+		Command: "nginx -g daemon off;",
+		State:   "running",
+		Health:  "healthy",
+		Ports: []project.ContainerPort{
+			{ContainerPort: 80, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 8080},
+			{ContainerPort: 443, Protocol: "tcp"},
+		},
+	}, info)
+}
+
+type stopRecordingClient struct {
+	client.ContainerAPIClient
+	stopped []string
+}
+
+func (c *stopRecordingClient) ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error {
+	c.stopped = append(c.stopped, containerID)
+	return nil
+}
+
+func TestStopOnlyRunningContainers(t *testing.T) {
+	apiClient := &stopRecordingClient{}
+	for _, running := range []bool{true, false} {
+		c := NewInspected(apiClient, &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:    fmt.Sprintf("running-%t", running),
+				State: &types.ContainerState{Running: running},
+			},
+		})
+		if err := c.Stop(context.Background(), 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, []string{"running-true"}, apiClient.stopped)
+}
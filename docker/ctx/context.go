@@ -9,13 +9,27 @@ import (
 )
 
 // Context holds context meta information about a libcompose project and docker
-// client information (like configuration file, builder to use, …)
+// client information (like configuration file, builder to use, …).
+// Factories allows to override (or wrap) the factories of the project
+// resources.
 type Context struct {
 	project.Context
 	ClientFactory client.Factory
 	ConfigDir     string
 	ConfigFile    *configfile.ConfigFile
 	AuthLookup    auth.Lookup
+	Factories     FactoryRegistry
+}
+
+// FactoryRegistry holds constructors overriding the factories a docker project
+// uses to create its services, networks and volumes. Each constructor is
+// called once the client factory is set up, with the factory that would be
+// used otherwise, so that it can wrap it (to tag the created resources, inject
+// test doubles…). A nil constructor keeps the default factory.
+type FactoryRegistry struct {
+	Service  func(context *Context, defaultFactory project.ServiceFactory) project.ServiceFactory
+	Networks func(context *Context, defaultFactory project.NetworksFactory) project.NetworksFactory
+	Volumes  func(context *Context, defaultFactory project.VolumesFactory) project.VolumesFactory
 }
 
 // LookupConfig tries to load the docker configuration files, if any.
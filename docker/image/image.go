@@ -4,16 +4,14 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os"
 
 	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
-	"github.com/docker/docker/pkg/term"
 	"github.com/docker/docker/registry"
 	"github.com/zengchen221/libcompose/docker/auth"
+	"github.com/zengchen221/libcompose/logger"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 )
@@ -39,15 +37,20 @@ func InspectImage(ctx context.Context, client client.ImageAPIClient, image strin
 
 // RemoveImage removes the specified image (can be a name, an id or a digest)
 // from the daemon store with the specified client.
-func RemoveImage(ctx context.Context, client client.ImageAPIClient, image string) error {
-	_, err := client.ImageRemove(ctx, image, types.ImageRemoveOptions{})
+func RemoveImage(ctx context.Context, clt client.ImageAPIClient, image string) error {
+	_, err := clt.ImageRemove(ctx, image, types.ImageRemoveOptions{})
+	if err != nil && client.IsErrNotFound(err) {
+		// Already removed, e.g. shared by several services
+		return nil
+	}
 	return err
 }
 
 // PullImage pulls the specified image (can be a name, an id or a digest)
-// to the daemon store with the specified client.
-func PullImage(ctx context.Context, client client.ImageAPIClient, serviceName string, authLookup auth.Lookup, image string) error {
-	fmt.Fprintf(os.Stderr, "Pulling %s (%s)...\n", serviceName, image)
+// to the daemon store with the specified client. The pull progress is
+// written to the specified logger.
+func PullImage(ctx context.Context, client client.ImageAPIClient, serviceName string, authLookup auth.Lookup, image, platform string, l logger.Logger) error {
+	logrus.Infof("Pulling %s (%s)...", serviceName, image)
 	distributionRef, err := reference.ParseNormalizedNamed(image)
 	if err != nil {
 		return err
@@ -68,6 +71,7 @@ func PullImage(ctx context.Context, client client.ImageAPIClient, serviceName st
 
 	options := types.ImagePullOptions{
 		RegistryAuth: encodedAuth,
+		Platform:     platform,
 	}
 	responseBody, err := client.ImagePull(ctx, distributionRef.String(), options)
 	if err != nil {
@@ -76,18 +80,15 @@ func PullImage(ctx context.Context, client client.ImageAPIClient, serviceName st
 	}
 	defer responseBody.Close()
 
-	var writeBuff io.Writer = os.Stderr
-
-	outFd, isTerminalOut := term.GetFdInfo(os.Stderr)
-
-	err = jsonmessage.DisplayJSONMessagesStream(responseBody, writeBuff, outFd, isTerminalOut, nil)
+	// Several images may be pulled at the same time, so the progress is
+	// never displayed as a terminal one
+	err = jsonmessage.DisplayJSONMessagesStream(responseBody, &logger.Wrapper{Logger: l}, 0, false, nil)
 	if err != nil {
 		if jerr, ok := err.(*jsonmessage.JSONError); ok {
 			// If no error code is set, default to 1
 			if jerr.Code == 0 {
 				jerr.Code = 1
 			}
-			fmt.Fprintf(os.Stderr, "%s", writeBuff)
 			return fmt.Errorf("Status: %s, Code: %d", jerr.Message, jerr.Code)
 		}
 	}
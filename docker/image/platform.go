@@ -0,0 +1,87 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/client"
+	"golang.org/x/net/context"
+)
+
+// PlatformMismatchError is returned by CheckPlatform when an image has been
+// built for another platform than the one of the service (or of the daemon if
+// the service doesn't specify any).
+type PlatformMismatchError struct {
+	Image          string
+	ImagePlatform  string
+	DaemonPlatform string
+	// ServicePlatform is set when the expected platform was specified by the
+	// service instead of being the daemon one.
+	ServicePlatform string
+}
+
+func (e *PlatformMismatchError) Error() string {
+	if e.ServicePlatform != "" {
+		return fmt.Sprintf("Image %s was built for platform %s, which does not match the service platform %s", e.Image, e.ImagePlatform, e.ServicePlatform)
+	}
+	return fmt.Sprintf("Image %s was built for platform %s, which does not match the daemon platform %s", e.Image, e.ImagePlatform, e.DaemonPlatform)
+}
+
+// normalizeArch converts the architecture reported by the daemon (uname style)
+// to the one used in image configurations (GOARCH style).
+func normalizeArch(arch string) string {
+	switch arch {
+	case "x86_64", "x86-64":
+		return "amd64"
+	case "aarch64":
+		return "arm64"
+	case "armhf", "armel", "armv6l", "armv7l":
+		return "arm"
+	case "i386", "i686":
+		return "386"
+	default:
+		return arch
+	}
+}
+
+// CheckPlatform inspects the specified image and compares its os and
+// architecture with the specified platform (os/arch[/variant]), or with the
+// daemon ones if it is empty. It returns a *PlatformMismatchError if they
+// differ.
+func CheckPlatform(ctx context.Context, clt client.CommonAPIClient, image, platform string) error {
+	imageInspect, err := InspectImage(ctx, clt, image)
+	if err != nil {
+		return err
+	}
+
+	var expectedOS, expectedArch string
+	if platform != "" {
+		parts := strings.Split(strings.ToLower(platform), "/")
+		expectedOS = parts[0]
+		if len(parts) > 1 {
+			expectedArch = normalizeArch(parts[1])
+		}
+	} else {
+		info, err := clt.Info(ctx)
+		if err != nil {
+			return err
+		}
+		expectedOS, expectedArch = info.OSType, normalizeArch(info.Architecture)
+	}
+
+	imageOS, imageArch := imageInspect.Os, normalizeArch(imageInspect.Architecture)
+	// Be lenient if any of the information is missing
+	if (imageOS != "" && expectedOS != "" && imageOS != expectedOS) || (imageArch != "" && expectedArch != "" && imageArch != expectedArch) {
+		mismatch := &PlatformMismatchError{
+			Image:         image,
+			ImagePlatform: imageOS + "/" + imageArch,
+		}
+		if platform != "" {
+			mismatch.ServicePlatform = platform
+		} else {
+			mismatch.DaemonPlatform = expectedOS + "/" + expectedArch
+		}
+		return mismatch
+	}
+	return nil
+}
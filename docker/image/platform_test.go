@@ -0,0 +1,78 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+type platformClient struct {
+	client.CommonAPIClient
+	image types.ImageInspect
+	info  types.Info
+}
+
+func (c *platformClient) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	return c.image, nil, nil
+}
+
+func (c *platformClient) Info(ctx context.Context) (types.Info, error) {
+	return c.info, nil
+}
+
+func TestCheckPlatform(t *testing.T) {
+	cases := []struct {
+		imageOS, imageArch, daemonOS, daemonArch string
+		mismatch                                 bool
+	}{
+		{"linux", "amd64", "linux", "x86_64", false},
+		{"linux", "arm64", "linux", "aarch64", false},
+		{"linux", "", "linux", "x86_64", false},
+		{"linux", "arm64", "linux", "x86_64", true},
+		{"windows", "amd64", "linux", "x86_64", true},
+	}
+
+	for _, c := range cases {
+		clt := &platformClient{
+			image: types.ImageInspect{Os: c.imageOS, Architecture: c.imageArch},
+			info:  types.Info{OSType: c.daemonOS, Architecture: c.daemonArch},
+		}
+		err := CheckPlatform(context.Background(), clt, "foo", "")
+		if c.mismatch {
+			assert.IsType(t, &PlatformMismatchError{}, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+}
+
+func TestCheckPlatformAgainstServicePlatform(t *testing.T) {
+	cases := []struct {
+		imageOS, imageArch, platform string
+		mismatch                     bool
+	}{
+		{"linux", "amd64", "linux/amd64", false},
+		{"linux", "arm", "linux/arm/v7", false},
+		{"linux", "amd64", "linux", false},
+		{"linux", "arm64", "linux/amd64", true},
+		{"windows", "amd64", "linux/amd64", true},
+	}
+
+	for _, c := range cases {
+		// The daemon runs on arm64, which must not matter here
+		clt := &platformClient{
+			image: types.ImageInspect{Os: c.imageOS, Architecture: c.imageArch},
+			info:  types.Info{OSType: "linux", Architecture: "aarch64"},
+		}
+		err := CheckPlatform(context.Background(), clt, "foo", c.platform)
+		if c.mismatch {
+			assert.IsType(t, &PlatformMismatchError{}, err)
+			assert.Contains(t, err.Error(), "service platform "+c.platform)
+		} else {
+			assert.Nil(t, err, "%v", c)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package image
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pullStateLock serializes the updates of the pull state files, as the
+// services of a project are pulled concurrently.
+var pullStateLock sync.Mutex
+
+// PullState records when the images of a project were last pulled, so that
+// the time-windowed pull policies (daily, weekly, every_<duration>) only pull
+// once the window has elapsed. The state is stored as a JSON file mapping
+// image names to the time of their last pull.
+type PullState struct {
+	Path string
+}
+
+// NewPullState returns the PullState of the specified project, stored in
+// <user cache directory>/libcompose/pulls/<project>.json (e.g.
+// ~/.cache/libcompose/pulls/myproject.json on Linux).
+func NewPullState(project string) *PullState {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return &PullState{
+		Path: filepath.Join(cacheDir, "libcompose", "pulls", project+".json"),
+	}
+}
+
+func (s *PullState) read() (map[string]time.Time, error) {
+	pulls := map[string]time.Time{}
+	bytes, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return pulls, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(bytes, &pulls); err != nil {
+		return nil, err
+	}
+	return pulls, nil
+}
+
+// LastPull returns the time of the last recorded pull of the specified image,
+// the zero time if there is none.
+func (s *PullState) LastPull(image string) (time.Time, error) {
+	pullStateLock.Lock()
+	defer pullStateLock.Unlock()
+
+	pulls, err := s.read()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return pulls[image], nil
+}
+
+// RecordPull records that the specified image was pulled at the specified
+// time.
+func (s *PullState) RecordPull(image string, pulledAt time.Time) error {
+	pullStateLock.Lock()
+	defer pullStateLock.Unlock()
+
+	pulls, err := s.read()
+	if err != nil {
+		return err
+	}
+	pulls[image] = pulledAt
+
+	bytes, err := json.Marshal(pulls)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.Path, bytes, 0644)
+}
@@ -0,0 +1,34 @@
+package image
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullState(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "pull-state")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	state := &PullState{Path: filepath.Join(tmpDir, "pulls", "project.json")}
+
+	last, err := state.LastPull("busybox")
+	assert.Nil(t, err)
+	assert.True(t, last.IsZero())
+
+	pulledAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Nil(t, state.RecordPull("busybox", pulledAt))
+	assert.Nil(t, state.RecordPull("alpine", pulledAt.Add(time.Hour)))
+
+	last, err = state.LastPull("busybox")
+	assert.Nil(t, err)
+	assert.True(t, pulledAt.Equal(last))
+	last, err = state.LastPull("alpine")
+	assert.Nil(t, err)
+	assert.True(t, pulledAt.Add(time.Hour).Equal(last))
+}
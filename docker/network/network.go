@@ -23,14 +23,18 @@ type Network struct {
 	driverOptions map[string]string
 	ipam          config.Ipam
 	external      bool
+	internal      bool
+	attachable    bool
+	labels        map[string]string
+	// customName is true when the name is not scoped to the project
+	customName bool
 }
 
 func (n *Network) fullName() string {
-	name := n.projectName + "_" + n.name
-	if n.external {
-		name = n.name
+	if n.external || n.customName {
+		return n.name
 	}
-	return name
+	return n.projectName + "_" + n.name
 }
 
 // Inspect inspect the current network
@@ -76,9 +80,12 @@ func (n *Network) EnsureItExists(ctx context.Context) error {
 func (n *Network) create(ctx context.Context) error {
 	fmt.Printf("Creating network %q with driver %q\n", n.fullName(), n.driver)
 	_, err := n.client.NetworkCreate(ctx, n.fullName(), types.NetworkCreate{
-		Driver:  n.driver,
-		Options: n.driverOptions,
-		IPAM:    convertToAPIIpam(n.ipam),
+		Driver:     n.driver,
+		Options:    n.driverOptions,
+		IPAM:       convertToAPIIpam(n.ipam),
+		Internal:   n.internal,
+		Attachable: n.attachable,
+		Labels:     n.labels,
 	})
 	return err
 }
@@ -101,19 +108,25 @@ func convertToAPIIpam(ipam config.Ipam) *network.IPAM {
 
 // NewNetwork creates a new network from the specified name and config.
 func NewNetwork(projectName, name string, config *config.NetworkConfig, client client.NetworkAPIClient) *Network {
-	networkName := name
-	if config.External.External {
-		networkName = config.External.Name
-	}
-	return &Network{
+	network := &Network{
 		client:        client,
-		name:          networkName,
+		name:          name,
 		projectName:   projectName,
 		driver:        config.Driver,
 		driverOptions: config.DriverOpts,
 		external:      config.External.External,
 		ipam:          config.Ipam,
+		internal:      config.Internal,
+		attachable:    config.Attachable,
+		labels:        config.Labels,
+	}
+	if network.external && config.External.Name != "" {
+		network.name = config.External.Name
+	} else if config.Name != "" {
+		network.name = config.Name
+		network.customName = true
 	}
+	return network
 }
 
 // Networks holds a list of network
@@ -227,6 +227,8 @@ type networkClient struct {
 	inspectNetworkDriver    string
 	inspectNetworkOptions   map[string]string
 	removeError             error
+	createdName             string
+	created                 types.NetworkCreate
 }
 
 func (c *networkClient) NetworkInspect(ctx context.Context, networkID string, options types.NetworkInspectOptions) (types.NetworkResource, error) {
@@ -241,6 +243,8 @@ func (c *networkClient) NetworkInspect(ctx context.Context, networkID string, op
 }
 
 func (c *networkClient) NetworkCreate(ctx context.Context, name string, options types.NetworkCreate) (types.NetworkCreateResponse, error) {
+	c.createdName = name
+	c.created = options
 	if c.expectedName != "" {
 		if options.Driver != c.expectedNetworkCreate.Driver {
 			return types.NetworkCreateResponse{}, fmt.Errorf("Invalid network create, expected driver %q, got %q", c.expectedNetworkCreate.Driver, options.Driver)
@@ -542,3 +546,60 @@ func TestNetworksRemoveErrors(t *testing.T) {
 		t.Errorf("Expected a error, got nothing.")
 	}
 }
+
+func TestNewNetwork(t *testing.T) {
+	cases := []struct {
+		config       *config.NetworkConfig
+		expectedName string
+	}{
+		{&config.NetworkConfig{}, "prj_net1"},
+		{&config.NetworkConfig{Name: "shared"}, "shared"},
+		{&config.NetworkConfig{External: yaml.External{External: true}}, "net1"},
+		{&config.NetworkConfig{External: yaml.External{External: true, Name: "other"}}, "other"},
+	}
+	for index, c := range cases {
+		n := NewNetwork("prj", "net1", c.config, &networkClient{})
+		if n.fullName() != c.expectedName {
+			t.Fatalf("%d: expected %s, got %s", index, c.expectedName, n.fullName())
+		}
+	}
+}
+
+func TestNetworkCreateOptions(t *testing.T) {
+	cli := &networkClient{
+		expectedName: "prj_net1",
+		expectedNetworkCreate: types.NetworkCreate{
+			Driver: "overlay",
+		},
+		inspectError: networkNotFound{
+			network: "prj_net1",
+		},
+	}
+	n := NewNetwork("prj", "net1", &config.NetworkConfig{
+		Driver:     "overlay",
+		DriverOpts: map[string]string{"encrypted": "true"},
+		Internal:   true,
+		Attachable: true,
+		Labels:     yaml.SliceorMap{"com.example.label": "value"},
+		Ipam: config.Ipam{
+			Config: []config.IpamConfig{
+				{Subnet: "172.28.0.0/16", Gateway: "172.28.5.254"},
+			},
+		},
+	}, cli)
+	if err := n.EnsureItExists(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cli.createdName != "prj_net1" {
+		t.Fatalf("expected network prj_net1 to be created, got %q", cli.createdName)
+	}
+	if !cli.created.Internal || !cli.created.Attachable {
+		t.Fatalf("expected an internal and attachable network, got %+v", cli.created)
+	}
+	if cli.created.Labels["com.example.label"] != "value" || cli.created.Options["encrypted"] != "true" {
+		t.Fatalf("expected labels and options to be passed, got %+v", cli.created)
+	}
+	if len(cli.created.IPAM.Config) != 1 || cli.created.IPAM.Config[0].Gateway != "172.28.5.254" {
+		t.Fatalf("expected ipam config to be passed, got %+v", cli.created.IPAM)
+	}
+}
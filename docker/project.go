@@ -1,6 +1,8 @@
 package docker
 
 import (
+	"strings"
+
 	"golang.org/x/net/context"
 
 	"github.com/docker/docker/api/types"
@@ -54,6 +56,16 @@ func NewProject(context *ctx.Context, parseOptions *config.ParseOptions) (projec
 		context.VolumesFactory = volumesFactory
 	}
 
+	if context.Factories.Service != nil {
+		context.ServiceFactory = context.Factories.Service(context, context.ServiceFactory)
+	}
+	if context.Factories.Networks != nil {
+		context.NetworksFactory = context.Factories.Networks(context, context.NetworksFactory)
+	}
+	if context.Factories.Volumes != nil {
+		context.VolumesFactory = context.Factories.Volumes(context, context.VolumesFactory)
+	}
+
 	// FIXME(vdemeester) Remove the context duplication ?
 	runtime := &Project{
 		clientFactory: context.ClientFactory,
@@ -74,12 +86,14 @@ type Project struct {
 }
 
 // RemoveOrphans implements project.RuntimeProject.RemoveOrphans.
-// It will remove orphan containers that are part of the project but not to any services.
+// It stops and removes the containers that are part of the project but not
+// of any of its services.
 func (p *Project) RemoveOrphans(ctx context.Context, projectName string, serviceConfigs *config.ServiceConfigs) error {
 	client := p.clientFactory.Create(nil)
 	filter := filters.NewArgs()
 	filter.Add("label", labels.PROJECT.EqString(projectName))
 	containers, err := client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
 		Filters: filter,
 	})
 	if err != nil {
@@ -92,7 +106,12 @@ func (p *Project) RemoveOrphans(ctx context.Context, projectName string, service
 	for _, container := range containers {
 		serviceLabel := container.Labels[labels.SERVICE.Str()]
 		if _, ok := currentServices[serviceLabel]; !ok {
-			if err := client.ContainerKill(ctx, container.ID, "SIGKILL"); err != nil {
+			name := container.ID
+			if len(container.Names) > 0 {
+				name = strings.TrimPrefix(container.Names[0], "/")
+			}
+			logrus.Infof("Removing orphan container %s of service %s", name, serviceLabel)
+			if err := client.ContainerStop(ctx, container.ID, nil); err != nil {
 				return err
 			}
 			if err := client.ContainerRemove(ctx, container.ID, types.ContainerRemoveOptions{
@@ -0,0 +1,140 @@
+package docker
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/zengchen221/libcompose/config"
+	"github.com/zengchen221/libcompose/docker/ctx"
+	"github.com/zengchen221/libcompose/docker/network"
+	"github.com/zengchen221/libcompose/docker/service"
+	"github.com/zengchen221/libcompose/docker/volume"
+	"github.com/zengchen221/libcompose/lookup"
+	"github.com/zengchen221/libcompose/project"
+)
+
+type nullClientFactory struct{}
+
+func (f *nullClientFactory) Create(service project.Service) client.APIClient {
+	return nil
+}
+
+type taggingNetworksFactory struct {
+	project.NetworksFactory
+	created []string
+}
+
+func (f *taggingNetworksFactory) Create(projectName string, networkConfigs map[string]*config.NetworkConfig, serviceConfigs *config.ServiceConfigs, networkEnabled bool) (project.Networks, error) {
+	f.created = append(f.created, projectName)
+	return f.NetworksFactory.Create(projectName, networkConfigs, serviceConfigs, networkEnabled)
+}
+
+func TestFactoryRegistry(t *testing.T) {
+	networksFactory := &taggingNetworksFactory{}
+	var defaultServiceFactory project.ServiceFactory
+	var defaultVolumesFactory project.VolumesFactory
+
+	context := &ctx.Context{
+		Context: project.Context{
+			ComposeBytes: [][]byte{[]byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+`)},
+			ProjectName: "registry",
+		},
+		ClientFactory: &nullClientFactory{},
+		ConfigDir:     t.TempDir(),
+		Factories: ctx.FactoryRegistry{
+			Service: func(context *ctx.Context, defaultFactory project.ServiceFactory) project.ServiceFactory {
+				defaultServiceFactory = defaultFactory
+				return defaultFactory
+			},
+			Networks: func(context *ctx.Context, defaultFactory project.NetworksFactory) project.NetworksFactory {
+				networksFactory.NetworksFactory = defaultFactory
+				return networksFactory
+			},
+			Volumes: func(context *ctx.Context, defaultFactory project.VolumesFactory) project.VolumesFactory {
+				defaultVolumesFactory = defaultFactory
+				return defaultFactory
+			},
+		},
+	}
+
+	_, err := NewProject(context, nil)
+	assert.Nil(t, err)
+
+	assert.IsType(t, &service.Factory{}, defaultServiceFactory)
+	assert.IsType(t, &network.DockerFactory{}, networksFactory.NetworksFactory)
+	assert.IsType(t, &volume.DockerFactory{}, defaultVolumesFactory)
+	assert.Equal(t, networksFactory, context.NetworksFactory)
+	assert.Equal(t, []string{"registry"}, networksFactory.created)
+}
+
+func TestNewProjectUsesDotEnvLookup(t *testing.T) {
+	context := &ctx.Context{
+		Context: project.Context{
+			ComposeFiles: []string{"testdata/docker-compose.yml"},
+			ComposeBytes: [][]byte{[]byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+`)},
+			ProjectName: "dotenv",
+		},
+		ClientFactory: &nullClientFactory{},
+		ConfigDir:     t.TempDir(),
+	}
+
+	_, err := NewProject(context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &lookup.DotEnvLookup{Dir: "testdata"}, context.EnvironmentLookup)
+}
+
+type orphansClient struct {
+	client.Client
+	containers []types.Container
+	removed    []string
+}
+
+func (c *orphansClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	return c.containers, nil
+}
+
+func (c *orphansClient) ContainerStop(ctx context.Context, containerID string, timeout *time.Duration) error {
+	return nil
+}
+
+func (c *orphansClient) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+	c.removed = append(c.removed, containerID)
+	return nil
+}
+
+type orphansClientFactory struct {
+	client *orphansClient
+}
+
+func (f *orphansClientFactory) Create(service project.Service) client.APIClient {
+	return f.client
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	clt := &orphansClient{containers: []types.Container{
+		{ID: "1", Names: []string{"/app_web_1"}, Labels: map[string]string{"com.docker.compose.service": "web"}},
+		{ID: "2", Names: []string{"/app_worker_1"}, Labels: map[string]string{"com.docker.compose.service": "worker"}},
+	}}
+	serviceConfigs := config.NewServiceConfigs()
+	serviceConfigs.Add("web", &config.ServiceConfig{})
+
+	p := &Project{clientFactory: &orphansClientFactory{client: clt}}
+	err := p.RemoveOrphans(context.Background(), "app", serviceConfigs)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2"}, clt.removed)
+}
@@ -1,20 +1,30 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/docker/cli/opts"
+	"github.com/docker/docker/api/types/blkiodev"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/go-connections/nat"
 	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
 	"github.com/zengchen221/libcompose/config"
 	composeclient "github.com/zengchen221/libcompose/docker/client"
 	composecontainer "github.com/zengchen221/libcompose/docker/container"
+	"github.com/zengchen221/libcompose/labels"
 	"github.com/zengchen221/libcompose/project"
 	"github.com/zengchen221/libcompose/utils"
+	"github.com/zengchen221/libcompose/yaml"
 	"golang.org/x/net/context"
 )
 
@@ -62,21 +72,56 @@ func ConvertToAPI(serviceConfig *config.ServiceConfig, ctx project.Context, clie
 	}
 
 	result := ConfigWrapper{
-		Config:     config,
-		HostConfig: hostConfig,
+		Config:           config,
+		HostConfig:       hostConfig,
+		NetworkingConfig: networkingConfig(serviceConfig),
 	}
 	return &result, nil
 }
 
+// networkingConfig returns the endpoint settings (aliases and static
+// addresses) of the network the container is created with, i.e. the first
+// network of the service. The container is connected to the other ones once
+// created.
+func networkingConfig(c *config.ServiceConfig) *network.NetworkingConfig {
+	if c.NetworkMode != "" || c.Networks == nil || len(c.Networks.Networks) == 0 {
+		return nil
+	}
+	net := c.Networks.Networks[0]
+	endpoint := &network.EndpointSettings{
+		Aliases: append([]string{}, net.Aliases...),
+	}
+	if net.IPv4Address != "" || net.IPv6Address != "" || len(net.LinkLocalIPs) > 0 {
+		endpoint.IPAMConfig = &network.EndpointIPAMConfig{
+			IPv4Address:  net.IPv4Address,
+			IPv6Address:  net.IPv6Address,
+			LinkLocalIPs: net.LinkLocalIPs,
+		}
+	}
+	return &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			net.RealName: endpoint,
+		},
+	}
+}
+
+// volumes returns the binds and volumes of the specified service, in the
+// short syntax. The host paths of the binds are normally resolved against
+// the compose file defining them when parsing, the ones that are still
+// relative are resolved against the first compose file. Tmpfs volumes are
+// left out (see tmpfsMounts).
 func volumes(c *config.ServiceConfig, ctx project.Context) []string {
 	if c.Volumes == nil {
 		return []string{}
 	}
-	volumes := make([]string, len(c.Volumes.Volumes))
+	volumes := make([]string, 0, len(c.Volumes.Volumes))
 	for _, v := range c.Volumes.Volumes {
-		vol := v
-		if len(ctx.ComposeFiles) > 0 && !project.IsNamedVolume(v.Source) {
-			sourceVol := ctx.ResourceLookup.ResolvePath(v.String(), ctx.ComposeFiles[0])
+		if v.Type == yaml.VolumeTypeTmpfs {
+			continue
+		}
+		vol := *v
+		if len(ctx.ComposeFiles) > 0 && vol.Source != "" && (vol.Type == yaml.VolumeTypeBind || !project.IsNamedVolume(vol.Source)) {
+			sourceVol := ctx.ResourceLookup.ResolvePath(vol.String(), ctx.ComposeFiles[0])
 			vol.Source = strings.SplitN(sourceVol, ":", 2)[0]
 		}
 		volumes = append(volumes, vol.String())
@@ -84,7 +129,84 @@ func volumes(c *config.ServiceConfig, ctx project.Context) []string {
 	return volumes
 }
 
+// securityOpts returns the specified security options with the = separator
+// the daemon expects. As with the docker cli, seccomp profiles other than
+// unconfined are files whose content is sent inline, their paths being
+// resolved against the compose file when it is parsed.
+func securityOpts(opts []string) ([]string, error) {
+	var result []string
+	for _, opt := range opts {
+		key, value, ok := config.SplitSecurityOpt(opt)
+		if !ok {
+			result = append(result, opt)
+			continue
+		}
+		if key == "seccomp" && value != "unconfined" {
+			profile, err := ioutil.ReadFile(value)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to load seccomp profile %s: %v", value, err)
+			}
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, profile); err != nil {
+				return nil, fmt.Errorf("Invalid seccomp profile %s: %v", value, err)
+			}
+			value = compacted.String()
+		}
+		result = append(result, key+"="+value)
+	}
+	return result, nil
+}
+
+// tmpfsMounts returns the tmpfs mounts of the specified service, by path,
+// from both its tmpfs option and its tmpfs volumes. Sizes are checked to be
+// byte quantities.
+func tmpfsMounts(c *config.ServiceConfig) (map[string]string, error) {
+	tmpfs := map[string]string{}
+	for _, path := range c.Tmpfs {
+		split := strings.SplitN(path, ":", 2)
+		if len(split) == 1 {
+			tmpfs[split[0]] = ""
+			continue
+		}
+		for _, option := range strings.Split(split[1], ",") {
+			if strings.HasPrefix(option, "size=") {
+				if _, err := units.RAMInBytes(strings.TrimPrefix(option, "size=")); err != nil {
+					return nil, fmt.Errorf("invalid tmpfs size on %s: %v", split[0], err)
+				}
+			}
+		}
+		tmpfs[split[0]] = split[1]
+	}
+	if c.Volumes == nil {
+		return tmpfs, nil
+	}
+	for _, v := range c.Volumes.Volumes {
+		if v.Type != yaml.VolumeTypeTmpfs {
+			continue
+		}
+		options := []string{}
+		if v.ReadOnly() {
+			options = append(options, "ro")
+		}
+		if v.TmpfsSize != 0 {
+			options = append(options, fmt.Sprintf("size=%d", v.TmpfsSize))
+		}
+		if v.TmpfsMode != 0 {
+			options = append(options, fmt.Sprintf("mode=%o", v.TmpfsMode))
+		}
+		tmpfs[v.Destination] = strings.Join(options, ",")
+	}
+	return tmpfs, nil
+}
+
+// restartPolicy returns the restart policy of the specified service, the
+// maximum retry count being set for on-failure:N. The policy is checked even
+// if the config was not validated, so that an invalid one is not sent as is
+// to the engine.
 func restartPolicy(c *config.ServiceConfig) (*container.RestartPolicy, error) {
+	if err := config.ValidateRestartPolicy(c.Restart); err != nil {
+		return nil, err
+	}
 	restart, err := opts.ParseRestartPolicy(c.Restart)
 	if err != nil {
 		return nil, err
@@ -92,6 +214,10 @@ func restartPolicy(c *config.ServiceConfig) (*container.RestartPolicy, error) {
 	return &container.RestartPolicy{Name: restart.Name, MaximumRetryCount: restart.MaximumRetryCount}, nil
 }
 
+// ports returns the exposed ports and the port bindings of the specified
+// service. Port ranges are expanded into one binding per port (the host and
+// container ranges must have the same length), the protocol defaults to tcp
+// and a host ip can be specified (ip:hostPort:containerPort).
 func ports(c *config.ServiceConfig) (map[nat.Port]struct{}, nat.PortMap, error) {
 	ports, binding, err := nat.ParsePortSpecs(c.Ports)
 	if err != nil {
@@ -140,6 +266,20 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 		return nil, nil, err
 	}
 
+	deviceCgroupRules, err := parseDeviceCgroupRules(c.DeviceCgroupRules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extraHosts, err := parseExtraHosts(c.ExtraHosts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blkio := blkioResources(c.BlkioConfig)
+
+	hostname, domainname := hostnameAndDomainname(c)
+
 	var volumesFrom []string
 	if c.VolumesFrom != nil {
 		volumesFrom, err = getVolumesFrom(c.VolumesFrom, ctx.Project.ServiceConfigs, ctx.ProjectName)
@@ -152,13 +292,13 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 
 	config := &container.Config{
 		Entrypoint:   strslice.StrSlice(utils.CopySlice(c.Entrypoint)),
-		Hostname:     c.Hostname,
-		Domainname:   c.DomainName,
+		Hostname:     hostname,
+		Domainname:   domainname,
 		User:         c.User,
 		Env:          utils.CopySlice(c.Environment),
 		Cmd:          strslice.StrSlice(utils.CopySlice(c.Command)),
 		Image:        c.Image,
-		Labels:       utils.CopyMap(c.Labels),
+		Labels:       userLabels(c.Labels),
 		ExposedPorts: exposedPorts,
 		Tty:          c.Tty,
 		OpenStdin:    c.StdinOpen,
@@ -166,7 +306,8 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 		Volumes:      toMap(Filter(vols, isVolume)),
 		MacAddress:   c.MacAddress,
 		StopSignal:   c.StopSignal,
-		StopTimeout:  utils.DurationStrToSecondsInt(c.StopGracePeriod),
+		StopTimeout:  stopGracePeriod(c),
+		Healthcheck:  healthConfig(c.HealthCheck),
 	}
 
 	ulimits := []*units.Ulimit{}
@@ -180,20 +321,51 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 		}
 	}
 
-	memorySwappiness := int64(c.MemSwappiness)
+	var memorySwappiness *int64
+	if c.MemSwappiness != nil {
+		swappiness := int64(*c.MemSwappiness)
+		memorySwappiness = &swappiness
+	}
+
+	var pidsLimit *int64
+	if c.PidsLimit != nil {
+		limit := int64(*c.PidsLimit)
+		pidsLimit = &limit
+	}
 
 	resources := container.Resources{
 		CgroupParent:      c.CgroupParent,
 		Memory:            int64(c.MemLimit),
 		MemoryReservation: int64(c.MemReservation),
 		MemorySwap:        int64(c.MemSwapLimit),
-		MemorySwappiness:  &memorySwappiness,
+		MemorySwappiness:  memorySwappiness,
 		CPUShares:         int64(c.CPUShares),
 		CPUQuota:          int64(c.CPUQuota),
+		NanoCPUs:          int64(c.CPUs * 1e9),
+		CPUCount:          int64(c.CPUCount),
+		CPUPercent:        int64(c.CPUPercent),
 		CpusetCpus:        c.CPUSet,
 		Ulimits:           ulimits,
 		Devices:           deviceMappings,
-		OomKillDisable:    &c.OomKillDisable,
+		DeviceCgroupRules: deviceCgroupRules,
+		OomKillDisable:    c.OomKillDisable,
+		PidsLimit:         pidsLimit,
+
+		BlkioWeight:          blkio.BlkioWeight,
+		BlkioWeightDevice:    blkio.BlkioWeightDevice,
+		BlkioDeviceReadBps:   blkio.BlkioDeviceReadBps,
+		BlkioDeviceWriteBps:  blkio.BlkioDeviceWriteBps,
+		BlkioDeviceReadIOps:  blkio.BlkioDeviceReadIOps,
+		BlkioDeviceWriteIOps: blkio.BlkioDeviceWriteIOps,
+	}
+
+	if c.GPUs != 0 {
+		resources.DeviceRequests = []container.DeviceRequest{
+			{
+				Count:        int(c.GPUs),
+				Capabilities: [][]string{{"gpu"}},
+			},
+		}
 	}
 
 	networkMode := c.NetworkMode
@@ -204,24 +376,11 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 	} else {
 		switch {
 		case strings.HasPrefix(c.NetworkMode, "service:"):
-			serviceName := c.NetworkMode[8:]
-			if serviceConfig, ok := ctx.Project.ServiceConfigs.Get(serviceName); ok {
-				// FIXME(vdemeester) this is actually not right, should be fixed but not there
-				service, err := ctx.ServiceFactory.Create(ctx.Project, serviceName, serviceConfig)
-				if err != nil {
-					return nil, nil, err
-				}
-				containers, err := service.Containers(context.Background())
-				if err != nil {
-					return nil, nil, err
-				}
-				if len(containers) != 0 {
-					container := containers[0]
-					containerID := container.ID()
-					networkMode = "container:" + containerID
-				}
-				// FIXME(vdemeester) log/warn in case of len(containers) == 0
+			containerID, err := serviceContainerID(ctx, c.NetworkMode[8:], "network stack")
+			if err != nil {
+				return nil, nil, err
 			}
+			networkMode = "container:" + containerID
 		case strings.HasPrefix(c.NetworkMode, "container:"):
 			containerName := c.NetworkMode[10:]
 			client := clientFactory.Create(nil)
@@ -235,14 +394,23 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 		}
 	}
 
-	tmpfs := map[string]string{}
-	for _, path := range c.Tmpfs {
-		split := strings.SplitN(path, ":", 2)
-		if len(split) == 1 {
-			tmpfs[split[0]] = ""
-		} else if len(split) == 2 {
-			tmpfs[split[0]] = split[1]
+	pidMode := c.Pid
+	if strings.HasPrefix(c.Pid, "service:") {
+		containerID, err := serviceContainerID(ctx, c.Pid[8:], "pid namespace")
+		if err != nil {
+			return nil, nil, err
 		}
+		pidMode = "container:" + containerID
+	}
+
+	tmpfs, err := tmpfsMounts(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	securityOpt, err := securityOpts(c.SecurityOpt)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	hostConfig := &container.HostConfig{
@@ -250,7 +418,7 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 		CapAdd:      strslice.StrSlice(utils.CopySlice(c.CapAdd)),
 		CapDrop:     strslice.StrSlice(utils.CopySlice(c.CapDrop)),
 		GroupAdd:    c.GroupAdd,
-		ExtraHosts:  utils.CopySlice(c.ExtraHosts),
+		ExtraHosts:  extraHosts,
 		Privileged:  c.Privileged,
 		Binds:       Filter(vols, isBind),
 		DNS:         utils.CopySlice(c.DNS),
@@ -264,25 +432,101 @@ func Convert(c *config.ServiceConfig, ctx project.Context, clientFactory compose
 		NetworkMode:    container.NetworkMode(networkMode),
 		ReadonlyRootfs: c.ReadOnly,
 		OomScoreAdj:    int(c.OomScoreAdj),
-		PidMode:        container.PidMode(c.Pid),
+		PidMode:        container.PidMode(pidMode),
 		UTSMode:        container.UTSMode(c.Uts),
 		IpcMode:        container.IpcMode(c.Ipc),
+		UsernsMode:     container.UsernsMode(c.UsernsMode),
 		PortBindings:   portBindings,
 		RestartPolicy:  *restartPolicy,
+		Runtime:        c.Runtime,
 		ShmSize:        int64(c.ShmSize),
-		SecurityOpt:    utils.CopySlice(c.SecurityOpt),
+		SecurityOpt:    securityOpt,
+		Sysctls:        utils.CopyMap(c.Sysctls),
 		Tmpfs:          tmpfs,
 		VolumeDriver:   c.VolumeDriver,
 		Resources:      resources,
 	}
 
+	if c.Init != nil {
+		init := *c.Init
+		hostConfig.Init = &init
+	} else if ctx.Init {
+		init := true
+		hostConfig.Init = &init
+	}
+
 	if config.Labels == nil {
 		config.Labels = map[string]string{}
 	}
 
+	for k, v := range c.Annotations {
+		config.Labels[labels.AnnotationPrefix+k] = v
+	}
+
 	return config, hostConfig, nil
 }
 
+// hostnameAndDomainname returns the hostname and domain name of the
+// containers of the specified service. A fully qualified hostname is split
+// into both if the domain name is not set, as the engine would otherwise use
+// it as is for the hostname only.
+func hostnameAndDomainname(c *config.ServiceConfig) (string, string) {
+	if c.DomainName == "" && strings.Contains(c.Hostname, ".") {
+		parts := strings.SplitN(c.Hostname, ".", 2)
+		return parts[0], parts[1]
+	}
+	return c.Hostname, c.DomainName
+}
+
+// serviceContainerID returns the id of the container whose namespace (the
+// specified one, used in errors) is shared by a service with a
+// service:<name> mode: the lowest numbered container of the named service.
+// The services sharing it are started after it (see DefaultDependentServices),
+// so it must exist.
+func serviceContainerID(ctx project.Context, serviceName, namespace string) (string, error) {
+	serviceConfig, ok := ctx.Project.ServiceConfigs.Get(serviceName)
+	if !ok {
+		return "", fmt.Errorf("Cannot use the %s of service %s: no such service", namespace, serviceName)
+	}
+	service, err := ctx.ServiceFactory.Create(ctx.Project, serviceName, serviceConfig)
+	if err != nil {
+		return "", err
+	}
+	containers, err := service.Containers(context.Background())
+	if err != nil {
+		return "", err
+	}
+	var first project.Container
+	firstNumber := 0
+	for _, c := range containers {
+		number, err := c.Number()
+		if err != nil {
+			return "", err
+		}
+		if first == nil || number < firstNumber {
+			first, firstNumber = c, number
+		}
+	}
+	if first == nil {
+		return "", fmt.Errorf("Cannot use the %s of service %s: it has no container", namespace, serviceName)
+	}
+	return first.ID(), nil
+}
+
+// userLabels returns the labels of a service without the reserved ones, so
+// that they can't clobber the labels libcompose relies on.
+func userLabels(serviceLabels map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range serviceLabels {
+		if labels.IsReserved(k) {
+			logrus.Warnf("Ignoring label %s: the %s prefix is reserved", k, labels.Prefix)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
 func getVolumesFrom(volumesFrom []string, serviceConfigs *config.ServiceConfigs, projectName string) ([]string, error) {
 	volumes := []string{}
 	for _, volumeFrom := range volumesFrom {
@@ -319,6 +563,78 @@ func parseDevices(devices []string) ([]container.DeviceMapping, error) {
 	return deviceMappings, nil
 }
 
+// parseExtraHosts checks that each of the specified extra hosts is made of a
+// host name and an IP address (or host-gateway), separated by a colon.
+func parseExtraHosts(hosts []string) ([]string, error) {
+	var extraHosts []string
+	for _, extraHost := range hosts {
+		arr := strings.SplitN(extraHost, ":", 2)
+		if len(arr) != 2 || arr[0] == "" || strings.ContainsAny(arr[0], " \t") {
+			return nil, fmt.Errorf("invalid extra host: %s, it should be host:ip", extraHost)
+		}
+		ip := strings.TrimSpace(arr[1])
+		if ip != "host-gateway" && net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid IP address in extra host %s: %s", extraHost, ip)
+		}
+		extraHosts = append(extraHosts, arr[0]+":"+ip)
+	}
+	return extraHosts, nil
+}
+
+// deviceCgroupRuleRegexp matches device cgroup rules, e.g. "c 189:* rmw".
+var deviceCgroupRuleRegexp = regexp.MustCompile(`^([acb]) ([0-9]+|\*):([0-9]+|\*) ([rwm]{1,3})$`)
+
+func parseDeviceCgroupRules(rules []string) ([]string, error) {
+	for _, rule := range rules {
+		if !deviceCgroupRuleRegexp.MatchString(rule) {
+			return nil, fmt.Errorf("invalid device cgroup rule: %s", rule)
+		}
+	}
+	return utils.CopySlice(rules), nil
+}
+
+// blkioResources converts the specified blkio_config, only the Blkio fields
+// of the returned resources being set. Device paths are passed as is: they
+// are paths on the daemon host, which resolves them to their major:minor
+// numbers itself (and rejects the missing ones).
+func blkioResources(blkio config.BlkioConfig) container.Resources {
+	resources := container.Resources{
+		BlkioWeight:       blkio.Weight,
+		BlkioWeightDevice: []*blkiodev.WeightDevice{},
+	}
+	for _, device := range blkio.WeightDevice {
+		resources.BlkioWeightDevice = append(resources.BlkioWeightDevice, &blkiodev.WeightDevice{
+			Path:   device.Path,
+			Weight: device.Weight,
+		})
+	}
+
+	throttleDevices := func(devices []config.BlkioThrottleDevice) []*blkiodev.ThrottleDevice {
+		result := []*blkiodev.ThrottleDevice{}
+		for _, device := range devices {
+			result = append(result, &blkiodev.ThrottleDevice{
+				Path: device.Path,
+				Rate: uint64(device.Rate),
+			})
+		}
+		return result
+	}
+	// IOps limits are throttled the same way, in operations per second
+	iopsDevices := func(devices []config.BlkioIOpsDevice) []*blkiodev.ThrottleDevice {
+		throttled := []config.BlkioThrottleDevice{}
+		for _, device := range devices {
+			throttled = append(throttled, config.BlkioThrottleDevice{Path: device.Path, Rate: yaml.MemStringorInt(device.Rate)})
+		}
+		return throttleDevices(throttled)
+	}
+
+	resources.BlkioDeviceReadBps = throttleDevices(blkio.DeviceReadBps)
+	resources.BlkioDeviceWriteBps = throttleDevices(blkio.DeviceWriteBps)
+	resources.BlkioDeviceReadIOps = iopsDevices(blkio.DeviceReadIOps)
+	resources.BlkioDeviceWriteIOps = iopsDevices(blkio.DeviceWriteIOps)
+	return resources
+}
+
 // parseDevice parses a device mapping string to a container.DeviceMapping struct
 // FIXME(vdemeester) de-duplicate this by re-exporting it in docker/docker
 func parseDevice(device string) (container.DeviceMapping, error) {
@@ -328,6 +644,9 @@ func parseDevice(device string) (container.DeviceMapping, error) {
 	arr := strings.Split(device, ":")
 	switch len(arr) {
 	case 3:
+		if !validDeviceMode(arr[2]) {
+			return container.DeviceMapping{}, fmt.Errorf("invalid device mode: %s", arr[2])
+		}
 		permissions = arr[2]
 		fallthrough
 	case 2:
@@ -374,3 +693,35 @@ func validDeviceMode(mode string) bool {
 	}
 	return true
 }
+
+// stopGracePeriod returns the stop_grace_period of a service in seconds, nil
+// if it isn't set (0 meaning the containers are killed right away).
+func stopGracePeriod(c *config.ServiceConfig) *int {
+	if c.StopGracePeriod == nil {
+		return nil
+	}
+	seconds := int(time.Duration(*c.StopGracePeriod).Seconds())
+	if seconds < 0 {
+		seconds = 0
+	}
+	return &seconds
+}
+
+// healthConfig converts the healthcheck of a service, returning nil if it
+// isn't configured so that the one of the image is inherited.
+func healthConfig(healthCheck config.HealthCheck) *container.HealthConfig {
+	if healthCheck.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}
+	}
+	if len(healthCheck.Test) == 0 && healthCheck.Interval == 0 && healthCheck.Timeout == 0 &&
+		healthCheck.StartPeriod == 0 && healthCheck.Retries == 0 {
+		return nil
+	}
+	return &container.HealthConfig{
+		Test:        utils.CopySlice(healthCheck.Test),
+		Interval:    time.Duration(healthCheck.Interval),
+		Timeout:     time.Duration(healthCheck.Timeout),
+		StartPeriod: time.Duration(healthCheck.StartPeriod),
+		Retries:     healthCheck.Retries,
+	}
+}
@@ -1,17 +1,24 @@
 package service
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
+
+	"golang.org/x/net/context"
 
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	shlex "github.com/flynn/go-shlex"
+	"github.com/stretchr/testify/assert"
 	"github.com/zengchen221/libcompose/config"
 	"github.com/zengchen221/libcompose/docker/ctx"
 	"github.com/zengchen221/libcompose/lookup"
+	"github.com/zengchen221/libcompose/project"
 	"github.com/zengchen221/libcompose/yaml"
-	shlex "github.com/flynn/go-shlex"
-	"github.com/stretchr/testify/assert"
 )
 
 func TestParseCommand(t *testing.T) {
@@ -58,6 +65,30 @@ func TestParseBindsAndVolumes(t *testing.T) {
 	assert.Equal(t, []string{"/home:/home", abs + "/foo:/home", "/usr/lib:/usr/lib:ro"}, hostCfg.Binds)
 }
 
+func TestParseLongSyntaxVolumes(t *testing.T) {
+	ctx := &ctx.Context{}
+	ctx.ComposeFiles = []string{"foo/docker-compose.yml"}
+	ctx.ResourceLookup = &lookup.FileResourceLookup{}
+
+	abs, err := filepath.Abs(".")
+	assert.Nil(t, err)
+	cfg, hostCfg, err := Convert(&config.ServiceConfig{
+		Volumes: &yaml.Volumes{
+			Volumes: []*yaml.Volume{
+				{Type: yaml.VolumeTypeBind, Source: "data", Destination: "/data", AccessMode: "ro", Propagation: "rshared"},
+				{Type: yaml.VolumeTypeVolume, Source: "project_cache", Destination: "/cache", NoCopy: true},
+				{Type: yaml.VolumeTypeVolume, Destination: "/anonymous"},
+				{Type: yaml.VolumeTypeTmpfs, Destination: "/run", AccessMode: "ro", TmpfsSize: 1024},
+			},
+		},
+		Tmpfs: []string{"/tmp"},
+	}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]struct{}{"/anonymous": {}}, cfg.Volumes)
+	assert.Equal(t, []string{abs + "/foo/data:/data:ro,rshared", "project_cache:/cache:nocopy"}, hostCfg.Binds)
+	assert.Equal(t, map[string]string{"/tmp": "", "/run": "ro,size=1024"}, hostCfg.Tmpfs)
+}
+
 func TestParseLabels(t *testing.T) {
 	ctx := &ctx.Context{}
 	ctx.ComposeFiles = []string{"foo/docker-compose.yml"}
@@ -82,6 +113,34 @@ func TestParseLabels(t *testing.T) {
 	assert.Equal(t, []string{"less"}, []string(cfg.Entrypoint))
 }
 
+func TestReservedLabels(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		Labels: yaml.SliceorMap{
+			"com.example.foo":            "bar",
+			"com.docker.compose.project": "other",
+		},
+	}
+	cfg, _, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"com.example.foo": "bar"}, cfg.Labels)
+}
+
+func TestAnnotations(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		Labels:      yaml.SliceorMap{"foo": "label"},
+		Annotations: yaml.SliceorMap{"foo": "annotation"},
+	}
+	cfg, _, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]string{
+		"foo":                               "label",
+		"com.docker.compose.annotation.foo": "annotation",
+	}, cfg.Labels)
+}
+
 func TestDNSOpt(t *testing.T) {
 	ctx := &ctx.Context{}
 	sc := &config.ServiceConfig{
@@ -99,6 +158,87 @@ func TestDNSOpt(t *testing.T) {
 	}, hostCfg.DNSOptions))
 }
 
+func TestSecurityOpt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seccomp")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	profile := filepath.Join(dir, "profile.json")
+	assert.Nil(t, ioutil.WriteFile(profile, []byte("{\n  \"defaultAction\": \"SCMP_ACT_ERRNO\"\n}\n"), 0644))
+
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		SecurityOpt: []string{
+			"seccomp:unconfined",
+			"apparmor:myprofile",
+			"no-new-privileges:true",
+			"no-new-privileges",
+			"seccomp=" + profile,
+		},
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"seccomp=unconfined",
+		"apparmor=myprofile",
+		"no-new-privileges=true",
+		"no-new-privileges",
+		`seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`,
+	}, hostCfg.SecurityOpt)
+
+	sc.SecurityOpt = []string{"seccomp=" + filepath.Join(dir, "missing.json")}
+	_, _, err = Convert(sc, ctx.Context, nil)
+	assert.Error(t, err)
+}
+
+func TestPrivilegedCgroupParentAndPidsLimit(t *testing.T) {
+	ctx := &ctx.Context{}
+	pidsLimit := yaml.StringorInt(100)
+	sc := &config.ServiceConfig{
+		Privileged:   true,
+		CgroupParent: "/mygroup",
+		PidsLimit:    &pidsLimit,
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.True(t, hostCfg.Privileged)
+	assert.Equal(t, "/mygroup", hostCfg.CgroupParent)
+	assert.Equal(t, int64(100), *hostCfg.PidsLimit)
+
+	_, hostCfg, err = Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, hostCfg.PidsLimit)
+}
+
+func TestRuntime(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		Runtime: "nvidia",
+		GPUs:    1,
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "nvidia", hostCfg.Runtime)
+	assert.Equal(t, []container.DeviceRequest{{Count: 1, Capabilities: [][]string{{"gpu"}}}}, hostCfg.DeviceRequests)
+}
+
+func TestTtyAndStdinOpen(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		Tty:       true,
+		StdinOpen: true,
+	}
+	cfg, _, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.True(t, cfg.Tty)
+	assert.True(t, cfg.OpenStdin)
+	assert.False(t, cfg.StdinOnce)
+
+	cfg, _, err = Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.False(t, cfg.Tty)
+	assert.False(t, cfg.OpenStdin)
+}
+
 func TestGroupAdd(t *testing.T) {
 	ctx := &ctx.Context{}
 	sc := &config.ServiceConfig{
@@ -129,13 +269,17 @@ func TestIsolation(t *testing.T) {
 
 func TestMemSwappiness(t *testing.T) {
 	ctx := &ctx.Context{}
+	swappiness := yaml.StringorInt(0)
 	sc := &config.ServiceConfig{
-		MemSwappiness: yaml.MemStringorInt(10),
+		MemSwappiness: &swappiness,
 	}
 	_, hostCfg, err := Convert(sc, ctx.Context, nil)
 	assert.Nil(t, err)
+	assert.Equal(t, int64(0), *hostCfg.MemorySwappiness)
 
-	assert.Equal(t, int64(10), *hostCfg.MemorySwappiness)
+	_, hostCfg, err = Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, hostCfg.MemorySwappiness)
 }
 
 func TestMemReservation(t *testing.T) {
@@ -151,13 +295,17 @@ func TestMemReservation(t *testing.T) {
 
 func TestOomKillDisable(t *testing.T) {
 	ctx := &ctx.Context{}
+	oomKillDisable := true
 	sc := &config.ServiceConfig{
-		OomKillDisable: true,
+		OomKillDisable: &oomKillDisable,
 	}
 	_, hostCfg, err := Convert(sc, ctx.Context, nil)
 	assert.Nil(t, err)
-
 	assert.Equal(t, true, *hostCfg.OomKillDisable)
+
+	_, hostCfg, err = Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, hostCfg.OomKillDisable)
 }
 
 func TestOomScoreAdj(t *testing.T) {
@@ -171,15 +319,107 @@ func TestOomScoreAdj(t *testing.T) {
 	assert.Equal(t, 500, hostCfg.OomScoreAdj)
 }
 
+func TestUlimits(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		Ulimits: yaml.Ulimits{
+			Elements: []yaml.Ulimit{
+				yaml.NewUlimit("nofile", 20000, 40000),
+				yaml.NewUlimit("nproc", 65535, 65535),
+			},
+		},
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+
+	assert.Len(t, hostCfg.Ulimits, 2)
+	assert.Equal(t, "nofile", hostCfg.Ulimits[0].Name)
+	assert.Equal(t, int64(20000), hostCfg.Ulimits[0].Soft)
+	assert.Equal(t, int64(40000), hostCfg.Ulimits[0].Hard)
+	assert.Equal(t, "nproc", hostCfg.Ulimits[1].Name)
+	assert.Equal(t, int64(65535), hostCfg.Ulimits[1].Soft)
+	assert.Equal(t, int64(65535), hostCfg.Ulimits[1].Hard)
+}
+
+func TestLogConfig(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		Logging: config.Log{
+			Driver:  "json-file",
+			Options: map[string]string{"max-size": "10m"},
+		},
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, container.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "10m"}}, hostCfg.LogConfig)
+
+	// The daemon default driver is used
+	_, hostCfg, err = Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", hostCfg.LogConfig.Type)
+	assert.Empty(t, hostCfg.LogConfig.Config)
+}
+
+func TestCapabilitiesAndSysctls(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		CapAdd:  []string{"NET_ADMIN"},
+		CapDrop: []string{"ALL"},
+		Sysctls: yaml.SliceorMap{"net.core.somaxconn": "1024"},
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"NET_ADMIN"}, []string(hostCfg.CapAdd))
+	assert.Equal(t, []string{"ALL"}, []string(hostCfg.CapDrop))
+	assert.Equal(t, map[string]string{"net.core.somaxconn": "1024"}, hostCfg.Sysctls)
+}
+
 func TestStopGracePeriod(t *testing.T) {
 	ctx := &ctx.Context{}
+	gracePeriod := yaml.Duration(5 * time.Second)
 	sc := &config.ServiceConfig{
-		StopGracePeriod: "5s",
+		StopGracePeriod: &gracePeriod,
 	}
 	cfg, _, err := Convert(sc, ctx.Context, nil)
 	assert.Nil(t, err)
-
 	assert.Equal(t, 5, *cfg.StopTimeout)
+
+	noGracePeriod := yaml.Duration(0)
+	sc.StopGracePeriod = &noGracePeriod
+	cfg, _, err = Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, *cfg.StopTimeout)
+
+	sc.StopGracePeriod = nil
+	cfg, _, err = Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, cfg.StopTimeout)
+}
+
+func TestBasicContainerSettings(t *testing.T) {
+	ctx := &ctx.Context{}
+	cfg, _, err := Convert(&config.ServiceConfig{
+		WorkingDir: "/app",
+		User:       "1000:1000",
+		Hostname:   "web",
+		DomainName: "example.com",
+	}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "/app", cfg.WorkingDir)
+	assert.Equal(t, "1000:1000", cfg.User)
+	assert.Equal(t, "web", cfg.Hostname)
+	assert.Equal(t, "example.com", cfg.Domainname)
+
+	cfg, _, err = Convert(&config.ServiceConfig{Hostname: "web.example.com"}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "web", cfg.Hostname)
+	assert.Equal(t, "example.com", cfg.Domainname)
+
+	cfg, _, err = Convert(&config.ServiceConfig{Hostname: "web.internal", DomainName: "example.com"}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "web.internal", cfg.Hostname)
+	assert.Equal(t, "example.com", cfg.Domainname)
 }
 
 func TestStopSignal(t *testing.T) {
@@ -196,7 +436,7 @@ func TestStopSignal(t *testing.T) {
 func TestTmpfs(t *testing.T) {
 	ctx := &ctx.Context{}
 	sc := &config.ServiceConfig{
-		Tmpfs: yaml.Stringorslice{"/run"},
+		Tmpfs: yaml.Tmpfs{"/run"},
 	}
 	_, hostCfg, err := Convert(sc, ctx.Context, nil)
 	assert.Nil(t, err)
@@ -206,7 +446,7 @@ func TestTmpfs(t *testing.T) {
 	}, hostCfg.Tmpfs))
 
 	sc = &config.ServiceConfig{
-		Tmpfs: yaml.Stringorslice{"/run:rw,noexec,nosuid,size=65536k"},
+		Tmpfs: yaml.Tmpfs{"/run:rw,noexec,nosuid,size=65536k"},
 	}
 	_, hostCfg, err = Convert(sc, ctx.Context, nil)
 	assert.Nil(t, err)
@@ -214,4 +454,400 @@ func TestTmpfs(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(map[string]string{
 		"/run": "rw,noexec,nosuid,size=65536k",
 	}, hostCfg.Tmpfs))
+
+	sc = &config.ServiceConfig{
+		Tmpfs: yaml.Tmpfs{"/run:size=lots"},
+	}
+	_, _, err = Convert(sc, ctx.Context, nil)
+	assert.NotNil(t, err)
+
+	sc = &config.ServiceConfig{
+		Volumes: &yaml.Volumes{Volumes: []*yaml.Volume{
+			{Type: yaml.VolumeTypeTmpfs, Destination: "/cache", TmpfsSize: 1024, TmpfsMode: 01777},
+		}},
+	}
+	_, hostCfg, err = Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"/cache": "size=1024,mode=1777"}, hostCfg.Tmpfs)
+}
+
+func TestCPUCountAndPercent(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		CPUCount:   yaml.StringorInt(2),
+		CPUPercent: yaml.StringorInt(50),
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, int64(2), hostCfg.CPUCount)
+	assert.Equal(t, int64(50), hostCfg.CPUPercent)
+}
+
+func TestGPUs(t *testing.T) {
+	ctx := &ctx.Context{}
+	_, hostCfg, err := Convert(&config.ServiceConfig{GPUs: yaml.AllGPUs}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []container.DeviceRequest{
+		{Count: -1, Capabilities: [][]string{{"gpu"}}},
+	}, hostCfg.DeviceRequests)
+
+	_, hostCfg, err = Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, hostCfg.DeviceRequests)
+}
+
+func TestInit(t *testing.T) {
+	enabled, disabled := true, false
+	cases := []struct {
+		projectInit bool
+		serviceInit *bool
+		expected    *bool
+	}{
+		{false, nil, nil},
+		{true, nil, &enabled},
+		{false, &enabled, &enabled},
+		{true, &disabled, &disabled},
+	}
+
+	for _, c := range cases {
+		ctx := &ctx.Context{}
+		ctx.Init = c.projectInit
+		_, hostCfg, err := Convert(&config.ServiceConfig{Init: c.serviceInit}, ctx.Context, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, hostCfg.Init)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	ctx := &ctx.Context{}
+	_, hostCfg, err := Convert(&config.ServiceConfig{ReadOnly: true}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.True(t, hostCfg.ReadonlyRootfs)
+
+	_, hostCfg, err = Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.False(t, hostCfg.ReadonlyRootfs)
+}
+
+func TestIsolationAndUsernsMode(t *testing.T) {
+	ctx := &ctx.Context{}
+	_, hostCfg, err := Convert(&config.ServiceConfig{Isolation: "hyperv", UsernsMode: "host"}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, container.Isolation("hyperv"), hostCfg.Isolation)
+	assert.Equal(t, container.UsernsMode("host"), hostCfg.UsernsMode)
+}
+
+func TestCPUsAndMemory(t *testing.T) {
+	ctx := &ctx.Context{}
+	_, hostCfg, err := Convert(&config.ServiceConfig{
+		CPUs:           0.5,
+		MemLimit:       yaml.MemStringorInt(512 * 1024 * 1024),
+		MemReservation: yaml.MemStringorInt(256 * 1024 * 1024),
+	}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(500000000), hostCfg.NanoCPUs)
+	assert.Equal(t, int64(512*1024*1024), hostCfg.Memory)
+	assert.Equal(t, int64(256*1024*1024), hostCfg.MemoryReservation)
+}
+
+func TestBlkioDevices(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		BlkioConfig: config.BlkioConfig{
+			WeightDevice:   []config.BlkioWeightDevice{{Path: "/dev/null", Weight: 400}},
+			DeviceReadBps:  []config.BlkioThrottleDevice{{Path: "/dev/null", Rate: 1024}},
+			DeviceWriteBps: []config.BlkioThrottleDevice{{Path: "/dev/zero", Rate: 2048}},
+			Weight:         300,
+			DeviceReadIOps: []config.BlkioIOpsDevice{{Path: "/dev/null", Rate: 100}},
+		},
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Len(t, hostCfg.BlkioWeightDevice, 1)
+	assert.Equal(t, "/dev/null", hostCfg.BlkioWeightDevice[0].Path)
+	assert.Equal(t, uint16(400), hostCfg.BlkioWeightDevice[0].Weight)
+	assert.Len(t, hostCfg.BlkioDeviceReadBps, 1)
+	assert.Equal(t, uint64(1024), hostCfg.BlkioDeviceReadBps[0].Rate)
+	assert.Len(t, hostCfg.BlkioDeviceWriteBps, 1)
+	assert.Equal(t, "/dev/zero", hostCfg.BlkioDeviceWriteBps[0].Path)
+	assert.Equal(t, uint16(300), hostCfg.BlkioWeight)
+	assert.Len(t, hostCfg.BlkioDeviceReadIOps, 1)
+	assert.Equal(t, uint64(100), hostCfg.BlkioDeviceReadIOps[0].Rate)
+	assert.Len(t, hostCfg.BlkioDeviceWriteIOps, 0)
+
+	// The devices are the ones of the daemon host, not checked locally
+	sc.BlkioConfig.DeviceReadBps[0].Path = "/dev/doesnotexist"
+	_, hostCfg, err = Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "/dev/doesnotexist", hostCfg.BlkioDeviceReadBps[0].Path)
+}
+
+func TestDevicesWithCapabilitiesAndPrivileged(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		CapAdd:     []string{"SYS_RAWIO"},
+		CapDrop:    []string{"NET_RAW"},
+		Devices:    []string{"/dev/sda:/dev/xvda:rwm", "/dev/ttyUSB0"},
+		Privileged: true,
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.True(t, hostCfg.Privileged)
+	assert.Equal(t, []string{"SYS_RAWIO"}, []string(hostCfg.CapAdd))
+	assert.Equal(t, []string{"NET_RAW"}, []string(hostCfg.CapDrop))
+	assert.Equal(t, []container.DeviceMapping{
+		{PathOnHost: "/dev/sda", PathInContainer: "/dev/xvda", CgroupPermissions: "rwm"},
+		{PathOnHost: "/dev/ttyUSB0", PathInContainer: "/dev/ttyUSB0", CgroupPermissions: "rwm"},
+	}, hostCfg.Devices)
+}
+
+func TestDeviceModesAndCgroupRules(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		Devices:           []string{"/dev/ttyUSB0:/dev/ttyUSB1:r", "/dev/sda:rw"},
+		DeviceCgroupRules: []string{"c 189:* rmw", "b 8:0 r"},
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []container.DeviceMapping{
+		{PathOnHost: "/dev/ttyUSB0", PathInContainer: "/dev/ttyUSB1", CgroupPermissions: "r"},
+		{PathOnHost: "/dev/sda", PathInContainer: "/dev/sda", CgroupPermissions: "rw"},
+	}, hostCfg.Devices)
+	assert.Equal(t, []string{"c 189:* rmw", "b 8:0 r"}, hostCfg.DeviceCgroupRules)
+
+	_, _, err = Convert(&config.ServiceConfig{Devices: []string{"/dev/sda:/dev/xvda:rwx"}}, ctx.Context, nil)
+	assert.EqualError(t, err, "invalid device mode: rwx")
+
+	_, _, err = Convert(&config.ServiceConfig{DeviceCgroupRules: []string{"x 189:* rmw"}}, ctx.Context, nil)
+	assert.EqualError(t, err, "invalid device cgroup rule: x 189:* rmw")
+}
+
+func TestExtraHosts(t *testing.T) {
+	ctx := &ctx.Context{}
+	sc := &config.ServiceConfig{
+		ExtraHosts: yaml.MaporColonSlice{"somehost:162.242.195.82", "otherhost: 50.31.209.229", "ipv6host:::1", "gateway:host-gateway"},
+	}
+	_, hostCfg, err := Convert(sc, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"somehost:162.242.195.82", "otherhost:50.31.209.229", "ipv6host:::1", "gateway:host-gateway"}, hostCfg.ExtraHosts)
+
+	for _, invalid := range []string{"somehost", ":162.242.195.82", "somehost:notanip", "somehost:162.242.195.82:80"} {
+		_, _, err = Convert(&config.ServiceConfig{ExtraHosts: yaml.MaporColonSlice{invalid}}, ctx.Context, nil)
+		assert.NotNil(t, err, "expected an error for %s", invalid)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	ctx := &ctx.Context{}
+	cfg, _, err := Convert(&config.ServiceConfig{}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, cfg.Healthcheck)
+
+	cfg, _, err = Convert(&config.ServiceConfig{
+		HealthCheck: config.HealthCheck{
+			Test:        yaml.HealthCheckTest{"CMD-SHELL", "curl -f http://localhost"},
+			Interval:    yaml.Duration(30 * time.Second),
+			Timeout:     yaml.Duration(10 * time.Second),
+			StartPeriod: yaml.Duration(5 * time.Second),
+			Retries:     3,
+		},
+	}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &container.HealthConfig{
+		Test:        []string{"CMD-SHELL", "curl -f http://localhost"},
+		Interval:    30 * time.Second,
+		Timeout:     10 * time.Second,
+		StartPeriod: 5 * time.Second,
+		Retries:     3,
+	}, cfg.Healthcheck)
+
+	cfg, _, err = Convert(&config.ServiceConfig{
+		HealthCheck: config.HealthCheck{Disable: true},
+	}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &container.HealthConfig{Test: []string{"NONE"}}, cfg.Healthcheck)
+}
+
+func TestPorts(t *testing.T) {
+	exposedPorts, portBindings, err := ports(&config.ServiceConfig{
+		Ports:  []string{"8000-8001:9000-9001", "53:53/udp", "127.0.0.1:8080:80"},
+		Expose: []string{"3000/udp"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[nat.Port]struct{}{
+		"9000/tcp": {},
+		"9001/tcp": {},
+		"53/udp":   {},
+		"80/tcp":   {},
+		"3000/udp": {},
+	}, exposedPorts)
+	assert.Equal(t, nat.PortMap{
+		"9000/tcp": {{HostPort: "8000"}},
+		"9001/tcp": {{HostPort: "8001"}},
+		"53/udp":   {{HostPort: "53"}},
+		"80/tcp":   {{HostIP: "127.0.0.1", HostPort: "8080"}},
+	}, portBindings)
+
+	_, _, err = ports(&config.ServiceConfig{Ports: []string{"8000-8002:9000-9001"}})
+	assert.NotNil(t, err)
+
+	exposedPorts, portBindings, err = ports(&config.ServiceConfig{
+		Ports:  []string{"8080:8000"},
+		Expose: []string{"8000-8002", "3000"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[nat.Port]struct{}{
+		"8000/tcp": {},
+		"8001/tcp": {},
+		"8002/tcp": {},
+		"3000/tcp": {},
+	}, exposedPorts)
+	assert.Equal(t, nat.PortMap{"8000/tcp": {{HostPort: "8080"}}}, portBindings)
+}
+
+func TestNetworkingConfig(t *testing.T) {
+	assert.Nil(t, networkingConfig(&config.ServiceConfig{}))
+	assert.Nil(t, networkingConfig(&config.ServiceConfig{
+		NetworkMode: "host",
+		Networks: &yaml.Networks{
+			Networks: []*yaml.Network{{Name: "backend", RealName: "prj_backend"}},
+		},
+	}))
+
+	networkConfig := networkingConfig(&config.ServiceConfig{
+		Networks: &yaml.Networks{
+			Networks: []*yaml.Network{
+				{
+					Name:        "backend",
+					RealName:    "prj_backend",
+					Aliases:     []string{"db", "database"},
+					IPv4Address: "172.16.238.10",
+				},
+				{
+					Name:     "frontend",
+					RealName: "prj_frontend",
+				},
+			},
+		},
+	})
+	assert.Len(t, networkConfig.EndpointsConfig, 1)
+	endpoint := networkConfig.EndpointsConfig["prj_backend"]
+	assert.Equal(t, []string{"db", "database"}, endpoint.Aliases)
+	assert.Equal(t, "172.16.238.10", endpoint.IPAMConfig.IPv4Address)
+
+	networkConfig = networkingConfig(&config.ServiceConfig{
+		Networks: &yaml.Networks{
+			Networks: []*yaml.Network{
+				{
+					Name:         "backend",
+					RealName:     "prj_backend",
+					LinkLocalIPs: []string{"169.254.8.8"},
+				},
+			},
+		},
+	})
+	endpoint = networkConfig.EndpointsConfig["prj_backend"]
+	assert.Equal(t, []string{"169.254.8.8"}, endpoint.IPAMConfig.LinkLocalIPs)
+}
+
+func TestRestartPolicy(t *testing.T) {
+	cases := []struct {
+		restart  string
+		expected *container.RestartPolicy
+	}{
+		{"", &container.RestartPolicy{}},
+		{"no", &container.RestartPolicy{Name: "no"}},
+		{"always", &container.RestartPolicy{Name: "always"}},
+		{"unless-stopped", &container.RestartPolicy{Name: "unless-stopped"}},
+		{"on-failure", &container.RestartPolicy{Name: "on-failure"}},
+		{"on-failure:5", &container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 5}},
+	}
+	for _, c := range cases {
+		policy, err := restartPolicy(&config.ServiceConfig{Restart: c.restart})
+		assert.Nil(t, err, c.restart)
+		assert.Equal(t, c.expected, policy, c.restart)
+	}
+
+	for _, restart := range []string{"sometimes", "always:3", "on-failure:many", "on-failure:-1"} {
+		_, err := restartPolicy(&config.ServiceConfig{Restart: restart})
+		assert.NotNil(t, err, restart)
+	}
+}
+
+type numberedContainer struct {
+	project.Container
+	id     string
+	number int
+}
+
+func (c *numberedContainer) ID() string {
+	return c.id
+}
+
+func (c *numberedContainer) Number() (int, error) {
+	return c.number, nil
+}
+
+type containersService struct {
+	project.EmptyService
+	containers []project.Container
+}
+
+func (s *containersService) Containers(ctx context.Context) ([]project.Container, error) {
+	return s.containers, nil
+}
+
+type containersServiceFactory map[string][]project.Container
+
+func (f containersServiceFactory) Create(p *project.Project, name string, serviceConfig *config.ServiceConfig) (project.Service, error) {
+	return &containersService{containers: f[name]}, nil
+}
+
+func TestServiceNetworkMode(t *testing.T) {
+	ctx := &ctx.Context{}
+	ctx.Project = &project.Project{ServiceConfigs: config.NewServiceConfigs()}
+	ctx.Project.ServiceConfigs.Add("vpn", &config.ServiceConfig{})
+	ctx.Project.ServiceConfigs.Add("idle", &config.ServiceConfig{})
+	ctx.ServiceFactory = containersServiceFactory{
+		"vpn": {&numberedContainer{id: "vpn2", number: 2}, &numberedContainer{id: "vpn1", number: 1}},
+	}
+
+	_, hostCfg, err := Convert(&config.ServiceConfig{NetworkMode: "service:vpn"}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, container.NetworkMode("container:vpn1"), hostCfg.NetworkMode)
+
+	_, _, err = Convert(&config.ServiceConfig{NetworkMode: "service:idle"}, ctx.Context, nil)
+	assert.EqualError(t, err, "Cannot use the network stack of service idle: it has no container")
+
+	_, _, err = Convert(&config.ServiceConfig{NetworkMode: "service:missing"}, ctx.Context, nil)
+	assert.EqualError(t, err, "Cannot use the network stack of service missing: no such service")
+
+	for _, mode := range []string{"host", "none", "bridge"} {
+		_, hostCfg, err = Convert(&config.ServiceConfig{NetworkMode: mode}, ctx.Context, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, container.NetworkMode(mode), hostCfg.NetworkMode)
+	}
+}
+
+func TestPidMode(t *testing.T) {
+	ctx := &ctx.Context{}
+	ctx.Project = &project.Project{ServiceConfigs: config.NewServiceConfigs()}
+	ctx.Project.ServiceConfigs.Add("app", &config.ServiceConfig{})
+	ctx.ServiceFactory = containersServiceFactory{
+		"app": {&numberedContainer{id: "app1", number: 1}},
+	}
+
+	_, hostCfg, err := Convert(&config.ServiceConfig{Pid: "service:app"}, ctx.Context, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, container.PidMode("container:app1"), hostCfg.PidMode)
+
+	_, _, err = Convert(&config.ServiceConfig{Pid: "service:missing"}, ctx.Context, nil)
+	assert.EqualError(t, err, "Cannot use the pid namespace of service missing: no such service")
+
+	for _, mode := range []string{"", "host", "container:profiler"} {
+		_, hostCfg, err = Convert(&config.ServiceConfig{Pid: mode}, ctx.Context, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, container.PidMode(mode), hostCfg.PidMode)
+	}
 }
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+)
+
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// retryPolicy controls how transient daemon errors are retried. The zero
+// value doesn't retry.
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+}
+
+// do calls fn until it succeeds, fails with an error that is not transient,
+// or was retried maxRetries times. The delay between attempts starts at
+// backoff and doubles on each retry.
+func (r retryPolicy) do(ctx context.Context, description string, fn func() error) error {
+	backoff := r.backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= r.maxRetries || !isTransient(err) {
+			return err
+		}
+		logrus.Warnf("Failed to %s, retrying in %s (%d/%d): %v", description, backoff, attempt+1, r.maxRetries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient returns whether the specified error is a daemon failure that
+// may not happen again (500 or 503), as opposed to errors such as a missing
+// image or an invalid configuration.
+func isTransient(err error) bool {
+	return errdefs.IsSystem(err) || errdefs.IsUnavailable(err)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	cases := []struct {
+		name             string
+		maxRetries       int
+		errs             []error
+		expectedAttempts int
+		expectedErr      bool
+	}{
+		{
+			name:             "no retries",
+			errs:             []error{errdefs.System(fmt.Errorf("500"))},
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+		{
+			name:             "server error retried until success",
+			maxRetries:       3,
+			errs:             []error{errdefs.System(fmt.Errorf("500")), errdefs.Unavailable(fmt.Errorf("503"))},
+			expectedAttempts: 3,
+		},
+		{
+			name:             "server error retried until max retries",
+			maxRetries:       2,
+			errs:             []error{errdefs.System(fmt.Errorf("500")), errdefs.System(fmt.Errorf("500")), errdefs.System(fmt.Errorf("500"))},
+			expectedAttempts: 3,
+			expectedErr:      true,
+		},
+		{
+			name:             "image not found not retried",
+			maxRetries:       3,
+			errs:             []error{errdefs.NotFound(fmt.Errorf("No such image"))},
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+		{
+			name:             "invalid config not retried",
+			maxRetries:       3,
+			errs:             []error{errdefs.InvalidParameter(fmt.Errorf("invalid"))},
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			policy := retryPolicy{maxRetries: c.maxRetries, backoff: time.Millisecond}
+			attempts := 0
+			err := policy.do(context.Background(), "test", func() error {
+				attempts++
+				if attempts <= len(c.errs) {
+					return c.errs[attempts-1]
+				}
+				return nil
+			})
+			assert.Equal(t, c.expectedAttempts, attempts)
+			assert.Equal(t, c.expectedErr, err != nil, "unexpected error %v", err)
+		})
+	}
+}
+
+func TestRetryPolicyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	policy := retryPolicy{maxRetries: 5, backoff: time.Hour}
+	attempts := 0
+	err := policy.do(ctx, "test", func() error {
+		attempts++
+		return errdefs.System(fmt.Errorf("500"))
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
@@ -1,8 +1,15 @@
 package service
 
 import (
+	"archive/tar"
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
@@ -20,6 +27,7 @@ import (
 	"github.com/zengchen221/libcompose/docker/ctx"
 	"github.com/zengchen221/libcompose/docker/image"
 	"github.com/zengchen221/libcompose/labels"
+	"github.com/zengchen221/libcompose/logger"
 	"github.com/zengchen221/libcompose/project"
 	"github.com/zengchen221/libcompose/project/events"
 	"github.com/zengchen221/libcompose/project/options"
@@ -35,6 +43,7 @@ type Service struct {
 	serviceConfig *config.ServiceConfig
 	clientFactory composeclient.Factory
 	authLookup    auth.Lookup
+	retry         retryPolicy
 
 	// FIXME(vdemeester) remove this at some point
 	context *ctx.Context
@@ -52,6 +61,18 @@ func NewService(name string, serviceConfig *config.ServiceConfig, context *ctx.C
 	}
 }
 
+// loggerFactory returns the factory of the loggers of the service: the
+// LogWriter of the context bound to the service if any, the LoggerFactory of
+// the context otherwise.
+func (s *Service) loggerFactory() logger.Factory {
+	if s.context.LogWriter == nil {
+		return s.context.LoggerFactory
+	}
+	return logger.WriterFactory(func(string) io.Writer {
+		return s.context.LogWriter(s.name)
+	})
+}
+
 // Name returns the service name.
 func (s *Service) Name() string {
 	return s.name
@@ -70,29 +91,67 @@ func (s *Service) DependentServices() []project.ServiceRelationship {
 // Create implements Service.Create. It ensures the image exists or build it
 // if it can and then create a container.
 func (s *Service) Create(ctx context.Context, options options.Create) error {
+	_, _, err := s.create(ctx, options, false)
+	return err
+}
+
+// create ensures the image of the service exists and creates its container,
+// or recreates the existing ones if needed. It returns the resulting
+// containers, along with the one created from scratch if there was none.
+func (s *Service) create(ctx context.Context, options options.Create, renewAnonymousVolumes bool) ([]*container.Container, *container.Container, error) {
 	containers, err := s.collectContainers(ctx)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	if err := s.ensureImageExists(ctx, options.NoBuild, options.ForceBuild); err != nil {
-		return err
-	}
+	logrus.Debugf("Found %d existing containers for service %s", len(containers), s.name)
 
-	if len(containers) != 0 {
-		return s.eachContainer(ctx, containers, func(c *container.Container) error {
-			_, err := s.recreateIfNeeded(ctx, c, options.NoRecreate, options.ForceRecreate)
-			return err
-		})
+	if len(containers) == 0 || !options.NoRecreate {
+		if err := s.ensureImageExists(ctx, options.NoBuild, options.ForceBuild, options.PullPolicy); err != nil {
+			return nil, nil, err
+		}
+		if err := s.checkImagePlatform(ctx, options.StrictPlatform); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	namer, err := s.namer(ctx, 1)
-	if err != nil {
+	var result []*container.Container
+	var created *container.Container
+	err = s.withOperationTimeout(ctx, "create", func(ctx context.Context) error {
+		result, created, err = s.createContainers(ctx, containers, options, renewAnonymousVolumes)
 		return err
+	})
+	return result, created, err
+}
+
+// createContainers creates the container of the service if it has none yet,
+// or recreates the specified existing ones if needed, see create.
+func (s *Service) createContainers(ctx context.Context, containers []*container.Container, options options.Create, renewAnonymousVolumes bool) ([]*container.Container, *container.Container, error) {
+	if len(containers) == 0 {
+		namer, err := s.namer(ctx, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		created, err := s.createContainer(ctx, namer, "", nil, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*container.Container{created}, created, nil
 	}
 
-	_, err = s.createContainer(ctx, namer, "", nil, false)
-	return err
+	var mu sync.Mutex
+	result := []*container.Container{}
+	err := s.eachContainer(ctx, containers, func(c *container.Container) error {
+		c, err := s.recreateIfNeeded(ctx, c, options.NoRecreate, options.ForceRecreate, renewAnonymousVolumes)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		result = append(result, c)
+		return nil
+	})
+	return result, nil, err
 }
 
 func (s *Service) namer(ctx context.Context, count int) (Namer, error) {
@@ -134,27 +193,94 @@ func (s *Service) collectContainers(ctx context.Context) ([]*container.Container
 	return result, nil
 }
 
-func (s *Service) ensureImageExists(ctx context.Context, noBuild bool, forceBuild bool) error {
+// byNumber sorts containers by their container number.
+type byNumber []*container.Container
+
+func (c byNumber) Len() int      { return len(c) }
+func (c byNumber) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byNumber) Less(i, j int) bool {
+	ni, _ := c[i].Number()
+	nj, _ := c[j].Number()
+	return ni < nj
+}
+
+// ensureImageExists pulls or builds the image of the service if needed,
+// according to the specified pull policy, or to the service one if empty.
+func (s *Service) ensureImageExists(ctx context.Context, noBuild bool, forceBuild bool, pullPolicy string) error {
 	if forceBuild {
-		return s.build(ctx, options.Build{})
+		return s.buildImage(ctx)
+	}
+	if pullPolicy == "" {
+		pullPolicy = s.Config().PullPolicy
 	}
 
 	exists, err := image.Exists(ctx, s.clientFactory.Create(s), s.imageName())
 	if err != nil {
 		return err
 	}
-	if exists {
+
+	switch pullPolicy {
+	case config.PullPolicyAlways:
+		// There is nothing to pull for services without image
+		if s.Config().Image != "" {
+			return s.withOperationTimeout(ctx, "pull", s.pullImage)
+		}
+		if noBuild {
+			return fmt.Errorf("Service %q needs to be built, but no-build was specified", s.name)
+		}
+		return s.buildImage(ctx)
+	case config.PullPolicyNever:
+		if !exists {
+			return fmt.Errorf("Image %s of service %s is missing and its pull policy is never", s.imageName(), s.name)
+		}
 		return nil
+	case config.PullPolicyBuild:
+		// Services without a build section can only be pulled
+		if s.Config().Build.Context != "" {
+			if noBuild {
+				return fmt.Errorf("Service %q needs to be built, but no-build was specified", s.name)
+			}
+			return s.buildImage(ctx)
+		}
+	}
+
+	if exists {
+		return s.withOperationTimeout(ctx, "pull", func(ctx context.Context) error {
+			return s.pullIfExpired(ctx, pullPolicy)
+		})
 	}
 
 	if s.Config().Build.Context != "" {
 		if noBuild {
 			return fmt.Errorf("Service %q needs to be built, but no-build was specified", s.name)
 		}
+		return s.buildImage(ctx)
+	}
+
+	return s.withOperationTimeout(ctx, "pull", s.pullImage)
+}
+
+// buildImage builds the image of the service within its operation timeout,
+// reporting failures as a project.BuildError.
+func (s *Service) buildImage(ctx context.Context) error {
+	err := s.withOperationTimeout(ctx, "build", func(ctx context.Context) error {
 		return s.build(ctx, options.Build{})
+	})
+	if err != nil {
+		return &project.BuildError{Service: s.name, Err: err}
 	}
+	return nil
+}
 
-	return s.Pull(ctx)
+// checkImagePlatform warns if the service image has been built for another
+// platform than the daemon one, or fails if strict is set.
+func (s *Service) checkImagePlatform(ctx context.Context, strict bool) error {
+	err := image.CheckPlatform(ctx, s.clientFactory.Create(s), s.imageName(), s.Config().Platform)
+	if _, ok := err.(*image.PlatformMismatchError); ok && !strict {
+		logrus.Warnf("Service %s: %v", s.name, err)
+		return nil
+	}
+	return err
 }
 
 func (s *Service) imageName() string {
@@ -170,21 +296,51 @@ func (s *Service) Build(ctx context.Context, buildOptions options.Build) error {
 }
 
 func (s *Service) build(ctx context.Context, buildOptions options.Build) error {
-	if s.Config().Build.Context == "" {
+	contextReader := buildOptions.ContextReaders[s.name]
+	if s.Config().Build.Context == "" && contextReader == nil {
 		return fmt.Errorf("Specified service does not have a build section")
 	}
-	builder := &builder.DaemonBuilder{
-		Client:           s.clientFactory.Create(s),
-		ContextDirectory: s.Config().Build.Context,
-		Dockerfile:       s.Config().Build.Dockerfile,
-		BuildArgs:        s.Config().Build.Args,
+	builder := s.newBuilder(buildOptions)
+	builder.Client = s.clientFactory.Create(s)
+	builder.ContextReader = contextReader
+	return builder.Build(ctx, s.imageName())
+}
+
+// newBuilder returns the builder for the build section of the service and the
+// specified build options, without client.
+func (s *Service) newBuilder(buildOptions options.Build) *builder.DaemonBuilder {
+	build := s.Config().Build
+	cacheFrom := []string{}
+	for _, image := range build.CacheFrom {
+		if image != nil {
+			cacheFrom = append(cacheFrom, *image)
+		}
+	}
+	return &builder.DaemonBuilder{
+		ContextDirectory: build.Context,
+		Dockerfile:       build.Dockerfile,
+		BuildArgs:        build.Args,
 		AuthConfigs:      s.authLookup.All(),
-		NoCache:          buildOptions.NoCache,
+		NoCache:          overrideBool(buildOptions.NoCache, build.NoCache),
 		ForceRemove:      buildOptions.ForceRemove,
-		Pull:             buildOptions.Pull,
-		LoggerFactory:    s.context.LoggerFactory,
+		Pull:             overrideBool(buildOptions.Pull, build.Pull),
+		CacheFrom:        cacheFrom,
+		Labels:           build.Labels,
+		Network:          build.Network,
+		Platform:         build.Platform,
+		ShmSize:          int64(build.ShmSize),
+		Target:           build.Target,
+		LoggerFactory:    s.loggerFactory(),
+		LoggerName:       s.name,
 	}
-	return builder.Build(ctx, s.imageName())
+}
+
+// overrideBool returns the specified value, unless overridden.
+func overrideBool(value bool, override *bool) bool {
+	if override != nil {
+		return *override
+	}
+	return value
 }
 
 func (s *Service) constructContainers(ctx context.Context, count int) ([]*container.Container, error) {
@@ -225,27 +381,108 @@ func (s *Service) constructContainers(ctx context.Context, count int) ([]*contai
 }
 
 // Up implements Service.Up. It builds the image if needed, creates a container
-// and start it.
+// and start it, each of these operations within the operation timeout of the
+// service.
 func (s *Service) Up(ctx context.Context, options options.Up) error {
+	s = s.withRestartPolicy(options)
+	s = s.withRetries(options)
+
+	containers, created, err := s.create(ctx, options.Create, options.RenewAnonymousVolumes)
+	if err == nil {
+		err = s.withOperationTimeout(ctx, "start", func(ctx context.Context) error {
+			return s.startContainers(ctx, containers)
+		})
+	}
+	_, timedOut := err.(*project.OperationTimeoutError)
+	if err != nil && created != nil && (ctx.Err() != nil || timedOut) {
+		// Don't leave behind the container created from scratch by this
+		// invocation half-configured, the context may not be usable anymore
+		// to remove it. Recreated containers are kept, the ones they replace
+		// are already gone.
+		logrus.Infof("Removing %s, interrupted while being brought up", created.Name())
+		if removeErr := created.Remove(context.Background(), false); removeErr != nil {
+			logrus.Warnf("Failed to remove %s: %v", created.Name(), removeErr)
+		}
+	}
+	return err
+}
+
+// Plan implements Service.Plan. It compares the containers of the service
+// with its configuration and returns what Up would do with each of them.
+func (s *Service) Plan(ctx context.Context, options options.Up) ([]project.Action, error) {
+	s = s.withRestartPolicy(options)
+
 	containers, err := s.collectContainers(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var imageName = s.imageName()
-	if len(containers) == 0 || !options.NoRecreate {
-		if err = s.ensureImageExists(ctx, options.NoBuild, options.ForceBuild); err != nil {
-			return err
+	if len(containers) == 0 {
+		namer, err := s.namer(ctx, 1)
+		if err != nil {
+			return nil, err
 		}
+		name, _ := namer.Next()
+		return []project.Action{{Service: s.name, Container: name, Kind: project.ActionCreate}}, nil
 	}
 
-	return s.up(ctx, imageName, true, options)
+	actions := []project.Action{}
+	for _, c := range containers {
+		kind := project.ActionNone
+		running := c.IsRunning(ctx)
+		if !options.NoRecreate {
+			outOfSync, err := s.OutOfSync(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			if options.ForceRecreate || outOfSync {
+				kind = project.ActionRecreate
+			}
+		}
+		if kind == project.ActionNone && !running {
+			kind = project.ActionStart
+		}
+		actions = append(actions, project.Action{Service: s.name, Container: c.Name(), Kind: kind})
+	}
+	return actions, nil
+}
+
+// withRestartPolicy returns the service with the restart policy overridden
+// by the specified up options, if any. It works on a copy so that the service
+// configuration is left untouched.
+func (s *Service) withRestartPolicy(options options.Up) *Service {
+	policy, ok := options.RestartPolicy[s.name]
+	if !ok {
+		return s
+	}
+	serviceConfig := *s.serviceConfig
+	serviceConfig.Restart = policy
+	overridden := *s
+	overridden.serviceConfig = &serviceConfig
+	return &overridden
+}
+
+// withOperationTimeout performs the specified operation of the service within
+// its operation timeout, if any.
+func (s *Service) withOperationTimeout(ctx context.Context, operation string, action func(ctx context.Context) error) error {
+	return s.context.WithOperationTimeout(ctx, s.name, operation, action)
+}
+
+// withRetries returns a copy of the service that retries container creation
+// and start as specified by the up options.
+func (s *Service) withRetries(options options.Up) *Service {
+	if options.MaxRetries == 0 {
+		return s
+	}
+	retrying := *s
+	retrying.retry = retryPolicy{maxRetries: options.MaxRetries, backoff: options.RetryBackoff}
+	return &retrying
 }
 
 // Run implements Service.Run. It runs a one of command within the service container.
 // It always create a new container.
 func (s *Service) Run(ctx context.Context, commandParts []string, options options.Run) (int, error) {
-	err := s.ensureImageExists(ctx, false, false)
+	err := s.ensureImageExists(ctx, false, false, "")
 	if err != nil {
 		return -1, err
 	}
@@ -295,59 +532,67 @@ func (s *Service) Info(ctx context.Context) (project.InfoSet, error) {
 	return result, nil
 }
 
-// Start implements Service.Start. It tries to start a container without creating it.
+// Start implements Service.Start. It starts the existing containers of the
+// service without creating them, and fails if the service has none.
 func (s *Service) Start(ctx context.Context) error {
-	return s.collectContainersAndDo(ctx, func(c *container.Container) error {
-		if err := s.connectContainerToNetworks(ctx, c, false); err != nil {
-			return err
-		}
-		return c.Start(ctx)
-	})
-}
-
-func (s *Service) up(ctx context.Context, imageName string, create bool, options options.Up) error {
 	containers, err := s.collectContainers(ctx)
 	if err != nil {
 		return err
 	}
+	if len(containers) == 0 {
+		return fmt.Errorf("Service %s has no container to start, create it first with create or up", s.name)
+	}
+	return s.startContainers(ctx, containers)
+}
 
-	logrus.Debugf("Found %d existing containers for service %s", len(containers), s.name)
-
-	if len(containers) == 0 && create {
-		namer, err := s.namer(ctx, 1)
-		if err != nil {
+// startContainers connects the specified containers to their networks,
+// starts them and runs the post_start hooks of the service.
+func (s *Service) startContainers(ctx context.Context, containers []*container.Container) error {
+	return s.eachContainer(ctx, containers, func(c *container.Container) error {
+		if err := s.connectContainerToNetworks(ctx, c, false); err != nil {
 			return err
 		}
-		c, err := s.createContainer(ctx, namer, "", nil, false)
+		// Starting a running container is a no-op, it is neither reported
+		// nor are its post_start hooks run again
+		if c.IsRunning(ctx) {
+			return nil
+		}
+
+		err := s.retry.do(ctx, "start "+c.Name(), func() error {
+			return c.Start(ctx)
+		})
 		if err != nil {
 			return err
 		}
-		containers = []*container.Container{c}
-	}
 
-	return s.eachContainer(ctx, containers, func(c *container.Container) error {
-		var err error
-		if create {
-			c, err = s.recreateIfNeeded(ctx, c, options.NoRecreate, options.ForceRecreate)
-			if err != nil {
-				return err
-			}
-		}
+		s.project.Notify(events.ContainerStarted, s.name, map[string]string{
+			"name": c.Name(),
+		})
 
-		if err := s.connectContainerToNetworks(ctx, c, false); err != nil {
+		return s.runHooks(ctx, c, "post_start", s.serviceConfig.PostStart)
+	})
+}
+
+// runHooks executes the specified lifecycle hooks in the container, one
+// after the other, and fails on the first one that doesn't exit with 0.
+func (s *Service) runHooks(ctx context.Context, c *container.Container, kind string, hooks []config.ServiceHook) error {
+	for _, hook := range hooks {
+		logrus.Debugf("Running %s hook %v in %s", kind, hook.Command, c.Name())
+		exitCode, err := c.Exec(ctx, types.ExecConfig{
+			Cmd:        utils.CopySlice(hook.Command),
+			User:       hook.User,
+			Privileged: hook.Privileged,
+			WorkingDir: hook.WorkingDir,
+			Env:        utils.CopySlice(hook.Environment),
+		}, s.loggerFactory().CreateContainerLogger(c.Name()))
+		if err != nil {
 			return err
 		}
-
-		err = c.Start(ctx)
-
-		if err == nil {
-			s.project.Notify(events.ContainerStarted, s.name, map[string]string{
-				"name": c.Name(),
-			})
+		if exitCode != 0 {
+			return fmt.Errorf("%s hook %v of service %s exited with code %d", kind, hook.Command, s.name, exitCode)
 		}
-
-		return err
-	})
+	}
+	return nil
 }
 
 func (s *Service) connectContainerToNetworks(ctx context.Context, c *container.Container, oneOff bool) error {
@@ -418,8 +663,9 @@ func (s *Service) NetworkConnect(ctx context.Context, c *container.Container, ne
 			Links:     links,
 			IPAddress: net.IPv4Address,
 			IPAMConfig: &network.EndpointIPAMConfig{
-				IPv4Address: net.IPv4Address,
-				IPv6Address: net.IPv6Address,
+				IPv4Address:  net.IPv4Address,
+				IPv6Address:  net.IPv6Address,
+				LinkLocalIPs: net.LinkLocalIPs,
 			},
 		})
 		logrus.Infof("disconnect")
@@ -430,13 +676,14 @@ func (s *Service) NetworkConnect(ctx context.Context, c *container.Container, ne
 		Links:     links,
 		IPAddress: net.IPv4Address,
 		IPAMConfig: &network.EndpointIPAMConfig{
-			IPv4Address: net.IPv4Address,
-			IPv6Address: net.IPv6Address,
+			IPv4Address:  net.IPv4Address,
+			IPv6Address:  net.IPv6Address,
+			LinkLocalIPs: net.LinkLocalIPs,
 		},
 	})
 }
 
-func (s *Service) recreateIfNeeded(ctx context.Context, c *container.Container, noRecreate, forceRecreate bool) (*container.Container, error) {
+func (s *Service) recreateIfNeeded(ctx context.Context, c *container.Container, noRecreate, forceRecreate, renewAnonymousVolumes bool) (*container.Container, error) {
 	if noRecreate {
 		return c, nil
 	}
@@ -452,7 +699,7 @@ func (s *Service) recreateIfNeeded(ctx context.Context, c *container.Container,
 
 	if forceRecreate || outOfSync {
 		logrus.Infof("Recreating %s", s.name)
-		newContainer, err := s.recreate(ctx, c)
+		newContainer, err := s.recreate(ctx, c, renewAnonymousVolumes)
 		if err != nil {
 			return c, err
 		}
@@ -462,7 +709,10 @@ func (s *Service) recreateIfNeeded(ctx context.Context, c *container.Container,
 	return c, err
 }
 
-func (s *Service) recreate(ctx context.Context, c *container.Container) (*container.Container, error) {
+// recreate replaces the specified container with a new one. The anonymous
+// volumes of the old container are reattached to the new one, unless
+// renewAnonymousVolumes is set.
+func (s *Service) recreate(ctx context.Context, c *container.Container, renewAnonymousVolumes bool) (*container.Container, error) {
 	name := c.Name()
 	id := c.ID()
 	newName := fmt.Sprintf("%s_%s", name, id[:12])
@@ -472,7 +722,11 @@ func (s *Service) recreate(ctx context.Context, c *container.Container) (*contai
 		return nil, err
 	}
 	namer := NewSingleNamer(name)
-	newContainer, err := s.createContainer(ctx, namer, id, nil, false)
+	oldContainer := id
+	if renewAnonymousVolumes {
+		oldContainer = ""
+	}
+	newContainer, err := s.createContainer(ctx, namer, oldContainer, nil, false)
 	if err != nil {
 		return nil, err
 	}
@@ -537,17 +791,159 @@ func (s *Service) eachContainer(ctx context.Context, containers []*container.Con
 	return tasks.Wait()
 }
 
-// Stop implements Service.Stop. It stops any containers related to the service.
+// Stop implements Service.Stop. It stops any running containers related to
+// the service, leaving them in place to be started again. The stop_grace_period
+// of the service takes precedence over the specified timeout.
 func (s *Service) Stop(ctx context.Context, timeout int) error {
-	timeout = s.stopTimeout(timeout)
+	timeout = s.gracefulStopTimeout(timeout)
 	return s.collectContainersAndDo(ctx, func(c *container.Container) error {
+		if len(s.serviceConfig.PreStop) > 0 && c.IsRunning(ctx) {
+			if err := s.runHooks(ctx, c, "pre_stop", s.serviceConfig.PreStop); err != nil {
+				logrus.Warnf("Failed to run pre_stop hooks of %s: %v", c.Name(), err)
+			}
+		}
 		return c.Stop(ctx, timeout)
 	})
 }
 
+// healthPollInterval is the interval at which the health of containers is
+// polled while waiting for them to be healthy.
+const healthPollInterval = time.Second
+
+// WaitHealthy implements Service.WaitHealthy. It waits for all the containers
+// of the service to be healthy.
+func (s *Service) WaitHealthy(ctx context.Context) error {
+	containers, err := s.collectContainers(ctx)
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("Service %s has no container to wait for", s.name)
+	}
+	return s.eachContainer(ctx, containers, func(c *container.Container) error {
+		return c.WaitHealthy(ctx, healthPollInterval)
+	})
+}
+
+// WaitFor implements Service.WaitFor. It waits for all the containers of the
+// service to be running, healthy or exited, and returns the exit codes of the
+// containers by name for the latter.
+func (s *Service) WaitFor(ctx context.Context, condition string) (map[string]int, error) {
+	if condition == project.WaitConditionHealthy {
+		return nil, s.WaitHealthy(ctx)
+	}
+	containers, err := s.collectContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("Service %s has no container to wait for", s.name)
+	}
+
+	var mu sync.Mutex
+	exitCodes := map[string]int{}
+	err = s.eachContainer(ctx, containers, func(c *container.Container) error {
+		if condition == project.WaitConditionRunning {
+			return c.WaitRunning(ctx, healthPollInterval)
+		}
+		exitCode, err := c.WaitExited(ctx, healthPollInterval)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		exitCodes[c.Name()] = exitCode
+		mu.Unlock()
+		return nil
+	})
+	if err != nil || condition == project.WaitConditionRunning {
+		return nil, err
+	}
+	return exitCodes, nil
+}
+
+// Sync implements Service.Sync. It copies the specified files in each
+// container of the service, and removes the deleted ones.
+func (s *Service) Sync(ctx context.Context, files []project.FileSync) error {
+	content, err := syncArchive(files)
+	if err != nil {
+		return err
+	}
+	var deleted []string
+	for _, file := range files {
+		if file.Deleted {
+			deleted = append(deleted, file.Target)
+		}
+	}
+
+	return s.collectContainersAndDo(ctx, func(c *container.Container) error {
+		if len(deleted) > 0 {
+			exitCode, err := c.Exec(ctx, types.ExecConfig{
+				Cmd: append([]string{"rm", "-rf"}, deleted...),
+			}, &logger.NullLogger{})
+			if err != nil {
+				return err
+			}
+			if exitCode != 0 {
+				return fmt.Errorf("Failed to remove deleted files from %s", c.Name())
+			}
+		}
+		if content == nil {
+			return nil
+		}
+		return c.CopyTo(ctx, "/", bytes.NewReader(content))
+	})
+}
+
+// syncArchive creates a tar archive holding the files to sync, at their
+// target path. It returns nil if there isn't any file to copy.
+func syncArchive(files []project.FileSync) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	count := 0
+	for _, file := range files {
+		if file.Deleted {
+			continue
+		}
+		info, err := os.Stat(file.Source)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Removed in the meantime, nothing to copy
+				continue
+			}
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return nil, err
+		}
+		header.Name = strings.TrimPrefix(file.Target, "/")
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadFile(file.Source)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return nil, err
+		}
+		count++
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
 // Restart implements Service.Restart. It restarts any containers related to the service.
 func (s *Service) Restart(ctx context.Context, timeout int) error {
-	timeout = s.stopTimeout(timeout)
+	timeout = s.gracefulStopTimeout(timeout)
 	return s.collectContainersAndDo(ctx, func(c *container.Container) error {
 		return c.Restart(ctx, timeout)
 	})
@@ -572,7 +968,7 @@ func (s *Service) Delete(ctx context.Context, options options.Delete) error {
 }
 
 // Log implements Service.Log. It returns the docker logs for each container related to the service.
-func (s *Service) Log(ctx context.Context, follow bool) error {
+func (s *Service) Log(ctx context.Context, options options.Log) error {
 	return s.collectContainersAndDo(ctx, func(c *container.Container) error {
 		containerNumber, err := c.Number()
 		if err != nil {
@@ -582,8 +978,8 @@ func (s *Service) Log(ctx context.Context, follow bool) error {
 		if s.Config().ContainerName != "" {
 			name = s.Config().ContainerName
 		}
-		l := s.context.LoggerFactory.CreateContainerLogger(name)
-		return c.Log(ctx, l, follow)
+		l := s.loggerFactory().CreateContainerLogger(name)
+		return c.Log(ctx, l, options)
 	})
 }
 
@@ -599,28 +995,22 @@ func (s *Service) Scale(ctx context.Context, scale int, timeout int) error {
 		return err
 	}
 	if len(containers) > scale {
-		foundCount := 0
-		for _, c := range containers {
-			foundCount++
-			if foundCount > scale {
-				timeout = s.stopTimeout(timeout)
-				if err := c.Stop(ctx, timeout); err != nil {
-					return err
-				}
-				// FIXME(vdemeester) remove volume in scale by default ?
-				if err := c.Remove(ctx, false); err != nil {
-					return err
-				}
+		// Remove the highest numbered containers first
+		sort.Sort(sort.Reverse(byNumber(containers)))
+		timeout = s.stopTimeout(timeout)
+		for _, c := range containers[:len(containers)-scale] {
+			if err := c.Stop(ctx, timeout); err != nil {
+				return err
+			}
+			// FIXME(vdemeester) remove volume in scale by default ?
+			if err := c.Remove(ctx, false); err != nil {
+				return err
 			}
 		}
 	}
 
-	if err != nil {
-		return err
-	}
-
 	if len(containers) < scale {
-		err := s.ensureImageExists(ctx, false, false)
+		err := s.ensureImageExists(ctx, false, false, "")
 		if err != nil {
 			return err
 		}
@@ -630,17 +1020,81 @@ func (s *Service) Scale(ctx context.Context, scale int, timeout int) error {
 		}
 	}
 
-	return s.up(ctx, "", false, options.Up{})
+	// Unlike Start, scaling a service down to no container is fine
+	containers, err = s.collectContainers(ctx)
+	if err != nil {
+		return err
+	}
+	return s.startContainers(ctx, containers)
 }
 
-// Pull implements Service.Pull. It pulls the image of the service and skip the service that
-// would need to be built.
+// Pull implements Service.Pull. It pulls the image of the service and, for
+// services that build their image, the base images of their Dockerfile.
 func (s *Service) Pull(ctx context.Context) error {
+	if err := s.pullBaseImages(ctx); err != nil {
+		return err
+	}
+	return s.pullImage(ctx)
+}
+
+// pullBaseImages pulls the images the build of the service starts from, so
+// that building it later doesn't have to. Remote build contexts are left to
+// the daemon.
+func (s *Service) pullBaseImages(ctx context.Context) error {
+	build := s.Config().Build
+	if build.Context == "" || config.IsValidRemote(build.Context) {
+		return nil
+	}
+	baseImages, err := builder.BaseImages(build.Context, build.Dockerfile)
+	if err != nil {
+		return err
+	}
+	platform := build.Platform
+	if platform == "" {
+		platform = s.Config().Platform
+	}
+	for _, baseImage := range baseImages {
+		if err := image.PullImage(ctx, s.clientFactory.Create(s), s.name, s.authLookup, baseImage, platform, s.loggerFactory().CreatePullLogger(s.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pullImage pulls the image of the service, if it has one.
+func (s *Service) pullImage(ctx context.Context) error {
 	if s.Config().Image == "" {
 		return nil
 	}
 
-	return image.PullImage(ctx, s.clientFactory.Create(s), s.name, s.authLookup, s.Config().Image)
+	if err := image.PullImage(ctx, s.clientFactory.Create(s), s.name, s.authLookup, s.Config().Image, s.Config().Platform, s.loggerFactory().CreatePullLogger(s.name)); err != nil {
+		return err
+	}
+
+	// Only the time-windowed pull policies need to know about past pulls
+	if interval, _ := config.PullPolicyInterval(s.Config().PullPolicy); interval > 0 {
+		if err := image.NewPullState(s.project.Name).RecordPull(s.Config().Image, time.Now()); err != nil {
+			logrus.Warnf("Failed to record the pull of %s: %v", s.Config().Image, err)
+		}
+	}
+	return nil
+}
+
+// pullIfExpired pulls the existing image of the service again if the pull
+// policy is a time-windowed one and the window since the last pull elapsed.
+func (s *Service) pullIfExpired(ctx context.Context, pullPolicy string) error {
+	interval, err := config.PullPolicyInterval(pullPolicy)
+	if err != nil || interval == 0 || s.Config().Image == "" {
+		return err
+	}
+	lastPull, err := image.NewPullState(s.project.Name).LastPull(s.Config().Image)
+	if err != nil {
+		logrus.Warnf("Failed to read the last pull of %s: %v", s.Config().Image, err)
+	}
+	if time.Since(lastPull) < interval {
+		return nil
+	}
+	return s.pullImage(ctx)
 }
 
 // Pause implements Service.Pause. It puts into pause the container(s) related
@@ -676,10 +1130,9 @@ func (s *Service) RemoveImage(ctx context.Context, imageType options.ImageType)
 	}
 }
 
-var eventAttributes = []string{"image", "name"}
-
 // Events implements Service.Events. It listen to all real-time events happening
-// for the service, and put them into the specified chan.
+// for the service, and put them into the specified chan. It returns when the
+// context is cancelled or the event stream fails.
 func (s *Service) Events(ctx context.Context, evts chan events.ContainerEvent) error {
 	filter := filters.NewArgs()
 	filter.Add("label", fmt.Sprintf("%s=%s", labels.PROJECT, s.project.Name))
@@ -688,28 +1141,35 @@ func (s *Service) Events(ctx context.Context, evts chan events.ContainerEvent) e
 	eventq, errq := client.Events(ctx, types.EventsOptions{
 		Filters: filter,
 	})
-	go func() {
-		for {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errq:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case event := <-eventq:
+			attributes := map[string]string{}
+			for key, value := range event.Actor.Attributes {
+				attributes[key] = value
+			}
+			e := events.ContainerEvent{
+				Service:    event.Actor.Attributes[labels.SERVICE.Str()],
+				Event:      event.Action,
+				Type:       event.Type,
+				ID:         event.Actor.ID,
+				Time:       time.Unix(event.Time, 0),
+				Attributes: attributes,
+			}
 			select {
-			case event := <-eventq:
-				service := event.Actor.Attributes[labels.SERVICE.Str()]
-				attributes := map[string]string{}
-				for _, attr := range eventAttributes {
-					attributes[attr] = event.Actor.Attributes[attr]
-				}
-				e := events.ContainerEvent{
-					Service:    service,
-					Event:      event.Action,
-					Type:       event.Type,
-					ID:         event.Actor.ID,
-					Time:       time.Unix(event.Time, 0),
-					Attributes: attributes,
-				}
-				evts <- e
+			case evts <- e:
+			case <-ctx.Done():
+				return nil
 			}
 		}
-	}()
-	return <-errq
+	}
 }
 
 // Containers implements Service.Containers. It returns the list of containers
@@ -755,9 +1215,22 @@ func (s *Service) stopTimeout(timeout int) int {
 	if timeout != 0 {
 		return timeout
 	}
-	configTimeout := utils.DurationStrToSecondsInt(s.Config().StopGracePeriod)
+	configTimeout := stopGracePeriod(s.Config())
 	if configTimeout != nil {
 		return *configTimeout
 	}
 	return DEFAULTTIMEOUT
 }
+
+// gracefulStopTimeout returns the timeout used to stop the containers, on
+// stop and before restarting them: the stop_grace_period of the service
+// takes precedence over the specified timeout, so that each service gets its
+// own. The stop signal is part of the container configuration, so the engine
+// already uses it.
+func (s *Service) gracefulStopTimeout(timeout int) int {
+	configTimeout := stopGracePeriod(s.Config())
+	if configTimeout != nil {
+		return *configTimeout
+	}
+	return s.stopTimeout(timeout)
+}
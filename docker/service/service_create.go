@@ -10,6 +10,7 @@ import (
 	"github.com/docker/docker/api/types"
 	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
 	"github.com/zengchen221/libcompose/config"
 	composecontainer "github.com/zengchen221/libcompose/docker/container"
 	"github.com/zengchen221/libcompose/labels"
@@ -19,17 +20,45 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// adaptToDaemonOS adapts the specified host config to linux daemons: the
+// windows only cpu_count and cpu_percent are dropped with a warning, and a
+// warning is issued for the isolations they reject. The daemon is only asked
+// for its os if one of these settings is used.
+func (s *Service) adaptToDaemonOS(ctx context.Context, client client.APIClient, hostConfig *containertypes.HostConfig) {
+	windowsCPU := hostConfig.CPUCount != 0 || hostConfig.CPUPercent != 0
+	if hostConfig.Isolation.IsDefault() && !windowsCPU {
+		return
+	}
+	if info, err := client.Info(ctx); err != nil || info.OSType != "linux" {
+		return
+	}
+	if !hostConfig.Isolation.IsDefault() {
+		logrus.Warnf("Service %s: the %s isolation is not supported by linux daemons, it will be rejected", s.name, hostConfig.Isolation)
+	}
+	if windowsCPU {
+		logrus.Warnf("Service %s: cpu_count and cpu_percent only apply to windows daemons, they are ignored", s.name)
+		hostConfig.CPUCount = 0
+		hostConfig.CPUPercent = 0
+	}
+}
+
 func (s *Service) createContainer(ctx context.Context, namer Namer, oldContainer string, configOverride *config.ServiceConfig, oneOff bool) (*composecontainer.Container, error) {
 	serviceConfig := s.serviceConfig
 	if configOverride != nil {
-		serviceConfig.Command = configOverride.Command
-		serviceConfig.Tty = configOverride.Tty
-		serviceConfig.StdinOpen = configOverride.StdinOpen
+		// Work on a copy, the override only applies to this container
+		overridden := *s.serviceConfig
+		overridden.Command = configOverride.Command
+		overridden.Tty = configOverride.Tty
+		overridden.StdinOpen = configOverride.StdinOpen
+		serviceConfig = &overridden
 	}
 	configWrapper, err := ConvertToAPI(serviceConfig, s.context.Context, s.clientFactory)
 	if err != nil {
 		return nil, err
 	}
+	// Like docker run -i, the stdin of one-off containers is closed once the
+	// attached client detaches
+	configWrapper.Config.StdinOnce = oneOff && serviceConfig.StdinOpen
 	configWrapper.Config.Image = s.imageName()
 
 	containerName, containerNumber := namer.Next()
@@ -40,6 +69,11 @@ func (s *Service) createContainer(ctx context.Context, namer Namer, oldContainer
 	configWrapper.Config.Labels[labels.ONEOFF.Str()] = strings.Title(strconv.FormatBool(oneOff))
 	configWrapper.Config.Labels[labels.NUMBER.Str()] = fmt.Sprintf("%d", containerNumber)
 	configWrapper.Config.Labels[labels.VERSION.Str()] = project.ComposeVersion
+	for key, value := range s.context.CustomLabels {
+		if _, ok := configWrapper.Config.Labels[key]; !ok {
+			configWrapper.Config.Labels[key] = value
+		}
+	}
 
 	err = s.populateAdditionalHostConfig(configWrapper.HostConfig)
 	if err != nil {
@@ -48,6 +82,7 @@ func (s *Service) createContainer(ctx context.Context, namer Namer, oldContainer
 
 	// FIXME(vdemeester): oldContainer should be a Container instead of a string
 	client := s.clientFactory.Create(s)
+	s.adaptToDaemonOS(ctx, client, configWrapper.HostConfig)
 	if oldContainer != "" {
 		info, err := client.ContainerInspect(ctx, oldContainer)
 		if err != nil {
@@ -77,7 +112,26 @@ func (s *Service) createContainer(ctx context.Context, namer Namer, oldContainer
 	}
 	logrus.Debugf("Creating container %s %#v", containerName, configWrapper)
 	// FIXME(vdemeester): long-term will be container.Create(…)
-	container, err := composecontainer.Create(ctx, client, containerName, configWrapper.Config, configWrapper.HostConfig, networkConfig)
+	var container *composecontainer.Container
+	retried := false
+	err = s.retry.do(ctx, "create "+containerName, func() error {
+		if retried {
+			// The daemon may have created the container before failing, a
+			// new create would then conflict with it.
+			existing, err := composecontainer.Get(ctx, client, containerName)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				container = composecontainer.NewInspected(client, existing)
+				return nil
+			}
+		}
+		retried = true
+		var err error
+		container, err = composecontainer.Create(ctx, client, containerName, configWrapper.Config, configWrapper.HostConfig, networkConfig)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +147,10 @@ func (s *Service) populateAdditionalHostConfig(hostConfig *containertypes.HostCo
 		return err
 	}
 
+	if s.serviceConfig.Ipc == "" && s.sharesIpc() {
+		hostConfig.IpcMode = containertypes.IpcMode("shareable")
+	}
+
 	for _, link := range s.DependentServices() {
 		if !s.project.ServiceConfigs.Has(link.Target) {
 			continue
@@ -170,6 +228,18 @@ func addLinks(links map[string]string, service project.Service, rel project.Serv
 	}
 }
 
+// sharesIpc returns whether another service of the project joins the IPC
+// namespace of this one, in which case it has to be shareable.
+func (s *Service) sharesIpc() bool {
+	for _, name := range s.project.ServiceConfigs.Keys() {
+		serviceConfig, _ := s.project.ServiceConfigs.Get(name)
+		if name != s.name && (serviceConfig.Ipc == "service:"+s.name || serviceConfig.Ipc == "container:"+s.name) {
+			return true
+		}
+	}
+	return false
+}
+
 func addIpc(config *containertypes.HostConfig, service project.Service, containers []project.Container, ipc string) (*containertypes.HostConfig, error) {
 	if len(containers) == 0 {
 		return nil, fmt.Errorf("Failed to find container for IPC %v", ipc)
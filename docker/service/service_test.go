@@ -1,9 +1,30 @@
 package service
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
+	"golang.org/x/net/context"
+
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"github.com/zengchen221/libcompose/config"
+	"github.com/zengchen221/libcompose/docker/auth"
+	"github.com/zengchen221/libcompose/docker/container"
+	"github.com/zengchen221/libcompose/docker/ctx"
+	"github.com/zengchen221/libcompose/labels"
+	"github.com/zengchen221/libcompose/logger"
+	"github.com/zengchen221/libcompose/project"
+	"github.com/zengchen221/libcompose/project/options"
+	"github.com/zengchen221/libcompose/yaml"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,3 +47,494 @@ func TestSpecifiesHostPort(t *testing.T) {
 		assert.False(t, service.specificiesHostPort())
 	}
 }
+
+func TestSharesIpc(t *testing.T) {
+	p := project.NewProject(&project.Context{}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("shm", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("reader", &config.ServiceConfig{Ipc: "service:shm"})
+	p.ServiceConfigs.Add("other", &config.ServiceConfig{})
+
+	shm := &Service{name: "shm", project: p, serviceConfig: &config.ServiceConfig{}}
+	assert.True(t, shm.sharesIpc())
+
+	other := &Service{name: "other", project: p, serviceConfig: &config.ServiceConfig{}}
+	assert.False(t, other.sharesIpc())
+
+	reader := &Service{name: "reader", project: p, serviceConfig: &config.ServiceConfig{Ipc: "service:shm"}}
+	rels := DefaultDependentServices(p, reader)
+	assert.Equal(t, []project.ServiceRelationship{
+		project.NewServiceRelationship("shm", project.RelTypeIpcNamespace),
+	}, rels)
+}
+
+func TestSharesPid(t *testing.T) {
+	p := project.NewProject(&project.Context{}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("profiler", &config.ServiceConfig{Pid: "service:app"})
+
+	profiler := &Service{name: "profiler", project: p, serviceConfig: &config.ServiceConfig{Pid: "service:app"}}
+	rels := DefaultDependentServices(p, profiler)
+	assert.Equal(t, []project.ServiceRelationship{
+		project.NewServiceRelationship("app", project.RelTypePidNamespace),
+	}, rels)
+}
+
+func TestContainersByNumber(t *testing.T) {
+	containers := []*container.Container{}
+	for _, number := range []string{"2", "10", "1"} {
+		containers = append(containers, container.NewInspected(nil, &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{ID: number},
+			Config: &dockercontainer.Config{
+				Labels: map[string]string{labels.NUMBER.Str(): number},
+			},
+		}))
+	}
+
+	sort.Sort(sort.Reverse(byNumber(containers)))
+
+	ids := []string{}
+	for _, c := range containers {
+		ids = append(ids, c.ID())
+	}
+	assert.Equal(t, []string{"10", "2", "1"}, ids)
+}
+
+func TestNewBuilder(t *testing.T) {
+	label, cache := "value", "myapp:latest"
+	s := &Service{
+		name: "app",
+		serviceConfig: &config.ServiceConfig{
+			Build: yaml.Build{
+				Context:   "./app",
+				Target:    "prod",
+				CacheFrom: []*string{&cache},
+				Labels:    map[string]*string{"com.example.label": &label},
+				Network:   "host",
+			},
+		},
+		authLookup: auth.NewConfigLookup(nil),
+		context:    &ctx.Context{},
+	}
+
+	b := s.newBuilder(options.Build{NoCache: true})
+	assert.Equal(t, "./app", b.ContextDirectory)
+	assert.Equal(t, "prod", b.Target)
+	assert.Equal(t, []string{"myapp:latest"}, b.CacheFrom)
+	assert.Equal(t, map[string]*string{"com.example.label": &label}, b.Labels)
+	assert.Equal(t, "host", b.Network)
+	assert.True(t, b.NoCache)
+	assert.False(t, b.Pull)
+
+	// The build section values override the global build options either way
+	noCache, pull := false, true
+	s.serviceConfig.Build.NoCache, s.serviceConfig.Build.Pull = &noCache, &pull
+	b = s.newBuilder(options.Build{NoCache: true})
+	assert.False(t, b.NoCache)
+	assert.True(t, b.Pull)
+}
+
+func TestStopAndRestartTimeout(t *testing.T) {
+	gracePeriod, noGracePeriod := yaml.Duration(90*time.Second), yaml.Duration(0)
+	withGracePeriod := &Service{serviceConfig: &config.ServiceConfig{StopGracePeriod: &gracePeriod}}
+	withZeroGracePeriod := &Service{serviceConfig: &config.ServiceConfig{StopGracePeriod: &noGracePeriod}}
+	withoutGracePeriod := &Service{serviceConfig: &config.ServiceConfig{}}
+
+	assert.Equal(t, 5, withGracePeriod.stopTimeout(5))
+	assert.Equal(t, 90, withGracePeriod.stopTimeout(0))
+	assert.Equal(t, 10, withoutGracePeriod.stopTimeout(0))
+
+	assert.Equal(t, 90, withGracePeriod.gracefulStopTimeout(5))
+	assert.Equal(t, 5, withoutGracePeriod.gracefulStopTimeout(5))
+	assert.Equal(t, 10, withoutGracePeriod.gracefulStopTimeout(0))
+
+	assert.Equal(t, 0, withZeroGracePeriod.stopTimeout(0))
+	assert.Equal(t, 0, withZeroGracePeriod.gracefulStopTimeout(5))
+}
+
+type staticClientFactory struct {
+	client client.APIClient
+}
+
+func (f staticClientFactory) Create(service project.Service) client.APIClient {
+	return f.client
+}
+
+func TestStartWithoutContainers(t *testing.T) {
+	s := &Service{
+		name:          "web",
+		project:       &project.Project{Name: "app"},
+		serviceConfig: &config.ServiceConfig{},
+		clientFactory: staticClientFactory{client: &NamerClient{}},
+	}
+	err := s.Start(context.Background())
+	assert.EqualError(t, err, "Service web has no container to start, create it first with create or up")
+}
+
+func TestLoggerFactory(t *testing.T) {
+	s := &Service{name: "web", context: &ctx.Context{}}
+	s.context.LoggerFactory = &logger.NullLogger{}
+	assert.Equal(t, s.context.LoggerFactory, s.loggerFactory())
+
+	outputs := map[string]*bytes.Buffer{}
+	s.context.LogWriter = func(service string) io.Writer {
+		if outputs[service] == nil {
+			outputs[service] = &bytes.Buffer{}
+		}
+		return outputs[service]
+	}
+	s.loggerFactory().CreateContainerLogger("myproject_web_1").Out([]byte("started\n"))
+	s.loggerFactory().CreatePullLogger("web").Err([]byte("pulling\n"))
+	assert.Len(t, outputs, 1)
+	assert.Equal(t, "started\npulling\n", outputs["web"].String())
+}
+
+// imageClient fails the pulls, so that the tests can tell the pulls from the
+// builds (which fail on their missing context).
+type imageClient struct {
+	client.Client
+	exists bool
+}
+
+func (c *imageClient) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	if !c.exists {
+		return types.ImageInspect{}, nil, errdefs.NotFound(fmt.Errorf("No such image: %s", image))
+	}
+	return types.ImageInspect{}, nil, nil
+}
+
+func (c *imageClient) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("pulled %s", ref)
+}
+
+func TestEnsureImageExists(t *testing.T) {
+	newService := func(serviceConfig *config.ServiceConfig, exists bool) *Service {
+		return &Service{
+			name:          "web",
+			project:       &project.Project{Name: "app"},
+			serviceConfig: serviceConfig,
+			clientFactory: staticClientFactory{client: &imageClient{exists: exists}},
+			authLookup:    auth.NewConfigLookup(nil),
+			context:       &ctx.Context{Context: project.Context{LoggerFactory: &logger.NullLogger{}}},
+		}
+	}
+	imageOnly := &config.ServiceConfig{Image: "busybox"}
+
+	err := newService(imageOnly, false).ensureImageExists(context.Background(), false, false, config.PullPolicyBuild)
+	assert.EqualError(t, err, "pulled docker.io/library/busybox")
+	err = newService(imageOnly, true).ensureImageExists(context.Background(), false, false, config.PullPolicyBuild)
+	assert.Nil(t, err)
+
+	buildable := &config.ServiceConfig{Image: "busybox", Build: yaml.Build{Context: "/nonexistent"}}
+	err = newService(buildable, true).ensureImageExists(context.Background(), false, false, config.PullPolicyBuild)
+	assert.IsType(t, &project.BuildError{}, err)
+
+	err = newService(imageOnly, true).ensureImageExists(context.Background(), false, false, config.PullPolicyAlways)
+	assert.EqualError(t, err, "pulled docker.io/library/busybox")
+	buildOnly := &config.ServiceConfig{Build: yaml.Build{Context: "/nonexistent"}}
+	err = newService(buildOnly, true).ensureImageExists(context.Background(), false, false, config.PullPolicyAlways)
+	assert.IsType(t, &project.BuildError{}, err)
+	err = newService(buildOnly, true).ensureImageExists(context.Background(), true, false, config.PullPolicyAlways)
+	assert.EqualError(t, err, `Service "web" needs to be built, but no-build was specified`)
+}
+
+// slowPullClient has no image and pulls until the context is done.
+type slowPullClient struct {
+	imageClient
+}
+
+func (c *slowPullClient) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestEnsureImageExistsPullTimeout(t *testing.T) {
+	s := &Service{
+		name:          "web",
+		project:       &project.Project{Name: "app"},
+		serviceConfig: &config.ServiceConfig{Image: "busybox"},
+		clientFactory: staticClientFactory{client: &slowPullClient{}},
+		authLookup:    auth.NewConfigLookup(nil),
+		context: &ctx.Context{Context: project.Context{
+			LoggerFactory:    &logger.NullLogger{},
+			OperationTimeout: 50 * time.Millisecond,
+		}},
+	}
+
+	err := s.ensureImageExists(context.Background(), false, false, "")
+	assert.Equal(t, &project.OperationTimeoutError{Service: "web", Operation: "pull", Timeout: 50 * time.Millisecond}, err)
+}
+
+type startClient struct {
+	client.Client
+	started []string
+	execs   []string
+}
+
+func (c *startClient) ContainerStart(ctx context.Context, container string, options types.ContainerStartOptions) error {
+	c.started = append(c.started, container)
+	return nil
+}
+
+func (c *startClient) ContainerExecCreate(ctx context.Context, container string, config types.ExecConfig) (types.IDResponse, error) {
+	c.execs = append(c.execs, container)
+	return types.IDResponse{}, fmt.Errorf("exec failed")
+}
+
+func TestStartContainersSkipsRunningOnes(t *testing.T) {
+	clt := &startClient{}
+	newContainer := func(id string, running bool) *container.Container {
+		return container.NewInspected(clt, &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:    id,
+				Name:  "/app_web_" + id,
+				State: &types.ContainerState{Running: running},
+			},
+			NetworkSettings: &types.NetworkSettings{},
+		})
+	}
+	s := &Service{
+		name:          "web",
+		project:       project.NewProject(&project.Context{}, nil, nil),
+		serviceConfig: &config.ServiceConfig{PostStart: []config.ServiceHook{{Command: []string{"true"}}}},
+		context:       &ctx.Context{Context: project.Context{LoggerFactory: &logger.NullLogger{}}},
+	}
+
+	err := s.startContainers(context.Background(), []*container.Container{newContainer("running", true)})
+	assert.Nil(t, err)
+	assert.Empty(t, clt.started)
+	assert.Empty(t, clt.execs)
+
+	err = s.startContainers(context.Background(), []*container.Container{newContainer("stopped", false)})
+	assert.EqualError(t, err, "exec failed")
+	assert.Equal(t, []string{"stopped"}, clt.started)
+	assert.Equal(t, []string{"stopped"}, clt.execs)
+}
+
+// daemonClient keeps the containers it creates in memory. Starting a
+// container cancels the context, as if the user interrupted the up.
+type daemonClient struct {
+	client.Client
+	sync.Mutex
+	cancel     context.CancelFunc
+	osType     string
+	containers map[string]*types.ContainerJSON
+	created    int
+	removed    []string
+	// createErrors is the number of creates that fail with a daemon error
+	// after the container was actually created.
+	createErrors int
+}
+
+func (c *daemonClient) add(name string, config *dockercontainer.Config) string {
+	c.created++
+	id := fmt.Sprintf("%064d", c.created)
+	c.containers[id] = &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         id,
+			Name:       "/" + name,
+			State:      &types.ContainerState{},
+			HostConfig: &dockercontainer.HostConfig{},
+		},
+		Config:          config,
+		NetworkSettings: &types.NetworkSettings{},
+	}
+	return id
+}
+
+func (c *daemonClient) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	c.Lock()
+	defer c.Unlock()
+	containers := []types.Container{}
+	for id := range c.containers {
+		containers = append(containers, types.Container{ID: id})
+	}
+	return containers, nil
+}
+
+func (c *daemonClient) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	c.Lock()
+	defer c.Unlock()
+	if container, ok := c.containers[id]; ok {
+		return *container, nil
+	}
+	for _, container := range c.containers {
+		if container.Name == "/"+id {
+			return *container, nil
+		}
+	}
+	return types.ContainerJSON{}, errdefs.NotFound(fmt.Errorf("No such container: %s", id))
+}
+
+func (c *daemonClient) ContainerCreate(ctx context.Context, config *dockercontainer.Config, hostConfig *dockercontainer.HostConfig, networkingConfig *network.NetworkingConfig, name string) (dockercontainer.ContainerCreateCreatedBody, error) {
+	c.Lock()
+	defer c.Unlock()
+	for _, container := range c.containers {
+		if container.Name == "/"+name {
+			return dockercontainer.ContainerCreateCreatedBody{}, errdefs.Conflict(fmt.Errorf("Conflict. The container name %q is already in use", "/"+name))
+		}
+	}
+	id := c.add(name, config)
+	c.containers[id].HostConfig = hostConfig
+	if c.createErrors > 0 {
+		c.createErrors--
+		return dockercontainer.ContainerCreateCreatedBody{}, errdefs.System(fmt.Errorf("Internal server error"))
+	}
+	return dockercontainer.ContainerCreateCreatedBody{ID: id}, nil
+}
+
+func (c *daemonClient) ContainerRename(ctx context.Context, id, name string) error {
+	c.Lock()
+	defer c.Unlock()
+	c.containers[id].Name = "/" + name
+	return nil
+}
+
+func (c *daemonClient) ContainerRemove(ctx context.Context, id string, options types.ContainerRemoveOptions) error {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.containers, id)
+	c.removed = append(c.removed, id)
+	return nil
+}
+
+func (c *daemonClient) ContainerStop(ctx context.Context, id string, timeout *time.Duration) error {
+	return nil
+}
+
+// ContainerStart cancels the context of the test if any, and hangs until
+// the context of the start is done.
+func (c *daemonClient) ContainerStart(ctx context.Context, id string, options types.ContainerStartOptions) error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *daemonClient) ImageInspectWithRaw(ctx context.Context, image string) (types.ImageInspect, []byte, error) {
+	return types.ImageInspect{}, nil, nil
+}
+
+func (c *daemonClient) Info(ctx context.Context) (types.Info, error) {
+	return types.Info{OSType: c.osType}, nil
+}
+
+func TestUpCancelledRemovesCreatedContainers(t *testing.T) {
+	for _, existing := range []bool{false, true} {
+		upCtx, cancel := context.WithCancel(context.Background())
+		clt := &daemonClient{cancel: cancel, containers: map[string]*types.ContainerJSON{}}
+		if existing {
+			clt.add("app_web_1", &dockercontainer.Config{Image: "busybox"})
+		}
+		p := project.NewProject(&project.Context{}, nil, nil)
+		p.Name = "app"
+		p.ServiceConfigs = config.NewServiceConfigs()
+		s := &Service{
+			name:          "web",
+			project:       p,
+			serviceConfig: &config.ServiceConfig{Image: "busybox"},
+			clientFactory: staticClientFactory{client: clt},
+			authLookup:    auth.NewConfigLookup(nil),
+			context:       &ctx.Context{Context: project.Context{LoggerFactory: &logger.NullLogger{}}},
+		}
+
+		err := s.Up(upCtx, options.Up{Create: options.Create{ForceRecreate: true}})
+		assert.Equal(t, context.Canceled, err)
+		if existing {
+			// The replacement of the existing container is kept
+			assert.Len(t, clt.containers, 1)
+			assert.Equal(t, []string{fmt.Sprintf("%064d", 1)}, clt.removed)
+		} else {
+			assert.Empty(t, clt.containers)
+			assert.Len(t, clt.removed, 1)
+		}
+	}
+}
+
+func TestUpStartTimeoutKeepsRecreatedContainers(t *testing.T) {
+	clt := &daemonClient{containers: map[string]*types.ContainerJSON{}}
+	clt.add("app_web_1", &dockercontainer.Config{Image: "busybox"})
+	p := project.NewProject(&project.Context{}, nil, nil)
+	p.Name = "app"
+	p.ServiceConfigs = config.NewServiceConfigs()
+	s := &Service{
+		name:          "web",
+		project:       p,
+		serviceConfig: &config.ServiceConfig{Image: "busybox"},
+		clientFactory: staticClientFactory{client: clt},
+		authLookup:    auth.NewConfigLookup(nil),
+		context: &ctx.Context{Context: project.Context{
+			LoggerFactory:    &logger.NullLogger{},
+			OperationTimeout: 50 * time.Millisecond,
+		}},
+	}
+
+	err := s.Up(context.Background(), options.Up{Create: options.Create{ForceRecreate: true}})
+	assert.Equal(t, &project.OperationTimeoutError{Service: "web", Operation: "start", Timeout: 50 * time.Millisecond}, err)
+	assert.Len(t, clt.containers, 1)
+	assert.Equal(t, []string{fmt.Sprintf("%064d", 1)}, clt.removed)
+}
+
+func TestWindowsCPUSettings(t *testing.T) {
+	for osType, expected := range map[string]int64{"linux": 0, "windows": 2} {
+		clt := &daemonClient{osType: osType, containers: map[string]*types.ContainerJSON{}}
+		p := project.NewProject(&project.Context{}, nil, nil)
+		p.Name = "app"
+		s := &Service{
+			name:          "web",
+			project:       p,
+			serviceConfig: &config.ServiceConfig{Image: "busybox", CPUCount: 2, CPUPercent: 2},
+			clientFactory: staticClientFactory{client: clt},
+			context:       &ctx.Context{},
+		}
+
+		c, err := s.createContainer(context.Background(), NewSingleNamer("app_web_1"), "", nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hostConfig := clt.containers[c.ID()].HostConfig
+		assert.Equal(t, expected, hostConfig.CPUCount, osType)
+		assert.Equal(t, expected, hostConfig.CPUPercent, osType)
+	}
+}
+
+func TestCreateRetryReusesCreatedContainer(t *testing.T) {
+	clt := &daemonClient{containers: map[string]*types.ContainerJSON{}, createErrors: 1}
+	p := project.NewProject(&project.Context{}, nil, nil)
+	p.Name = "app"
+	s := &Service{
+		name:          "web",
+		project:       p,
+		serviceConfig: &config.ServiceConfig{Image: "busybox"},
+		clientFactory: staticClientFactory{client: clt},
+		context:       &ctx.Context{},
+		retry:         retryPolicy{maxRetries: 1, backoff: time.Millisecond},
+	}
+
+	c, err := s.createContainer(context.Background(), NewSingleNamer("app_web_1"), "", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, fmt.Sprintf("%064d", 1), c.ID())
+	assert.Len(t, clt.containers, 1)
+}
+
+func TestScaleToZero(t *testing.T) {
+	clt := &daemonClient{containers: map[string]*types.ContainerJSON{}}
+	clt.add("app_web_1", &dockercontainer.Config{Image: "busybox"})
+	clt.add("app_web_2", &dockercontainer.Config{Image: "busybox"})
+	p := project.NewProject(&project.Context{}, nil, nil)
+	p.Name = "app"
+	s := &Service{
+		name:          "web",
+		project:       p,
+		serviceConfig: &config.ServiceConfig{Image: "busybox"},
+		clientFactory: staticClientFactory{client: clt},
+		context:       &ctx.Context{},
+	}
+
+	assert.Nil(t, s.Scale(context.Background(), 0, 10))
+	assert.Empty(t, clt.containers)
+}
@@ -20,15 +20,16 @@ type Volume struct {
 	driver        string
 	driverOptions map[string]string
 	external      bool
-	// TODO (shouze) missing labels
+	labels        map[string]string
+	// customName is true when the name is not scoped to the project
+	customName bool
 }
 
 func (v *Volume) fullName() string {
-	name := v.projectName + "_" + v.name
-	if v.external {
-		name = v.name
+	if v.external || v.customName {
+		return v.name
 	}
-	return name
+	return v.projectName + "_" + v.name
 }
 
 // Inspect inspect the current volume
@@ -72,7 +73,7 @@ func (v *Volume) create(ctx context.Context) error {
 		Name:       v.fullName(),
 		Driver:     v.driver,
 		DriverOpts: v.driverOptions,
-		// TODO (shouze) missing labels
+		Labels:     v.labels,
 	})
 
 	return err
@@ -89,7 +90,13 @@ func NewVolume(projectName, name string, config *config.VolumeConfig, client cli
 		vol.driver = config.Driver
 		vol.driverOptions = config.DriverOpts
 		vol.external = config.External.External
-
+		vol.labels = config.Labels
+		if vol.external && config.External.Name != "" {
+			vol.name = config.External.Name
+		} else if config.Name != "" {
+			vol.name = config.Name
+			vol.customName = true
+		}
 	}
 	return vol
 }
@@ -1,11 +1,15 @@
 package volume
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
 	"github.com/zengchen221/libcompose/config"
+	"github.com/zengchen221/libcompose/yaml"
+	"golang.org/x/net/context"
 )
 
 func TestVolumesFromServices(t *testing.T) {
@@ -95,3 +99,105 @@ type volumeClient struct {
 	inspectVolumeOptions map[string]string
 	removeError          error
 }
+
+func (c *volumeClient) VolumeInspect(ctx context.Context, volumeID string) (types.Volume, error) {
+	if c.inspectError != nil {
+		return types.Volume{}, c.inspectError
+	}
+	return types.Volume{
+		Name:    volumeID,
+		Driver:  c.inspectVolumeDriver,
+		Options: c.inspectVolumeOptions,
+	}, nil
+}
+
+func (c *volumeClient) VolumeCreate(ctx context.Context, options volume.VolumeCreateBody) (types.Volume, error) {
+	c.expectedVolumeCreate = options
+	return types.Volume{Name: options.Name}, nil
+}
+
+func (c *volumeClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	c.expectedName = volumeID
+	return c.removeError
+}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string  { return "no such volume" }
+func (notFoundError) NotFound() bool { return true }
+
+func TestVolumeEnsureItExists(t *testing.T) {
+	cases := []struct {
+		config         *config.VolumeConfig
+		inspectError   error
+		expectedCreate volume.VolumeCreateBody
+		expectedError  bool
+	}{
+		{
+			config: &config.VolumeConfig{
+				Driver:     "local",
+				DriverOpts: map[string]string{"type": "tmpfs"},
+				Labels:     map[string]string{"com.example.label": "value"},
+			},
+			inspectError: notFoundError{},
+			expectedCreate: volume.VolumeCreateBody{
+				Name:       "prj_vol1",
+				Driver:     "local",
+				DriverOpts: map[string]string{"type": "tmpfs"},
+				Labels:     map[string]string{"com.example.label": "value"},
+			},
+		},
+		{
+			config:       &config.VolumeConfig{Name: "shared"},
+			inspectError: notFoundError{},
+			expectedCreate: volume.VolumeCreateBody{
+				Name: "shared",
+			},
+		},
+		{
+			config:        &config.VolumeConfig{External: yaml.External{External: true}},
+			inspectError:  notFoundError{},
+			expectedError: true,
+		},
+		{
+			config: &config.VolumeConfig{External: yaml.External{External: true, Name: "other"}},
+		},
+	}
+
+	for index, c := range cases {
+		cli := &volumeClient{inspectError: c.inspectError}
+		vol := NewVolume("prj", "vol1", c.config, cli)
+		err := vol.EnsureItExists(context.Background())
+		if c.expectedError {
+			if err == nil {
+				t.Fatalf("%d: expected an error, got nothing", index)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: didn't expect an error, got one %s", index, err.Error())
+		}
+		if !reflect.DeepEqual(cli.expectedVolumeCreate, c.expectedCreate) {
+			t.Fatalf("%d: expected volume create %v, got %v", index, c.expectedCreate, cli.expectedVolumeCreate)
+		}
+	}
+}
+
+func TestVolumeFullName(t *testing.T) {
+	cases := []struct {
+		config   *config.VolumeConfig
+		expected string
+	}{
+		{nil, "prj_vol1"},
+		{&config.VolumeConfig{Name: "shared"}, "shared"},
+		{&config.VolumeConfig{External: yaml.External{External: true}}, "vol1"},
+		{&config.VolumeConfig{External: yaml.External{External: true, Name: "other"}}, "other"},
+	}
+
+	for index, c := range cases {
+		vol := NewVolume("prj", "vol1", c.config, &volumeClient{})
+		if vol.fullName() != c.expected {
+			t.Fatalf("%d: expected %s, got %s", index, c.expected, vol.fullName())
+		}
+	}
+}
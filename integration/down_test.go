@@ -3,6 +3,9 @@ package integration
 import (
 	"fmt"
 
+	"github.com/docker/docker/api/types"
+	"golang.org/x/net/context"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -34,3 +37,28 @@ func (s *CliSuite) TestDownMultiple(c *C) {
 	containers = s.GetContainersByProject(c, p)
 	c.Assert(len(containers), Equals, 0)
 }
+
+func (s *CliSuite) TestDownKeepNetworks(c *C) {
+	template := `
+version: '2'
+services:
+  hello:
+    image: busybox
+    command: top
+`
+	p := s.ProjectFromText(c, "up", template)
+	network := fmt.Sprintf("%s_default", p)
+
+	s.FromText(c, p, "down", "--keep-networks", template)
+
+	containers := s.GetContainersByProject(c, p)
+	c.Assert(len(containers), Equals, 0)
+
+	client := GetClient(c)
+	_, err := client.NetworkInspect(context.Background(), network, types.NetworkInspectOptions{})
+	c.Assert(err, IsNil)
+
+	s.FromText(c, p, "down", template)
+	_, err = client.NetworkInspect(context.Background(), network, types.NetworkInspectOptions{})
+	c.Assert(err, NotNil)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/go-connections/nat"
@@ -81,6 +82,25 @@ func (s *CliSuite) TestRecreateVols(c *C) {
 	c.Assert(cn2Mounts["/root:/root"], Equals, false)
 }
 
+func (s *CliSuite) TestRecreateRenewAnonymousVolumes(c *C) {
+	p := s.ProjectFromText(c, "up", SimpleTemplateWithVols)
+
+	name := fmt.Sprintf("%s_%s_1", p, "hello")
+	cn := s.GetContainerByName(c, name)
+	c.Assert(cn, NotNil)
+
+	p = s.FromText(c, p, "up", "--force-recreate", "--renew-anon-volumes", SimpleTemplateWithVols)
+	cn2 := s.GetContainerByName(c, name)
+	c.Assert(cn.ID, Not(Equals), cn2.ID)
+
+	cn2Mounts := mountSet(cn2.Mounts)
+	for _, mount := range cn.Mounts {
+		if mount.Destination == "/var/lib/vol1" {
+			c.Assert(cn2Mounts[fmt.Sprint(mount.Source, ":", mount.Destination)], Equals, false)
+		}
+	}
+}
+
 func (s *CliSuite) TestRecreateNoRecreate(c *C) {
 	p := s.ProjectFromText(c, "up", SimpleTemplate)
 
@@ -288,6 +308,53 @@ func (s *CliSuite) TestLink(c *C) {
 	}))
 }
 
+func (s *CliSuite) TestUpReadOnlyWithTmpfs(c *C) {
+	p := s.ProjectFromText(c, "up", `
+version: '2'
+services:
+  hello:
+    image: busybox
+    command: sh -c "touch /tmp/ok && top"
+    read_only: true
+    tmpfs: /tmp
+`)
+
+	name := fmt.Sprintf("%s_%s_1", p, "hello")
+	cn := s.GetContainerByName(c, name)
+	c.Assert(cn, NotNil)
+	c.Assert(cn.HostConfig.ReadonlyRootfs, Equals, true)
+	_, ok := cn.HostConfig.Tmpfs["/tmp"]
+	c.Assert(ok, Equals, true)
+
+	// The command only keeps running if /tmp was writable
+	time.Sleep(time.Second)
+	cn = s.GetContainerByName(c, name)
+	c.Assert(cn.State.Running, Equals, true)
+}
+
+func (s *CliSuite) TestUpWithDevicesAndCapabilities(c *C) {
+	p := s.ProjectFromText(c, "up", `
+version: '2'
+services:
+  hello:
+    image: busybox
+    command: top
+    cap_add:
+      - SYS_RAWIO
+    devices:
+      - /dev/null:/dev/xnull:rw
+`)
+
+	name := fmt.Sprintf("%s_%s_1", p, "hello")
+	cn := s.GetContainerByName(c, name)
+	c.Assert(cn, NotNil)
+	c.Assert([]string(cn.HostConfig.CapAdd), DeepEquals, []string{"SYS_RAWIO"})
+	c.Assert(cn.HostConfig.Devices, HasLen, 1)
+	c.Assert(cn.HostConfig.Devices[0].PathOnHost, Equals, "/dev/null")
+	c.Assert(cn.HostConfig.Devices[0].PathInContainer, Equals, "/dev/xnull")
+	c.Assert(cn.HostConfig.Privileged, Equals, false)
+}
+
 func (s *CliSuite) TestUpNoBuildFailIfImageNotPresent(c *C) {
 	p := s.RandomProject()
 	cmd := exec.Command(s.command, "-f", "./assets/build/docker-compose.yml", "-p", p, "up", "--no-build")
@@ -3,6 +3,7 @@ package labels
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/zengchen221/libcompose/utils"
 )
@@ -20,6 +21,18 @@ const (
 	VERSION = Label("com.docker.compose.version")
 )
 
+// Prefix is the prefix of the labels set by libcompose, which are reserved.
+const Prefix = "com.docker.compose."
+
+// IsReserved returns whether the specified label is reserved to libcompose.
+func IsReserved(label string) bool {
+	return strings.HasPrefix(label, Prefix)
+}
+
+// AnnotationPrefix is the prefix of the container labels holding the service
+// annotations, as the docker API does not support OCI annotations yet.
+const AnnotationPrefix = "com.docker.compose.annotation."
+
 // EqString returns a label json string representation with the specified value.
 func (f Label) EqString(value string) string {
 	return LabelFilterString(string(f), value)
@@ -48,3 +48,16 @@ func TestLabelFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestIsReserved(t *testing.T) {
+	for _, label := range []string{PROJECT.Str(), SERVICE.Str(), AnnotationPrefix + "foo"} {
+		if !IsReserved(label) {
+			t.Fatalf("expected %s to be reserved", label)
+		}
+	}
+	for _, label := range []string{"com.example.foo", "com.docker.composer"} {
+		if IsReserved(label) {
+			t.Fatalf("expected %s not to be reserved", label)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"io"
+	"io/ioutil"
+)
+
+// WriterFactory is a logger.Factory implementation writing the output of
+// containers, builds and pulls, with no formatting, to the writer it returns
+// for their name. A nil writer discards the output.
+type WriterFactory func(name string) io.Writer
+
+// CreateContainerLogger allows WriterFactory to implement logger.Factory.
+func (f WriterFactory) CreateContainerLogger(name string) Logger {
+	return f.create(name)
+}
+
+// CreateBuildLogger allows WriterFactory to implement logger.Factory.
+func (f WriterFactory) CreateBuildLogger(name string) Logger {
+	return f.create(name)
+}
+
+// CreatePullLogger allows WriterFactory to implement logger.Factory.
+func (f WriterFactory) CreatePullLogger(name string) Logger {
+	return f.create(name)
+}
+
+func (f WriterFactory) create(name string) Logger {
+	w := f(name)
+	if w == nil {
+		w = ioutil.Discard
+	}
+	return &WriterLogger{Writer: w}
+}
+
+// WriterLogger is a logger.Logger implementation writing both the standard
+// output and error to Writer.
+type WriterLogger struct {
+	Writer io.Writer
+}
+
+// Out writes the message to the writer.
+func (w *WriterLogger) Out(message []byte) {
+	w.Writer.Write(message)
+}
+
+// Err writes the message to the writer.
+func (w *WriterLogger) Err(message []byte) {
+	w.Writer.Write(message)
+}
+
+// OutWriter returns the base writer
+func (w *WriterLogger) OutWriter() io.Writer {
+	return w.Writer
+}
+
+// ErrWriter returns the base writer
+func (w *WriterLogger) ErrWriter() io.Writer {
+	return w.Writer
+}
@@ -23,3 +23,33 @@ func (l *ComposableEnvLookup) Lookup(key string, config *config.ServiceConfig) [
 	}
 	return result
 }
+
+// CompositeEnvironmentLookup is a structure that implements the
+// project.EnvironmentLookup interface. Unlike ComposableEnvLookup, the first of
+// its lookups that defines a variable wins, so that sources (e.g. the .env
+// file, the OS environment, a secrets store) can be layered by precedence.
+type CompositeEnvironmentLookup struct {
+	lookups []config.EnvironmentLookup
+}
+
+// NewCompositeEnvironmentLookup creates a CompositeEnvironmentLookup that
+// calls the specified lookups in order.
+func NewCompositeEnvironmentLookup(lookups ...config.EnvironmentLookup) *CompositeEnvironmentLookup {
+	return &CompositeEnvironmentLookup{
+		lookups: lookups,
+	}
+}
+
+// Lookup returns the result of the first lookup that defines the specified
+// variable, or an empty slice if none does.
+func (l *CompositeEnvironmentLookup) Lookup(key string, config *config.ServiceConfig) []string {
+	for _, lookup := range l.lookups {
+		if lookup == nil {
+			continue
+		}
+		if env := lookup.Lookup(key, config); len(env) > 0 {
+			return env
+		}
+	}
+	return []string{}
+}
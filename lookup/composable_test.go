@@ -45,3 +45,18 @@ func TestComposableLookupReturnsTheLastValue(t *testing.T) {
 	}
 	validateLookup(t, "value=1", envLookup.Lookup("value", nil))
 }
+
+func TestCompositeLookupReturnsTheFirstValue(t *testing.T) {
+	envLookup := NewCompositeEnvironmentLookup()
+	if actuals := envLookup.Lookup("value", nil); len(actuals) != 0 {
+		t.Fatalf("expected an empty slice, got %v", actuals)
+	}
+
+	envLookup = NewCompositeEnvironmentLookup(
+		&simpleEnvLookup{value: []string{}},
+		nil,
+		&simpleEnvLookup{value: []string{"value=1"}},
+		&simpleEnvLookup{value: []string{"value=2"}},
+	)
+	validateLookup(t, "value=1", envLookup.Lookup("value", nil))
+}
@@ -0,0 +1,53 @@
+package lookup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zengchen221/libcompose/config"
+)
+
+// DotEnvLookup is a structure that implements the project.EnvironmentLookup interface.
+// It looks up variables in the OS environment first, then in the .env file of the
+// project directory, as compose does for interpolation. The .env file is read once,
+// a missing one being considered empty.
+type DotEnvLookup struct {
+	// Dir is the project directory, the working directory if empty.
+	Dir string
+
+	once sync.Once
+	vars map[string]string
+}
+
+// Lookup creates a string slice of string containing a "docker-friendly" environment string
+// in the form of 'key=value'. Variables set in the OS environment (even to an empty
+// value) take precedence over the ones of the .env file.
+func (d *DotEnvLookup) Lookup(key string, config *config.ServiceConfig) []string {
+	if value, ok := os.LookupEnv(key); ok {
+		return []string{fmt.Sprintf("%s=%s", key, value)}
+	}
+
+	d.once.Do(d.load)
+	if value, ok := d.vars[key]; ok {
+		return []string{fmt.Sprintf("%s=%s", key, value)}
+	}
+	return []string{}
+}
+
+func (d *DotEnvLookup) load() {
+	file := filepath.Join(d.Dir, ".env")
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logrus.Warnf("Failed to read %s: %v", file, err)
+		}
+		return
+	}
+	if d.vars, err = config.ParseEnvFile(content); err != nil {
+		logrus.Warnf("Failed to parse %s: %v", file, err)
+	}
+}
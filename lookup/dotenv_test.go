@@ -0,0 +1,52 @@
+package lookup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDotEnvLookup(t *testing.T) {
+	content := `# project settings
+export TAG=1.0
+IMAGE="busybox:latest"
+SHADOWED=from-file
+`
+	tmpFolder, err := ioutil.TempDir("", "test-dotenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpFolder)
+	if err := ioutil.WriteFile(filepath.Join(tmpFolder, ".env"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SHADOWED", "from-env")
+	defer os.Unsetenv("SHADOWED")
+
+	dotEnvLookup := &DotEnvLookup{
+		Dir: tmpFolder,
+	}
+
+	validateLookup(t, "TAG=1.0", dotEnvLookup.Lookup("TAG", nil))
+	validateLookup(t, "IMAGE=busybox:latest", dotEnvLookup.Lookup("IMAGE", nil))
+	validateLookup(t, "SHADOWED=from-env", dotEnvLookup.Lookup("SHADOWED", nil))
+
+	envs := dotEnvLookup.Lookup("DOES_NOT_EXIST", nil)
+	if len(envs) != 0 {
+		t.Fatalf("Expected envs to be empty, but was %v", envs)
+	}
+}
+
+func TestDotEnvLookupWithoutFile(t *testing.T) {
+	dotEnvLookup := &DotEnvLookup{
+		Dir: "anything",
+	}
+
+	envs := dotEnvLookup.Lookup("any", nil)
+	if len(envs) != 0 {
+		t.Fatalf("Expected envs to be empty, but was %v", envs)
+	}
+	validateLookup(t, "PATH="+os.Getenv("PATH"), dotEnvLookup.Lookup("PATH", nil))
+}
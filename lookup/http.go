@@ -0,0 +1,128 @@
+package lookup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/zengchen221/libcompose/config"
+	"golang.org/x/net/context"
+)
+
+// HTTPResourceLookup is a project.ResourceLookup implementation that is able to
+// fetch files over http(s). Any other file is delegated to the Fallback lookup
+// (a FileResourceLookup if not specified). Fetched files are cached, so
+// that a remote file referenced several times is only downloaded once.
+type HTTPResourceLookup struct {
+	// Context is used for each request, cancelling it aborts pending downloads.
+	Context context.Context
+	// Client is the http client used to fetch remote files, http.DefaultClient
+	// if nil.
+	Client *http.Client
+	// Fallback is used to lookup files that are not remote.
+	Fallback config.ResourceLookup
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+// isRemote returns whether the given file is an http(s) url.
+func isRemote(file string) bool {
+	return strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://")
+}
+
+// remotePath resolves file against relativeTo if the latter is a remote url.
+// It returns an empty string if the resulting file is not remote.
+func remotePath(file, relativeTo string) string {
+	if isRemote(file) {
+		return file
+	}
+	if !isRemote(relativeTo) {
+		return ""
+	}
+	base, err := url.Parse(relativeTo)
+	if err != nil {
+		return ""
+	}
+	ref, err := url.Parse(file)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func (h *HTTPResourceLookup) fallback() config.ResourceLookup {
+	if h.Fallback == nil {
+		return &FileResourceLookup{}
+	}
+	return h.Fallback
+}
+
+// Lookup returns the content and the actual url of the specified file if it is
+// a remote one (or if it is relative to a remote one), otherwise the lookup is
+// delegated to the Fallback lookup. A remote file that is not found is
+// reported with an error satisfying os.IsNotExist, as for local files.
+func (h *HTTPResourceLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
+	u := remotePath(file, relativeTo)
+	if u == "" {
+		return h.fallback().Lookup(file, relativeTo)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if bytes, ok := h.cache[u]; ok {
+		return bytes, u, nil
+	}
+
+	bytes, err := h.fetch(u)
+	if err != nil {
+		return nil, u, err
+	}
+	if h.cache == nil {
+		h.cache = map[string][]byte{}
+	}
+	h.cache[u] = bytes
+	return bytes, u, nil
+}
+
+func (h *HTTPResourceLookup) fetch(u string) ([]byte, error) {
+	logrus.Debugf("Fetching remote file %s", u)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.Context != nil {
+		req = req.WithContext(h.Context)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return nil, &os.PathError{Op: "fetch", Path: u, Err: os.ErrNotExist}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch %s: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ResolvePath returns the path to be used for the given path volume. Remote
+// compose files can't reference host paths relative to them, so the path is
+// returned as is in that case.
+func (h *HTTPResourceLookup) ResolvePath(path, relativeTo string) string {
+	if isRemote(relativeTo) {
+		return path
+	}
+	return h.fallback().ResolvePath(path, relativeTo)
+}
@@ -0,0 +1,118 @@
+package lookup
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/zengchen221/libcompose/config"
+	"golang.org/x/net/context"
+)
+
+func TestHTTPLookupRemote(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/base/common.yml":
+			fmt.Fprint(w, "common")
+		case "/base/other.yml":
+			fmt.Fprint(w, "other")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	lookup := &HTTPResourceLookup{}
+
+	bytes, resolved, err := lookup.Lookup(server.URL+"/base/common.yml", "/some/docker-compose.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != "common" || resolved != server.URL+"/base/common.yml" {
+		t.Fatalf("Unexpected lookup result %q, %q", bytes, resolved)
+	}
+
+	// Relative to a remote file
+	bytes, resolved, err = lookup.Lookup("other.yml", server.URL+"/base/common.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != "other" || resolved != server.URL+"/base/other.yml" {
+		t.Fatalf("Unexpected lookup result %q, %q", bytes, resolved)
+	}
+
+	// Cached
+	if _, _, err = lookup.Lookup(server.URL+"/base/common.yml", ""); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 2 {
+		t.Fatalf("Expected 2 requests, got %d", hits)
+	}
+
+	if _, _, err = lookup.Lookup(server.URL+"/missing.yml", ""); !os.IsNotExist(err) {
+		t.Fatalf("Expected a not exist error for a missing remote file, got %v", err)
+	}
+}
+
+func TestHTTPLookupCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	lookup := &HTTPResourceLookup{Context: ctx}
+	if _, _, err := lookup.Lookup(server.URL+"/docker-compose.yml", ""); err == nil {
+		t.Fatal("Expected an error with a cancelled context")
+	}
+}
+
+func TestHTTPLookupFallback(t *testing.T) {
+	lookup := &HTTPResourceLookup{}
+	_, _, err := lookup.Lookup("file", "/does/not/exists/")
+	if err == nil || err.Error() != "open /does/not/exists/file: no such file or directory" {
+		t.Fatalf("Expected a file lookup error, got %v", err)
+	}
+
+	if path := lookup.ResolvePath("./data:/data", "http://example.com/docker-compose.yml"); path != "./data:/data" {
+		t.Fatalf("Expected path to be left untouched, got %s", path)
+	}
+	if path := lookup.ResolvePath("./data:/data", "/tmp/docker-compose.yml"); path != "/tmp/data:/data" {
+		t.Fatalf("Expected path to be resolved, got %s", path)
+	}
+}
+
+func TestHTTPLookupOptionalEnvFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/base/common.env":
+			fmt.Fprint(w, "COMMON=remote\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, configs, _, _, err := config.Merge(config.NewServiceConfigs(), nil, &HTTPResourceLookup{}, server.URL+"/base/docker-compose.yml", []byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    env_file:
+      - common.env
+      - path: local.env
+        required: false
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := configs["web"].Environment; len(env) != 1 || env[0] != "COMMON=remote" {
+		t.Fatalf("Expected the missing optional env file to be skipped, got %v", env)
+	}
+}
@@ -0,0 +1,49 @@
+package lookup
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// MemoryResourceLookup is a project.ResourceLookup implementation that reads
+// files from memory instead of the disk, e.g. for compose files embedded in a
+// binary or for tests. Files are keyed by slash separated paths, relative
+// paths being resolved against the directory of the file referencing them,
+// like FileResourceLookup does.
+type MemoryResourceLookup struct {
+	// Files holds the content of the files by path.
+	Files map[string][]byte
+}
+
+// memoryPath resolves file against the directory of relativeTo. Stdin ("-")
+// is considered to be in the current (".") directory.
+func memoryPath(file, relativeTo string) string {
+	if path.IsAbs(file) || relativeTo == "-" {
+		return path.Clean(file)
+	}
+	return path.Join(path.Dir(relativeTo), file)
+}
+
+// Lookup returns the content and the resolved path of the specified file. A
+// missing file is reported with an error satisfying os.IsNotExist.
+func (m *MemoryResourceLookup) Lookup(file, relativeTo string) ([]byte, string, error) {
+	file = memoryPath(file, relativeTo)
+	for name, bytes := range m.Files {
+		if path.Clean(name) == file {
+			return bytes, file, nil
+		}
+	}
+	return nil, file, &os.PathError{Op: "open", Path: file, Err: os.ErrNotExist}
+}
+
+// ResolvePath returns the path to be used for the given path volume, the
+// host path being resolved as Lookup does.
+func (m *MemoryResourceLookup) ResolvePath(volume, relativeTo string) string {
+	vs := strings.SplitN(volume, ":", 2)
+	if len(vs) != 2 || path.IsAbs(vs[0]) {
+		return volume
+	}
+	vs[0] = memoryPath(vs[0], relativeTo)
+	return strings.Join(vs, ":")
+}
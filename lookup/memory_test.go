@@ -0,0 +1,154 @@
+package lookup
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/zengchen221/libcompose/config"
+)
+
+func TestMemoryLookup(t *testing.T) {
+	lookup := &MemoryResourceLookup{
+		Files: map[string][]byte{
+			"/app/common.yml":  []byte("common"),
+			"/shared/.env":     []byte("env"),
+			"./local/file.yml": []byte("local"),
+		},
+	}
+
+	valids := map[input]string{
+		input{"common.yml", "/app/docker-compose.yml"}:     "/app/common.yml",
+		input{"../shared/.env", "/app/docker-compose.yml"}: "/shared/.env",
+		input{"/app/common.yml", "/other/compose.yml"}:     "/app/common.yml",
+		input{"local/file.yml", "docker-compose.yml"}:      "local/file.yml",
+		input{"local/file.yml", "-"}:                       "local/file.yml",
+	}
+	for valid, expectedPath := range valids {
+		_, resolved, err := lookup.Lookup(valid.file, valid.relativeTo)
+		if err != nil || resolved != expectedPath {
+			t.Fatalf("Expected %s relative to %s to resolve to %s, got %s, %v", valid.file, valid.relativeTo, expectedPath, resolved, err)
+		}
+	}
+
+	_, _, err := lookup.Lookup("common.yml", "/other/docker-compose.yml")
+	if err == nil || !os.IsNotExist(err) {
+		t.Fatalf("Expected a not exist error, got %v", err)
+	}
+
+	if resolved := lookup.ResolvePath("./data:/data", "/app/docker-compose.yml"); resolved != "/app/data:/data" {
+		t.Fatalf("Expected /app/data:/data, got %s", resolved)
+	}
+}
+
+func TestMemoryLookupMerge(t *testing.T) {
+	lookup := &MemoryResourceLookup{
+		Files: map[string][]byte{
+			"/project/base.yml": []byte(`
+version: '2'
+services:
+  base:
+    image: busybox
+    env_file:
+      - path: ../env/base.env
+      - path: missing.env
+        required: false
+`),
+			"/env/base.env": []byte("LEVEL=debug\n"),
+		},
+	}
+
+	_, configs, _, _, err := config.Merge(config.NewServiceConfigs(), nil, lookup, "/project/docker-compose.yml", []byte(`
+version: '2'
+services:
+  web:
+    extends:
+      file: base.yml
+      service: base
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if configs["web"].Image != "busybox" {
+		t.Fatalf("Expected the image of the extended service, got %q", configs["web"].Image)
+	}
+	if len(configs["web"].Environment) != 1 || configs["web"].Environment[0] != "LEVEL=debug" {
+		t.Fatalf("Expected the environment of the env_file, got %v", configs["web"].Environment)
+	}
+}
+
+func TestMemoryLookupMergeRelativePaths(t *testing.T) {
+	lookup := &MemoryResourceLookup{}
+
+	existingServices := config.NewServiceConfigs()
+	_, configs, _, _, err := config.Merge(existingServices, nil, lookup, "/project/docker-compose.yml", []byte(`
+version: '2'
+services:
+  web:
+    build: ./app
+    volumes:
+      - ./data:/data
+      - cache:/cache
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, serviceConfig := range configs {
+		existingServices.Add(name, serviceConfig)
+	}
+
+	_, configs, _, _, err = config.Merge(existingServices, nil, lookup, "/overrides/docker-compose.override.yml", []byte(`
+version: '2'
+services:
+  web:
+    volumes:
+      - ./logs:/logs
+      - type: bind
+        source: ./conf
+        target: /conf
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := configs["web"]
+	if web.Build.Context != "/project/app" {
+		t.Fatalf("Expected the build context relative to the first file, got %q", web.Build.Context)
+	}
+	expected := map[string]string{
+		"/data":  "/project/data",
+		"/cache": "cache",
+		"/logs":  "/overrides/logs",
+		"/conf":  "/overrides/conf",
+	}
+	if len(web.Volumes.Volumes) != len(expected) {
+		t.Fatalf("Expected %d volumes, got %v", len(expected), web.Volumes)
+	}
+	for _, volume := range web.Volumes.Volumes {
+		if expected[volume.Destination] != volume.Source {
+			t.Fatalf("Expected %s to be mounted from %s, got %s", volume.Destination, expected[volume.Destination], volume.Source)
+		}
+	}
+}
+
+func TestMemoryLookupMergeSeccompProfile(t *testing.T) {
+	_, configs, _, _, err := config.Merge(config.NewServiceConfigs(), nil, &MemoryResourceLookup{}, "/project/docker-compose.yml", []byte(`
+version: '2'
+services:
+  web:
+    image: busybox
+    security_opt:
+      - seccomp:profiles/seccomp.json
+      - seccomp=unconfined
+      - apparmor=myprofile
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"seccomp=/project/profiles/seccomp.json", "seccomp=unconfined", "apparmor=myprofile"}
+	if !reflect.DeepEqual(configs["web"].SecurityOpt, expected) {
+		t.Fatalf("Expected %v, got %v", expected, configs["web"].SecurityOpt)
+	}
+}
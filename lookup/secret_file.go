@@ -0,0 +1,47 @@
+package lookup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/zengchen221/libcompose/config"
+)
+
+// DefaultSecretsDir is the directory where docker mounts the secrets of a
+// container, used by SecretFileLookup if no directory is specified.
+const DefaultSecretsDir = "/run/secrets"
+
+// SecretFileLookup is a structure that implements the project.EnvironmentLookup interface.
+// It reads the value of a variable from the file named after it in a directory,
+// e.g. ${DB_PASSWORD} from /run/secrets/DB_PASSWORD or /run/secrets/db_password,
+// so that secrets stay out of the compose file and of the process environment.
+// It can be layered with other lookups using CompositeEnvironmentLookup.
+type SecretFileLookup struct {
+	// Dir is the directory holding the secret files, DefaultSecretsDir if
+	// empty.
+	Dir string
+}
+
+// Lookup creates a string slice of string containing a "docker-friendly" environment string
+// in the form of 'key=value'. The value is the content of the secret file, without its
+// trailing newline. If there is no such file, the slice is empty.
+func (l *SecretFileLookup) Lookup(key string, config *config.ServiceConfig) []string {
+	if key == "" || strings.ContainsAny(key, `/\`) || key == "." || key == ".." {
+		return []string{}
+	}
+	dir := l.Dir
+	if dir == "" {
+		dir = DefaultSecretsDir
+	}
+	for _, name := range []string{key, strings.ToLower(key)} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r")
+		return []string{fmt.Sprintf("%s=%s", key, value)}
+	}
+	return []string{}
+}
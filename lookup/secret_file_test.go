@@ -0,0 +1,40 @@
+package lookup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecretFileLookup(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "secret-file-lookup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	files := map[string]string{
+		"db_password": "s3cr3t\n",
+		"API_TOKEN":   "token\r\n",
+		"multiline":   "line1\nline2\n\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	envLookup := &SecretFileLookup{Dir: tmpDir}
+	validateLookup(t, "DB_PASSWORD=s3cr3t", envLookup.Lookup("DB_PASSWORD", nil))
+	validateLookup(t, "API_TOKEN=token", envLookup.Lookup("API_TOKEN", nil))
+	validateLookup(t, "multiline=line1\nline2\n", envLookup.Lookup("multiline", nil))
+	for _, key := range []string{"MISSING", "", "..", "../db_password"} {
+		if actuals := envLookup.Lookup(key, nil); len(actuals) != 0 {
+			t.Fatalf("expected an empty slice for %q, got %v", key, actuals)
+		}
+	}
+
+	composite := NewCompositeEnvironmentLookup(&simpleEnvLookup{value: []string{}}, envLookup)
+	validateLookup(t, "DB_PASSWORD=s3cr3t", composite.Lookup("DB_PASSWORD", nil))
+}
@@ -1,7 +1,11 @@
 package project
 
 import (
+	"io"
+
 	"golang.org/x/net/context"
+
+	"github.com/zengchen221/libcompose/project/options"
 )
 
 // Container defines what a libcompose container provides.
@@ -10,4 +14,48 @@ type Container interface {
 	Name() string
 	Port(ctx context.Context, port string) (string, error)
 	IsRunning(ctx context.Context) bool
+	Number() (int, error)
+	LogStream(ctx context.Context, options options.Log) (io.ReadCloser, error)
+	ExecCommand(ctx context.Context, options options.Exec) (int, error)
+	Top(ctx context.Context) ([]ContainerProcess, error)
+	Inspect(ctx context.Context) (ContainerInfo, error)
+}
+
+// ContainerInfo holds the state of a container of a project, as listed by
+// Project.List.
+type ContainerInfo struct {
+	ID      string
+	Name    string
+	Service string
+	Image   string
+	Command string
+	// State is the state of the container (e.g. running or exited).
+	State string
+	// Health is the health status of the container (starting, healthy or
+	// unhealthy), empty if it has no healthcheck.
+	Health string
+	// Ports holds the exposed ports of the container, published or not.
+	Ports []ContainerPort
+}
+
+// ContainerPort holds an exposed port of a container and, if published, the
+// host address it is bound to.
+type ContainerPort struct {
+	// ContainerPort is the port in the container (e.g. 80).
+	ContainerPort int
+	// Protocol is tcp, udp or sctp.
+	Protocol string
+	// HostIP is the host address the port is published on, empty if not
+	// published.
+	HostIP string
+	// HostPort is the host port the port is published on, 0 if not
+	// published.
+	HostPort int
+}
+
+// ContainerProcess holds a process running in a container, as reported by
+// docker top: the column titles and the matching values.
+type ContainerProcess struct {
+	Titles []string
+	Values []string
 }
@@ -2,12 +2,14 @@ package project
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/zengchen221/libcompose/config"
 	"github.com/zengchen221/libcompose/logger"
@@ -16,6 +18,26 @@ import (
 
 var projectRegexp = regexp.MustCompile("[^a-zA-Z0-9_.-]")
 
+// DefaultComposeFiles holds the compose file names looked up, in order, in the
+// working directory when no compose file is specified.
+var DefaultComposeFiles = []string{
+	"docker-compose.yml",
+	"docker-compose.yaml",
+	"compose.yml",
+	"compose.yaml",
+}
+
+// NoComposeFilesError is returned when no compose file was specified and none
+// of the default ones could be found.
+type NoComposeFilesError struct {
+	// Searched holds the locations that were looked up.
+	Searched []string
+}
+
+func (e *NoComposeFilesError) Error() string {
+	return fmt.Sprintf("No compose files found, searched: %s", strings.Join(e.Searched, ", "))
+}
+
 // Context holds context meta information about a libcompose project, like
 // the project name, the compose file, etc.
 type Context struct {
@@ -30,7 +52,107 @@ type Context struct {
 	ResourceLookup      config.ResourceLookup
 	LoggerFactory       logger.Factory
 	IgnoreMissingConfig bool
-	Project             *Project
+	// EnvironmentLookups holds additional lookups (e.g. a secrets store)
+	// consulted in order after the default one (the OS environment, then the
+	// .env file) when EnvironmentLookup is not set.
+	EnvironmentLookups []config.EnvironmentLookup
+	// DisableOverrideFile prevents the override file of the default compose
+	// file (e.g. docker-compose.override.yml) from being merged on top of it
+	// when no compose file is specified.
+	DisableOverrideFile bool
+	// DisableNetworks prevents any network from being created or attached,
+	// containers are left on the daemon default network (or the one
+	// specified by their network_mode).
+	DisableNetworks bool
+	// Init is the default value of the init option of the services that
+	// don't specify it.
+	Init bool
+	// Profiles holds the active profiles: services with profiles are only
+	// brought up if one of them is active, unless explicitly requested. The
+	// profiles of the COMPOSE_PROFILES environment variable are active too.
+	Profiles []string
+	// CustomLabels holds labels added to every container, volume and
+	// network created for the project. They never override the labels set
+	// by libcompose nor the ones of the configuration.
+	CustomLabels map[string]string
+	// LogWriter, if set, returns the writer the output of the builds, pulls
+	// and containers of the specified service is written to, with no
+	// formatting, instead of using LoggerFactory.
+	LogWriter func(service string) io.Writer
+	// OperationTimeout bounds each pull, build, create and start of a
+	// service, an operation taking longer failing with an
+	// OperationTimeoutError. Up and Create leave it to the services to bound
+	// each of the operations they perform. Zero means no timeout.
+	OperationTimeout time.Duration
+	// ServiceOperationTimeouts overrides OperationTimeout for the specified
+	// services, by service name. Zero means no timeout.
+	ServiceOperationTimeouts map[string]time.Duration
+	Project                  *Project
+}
+
+// findComposeFiles looks up the first of the default compose files in the
+// working directory, along with its override file if there is one (unless
+// withOverride is false).
+func findComposeFiles(withOverride bool) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	searched := []string{}
+	for _, name := range DefaultComposeFiles {
+		file := filepath.Join(wd, name)
+		searched = append(searched, file)
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		files := []string{name}
+		if !withOverride {
+			return files, nil
+		}
+		ext := filepath.Ext(name)
+		override := strings.TrimSuffix(name, ext) + ".override" + ext
+		if _, err := os.Stat(filepath.Join(wd, override)); err == nil {
+			files = append(files, override)
+		}
+		return files, nil
+	}
+	return nil, &NoComposeFilesError{Searched: searched}
+}
+
+// composeFilesFromEnv returns the compose files listed in the COMPOSE_FILE
+// environment variable, separated by COMPOSE_PATH_SEPARATOR if set or by the
+// OS path list separator otherwise.
+func composeFilesFromEnv() []string {
+	value := os.Getenv("COMPOSE_FILE")
+	if value == "" {
+		return nil
+	}
+
+	separator := os.Getenv("COMPOSE_PATH_SEPARATOR")
+	if separator == "" {
+		separator = string(os.PathListSeparator)
+	}
+
+	files := []string{}
+	for _, file := range strings.Split(value, separator) {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// profilesFromEnv returns the profiles listed in the COMPOSE_PROFILES
+// environment variable, separated by commas.
+func profilesFromEnv() []string {
+	profiles := []string{}
+	for _, profile := range strings.Split(os.Getenv("COMPOSE_PROFILES"), ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
 }
 
 func (c *Context) readComposeFiles() error {
@@ -38,20 +160,42 @@ func (c *Context) readComposeFiles() error {
 		return nil
 	}
 
-	logrus.Debugf("Opening compose files: %s", strings.Join(c.ComposeFiles, ","))
-
-	// Handle STDIN (`-f -`)
-	if len(c.ComposeFiles) == 1 && c.ComposeFiles[0] == "-" {
-		composeBytes, err := ioutil.ReadAll(os.Stdin)
+	if len(c.ComposeFiles) == 0 {
+		files, err := findComposeFiles(!c.DisableOverrideFile)
 		if err != nil {
-			logrus.Errorf("Failed to read compose file from stdin: %v", err)
+			if _, ok := err.(*NoComposeFilesError); ok && c.IgnoreMissingConfig {
+				return nil
+			}
 			return err
 		}
-		c.ComposeBytes = [][]byte{composeBytes}
-		return nil
+		c.ComposeFiles = files
+	}
+
+	logrus.Debugf("Opening compose files: %s", strings.Join(c.ComposeFiles, ","))
+
+	stdin := 0
+	for _, composeFile := range c.ComposeFiles {
+		if composeFile == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return fmt.Errorf("The compose file can only be read once from stdin (-)")
 	}
 
 	for _, composeFile := range c.ComposeFiles {
+		// Handle STDIN (`-f -`), relative paths are then resolved from the
+		// working directory
+		if composeFile == "-" {
+			composeBytes, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				logrus.Errorf("Failed to read compose file from stdin: %v", err)
+				return err
+			}
+			c.ComposeBytes = append(c.ComposeBytes, composeBytes)
+			continue
+		}
+
 		composeBytes, err := ioutil.ReadFile(composeFile)
 		if err != nil && !os.IsNotExist(err) {
 			logrus.Errorf("Failed to open the compose file: %s", composeFile)
@@ -41,6 +41,11 @@ func (e *EmptyService) Stop(ctx context.Context, timeout int) error {
 	return nil
 }
 
+// Sync implements Service.Sync but does nothing.
+func (e *EmptyService) Sync(ctx context.Context, files []FileSync) error {
+	return nil
+}
+
 // Delete implements Service.Delete but does nothing.
 func (e *EmptyService) Delete(ctx context.Context, options options.Delete) error {
 	return nil
@@ -52,7 +57,7 @@ func (e *EmptyService) Restart(ctx context.Context, timeout int) error {
 }
 
 // Log implements Service.Log but does nothing.
-func (e *EmptyService) Log(ctx context.Context, follow bool) error {
+func (e *EmptyService) Log(ctx context.Context, options options.Log) error {
 	return nil
 }
 
@@ -91,6 +96,11 @@ func (e *EmptyService) Unpause(ctx context.Context) error {
 	return nil
 }
 
+// Plan implements Service.Plan but plans nothing.
+func (e *EmptyService) Plan(ctx context.Context, options options.Up) ([]Action, error) {
+	return nil, nil
+}
+
 // Run implements Service.Run but does nothing.
 func (e *EmptyService) Run(ctx context.Context, commandParts []string, options options.Run) (int, error) {
 	return 0, nil
@@ -106,6 +116,16 @@ func (e *EmptyService) Events(ctx context.Context, events chan events.ContainerE
 	return nil
 }
 
+// WaitHealthy implements Service.WaitHealthy but does nothing.
+func (e *EmptyService) WaitHealthy(ctx context.Context) error {
+	return nil
+}
+
+// WaitFor implements Service.WaitFor but does nothing.
+func (e *EmptyService) WaitFor(ctx context.Context, condition string) (map[string]int, error) {
+	return nil, nil
+}
+
 // DependentServices implements Service.DependentServices with empty slice.
 func (e *EmptyService) DependentServices() []ServiceRelationship {
 	return []ServiceRelationship{}
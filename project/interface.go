@@ -1,6 +1,8 @@
 package project
 
 import (
+	"io"
+
 	"golang.org/x/net/context"
 
 	"github.com/zengchen221/libcompose/config"
@@ -19,20 +21,29 @@ type APIProject interface {
 	Delete(ctx context.Context, options options.Delete, services ...string) error
 	Down(ctx context.Context, options options.Down, services ...string) error
 	Events(ctx context.Context, services ...string) (chan events.ContainerEvent, error)
+	Exec(ctx context.Context, service string, options options.Exec) error
 	Kill(ctx context.Context, signal string, services ...string) error
-	Log(ctx context.Context, follow bool, services ...string) error
+	List(ctx context.Context, services ...string) ([]ContainerInfo, error)
+	Log(ctx context.Context, options options.Log, services ...string) error
+	LogService(ctx context.Context, service string, index int, options options.Log) (io.ReadCloser, error)
 	Pause(ctx context.Context, services ...string) error
+	Plan(ctx context.Context, options options.Up, services ...string) ([]Action, error)
 	Ps(ctx context.Context, services ...string) (InfoSet, error)
 	// FIXME(vdemeester) we could use nat.Port instead ?
 	Port(ctx context.Context, index int, protocol, serviceName, privatePort string) (string, error)
-	Pull(ctx context.Context, services ...string) error
+	Pull(ctx context.Context, options options.Pull, services ...string) error
+	Remove(ctx context.Context, options options.Delete, services ...string) error
+	RemoveStopped(ctx context.Context, removeVolume bool, services ...string) error
 	Restart(ctx context.Context, timeout int, services ...string) error
 	Run(ctx context.Context, serviceName string, commandParts []string, options options.Run) (int, error)
 	Scale(ctx context.Context, timeout int, servicesScale map[string]int) error
 	Start(ctx context.Context, services ...string) error
 	Stop(ctx context.Context, timeout int, services ...string) error
+	Top(ctx context.Context, services ...string) (map[string][]ContainerProcess, error)
 	Unpause(ctx context.Context, services ...string) error
 	Up(ctx context.Context, options options.Up, services ...string) error
+	WaitFor(ctx context.Context, condition string, services ...string) error
+	Watch(ctx context.Context, options options.Watch, services ...string) error
 
 	Parse() error
 	CreateService(name string) (Service, error)
@@ -41,6 +52,10 @@ type APIProject interface {
 	Containers(ctx context.Context, filter Filter, services ...string) ([]string, error)
 
 	GetServiceConfig(service string) (*config.ServiceConfig, bool)
+	ServiceNames() []string
+	Services() []*config.ServiceConfig
+	ServiceImage(service string) (string, error)
+	ServiceConfigJSON(service string) ([]byte, error)
 }
 
 // Filter holds filter element to filter containers
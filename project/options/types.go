@@ -1,23 +1,51 @@
 package options
 
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
 // Build holds options of compose build.
 type Build struct {
 	NoCache     bool
 	ForceRemove bool
 	Pull        bool
+	// ContextReaders holds, by service name, tar streams to use as build
+	// context instead of the build context directory of the service.
+	ContextReaders map[string]io.Reader
 }
 
 // Delete holds options of compose rm.
 type Delete struct {
-	RemoveVolume  bool
+	// RemoveVolume removes the anonymous volumes of the containers too.
+	RemoveVolume bool
+	// RemoveRunning removes the running containers too (force), instead of
+	// only the stopped ones.
 	RemoveRunning bool
 }
 
 // Down holds options of compose down.
 type Down struct {
-	RemoveVolume  bool
-	RemoveImages  ImageType
+	// RemoveVolume removes the volumes of the project, named and anonymous.
+	RemoveVolume bool
+	// RemoveImages removes the images of the services, all of them or only
+	// the ones without a custom name (local).
+	RemoveImages ImageType
+	// RemoveOrphans removes the containers of the project whose service is
+	// not defined anymore.
 	RemoveOrphans bool
+	// KeepNetworks leaves the project networks in place, e.g. when they are
+	// shared with containers outside of the project.
+	KeepNetworks bool
+	// Profiles holds profiles to activate on top of the ones of the project
+	// context and of the COMPOSE_PROFILES environment variable, only the
+	// services they enable are torn down.
+	Profiles []string
+	// LabelSelector restricts the services to the ones whose labels match
+	// it.
+	LabelSelector LabelSelector
 }
 
 // Create holds options of compose create.
@@ -26,6 +54,62 @@ type Create struct {
 	ForceRecreate bool
 	NoBuild       bool
 	ForceBuild    bool
+	// StrictPlatform makes a mismatch between the image and the daemon
+	// platforms an error instead of a warning.
+	StrictPlatform bool
+	// PullPolicy overrides the pull policy of the services: always,
+	// missing, never or build (see the pull_policy service option).
+	PullPolicy string
+	// LabelSelector restricts the services to the ones whose labels match
+	// it.
+	LabelSelector LabelSelector
+}
+
+// Exec holds options of compose exec.
+type Exec struct {
+	// Command is the command to run in the container.
+	Command []string
+	// Tty allocates a pseudo-TTY for the command.
+	Tty bool
+	// Detach runs the command in the background, without attaching to it.
+	Detach bool
+	// User is the user to run the command as, the container one if empty.
+	User string
+	// Index is the number of the targeted container (replica index,
+	// starting at 1), the first one if not set.
+	Index int
+}
+
+// Log holds options of compose logs.
+type Log struct {
+	// Follow keeps streaming the new log lines until the context is
+	// cancelled.
+	Follow bool
+	// Stdout and Stderr select the streams to include, both of them if
+	// none is set.
+	Stdout bool
+	Stderr bool
+	// Timestamps prefixes each line with its timestamp.
+	Timestamps bool
+	// Tail is the number of lines to show from the end of the logs, all of
+	// them if empty.
+	Tail string
+	// Since only shows the logs since the specified timestamp (e.g.
+	// 2006-01-02T15:04:05) or relative duration (e.g. 42m).
+	Since string
+	// LabelSelector restricts the services to the ones whose labels match
+	// it.
+	LabelSelector LabelSelector
+}
+
+// Pull holds options of compose pull.
+type Pull struct {
+	// Parallelism is the maximum number of images pulled at the same time,
+	// the number of CPUs if not set.
+	Parallelism int
+	// IgnoreFailures keeps pulling the other images when one of them fails,
+	// instead of cancelling the pending pulls.
+	IgnoreFailures bool
 }
 
 // Run holds options of compose run.
@@ -37,6 +121,81 @@ type Run struct {
 // Up holds options of compose up.
 type Up struct {
 	Create
+	// RenewAnonymousVolumes discards the anonymous volumes of recreated
+	// containers instead of reattaching them to the new ones.
+	RenewAnonymousVolumes bool
+	// RestartPolicy overrides the restart policy of the specified services
+	// (by service name), without changing their configuration.
+	RestartPolicy map[string]string
+	// RecreateDeps controls the recreation of the dependencies of the
+	// services that are not explicitly specified: RecreateDepsChanged (the
+	// default) only recreates them if their configuration changed,
+	// RecreateDepsAlways always recreates them and RecreateDepsNever never
+	// does.
+	RecreateDeps string
+	// IgnoreBuildFailures skips the services whose image fails to build
+	// instead of aborting. Each skipped service is reported with a warning
+	// and a ServiceUpIgnored event holding the build error.
+	IgnoreBuildFailures bool
+	// Parallelism is the maximum number of services brought up at the same
+	// time, unlimited if 0. Services are brought up as soon as their
+	// dependencies are.
+	Parallelism int
+	// Profiles holds profiles to activate on top of the ones of the project
+	// context and of the COMPOSE_PROFILES environment variable.
+	Profiles []string
+	// MaxRetries is the number of times container creation and start are
+	// retried when the daemon fails with a transient error (500 or 503).
+	// Other errors, such as a missing image, are never retried.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled on each
+	// following one. It defaults to 500ms.
+	RetryBackoff time.Duration
+	// RemoveOrphans stops and removes, once the services are up, the
+	// containers of the project whose service is not defined anymore.
+	RemoveOrphans bool
+}
+
+// Values of Up.RecreateDeps.
+const (
+	RecreateDepsChanged = "changed"
+	RecreateDepsAlways  = "always"
+	RecreateDepsNever   = "never"
+)
+
+// Watch holds options of compose watch.
+type Watch struct {
+	// PollInterval is the interval between two scans of the watched paths.
+	PollInterval time.Duration
+	// Debounce is the time to wait for the changes to settle before
+	// performing the actions.
+	Debounce time.Duration
+}
+
+// LabelSelector selects services by their labels: a service matches if it
+// has all the labels of the selector, with the same values. An empty value
+// only requires the label to be set.
+type LabelSelector map[string]string
+
+// Matches returns whether the specified labels match the selector. Any
+// labels match an empty selector.
+func (l LabelSelector) Matches(labels map[string]string) bool {
+	for key, value := range l {
+		actual, ok := labels[key]
+		if !ok || (value != "" && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l LabelSelector) String() string {
+	pairs := []string{}
+	for key, value := range l {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
 }
 
 // ImageType defines the type of image (local, all)
@@ -37,3 +37,27 @@ func TestImageType(t *testing.T) {
 		}
 	}
 }
+
+func TestLabelSelector(t *testing.T) {
+	labels := map[string]string{"tier": "frontend", "team": "web"}
+	cases := []struct {
+		selector LabelSelector
+		matches  bool
+	}{
+		{selector: nil, matches: true},
+		{selector: LabelSelector{"tier": "frontend"}, matches: true},
+		{selector: LabelSelector{"tier": "frontend", "team": "web"}, matches: true},
+		{selector: LabelSelector{"team": ""}, matches: true},
+		{selector: LabelSelector{"tier": "backend"}, matches: false},
+		{selector: LabelSelector{"tier": "frontend", "env": ""}, matches: false},
+	}
+	for _, c := range cases {
+		if c.selector.Matches(labels) != c.matches {
+			t.Errorf("Expected %v to match %v: %v", c.selector, labels, c.matches)
+		}
+	}
+
+	if s := (LabelSelector{"tier": "frontend", "env": ""}).String(); s != "env=,tier=frontend" {
+		t.Errorf("Invalid selector string %s", s)
+	}
+}
@@ -0,0 +1,62 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/zengchen221/libcompose/config"
+)
+
+// ParsedProject is the runtime independent representation of a parsed
+// project. It is meant to be consumed by external tools (editors, linters…)
+// that only need the merged configuration and not a docker daemon.
+type ParsedProject struct {
+	// Name is the project name.
+	Name string
+	// Version is the compose file format version ("1", "2", …).
+	Version string
+	// Services holds the merged service configurations by service name.
+	Services map[string]*config.ServiceConfig
+	// Volumes holds the top-level volume configurations by volume name.
+	Volumes map[string]*config.VolumeConfig
+	// Networks holds the top-level network configurations by network name.
+	Networks map[string]*config.NetworkConfig
+	// Warnings holds the non fatal issues found while parsing the project,
+	// e.g. the keys that are ignored.
+	Warnings []config.ConfigWarning
+}
+
+// Parse parses the compose files of the specified context and returns the
+// resulting ParsedProject. No runtime is involved, so it doesn't require any
+// access to a docker daemon.
+func Parse(context *Context, parseOptions *config.ParseOptions) (*ParsedProject, error) {
+	p := NewProject(context, nil, parseOptions)
+	if p == nil {
+		return nil, fmt.Errorf("Failed to create project")
+	}
+	if err := p.Parse(); err != nil {
+		return nil, err
+	}
+	return p.Parsed(), nil
+}
+
+// Parsed returns the ParsedProject representation of the project.
+func (p *Project) Parsed() *ParsedProject {
+	parsed := &ParsedProject{
+		Name:     p.Name,
+		Version:  p.configVersion,
+		Services: map[string]*config.ServiceConfig{},
+		Volumes:  map[string]*config.VolumeConfig{},
+		Networks: map[string]*config.NetworkConfig{},
+		Warnings: append([]config.ConfigWarning{}, p.warnings...),
+	}
+	for _, name := range p.ServiceConfigs.Keys() {
+		parsed.Services[name], _ = p.ServiceConfigs.Get(name)
+	}
+	for name, volume := range p.VolumeConfigs {
+		parsed.Volumes[name] = volume
+	}
+	for name, network := range p.NetworkConfigs {
+		parsed.Networks[name] = network
+	}
+	return parsed
+}
@@ -0,0 +1,64 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProject(t *testing.T) {
+	parsed, err := Parse(&Context{
+		ProjectName: "foo",
+		ComposeBytes: [][]byte{
+			[]byte(`version: '2'
+services:
+  web:
+    image: nginx
+volumes:
+  data: {}
+`),
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "foo", parsed.Name)
+	assert.Equal(t, "2", parsed.Version)
+	assert.Equal(t, "nginx", parsed.Services["web"].Image)
+	assert.Contains(t, parsed.Volumes, "data")
+	assert.Contains(t, parsed.Networks, "default")
+	assert.Empty(t, parsed.Warnings)
+}
+
+func TestParseProjectWarnings(t *testing.T) {
+	parsed, err := Parse(&Context{
+		ProjectName: "foo",
+		ComposeBytes: [][]byte{
+			[]byte(`version: '3'
+services:
+  web:
+    image: nginx
+    deploy:
+      placement:
+        constraints: [node.role == manager]
+`),
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, parsed.Warnings, 1)
+	assert.Equal(t, "web", parsed.Warnings[0].Service)
+	assert.Equal(t, "deploy.placement", parsed.Warnings[0].Field)
+}
+
+func TestParseProjectWithBadContent(t *testing.T) {
+	_, err := Parse(&Context{
+		ComposeBytes: [][]byte{
+			[]byte("garbage"),
+		},
+	}, nil)
+	assert.NotNil(t, err)
+}
@@ -3,9 +3,9 @@ package project
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/context"
@@ -14,6 +14,7 @@ import (
 	"github.com/zengchen221/libcompose/logger"
 	"github.com/zengchen221/libcompose/lookup"
 	"github.com/zengchen221/libcompose/project/events"
+	"github.com/zengchen221/libcompose/project/options"
 	"github.com/zengchen221/libcompose/utils"
 	"github.com/zengchen221/libcompose/yaml"
 	log "github.com/sirupsen/logrus"
@@ -39,6 +40,7 @@ type Project struct {
 	networks      Networks
 	volumes       Volumes
 	configVersion string
+	warnings      []config.ConfigWarning
 	context       *Context
 	reload        []string
 	upCount       int
@@ -61,33 +63,28 @@ func NewProject(context *Context, runtime RuntimeProject, parseOptions *config.P
 		context.LoggerFactory = &logger.NullLogger{}
 	}
 
+	// Explicit compose files (or content) win over the COMPOSE_FILE variable,
+	// which is resolved here so that the .env file is looked up next to them
+	if len(context.ComposeFiles) == 0 && context.ComposeBytes == nil {
+		context.ComposeFiles = composeFilesFromEnv()
+	}
+
 	if context.ResourceLookup == nil {
-		context.ResourceLookup = &lookup.FileResourceLookup{}
+		context.ResourceLookup = &lookup.HTTPResourceLookup{
+			Fallback: &lookup.FileResourceLookup{},
+		}
 	}
 
 	if context.EnvironmentLookup == nil {
-		var envPath, absPath, cwd string
-		var err error
-		if len(context.ComposeFiles) > 0 {
-			absPath, err = filepath.Abs(context.ComposeFiles[0])
-			dir, _ := path.Split(absPath)
-			envPath = filepath.Join(dir, ".env")
-		} else {
-			cwd, err = os.Getwd()
-			envPath = filepath.Join(cwd, ".env")
+		dir := ""
+		if len(context.ComposeFiles) > 0 && context.ComposeFiles[0] != "-" {
+			dir = filepath.Dir(context.ComposeFiles[0])
 		}
-
-		if err != nil {
-			log.Errorf("Could not get the rooted path name to the current directory: %v", err)
-			return nil
+		context.EnvironmentLookup = &lookup.DotEnvLookup{
+			Dir: dir,
 		}
-		context.EnvironmentLookup = &lookup.ComposableEnvLookup{
-			Lookups: []config.EnvironmentLookup{
-				&lookup.EnvfileLookup{
-					Path: envPath,
-				},
-				&lookup.OsEnvLookup{},
-			},
+		if len(context.EnvironmentLookups) > 0 {
+			context.EnvironmentLookup = lookup.NewCompositeEnvironmentLookup(append([]config.EnvironmentLookup{context.EnvironmentLookup}, context.EnvironmentLookups...)...)
 		}
 	}
 
@@ -107,11 +104,15 @@ func (p *Project) Parse() error {
 	}
 
 	p.Name = p.context.ProjectName
+	p.warnings = nil
 
-	p.Files = p.context.ComposeFiles
-
-	if len(p.Files) == 1 && p.Files[0] == "-" {
-		p.Files = []string{"."}
+	// The compose file read from stdin is relative to the working directory
+	p.Files = []string{}
+	for _, file := range p.context.ComposeFiles {
+		if file == "-" {
+			file = "."
+		}
+		p.Files = append(p.Files, file)
 	}
 
 	if p.context.ComposeBytes != nil {
@@ -137,31 +138,20 @@ func (p *Project) CreateService(name string) (Service, error) {
 		return nil, fmt.Errorf("Failed to find service: %s", name)
 	}
 
-	// Copy because we are about to modify the environment
+	// Copy because we are about to modify the build args. The variables of
+	// the environment without value are already resolved when parsing.
 	config := *existing
 
 	if p.context.EnvironmentLookup != nil {
-		parsedEnv := make([]string, 0, len(config.Environment))
-
-		for _, env := range config.Environment {
-			parts := strings.SplitN(env, "=", 2)
-			if len(parts) > 1 {
-				parsedEnv = append(parsedEnv, env)
-				continue
-			} else {
-				env = parts[0]
-			}
-
-			for _, value := range p.context.EnvironmentLookup.Lookup(env, &config) {
-				parsedEnv = append(parsedEnv, value)
-			}
-		}
-
-		config.Environment = parsedEnv
-
 		// check the environment for extra build Args that are set but not given a value in the compose file
+		// (on a copy of the args, to leave the project configuration untouched)
+		buildArgs := make(map[string]*string, len(config.Build.Args))
+		for arg, value := range config.Build.Args {
+			buildArgs[arg] = value
+		}
+		config.Build.Args = buildArgs
 		for arg, value := range config.Build.Args {
-			if *value == "\x00" {
+			if value == nil || *value == "\x00" {
 				envValue := p.context.EnvironmentLookup.Lookup(arg, &config)
 				// depending on what we get back we do different things
 				switch l := len(envValue); l {
@@ -169,6 +159,10 @@ func (p *Project) CreateService(name string) (Service, error) {
 					delete(config.Build.Args, arg)
 				case 1:
 					parts := strings.SplitN(envValue[0], "=", 2)
+					if len(parts) < 2 {
+						delete(config.Build.Args, arg)
+						continue
+					}
 					config.Build.Args[parts[0]] = &parts[1]
 				default:
 					return nil, fmt.Errorf("tried to set Build Arg %#v to multi-value %#v", arg, envValue)
@@ -204,6 +198,26 @@ func (p *Project) AddNetworkConfig(name string, config *config.NetworkConfig) er
 	return nil
 }
 
+// parseOptions returns the parse options of the project, with a Warn
+// function that records the warnings for Parsed before passing them on to
+// the original one (or logging them).
+func (p *Project) parseOptions() *config.ParseOptions {
+	options := config.DefaultParseOptions()
+	if p.ParseOptions != nil {
+		options = *p.ParseOptions
+	}
+	warn := options.Warn
+	options.Warn = func(warning config.ConfigWarning) {
+		p.warnings = append(p.warnings, warning)
+		if warn != nil {
+			warn(warning)
+		} else {
+			log.Warn(warning.Message)
+		}
+	}
+	return &options
+}
+
 // Load loads the specified byte array (the composefile content) and adds the
 // service configuration to the project.
 // FIXME is it needed ?
@@ -212,7 +226,7 @@ func (p *Project) Load(bytes []byte) error {
 }
 
 func (p *Project) load(file string, bytes []byte) error {
-	version, serviceConfigs, volumeConfigs, networkConfigs, err := config.Merge(p.ServiceConfigs, p.context.EnvironmentLookup, p.context.ResourceLookup, file, bytes, p.ParseOptions)
+	version, serviceConfigs, volumeConfigs, networkConfigs, err := config.Merge(p.ServiceConfigs, p.context.EnvironmentLookup, p.context.ResourceLookup, file, bytes, p.parseOptions())
 	if err != nil {
 		log.Errorf("Could not parse config for project %s : %v", p.Name, err)
 		return err
@@ -245,8 +259,10 @@ func (p *Project) load(file string, bytes []byte) error {
 	p.handleNetworkConfig()
 	p.handleVolumeConfig()
 
-	if p.context.NetworksFactory != nil {
-		networks, err := p.context.NetworksFactory.Create(p.Name, p.NetworkConfigs, p.ServiceConfigs, p.isNetworkEnabled())
+	if p.context.DisableNetworks {
+		p.networks = &EmptyNetworks{}
+	} else if p.context.NetworksFactory != nil {
+		networks, err := p.context.NetworksFactory.Create(p.Name, p.labeledNetworkConfigs(), p.ServiceConfigs, p.isNetworkEnabled())
 		if err != nil {
 			return err
 		}
@@ -255,7 +271,7 @@ func (p *Project) load(file string, bytes []byte) error {
 	}
 
 	if p.context.VolumesFactory != nil {
-		volumes, err := p.context.VolumesFactory.Create(p.Name, p.VolumeConfigs, p.ServiceConfigs, p.isVolumeEnabled())
+		volumes, err := p.context.VolumesFactory.Create(p.Name, p.labeledVolumeConfigs(), p.ServiceConfigs, p.isVolumeEnabled())
 		if err != nil {
 			return err
 		}
@@ -266,7 +282,68 @@ func (p *Project) load(file string, bytes []byte) error {
 	return nil
 }
 
+// labeledNetworkConfigs returns the network configs of the project with the
+// custom labels of the context added to copies of them. Networks without a
+// configuration (nil) get one.
+func (p *Project) labeledNetworkConfigs() map[string]*config.NetworkConfig {
+	if len(p.context.CustomLabels) == 0 {
+		return p.NetworkConfigs
+	}
+	configs := map[string]*config.NetworkConfig{}
+	for name, networkConfig := range p.NetworkConfigs {
+		labeled := config.NetworkConfig{}
+		if networkConfig != nil {
+			labeled = *networkConfig
+		}
+		labeled.Labels = addCustomLabels(labeled.Labels, p.context.CustomLabels)
+		configs[name] = &labeled
+	}
+	return configs
+}
+
+// labeledVolumeConfigs returns the volume configs of the project with the
+// custom labels of the context added to copies of them. Volumes without a
+// configuration (nil) get one.
+func (p *Project) labeledVolumeConfigs() map[string]*config.VolumeConfig {
+	if len(p.context.CustomLabels) == 0 {
+		return p.VolumeConfigs
+	}
+	configs := map[string]*config.VolumeConfig{}
+	for name, volumeConfig := range p.VolumeConfigs {
+		labeled := config.VolumeConfig{}
+		if volumeConfig != nil {
+			labeled = *volumeConfig
+		}
+		labeled.Labels = addCustomLabels(labeled.Labels, p.context.CustomLabels)
+		configs[name] = &labeled
+	}
+	return configs
+}
+
+// addCustomLabels returns a copy of the specified labels with the custom
+// ones that they don't already define.
+func addCustomLabels(labels yaml.SliceorMap, custom map[string]string) yaml.SliceorMap {
+	result := yaml.SliceorMap{}
+	for key, value := range custom {
+		result[key] = value
+	}
+	for key, value := range labels {
+		result[key] = value
+	}
+	return result
+}
+
 func (p *Project) handleNetworkConfig() {
+	if p.context.DisableNetworks {
+		for _, serviceName := range p.ServiceConfigs.Keys() {
+			serviceConfig, _ := p.ServiceConfigs.Get(serviceName)
+			if serviceConfig.Networks != nil && len(serviceConfig.Networks.Networks) > 0 {
+				log.Warnf("Networks are disabled, ignoring the networks of service %s", serviceName)
+				serviceConfig.Networks = nil
+			}
+		}
+		return
+	}
 	if p.isNetworkEnabled() {
 		for _, serviceName := range p.ServiceConfigs.Keys() {
 			serviceConfig, _ := p.ServiceConfigs.Get(serviceName)
@@ -294,7 +371,11 @@ func (p *Project) handleNetworkConfig() {
 						network.RealName = network.Name
 						if net.External.Name != "" {
 							network.RealName = net.External.Name
+						} else if net.Name != "" {
+							network.RealName = net.Name
 						}
+					} else if net.Name != "" {
+						network.RealName = net.Name
 					} else {
 						network.RealName = p.Name + "_" + network.Name
 					}
@@ -312,7 +393,17 @@ func (p *Project) handleNetworkConfig() {
 }
 
 func (p *Project) isNetworkEnabled() bool {
-	return p.configVersion == "2"
+	return p.configMajorVersion() >= 2 && !p.context.DisableNetworks
+}
+
+// configMajorVersion returns the major version of the compose file format
+// (e.g. 3 for "3.4"), 1 if it can't be determined.
+func (p *Project) configMajorVersion() int {
+	major, err := strconv.Atoi(strings.SplitN(p.configVersion, ".", 2)[0])
+	if err != nil {
+		return 1
+	}
+	return major
 }
 
 func (p *Project) handleVolumeConfig() {
@@ -325,7 +416,7 @@ func (p *Project) handleVolumeConfig() {
 				continue
 			}
 			for _, volume := range serviceConfig.Volumes.Volumes {
-				if !IsNamedVolume(volume.Source) {
+				if volume.Type == yaml.VolumeTypeBind || !IsNamedVolume(volume.Source) {
 					continue
 				}
 
@@ -337,7 +428,11 @@ func (p *Project) handleVolumeConfig() {
 				if vol.External.External {
 					if vol.External.Name != "" {
 						volume.Source = vol.External.Name
+					} else if vol.Name != "" {
+						volume.Source = vol.Name
 					}
+				} else if vol.Name != "" {
+					volume.Source = vol.Name
 				} else {
 					volume.Source = p.Name + "_" + volume.Source
 				}
@@ -347,7 +442,7 @@ func (p *Project) handleVolumeConfig() {
 }
 
 func (p *Project) isVolumeEnabled() bool {
-	return p.configVersion == "2"
+	return p.configMajorVersion() >= 2
 }
 
 // initialize sets up required element for project before any action (on project and service).
@@ -553,6 +648,73 @@ func (p *Project) GetServiceConfig(name string) (*config.ServiceConfig, bool) {
 	return p.ServiceConfigs.Get(name)
 }
 
+// ServiceNames returns the names of the services of the project in a stable
+// order where dependencies (links, depends_on, volumes_from…) come before
+// the services depending on them, names being sorted otherwise. Cycles and
+// dependencies on undefined services are ignored.
+func (p *Project) ServiceNames() []string {
+	names := p.ServiceConfigs.Keys()
+	sort.Strings(names)
+
+	ordered := []string{}
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		serviceConfig, ok := p.ServiceConfigs.Get(name)
+		if !ok || visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dependency := range configDependencies(serviceConfig) {
+			visit(dependency.Target)
+		}
+		ordered = append(ordered, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered
+}
+
+// Services returns the configurations of the services of the project, in the
+// order of ServiceNames. They are the ones of the project and must not be
+// modified, GetServiceConfig looks one up by name.
+func (p *Project) Services() []*config.ServiceConfig {
+	services := []*config.ServiceConfig{}
+	for _, name := range p.ServiceNames() {
+		serviceConfig, _ := p.ServiceConfigs.Get(name)
+		services = append(services, serviceConfig)
+	}
+	return services
+}
+
+// SelectServices returns the specified services (all of them if none is
+// specified) whose labels match the selector. The services are returned as
+// is if the selector is empty, and it fails if none of them matches, so that
+// a selector can't end up selecting the whole project.
+func (p *Project) SelectServices(selector options.LabelSelector, services ...string) ([]string, error) {
+	if len(selector) == 0 {
+		return services, nil
+	}
+	if len(services) == 0 {
+		services = p.ServiceConfigs.Keys()
+	}
+	selected := []string{}
+	for _, name := range services {
+		serviceConfig, ok := p.ServiceConfigs.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("No such service: %s", name)
+		}
+		if selector.Matches(serviceConfig.Labels) {
+			selected = append(selected, name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("No service matches the label selector %s", selector)
+	}
+	return selected, nil
+}
+
 // IsNamedVolume returns whether the specified volume (string) is a named volume or not.
 func IsNamedVolume(volume string) bool {
 	return !strings.HasPrefix(volume, ".") && !strings.HasPrefix(volume, "/") && !strings.HasPrefix(volume, "~")
@@ -3,15 +3,36 @@ package project
 import (
 	"golang.org/x/net/context"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/zengchen221/libcompose/project/events"
 	"github.com/zengchen221/libcompose/project/options"
 )
 
-// Build builds the specified services (like docker build).
+// Build builds the images of the specified services (like docker build),
+// without creating or starting any container. Services without a build
+// section (nor a context reader in the build options) are skipped. The
+// build output of each service goes to its build logger.
 func (p *Project) Build(ctx context.Context, buildOptions options.Build, services ...string) error {
-	return p.perform(events.ProjectBuildStart, events.ProjectBuildDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+	if len(services) == 0 {
+		services = p.activeServices()
+	}
+	buildable := []string{}
+	for _, name := range services {
+		serviceConfig, ok := p.ServiceConfigs.Get(name)
+		if ok && serviceConfig.Build.Context == "" && buildOptions.ContextReaders[name] == nil {
+			log.Infof("%s uses an image, skipping", name)
+			continue
+		}
+		buildable = append(buildable, name)
+	}
+	if len(buildable) == 0 {
+		return nil
+	}
+	return p.perform(events.ProjectBuildStart, events.ProjectBuildDone, buildable, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(wrappers, events.ServiceBuildStart, events.ServiceBuild, func(service Service) error {
-			return service.Build(ctx, buildOptions)
+			return p.context.WithOperationTimeout(ctx, service.Name(), "build", func(ctx context.Context) error {
+				return service.Build(ctx, buildOptions)
+			})
 		})
 	}), nil)
 }
@@ -1,7 +1,13 @@
 package project
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
 	"github.com/zengchen221/libcompose/config"
+	"github.com/zengchen221/libcompose/utils"
 	"gopkg.in/yaml.v2"
 )
 
@@ -9,19 +15,66 @@ import (
 type ExportedConfig struct {
 	Version  string                           `yaml:"version,omitempty"`
 	Services map[string]*config.ServiceConfig `yaml:"services"`
-	Volumes  map[string]*config.VolumeConfig  `yaml:"volumes"`
-	Networks map[string]*config.NetworkConfig `yaml:"networks"`
+	Volumes  map[string]*config.VolumeConfig  `yaml:"volumes,omitempty"`
+	Networks map[string]*config.NetworkConfig `yaml:"networks,omitempty"`
 }
 
-// Config validates and print the compose file.
+// Config returns the merged configuration of the project (extends, overrides
+// and interpolation applied) as YAML. The keys are sorted, so the output can
+// be compared across runs.
 func (p *Project) Config() (string, error) {
+	var buffer bytes.Buffer
+	if err := p.WriteConfig(&buffer); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// WriteConfig writes the merged configuration of the project to the specified
+// writer, as Config returns it: a version 2.0 compose file with sorted keys,
+// top-level volumes and networks being left out when there are none. It only
+// relies on the parsed configuration, so it doesn't require any access to a
+// docker daemon.
+func (p *Project) WriteConfig(w io.Writer) error {
+	services := map[string]*config.ServiceConfig{}
+	for name, serviceConfig := range p.ServiceConfigs.All() {
+		// extends is already resolved
+		exported := *serviceConfig
+		exported.Extends = nil
+		services[name] = &exported
+	}
 	cfg := ExportedConfig{
 		Version:  "2.0",
-		Services: p.ServiceConfigs.All(),
+		Services: services,
 		Volumes:  p.VolumeConfigs,
 		Networks: p.NetworkConfigs,
 	}
 
-	bytes, err := yaml.Marshal(cfg)
-	return string(bytes), err
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// ServiceConfigJSON returns the merged configuration of the specified service
+// (extends, overrides and interpolation applied) as JSON, using the same keys
+// as the compose file.
+func (p *Project) ServiceConfigJSON(name string) ([]byte, error) {
+	serviceConfig, ok := p.ServiceConfigs.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("No such service: %s", name)
+	}
+
+	// Go through yaml so that the custom marshallers of the config types apply
+	bytes, err := yaml.Marshal(serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(bytes, &raw); err != nil {
+		return nil, err
+	}
+	return json.Marshal(utils.ConvertKeysToStrings(raw))
 }
@@ -5,21 +5,47 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/zengchen221/libcompose/config"
 	"github.com/zengchen221/libcompose/project/events"
 	"github.com/zengchen221/libcompose/project/options"
 )
 
-// Create creates the specified services (like docker create).
+// Create creates the specified services (like docker create), without
+// starting them: the services are provisioned the same way as Up does, so
+// that they can be started later on with Start.
 func (p *Project) Create(ctx context.Context, options options.Create, services ...string) error {
-	if options.NoRecreate && options.ForceRecreate {
-		return fmt.Errorf("no-recreate and force-recreate cannot be combined")
+	if err := validateCreate(options); err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		services = p.activeServices()
+		if len(services) == 0 {
+			return p.initialize(ctx)
+		}
+	}
+	services, err := p.SelectServices(options.LabelSelector, services...)
+	if err != nil {
+		return err
 	}
 	if err := p.initialize(ctx); err != nil {
 		return err
 	}
-	return p.perform(events.ProjectCreateStart, events.ProjectCreateDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+	err = p.perform(events.ProjectCreateStart, events.ProjectCreateDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(wrappers, events.ServiceCreateStart, events.ServiceCreate, func(service Service) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			return service.Create(ctx, options)
 		})
 	}), nil)
+	return cancelled(ctx, err)
+}
+
+// validateCreate checks that the create options are valid and not
+// contradictory.
+func validateCreate(createOptions options.Create) error {
+	if createOptions.NoRecreate && createOptions.ForceRecreate {
+		return fmt.Errorf("no-recreate and force-recreate cannot be combined")
+	}
+	return config.ValidatePullPolicy(createOptions.PullPolicy)
 }
@@ -1,12 +1,27 @@
 package project
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"golang.org/x/net/context"
 
 	"github.com/zengchen221/libcompose/project/events"
 	"github.com/zengchen221/libcompose/project/options"
 )
 
+// SkippedContainersError is returned by Remove when running containers were
+// left untouched.
+type SkippedContainersError struct {
+	// Containers holds the names of the running containers.
+	Containers []string
+}
+
+func (e *SkippedContainersError) Error() string {
+	return fmt.Sprintf("Skipped running containers %s, stop them first or set RemoveRunning", strings.Join(e.Containers, ", "))
+}
+
 // Delete removes the specified services (like docker rm).
 func (p *Project) Delete(ctx context.Context, options options.Delete, services ...string) error {
 	return p.perform(events.ProjectDeleteStart, events.ProjectDeleteDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
@@ -15,3 +30,51 @@ func (p *Project) Delete(ctx context.Context, options options.Delete, services .
 		})
 	}), nil)
 }
+
+// Remove removes the containers of the specified services (all of them if
+// none is specified), like docker-compose rm. Running containers are only
+// removed if RemoveRunning is set: they are skipped otherwise, and reported
+// with a *SkippedContainersError once the stopped ones are removed.
+func (p *Project) Remove(ctx context.Context, options options.Delete, services ...string) error {
+	skipped := []string{}
+	if !options.RemoveRunning {
+		if len(services) == 0 {
+			services = p.ServiceConfigs.Keys()
+		}
+		for _, name := range services {
+			service, err := p.CreateService(name)
+			if err != nil {
+				return err
+			}
+			containers, err := service.Containers(ctx)
+			if err != nil {
+				return err
+			}
+			for _, c := range containers {
+				if c.IsRunning(ctx) {
+					skipped = append(skipped, c.Name())
+				}
+			}
+		}
+	}
+
+	if err := p.Delete(ctx, options, services...); err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		return &SkippedContainersError{Containers: skipped}
+	}
+	return nil
+}
+
+// RemoveStopped is a shorthand for Remove that doesn't report the running
+// containers it leaves untouched. The anonymous volumes of the removed
+// containers are removed too if removeVolume is set.
+func (p *Project) RemoveStopped(ctx context.Context, removeVolume bool, services ...string) error {
+	err := p.Remove(ctx, options.Delete{RemoveVolume: removeVolume}, services...)
+	if _, ok := err.(*SkippedContainersError); ok {
+		return nil
+	}
+	return err
+}
@@ -10,33 +10,57 @@ import (
 )
 
 // Down stops the specified services and clean related containers (like docker stop + docker rm).
+// If no service is specified, only the ones enabled by the active profiles
+// are torn down. The networks and volumes of the project are kept when
+// services or a label selector are specified.
+// Depending on the options, it also removes the containers of services that
+// are not part of the project anymore (orphans), the volumes of the project
+// and the images of the services (all of them, or only the ones built
+// locally).
 func (p *Project) Down(ctx context.Context, opts options.Down, services ...string) error {
 	if !opts.RemoveImages.Valid() {
 		return fmt.Errorf("--rmi flag must be local, all or empty")
 	}
-	if err := p.Stop(ctx, 10, services...); err != nil {
+	// The networks and volumes are shared by all the services, they are
+	// kept when only some of them are torn down
+	scoped := len(services) > 0 || len(opts.LabelSelector) > 0
+	if len(services) == 0 {
+		services = p.activeServices(opts.Profiles...)
+	}
+	services, err := p.SelectServices(opts.LabelSelector, services...)
+	if err != nil {
 		return err
 	}
-	if opts.RemoveOrphans {
+	if len(services) > 0 {
+		// A zero timeout lets each service use its stop_grace_period
+		if err := p.Stop(ctx, 0, services...); err != nil {
+			return err
+		}
+	}
+	if opts.RemoveOrphans && p.runtime != nil {
 		if err := p.runtime.RemoveOrphans(ctx, p.Name, p.ServiceConfigs); err != nil {
 			return err
 		}
 	}
-	if err := p.Delete(ctx, options.Delete{
-		RemoveVolume: opts.RemoveVolume,
-	}, services...); err != nil {
-		return err
+	if len(services) > 0 {
+		if err := p.Delete(ctx, options.Delete{
+			RemoveVolume: opts.RemoveVolume,
+		}, services...); err != nil {
+			return err
+		}
 	}
 
-	networks, err := p.context.NetworksFactory.Create(p.Name, p.NetworkConfigs, p.ServiceConfigs, p.isNetworkEnabled())
-	if err != nil {
-		return err
-	}
-	if err := networks.Remove(ctx); err != nil {
-		return err
+	if !scoped && !p.context.DisableNetworks && !opts.KeepNetworks && p.context.NetworksFactory != nil {
+		networks, err := p.context.NetworksFactory.Create(p.Name, p.NetworkConfigs, p.ServiceConfigs, p.isNetworkEnabled())
+		if err != nil {
+			return err
+		}
+		if err := networks.Remove(ctx); err != nil {
+			return err
+		}
 	}
 
-	if opts.RemoveVolume {
+	if !scoped && opts.RemoveVolume && p.context.VolumesFactory != nil {
 		volumes, err := p.context.VolumesFactory.Create(p.Name, p.VolumeConfigs, p.ServiceConfigs, p.isVolumeEnabled())
 		if err != nil {
 			return err
@@ -46,7 +70,10 @@ func (p *Project) Down(ctx context.Context, opts options.Down, services ...strin
 		}
 	}
 
-	return p.forEach([]string{}, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+	if opts.RemoveImages == "" || len(services) == 0 {
+		return nil
+	}
+	return p.forEach(services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(wrappers, events.NoEvent, events.NoEvent, func(service Service) error {
 			return service.RemoveImage(ctx, opts.RemoveImages)
 		})
@@ -1,24 +1,44 @@
 package project
 
 import (
+	"sync"
+
 	"golang.org/x/net/context"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/zengchen221/libcompose/project/events"
 )
 
-// Events listen for real time events from containers (of the project).
+// Events listen for real time events from containers (of the project). The
+// returned chan is closed once the context is cancelled or the event streams
+// of all the services ended.
 func (p *Project) Events(ctx context.Context, services ...string) (chan events.ContainerEvent, error) {
-	events := make(chan events.ContainerEvent)
 	if len(services) == 0 {
 		services = p.ServiceConfigs.Keys()
 	}
-	// FIXME(vdemeester) handle errors (chan) here
+	serviceList := []Service{}
 	for _, service := range services {
 		s, err := p.CreateService(service)
 		if err != nil {
 			return nil, err
 		}
-		go s.Events(ctx, events)
+		serviceList = append(serviceList, s)
+	}
+
+	events := make(chan events.ContainerEvent)
+	var wg sync.WaitGroup
+	for _, s := range serviceList {
+		wg.Add(1)
+		go func(s Service) {
+			defer wg.Done()
+			if err := s.Events(ctx, events); err != nil {
+				log.Errorf("Failed to listen to the events of service %s: %v", s.Name(), err)
+			}
+		}(s)
 	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
 	return events, nil
 }
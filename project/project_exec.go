@@ -0,0 +1,64 @@
+package project
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/zengchen221/libcompose/project/options"
+)
+
+// ExecError is returned by Exec when the command exits with a non zero code.
+type ExecError struct {
+	Service  string
+	ExitCode int
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("Command in service %s exited with code %d", e.Service, e.ExitCode)
+}
+
+// Exec runs a command in a running container of the specified service. Unless
+// detached, the standard streams are attached to the command and an
+// ExecError holding its exit code is returned if it fails.
+func (p *Project) Exec(ctx context.Context, serviceName string, opts options.Exec) error {
+	if len(opts.Command) == 0 {
+		return fmt.Errorf("No command specified")
+	}
+	index := opts.Index
+	if index == 0 {
+		index = 1
+	}
+
+	service, err := p.CreateService(serviceName)
+	if err != nil {
+		return err
+	}
+
+	containers, err := service.Containers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		number, err := c.Number()
+		if err != nil {
+			return err
+		}
+		if number != index {
+			continue
+		}
+		if !c.IsRunning(ctx) {
+			return fmt.Errorf("Container %s of service %s is not running", c.Name(), serviceName)
+		}
+		exitCode, err := c.ExecCommand(ctx, opts)
+		if err != nil {
+			return err
+		}
+		if exitCode != 0 {
+			return &ExecError{Service: serviceName, ExitCode: exitCode}
+		}
+		return nil
+	}
+	return fmt.Errorf("No container found for service %s with index %d", serviceName, index)
+}
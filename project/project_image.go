@@ -0,0 +1,33 @@
+package project
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+)
+
+// ServiceImage returns the fully qualified reference of the image the
+// specified service uses: the registry and the latest tag are made explicit,
+// and digests are preserved. Services without an image use the one they
+// build, named after the project and the service. It doesn't require any
+// access to a docker daemon.
+func (p *Project) ServiceImage(name string) (string, error) {
+	serviceConfig, ok := p.GetServiceConfig(name)
+	if !ok {
+		return "", fmt.Errorf("No such service: %s", name)
+	}
+
+	image := serviceConfig.Image
+	if image == "" {
+		if serviceConfig.Build.Context == "" {
+			return "", fmt.Errorf("Service %s has neither an image nor a build section", name)
+		}
+		image = fmt.Sprintf("%s_%s", p.Name, name)
+	}
+
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", fmt.Errorf("Invalid image reference %q for service %s: %v", image, name, err)
+	}
+	return reference.TagNameOnly(named).String(), nil
+}
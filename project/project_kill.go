@@ -3,14 +3,26 @@ package project
 import (
 	"golang.org/x/net/context"
 
+	"github.com/docker/docker/pkg/signal"
 	"github.com/zengchen221/libcompose/project/events"
 )
 
-// Kill kills the specified services (like docker kill).
-func (p *Project) Kill(ctx context.Context, signal string, services ...string) error {
+// DefaultKillSignal is the signal sent by Kill when none is specified.
+const DefaultKillSignal = "SIGKILL"
+
+// Kill kills the specified services (like docker kill), all of them if none
+// is specified, by sending the specified signal to their running containers.
+// The signal can be given by name (SIGTERM or TERM) or by number (15).
+func (p *Project) Kill(ctx context.Context, signalName string, services ...string) error {
+	if signalName == "" {
+		signalName = DefaultKillSignal
+	}
+	if _, err := signal.ParseSignal(signalName); err != nil {
+		return err
+	}
 	return p.perform(events.ProjectKillStart, events.ProjectKillDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(nil, events.ServiceKillStart, events.ServiceKill, func(service Service) error {
-			return service.Kill(ctx, signal)
+			return service.Kill(ctx, signalName)
 		})
 	}), nil)
 }
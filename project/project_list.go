@@ -0,0 +1,43 @@
+package project
+
+import (
+	"sort"
+
+	"golang.org/x/net/context"
+)
+
+// List returns the state of the containers of the specified services (all of
+// them if none is specified), stopped ones included. Unlike Ps, the state is
+// returned as structured data, to be formatted by the caller.
+func (p *Project) List(ctx context.Context, services ...string) ([]ContainerInfo, error) {
+	result := []ContainerInfo{}
+
+	if len(services) == 0 {
+		services = p.ServiceConfigs.Keys()
+		sort.Strings(services)
+	}
+
+	for _, name := range services {
+		service, err := p.CreateService(name)
+		if err != nil {
+			return nil, err
+		}
+
+		containers, err := service.Containers(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range containers {
+			info, err := c.Inspect(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if info.Service == "" {
+				info.Service = name
+			}
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
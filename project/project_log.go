@@ -1,16 +1,62 @@
 package project
 
 import (
+	"fmt"
+	"io"
+
 	"golang.org/x/net/context"
 
+	"github.com/zengchen221/libcompose/config"
 	"github.com/zengchen221/libcompose/project/events"
+	"github.com/zengchen221/libcompose/project/options"
+	"github.com/zengchen221/libcompose/utils"
 )
 
-// Log aggregates and prints out the logs for the specified services.
-func (p *Project) Log(ctx context.Context, follow bool, services ...string) error {
+// Log aggregates and prints out the logs for the specified services through
+// the project LoggerFactory. The services marked with `attach: false` are
+// left out, unless they are explicitly specified. When following the logs,
+// it returns once the context is cancelled.
+func (p *Project) Log(ctx context.Context, opts options.Log, services ...string) error {
+	services, err := p.SelectServices(opts.LabelSelector, services...)
+	if err != nil {
+		return err
+	}
 	return p.forEach(services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(nil, events.NoEvent, events.NoEvent, func(service Service) error {
-			return service.Log(ctx, follow)
+			if !utils.Contains(services, service.Name()) && !isAttached(service.Config()) {
+				return nil
+			}
+			return service.Log(ctx, opts)
 		})
 	}), nil)
 }
+
+// LogService returns the log stream of the container with the specified
+// number (replica index, starting at 1) of the specified service. The caller
+// is responsible for closing it.
+func (p *Project) LogService(ctx context.Context, serviceName string, index int, opts options.Log) (io.ReadCloser, error) {
+	service, err := p.CreateService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := service.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range containers {
+		number, err := c.Number()
+		if err != nil {
+			return nil, err
+		}
+		if number == index {
+			return c.LogStream(ctx, opts)
+		}
+	}
+	return nil, fmt.Errorf("No container found for service %s with index %d", serviceName, index)
+}
+
+func isAttached(serviceConfig *config.ServiceConfig) bool {
+	return serviceConfig == nil || serviceConfig.Attach == nil || *serviceConfig.Attach
+}
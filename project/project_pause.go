@@ -6,7 +6,9 @@ import (
 	"github.com/zengchen221/libcompose/project/events"
 )
 
-// Pause pauses the specified services containers (like docker pause).
+// Pause pauses the specified services containers (like docker pause), or the
+// containers of all the services if none is specified. Already paused
+// containers are left as is.
 func (p *Project) Pause(ctx context.Context, services ...string) error {
 	return p.perform(events.ProjectPauseStart, events.ProjectPauseDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(nil, events.ServicePauseStart, events.ServicePause, func(service Service) error {
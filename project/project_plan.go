@@ -0,0 +1,58 @@
+package project
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/zengchen221/libcompose/project/options"
+)
+
+// ActionKind is the kind of an action Up would perform on a container.
+type ActionKind string
+
+// Action kinds, as computed by Plan.
+const (
+	// ActionCreate creates (and starts) a new container.
+	ActionCreate = ActionKind("create")
+	// ActionRecreate replaces an existing container, because its
+	// configuration is out of sync or recreation is forced.
+	ActionRecreate = ActionKind("recreate")
+	// ActionStart starts an existing stopped container.
+	ActionStart = ActionKind("start")
+	// ActionNone leaves a running container, which is up to date, alone.
+	ActionNone = ActionKind("none")
+)
+
+// Action is an action Up would perform on a container of a service.
+type Action struct {
+	Service   string
+	Container string
+	Kind      ActionKind
+}
+
+// Plan returns the actions Up would perform on the containers of the
+// specified services with the specified options, without performing them:
+// the containers are compared with the service configurations (using their
+// config hash) but nothing is created, removed or started.
+func (p *Project) Plan(ctx context.Context, options options.Up, services ...string) ([]Action, error) {
+	if err := p.validateUp(options); err != nil {
+		return nil, err
+	}
+
+	if len(services) == 0 {
+		services = p.activeServices(options.Profiles...)
+	}
+
+	actions := []Action{}
+	for _, name := range services {
+		service, err := p.CreateService(name)
+		if err != nil {
+			return nil, err
+		}
+		serviceActions, err := service.Plan(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, serviceActions...)
+	}
+	return actions, nil
+}
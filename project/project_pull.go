@@ -1,16 +1,79 @@
 package project
 
 import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
 	"golang.org/x/net/context"
 
 	"github.com/zengchen221/libcompose/project/events"
+	"github.com/zengchen221/libcompose/project/options"
 )
 
-// Pull pulls the specified services (like docker pull).
-func (p *Project) Pull(ctx context.Context, services ...string) error {
-	return p.forEach(services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+// PullError is returned by Pull when the images of some services could not be
+// pulled.
+type PullError struct {
+	// Services holds the pull error of each failed service, by service name.
+	Services map[string]error
+}
+
+func (e *PullError) Error() string {
+	names := []string{}
+	for name := range e.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failures := []string{}
+	for _, name := range names {
+		failures = append(failures, fmt.Sprintf("%s: %v", name, e.Services[name]))
+	}
+	return fmt.Sprintf("Failed to pull services: %s", strings.Join(failures, ", "))
+}
+
+// Pull pulls the specified services (like docker pull). The images are pulled
+// concurrently, at most options.Parallelism at a time. Unless failures are
+// ignored, the first failure cancels the pending pulls.
+func (p *Project) Pull(ctx context.Context, opts options.Pull, services ...string) error {
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	slots := make(chan struct{}, parallelism)
+	var lock sync.Mutex
+	failures := map[string]error{}
+
+	err := p.forEach(services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(nil, events.ServicePullStart, events.ServicePull, func(service Service) error {
-			return service.Pull(ctx)
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			err := p.context.WithOperationTimeout(ctx, service.Name(), "pull", service.Pull)
+			if err != nil {
+				lock.Lock()
+				defer lock.Unlock()
+				// Failures following a cancellation are a consequence of it
+				if ctx.Err() == nil {
+					failures[service.Name()] = err
+					if !opts.IgnoreFailures {
+						cancel()
+					}
+				}
+			}
+			return err
 		})
 	}), nil)
+
+	if len(failures) > 0 {
+		return &PullError{Services: failures}
+	}
+	return err
 }
@@ -6,11 +6,49 @@ import (
 	"github.com/zengchen221/libcompose/project/events"
 )
 
-// Restart restarts the specified services (like docker restart).
+// Restart restarts the specified services (like docker restart). The
+// services depending on them with `restart: true` are restarted as well,
+// after their dependencies. The containers of services with a
+// stop_grace_period are given that long to stop, the others the specified
+// timeout.
 func (p *Project) Restart(ctx context.Context, timeout int, services ...string) error {
-	return p.perform(events.ProjectRestartStart, events.ProjectRestartDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+	return p.perform(events.ProjectRestartStart, events.ProjectRestartDone, p.withRestartDependents(services), wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(wrappers, events.ServiceRestartStart, events.ServiceRestart, func(service Service) error {
 			return service.Restart(ctx, timeout)
 		})
 	}), nil)
 }
+
+// withRestartDependents returns the specified services along with the ones
+// that (transitively) depend on them with `restart: true`. No service means
+// all of them, so there is nothing to add in that case.
+func (p *Project) withRestartDependents(services []string) []string {
+	if len(services) == 0 {
+		return services
+	}
+
+	restarted := map[string]bool{}
+	for _, name := range services {
+		restarted[name] = true
+	}
+	result := append([]string{}, services...)
+
+	for added := true; added; {
+		added = false
+		for _, name := range p.ServiceConfigs.Keys() {
+			if restarted[name] {
+				continue
+			}
+			serviceConfig, _ := p.ServiceConfigs.Get(name)
+			for _, dependency := range serviceConfig.DependsOn {
+				if dependency.Restart && restarted[dependency.Service] {
+					restarted[name] = true
+					result = append(result, name)
+					added = true
+					break
+				}
+			}
+		}
+	}
+	return result
+}
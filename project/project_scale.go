@@ -8,16 +8,22 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// Scale scales the specified services.
+// Scale scales the specified services, creating or removing containers so
+// that each of them runs the requested number of instances. Services with a
+// custom container name can't run more than one instance.
 func (p *Project) Scale(ctx context.Context, timeout int, servicesScale map[string]int) error {
 	// This code is a bit verbose but I wanted to parse everything up front
 	order := make([]string, 0, 0)
 	services := make(map[string]Service)
 
-	for name := range servicesScale {
-		if !p.ServiceConfigs.Has(name) {
+	for name, scale := range servicesScale {
+		serviceConfig, ok := p.ServiceConfigs.Get(name)
+		if !ok {
 			return fmt.Errorf("%s is not defined in the template", name)
 		}
+		if serviceConfig.ContainerName != "" && scale > 1 {
+			return fmt.Errorf("Service %s uses the custom container name %s, remove it to scale the service", name, serviceConfig.ContainerName)
+		}
 
 		service, err := p.CreateService(name)
 		if err != nil {
@@ -6,11 +6,24 @@ import (
 	"github.com/zengchen221/libcompose/project/events"
 )
 
-// Start starts the specified services (like docker start).
+// Start starts the existing containers of the specified services (like
+// docker start), dependencies first, all the services enabled by the active
+// profiles if none is specified. Containers are not created nor recreated,
+// use Create or Up for that.
 func (p *Project) Start(ctx context.Context, services ...string) error {
-	return p.perform(events.ProjectStartStart, events.ProjectStartDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+	if len(services) == 0 {
+		services = p.activeServices()
+		if len(services) == 0 {
+			return nil
+		}
+	}
+	err := p.perform(events.ProjectStartStart, events.ProjectStartDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(wrappers, events.ServiceStartStart, events.ServiceStart, func(service Service) error {
-			return service.Start(ctx)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return p.context.WithOperationTimeout(ctx, service.Name(), "start", service.Start)
 		})
 	}), nil)
+	return cancelled(ctx, err)
 }
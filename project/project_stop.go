@@ -6,10 +6,11 @@ import (
 	"github.com/zengchen221/libcompose/project/events"
 )
 
-// Stop stops the specified services (like docker stop).
+// Stop stops the specified services (like docker stop). Services are stopped
+// in the reverse dependency order, dependent services first.
 func (p *Project) Stop(ctx context.Context, timeout int, services ...string) error {
 	return p.perform(events.ProjectStopStart, events.ProjectStopDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
-		wrapper.Do(nil, events.ServiceStopStart, events.ServiceStop, func(service Service) error {
+		wrapper.DoReverse(wrappers, events.ServiceStopStart, events.ServiceStop, func(service Service) error {
 			return service.Stop(ctx, timeout)
 		})
 	}), nil)
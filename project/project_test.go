@@ -1,14 +1,24 @@
 package project
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 
 	"github.com/zengchen221/libcompose/config"
+	"github.com/zengchen221/libcompose/project/events"
 	"github.com/zengchen221/libcompose/project/options"
 	"github.com/zengchen221/libcompose/yaml"
 	"github.com/stretchr/testify/assert"
@@ -136,6 +146,87 @@ func TestParseWithGoodContent(t *testing.T) {
 	}
 }
 
+func TestParseNamedVolumes(t *testing.T) {
+	p := NewProject(&Context{
+		ProjectName: "myproject",
+		ComposeBytes: [][]byte{
+			[]byte(`
+version: '2'
+services:
+  web:
+    image: foo
+    volumes:
+      - data:/short
+      - type: volume
+        source: data
+        target: /long
+      - type: bind
+        source: data
+        target: /bind
+      - shared:/shared
+      - ext:/ext
+      - renamed:/renamed
+volumes:
+  data: {}
+  shared:
+    name: shared-data
+    labels:
+      - com.example.shared=true
+  ext:
+    external: true
+  renamed:
+    external:
+      name: other-data
+`),
+		},
+	}, nil, nil)
+
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	web, _ := p.GetServiceConfig("web")
+	sources := []string{}
+	for _, volume := range web.Volumes.Volumes {
+		sources = append(sources, volume.Source)
+	}
+	assert.Equal(t, []string{"myproject_data", "myproject_data", "data", "shared-data", "ext", "other-data"}, sources)
+	assert.Equal(t, "true", p.VolumeConfigs["shared"].Labels["com.example.shared"])
+}
+
+func TestCustomLabels(t *testing.T) {
+	p := NewProject(&Context{
+		ProjectName: "foo",
+		ComposeBytes: [][]byte{
+			[]byte(`version: '2'
+services:
+  web:
+    image: nginx
+volumes:
+  data: {}
+  shared:
+    labels:
+      env: shared
+networks:
+  front: {}
+`),
+		},
+		CustomLabels: map[string]string{"owner": "infra", "env": "prod"},
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	volumes := p.labeledVolumeConfigs()
+	assert.Equal(t, yaml.SliceorMap{"owner": "infra", "env": "prod"}, volumes["data"].Labels)
+	assert.Equal(t, yaml.SliceorMap{"owner": "infra", "env": "shared"}, volumes["shared"].Labels)
+	assert.Equal(t, yaml.SliceorMap{"env": "shared"}, p.VolumeConfigs["shared"].Labels)
+
+	networks := p.labeledNetworkConfigs()
+	assert.Equal(t, yaml.SliceorMap{"owner": "infra", "env": "prod"}, networks["front"].Labels)
+	assert.Equal(t, yaml.SliceorMap{"owner": "infra", "env": "prod"}, networks["default"].Labels)
+	assert.Empty(t, p.NetworkConfigs["front"].Labels)
+}
+
 func TestParseWithDefaultEnvironmentLookup(t *testing.T) {
 	p := NewProject(&Context{
 		ComposeBytes: [][]byte{
@@ -156,6 +247,30 @@ func (t *TestEnvironmentLookup) Lookup(key string, config *config.ServiceConfig)
 	return []string{fmt.Sprintf("%s=X", key)}
 }
 
+func TestAdditionalEnvironmentLookups(t *testing.T) {
+	os.Setenv("LIBCOMPOSE_TEST_IMAGE", "busybox")
+	defer os.Unsetenv("LIBCOMPOSE_TEST_IMAGE")
+
+	p := NewProject(&Context{
+		ComposeBytes: [][]byte{[]byte(`version: '2'
+services:
+  web:
+    image: ${LIBCOMPOSE_TEST_IMAGE}
+    command: ${LIBCOMPOSE_TEST_COMMAND}
+`)},
+		ProjectName:        "lookups",
+		EnvironmentLookups: []config.EnvironmentLookup{&TestEnvironmentLookup{}},
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	web, _ := p.GetServiceConfig("web")
+	// The OS environment comes first
+	assert.Equal(t, "busybox", web.Image)
+	assert.Equal(t, yaml.Command{"X"}, web.Command)
+}
+
 func TestEnvironmentResolve(t *testing.T) {
 	factory := &TestServiceFactory{
 		Counts: map[string]int{},
@@ -165,14 +280,17 @@ func TestEnvironmentResolve(t *testing.T) {
 		ServiceFactory:    factory,
 		EnvironmentLookup: &TestEnvironmentLookup{},
 	}, nil, nil)
-	p.ServiceConfigs = config.NewServiceConfigs()
-	p.ServiceConfigs.Add("foo", &config.ServiceConfig{
-		Environment: yaml.MaporEqualSlice([]string{
-			"A",
-			"A=",
-			"A=B",
-		}),
-	})
+	// The variables without value are resolved when parsing
+	if err := p.Load([]byte(`
+foo:
+  image: busybox
+  environment:
+    - A
+    - A=
+    - A=B
+`)); err != nil {
+		t.Fatal(err)
+	}
 
 	service, err := p.CreateService("foo")
 	if err != nil {
@@ -184,6 +302,31 @@ func TestEnvironmentResolve(t *testing.T) {
 	}
 }
 
+func TestBuildArgsResolve(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory:    &TestServiceFactory{Counts: map[string]int{}},
+		EnvironmentLookup: &TestEnvironmentLookup{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	unset, value := "\x00", "B"
+	p.ServiceConfigs.Add("foo", &config.ServiceConfig{
+		Build: yaml.Build{
+			Context: ".",
+			Args:    map[string]*string{"A": &unset, "B": &value},
+		},
+	})
+
+	service, err := p.CreateService("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "X", *service.Config().Build.Args["A"])
+	assert.Equal(t, "B", *service.Config().Build.Args["B"])
+	fooConfig, _ := p.GetServiceConfig("foo")
+	assert.Equal(t, "\x00", *fooConfig.Build.Args["A"])
+}
+
 func TestParseWithMultipleComposeFiles(t *testing.T) {
 	configOne := []byte(`
   multiple:
@@ -202,7 +345,7 @@ func TestParseWithMultipleComposeFiles(t *testing.T) {
   multiple:
     image: busybox
     mem_limit: "40m"
-    memswap_limit: 40000000
+    memswap_limit: 50000000
     ports:
       - 10000`)
 
@@ -245,5 +388,1758 @@ func TestParseWithMultipleComposeFiles(t *testing.T) {
 	assert.Equal(t, "multi", multipleConfig.ContainerName)
 	assert.Equal(t, []string{"8000", "9000", "10000"}, multipleConfig.Ports)
 	assert.Equal(t, yaml.MemStringorInt(41943040), multipleConfig.MemLimit)
-	assert.Equal(t, yaml.MemStringorInt(40000000), multipleConfig.MemSwapLimit)
+	assert.Equal(t, yaml.MemStringorInt(50000000), multipleConfig.MemSwapLimit)
+}
+
+type OrderServiceFactory struct {
+	sync.Mutex
+	Order []string
+	// UpErrors holds the errors to return from Up, by service name.
+	UpErrors map[string]error
+	// HealthErrors holds the errors to return from WaitHealthy, by service
+	// name.
+	HealthErrors map[string]error
+	// ExitCodes holds the exit codes of the containers WaitFor waits for to
+	// exit, by service name.
+	ExitCodes map[string]int
+	// Blocking holds the services whose Up and Start block until the context
+	// is cancelled.
+	Blocking map[string]bool
+	// Barrier, if set, makes Up wait for it before failing, so that the
+	// failing and blocking services are all brought up before any of them
+	// fails.
+	Barrier *sync.WaitGroup
+	// MaxRunning is the maximum number of services seen in Up at the same
+	// time.
+	MaxRunning int
+	running    int
+}
+
+type OrderService struct {
+	EmptyService
+	factory *OrderServiceFactory
+	project *Project
+	name    string
+	config  *config.ServiceConfig
+}
+
+func (o *OrderServiceFactory) Create(project *Project, name string, serviceConfig *config.ServiceConfig) (Service, error) {
+	return &OrderService{
+		factory: o,
+		project: project,
+		name:    name,
+		config:  serviceConfig,
+	}, nil
+}
+
+func (o *OrderServiceFactory) record(action, name string) {
+	// Give a chance to other services to run concurrently
+	time.Sleep(10 * time.Millisecond)
+	o.Lock()
+	defer o.Unlock()
+	o.Order = append(o.Order, action+":"+name)
+}
+
+func (o *OrderService) Name() string {
+	return o.name
+}
+
+func (o *OrderService) Config() *config.ServiceConfig {
+	return o.config
+}
+
+func (o *OrderService) DependentServices() []ServiceRelationship {
+	return DefaultDependentServices(o.project, o)
+}
+
+func (o *OrderService) Create(ctx context.Context, options options.Create) error {
+	o.factory.record("create", fmt.Sprintf("%s(force=%t,norecreate=%t)", o.name, options.ForceRecreate, options.NoRecreate))
+	return nil
+}
+
+func (o *OrderService) Build(ctx context.Context, options options.Build) error {
+	o.factory.record("build", fmt.Sprintf("%s(nocache=%t,pull=%t)", o.name, options.NoCache, options.Pull))
+	return nil
+}
+
+func (o *OrderService) Kill(ctx context.Context, signal string) error {
+	o.factory.record("kill", fmt.Sprintf("%s(%s)", o.name, signal))
+	return nil
+}
+
+func (o *OrderService) Start(ctx context.Context) error {
+	if o.factory.Blocking[o.name] {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	o.factory.record("start", o.name)
+	return nil
+}
+
+func (o *OrderService) Restart(ctx context.Context, timeout int) error {
+	o.factory.record("restart", o.name)
+	return nil
+}
+
+func (o *OrderService) Stop(ctx context.Context, timeout int) error {
+	o.factory.record("stop", o.name)
+	return nil
+}
+
+func (o *OrderService) Pause(ctx context.Context) error {
+	o.factory.record("pause", o.name)
+	return nil
+}
+
+func (o *OrderService) Unpause(ctx context.Context) error {
+	o.factory.record("unpause", o.name)
+	return nil
+}
+
+func (o *OrderService) Log(ctx context.Context, options options.Log) error {
+	o.factory.record("log", o.name)
+	return nil
+}
+
+func (o *OrderService) Delete(ctx context.Context, options options.Delete) error {
+	o.factory.record("delete", fmt.Sprintf("%s(volumes=%t,running=%t)", o.name, options.RemoveVolume, options.RemoveRunning))
+	return nil
+}
+
+func (o *OrderService) RemoveImage(ctx context.Context, imageType options.ImageType) error {
+	o.factory.record("rmi", fmt.Sprintf("%s(%s)", o.name, imageType))
+	return nil
+}
+
+func (o *OrderService) Up(ctx context.Context, options options.Up) error {
+	o.factory.Lock()
+	o.factory.running++
+	if o.factory.running > o.factory.MaxRunning {
+		o.factory.MaxRunning = o.factory.running
+	}
+	o.factory.Unlock()
+	defer func() {
+		o.factory.Lock()
+		o.factory.running--
+		o.factory.Unlock()
+	}()
+
+	if err := o.factory.UpErrors[o.name]; err != nil {
+		if o.factory.Barrier != nil {
+			o.factory.Barrier.Done()
+			o.factory.Barrier.Wait()
+		}
+		return err
+	}
+	if o.factory.Blocking[o.name] {
+		if o.factory.Barrier != nil {
+			o.factory.Barrier.Done()
+		}
+		<-ctx.Done()
+		// Services may wrap the cancellation
+		return fmt.Errorf("Interrupted while bringing up %s: %w", o.name, ctx.Err())
+	}
+	o.factory.record("up", fmt.Sprintf("%s(force=%t,norecreate=%t)", o.name, options.ForceRecreate, options.NoRecreate))
+	return nil
+}
+
+func (o *OrderService) Plan(ctx context.Context, options options.Up) ([]Action, error) {
+	kind := ActionNone
+	if options.ForceRecreate {
+		kind = ActionRecreate
+	}
+	return []Action{{Service: o.name, Container: o.name + "_1", Kind: kind}}, nil
+}
+
+func (o *OrderService) WaitHealthy(ctx context.Context) error {
+	o.factory.record("healthy", o.name)
+	return o.factory.HealthErrors[o.name]
+}
+
+func (o *OrderService) WaitFor(ctx context.Context, condition string) (map[string]int, error) {
+	o.factory.record("wait("+condition+")", o.name)
+	if condition != WaitConditionExited {
+		return nil, nil
+	}
+	return map[string]int{o.name + "_1": o.factory.ExitCodes[o.name]}, nil
+}
+
+func TestStopInReverseDependencyOrder(t *testing.T) {
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db"}}})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{Links: yaml.MaporColonSlice{"app"}})
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(context.Background(), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{
+		"start:db", "start:app", "start:web",
+		"stop:web", "stop:app", "stop:db",
+	}, factory.Order)
+}
+
+func TestExternalLinksAreNotDependencies(t *testing.T) {
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{ExternalLinks: []string{"legacy_db:db"}})
+
+	service, err := p.CreateService("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, service.DependentServices())
+
+	if err := p.Start(context.Background(), "web"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"start:web"}, factory.Order)
+}
+
+func TestUpCancelled(t *testing.T) {
+	factory := &OrderServiceFactory{Blocking: map[string]bool{"db": true}}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db"}}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	done := make(chan error)
+	go func() {
+		done <- p.Up(ctx, options.Up{})
+	}()
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Up didn't return after the context was cancelled")
+	}
+	assert.Empty(t, factory.Order)
+}
+
+func TestPlan(t *testing.T) {
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	actions, err := p.Plan(context.Background(), options.Up{Create: options.Create{ForceRecreate: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []Action{
+		{Service: "db", Container: "db_1", Kind: ActionRecreate},
+		{Service: "web", Container: "web_1", Kind: ActionRecreate},
+	}, actions)
+	assert.Empty(t, factory.Order)
+
+	_, err = p.Plan(context.Background(), options.Up{RestartPolicy: map[string]string{"cache": "always"}})
+	assert.Error(t, err)
+}
+
+func TestKill(t *testing.T) {
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	if err := p.Kill(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []string{"kill:db(SIGKILL)", "kill:web(SIGKILL)"}, factory.Order)
+
+	for _, signal := range []string{"SIGTERM", "TERM", "15"} {
+		factory.Order = nil
+		if err := p.Kill(context.Background(), signal, "web"); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []string{"kill:web(" + signal + ")"}, factory.Order)
+	}
+
+	factory.Order = nil
+	assert.Error(t, p.Kill(context.Background(), "SIGWHATEVER"))
+	assert.Empty(t, factory.Order)
+}
+
+func TestPauseAndUnpause(t *testing.T) {
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	if err := p.Pause(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []string{"pause:db", "pause:web"}, factory.Order)
+
+	factory.Order = nil
+	if err := p.Unpause(context.Background(), "web"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"unpause:web"}, factory.Order)
+}
+
+func TestUpRestartPolicyValidation(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &TestServiceFactory{Counts: map[string]int{}},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("foo", &config.ServiceConfig{Restart: "always"})
+
+	err := p.Up(context.Background(), options.Up{RestartPolicy: map[string]string{"foo": "sometimes"}})
+	assert.NotNil(t, err)
+
+	err = p.Up(context.Background(), options.Up{RestartPolicy: map[string]string{"bar": "no"}})
+	assert.NotNil(t, err)
+
+	err = p.Up(context.Background(), options.Up{RestartPolicy: map[string]string{"foo": "no"}})
+	assert.Nil(t, err)
+	fooConfig, _ := p.GetServiceConfig("foo")
+	assert.Equal(t, "always", fooConfig.Restart)
+}
+
+func TestLogSkipsDetachedServices(t *testing.T) {
+	attach := false
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{Attach: &attach})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db"}}})
+
+	if err := p.Log(context.Background(), options.Log{}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"log:web"}, factory.Order)
+
+	factory.Order = nil
+	if err := p.Log(context.Background(), options.Log{}, "db"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"log:db"}, factory.Order)
+}
+
+type LogContainer struct {
+	number  int
+	stopped bool
+}
+
+func (l *LogContainer) ID() string {
+	return fmt.Sprintf("id%d", l.number)
+}
+
+func (l *LogContainer) Name() string {
+	return fmt.Sprintf("web_%d", l.number)
+}
+
+func (l *LogContainer) Port(ctx context.Context, port string) (string, error) {
+	return "", nil
+}
+
+func (l *LogContainer) IsRunning(ctx context.Context) bool {
+	return !l.stopped
+}
+
+func (l *LogContainer) Number() (int, error) {
+	return l.number, nil
+}
+
+func (l *LogContainer) LogStream(ctx context.Context, opts options.Log) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(fmt.Sprintf("logs of %s, follow: %v", l.Name(), opts.Follow))), nil
+}
+
+func (l *LogContainer) ExecCommand(ctx context.Context, opts options.Exec) (int, error) {
+	if opts.Command[0] == "false" {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (l *LogContainer) Top(ctx context.Context) ([]ContainerProcess, error) {
+	return []ContainerProcess{{Titles: []string{"PID", "CMD"}, Values: []string{"1", l.Name()}}}, nil
+}
+
+func (l *LogContainer) Inspect(ctx context.Context) (ContainerInfo, error) {
+	state := "running"
+	if l.stopped {
+		state = "exited"
+	}
+	return ContainerInfo{ID: l.ID(), Name: l.Name(), State: state}, nil
+}
+
+type LogService struct {
+	EmptyService
+}
+
+func (l *LogService) Containers(ctx context.Context) ([]Container, error) {
+	return []Container{&LogContainer{number: 2}, &LogContainer{number: 1}}, nil
+}
+
+type LogServiceFactory struct{}
+
+func (l *LogServiceFactory) Create(project *Project, name string, serviceConfig *config.ServiceConfig) (Service, error) {
+	return &LogService{}, nil
+}
+
+func TestLogService(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &LogServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	stream, err := p.LogService(context.Background(), "web", 2, options.Log{Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	content, err := ioutil.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "logs of web_2, follow: true", string(content))
+
+	_, err = p.LogService(context.Background(), "web", 3, options.Log{})
+	assert.NotNil(t, err)
+
+	_, err = p.LogService(context.Background(), "db", 1, options.Log{})
+	assert.NotNil(t, err)
+}
+
+func TestExec(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &LogServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	err := p.Exec(context.Background(), "web", options.Exec{Command: []string{"true"}})
+	assert.Nil(t, err)
+
+	err = p.Exec(context.Background(), "web", options.Exec{Command: []string{"false"}, Index: 2})
+	execErr, ok := err.(*ExecError)
+	if !ok {
+		t.Fatalf("Expected an ExecError, got %v", err)
+	}
+	assert.Equal(t, 1, execErr.ExitCode)
+
+	err = p.Exec(context.Background(), "web", options.Exec{Command: []string{"true"}, Index: 3})
+	assert.EqualError(t, err, "No container found for service web with index 3")
+
+	err = p.Exec(context.Background(), "web", options.Exec{})
+	assert.NotNil(t, err)
+}
+
+type TopService struct {
+	EmptyService
+}
+
+func (t *TopService) Containers(ctx context.Context) ([]Container, error) {
+	return []Container{&LogContainer{number: 1}, &LogContainer{number: 2, stopped: true}}, nil
+}
+
+type TopServiceFactory struct{}
+
+func (t *TopServiceFactory) Create(project *Project, name string, serviceConfig *config.ServiceConfig) (Service, error) {
+	return &TopService{}, nil
+}
+
+func TestTop(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &TopServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	top, err := p.Top(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string][]ContainerProcess{
+		"web_1": {{Titles: []string{"PID", "CMD"}, Values: []string{"1", "web_1"}}},
+	}, top)
+
+	_, err = p.Top(context.Background(), "db")
+	assert.NotNil(t, err)
+}
+
+type RemoveService struct {
+	TopService
+	deleted []options.Delete
+}
+
+func (r *RemoveService) Delete(ctx context.Context, options options.Delete) error {
+	r.deleted = append(r.deleted, options)
+	return nil
+}
+
+type RemoveServiceFactory struct {
+	service *RemoveService
+}
+
+func (r *RemoveServiceFactory) Create(project *Project, name string, serviceConfig *config.ServiceConfig) (Service, error) {
+	return r.service, nil
+}
+
+func TestRemove(t *testing.T) {
+	service := &RemoveService{}
+	p := NewProject(&Context{
+		ServiceFactory: &RemoveServiceFactory{service: service},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	err := p.Remove(context.Background(), options.Delete{RemoveVolume: true})
+	skippedErr, ok := err.(*SkippedContainersError)
+	if !ok {
+		t.Fatalf("Expected a SkippedContainersError, got %v", err)
+	}
+	assert.Equal(t, []string{"web_1"}, skippedErr.Containers)
+	assert.Equal(t, "Skipped running containers web_1, stop them first or set RemoveRunning", err.Error())
+	assert.Equal(t, []options.Delete{{RemoveVolume: true}}, service.deleted)
+
+	assert.Nil(t, p.Remove(context.Background(), options.Delete{RemoveRunning: true}, "web"))
+	assert.Equal(t, options.Delete{RemoveRunning: true}, service.deleted[1])
+
+	assert.NotNil(t, p.Remove(context.Background(), options.Delete{}, "db"))
+}
+
+func TestList(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &TopServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	containers, err := p.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []ContainerInfo{
+		{ID: "id1", Name: "web_1", Service: "web", State: "running"},
+		{ID: "id2", Name: "web_2", Service: "web", State: "exited"},
+	}, containers)
+
+	_, err = p.List(context.Background(), "db")
+	assert.NotNil(t, err)
+}
+
+type EventService struct {
+	EmptyService
+	name string
+}
+
+func (e *EventService) Name() string {
+	return e.name
+}
+
+func (e *EventService) Events(ctx context.Context, evts chan events.ContainerEvent) error {
+	evts <- events.ContainerEvent{Service: e.name, Event: "start"}
+	<-ctx.Done()
+	return nil
+}
+
+type EventServiceFactory struct{}
+
+func (e *EventServiceFactory) Create(project *Project, name string, serviceConfig *config.ServiceConfig) (Service, error) {
+	return &EventService{name: name}, nil
+}
+
+func TestEvents(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &EventServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	evts, err := p.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	services := []string{}
+	for i := 0; i < 2; i++ {
+		e := <-evts
+		assert.Equal(t, "start", e.Event)
+		services = append(services, e.Service)
+	}
+	assert.ElementsMatch(t, []string{"db", "web"}, services)
+
+	cancel()
+	select {
+	case _, ok := <-evts:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("Expected the events chan to be closed")
+	}
+}
+
+type PullServiceFactory struct {
+	sync.Mutex
+	active    int
+	maxActive int
+	pulled    []string
+	errors    map[string]error
+}
+
+type PullService struct {
+	EmptyService
+	factory *PullServiceFactory
+	name    string
+}
+
+func (f *PullServiceFactory) Create(project *Project, name string, serviceConfig *config.ServiceConfig) (Service, error) {
+	return &PullService{factory: f, name: name}, nil
+}
+
+func (s *PullService) Name() string {
+	return s.name
+}
+
+func (s *PullService) Pull(ctx context.Context) error {
+	s.factory.Lock()
+	s.factory.active++
+	if s.factory.active > s.factory.maxActive {
+		s.factory.maxActive = s.factory.active
+	}
+	s.factory.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.factory.Lock()
+	defer s.factory.Unlock()
+	s.factory.active--
+	if err := s.factory.errors[s.name]; err != nil {
+		return err
+	}
+	s.factory.pulled = append(s.factory.pulled, s.name)
+	return nil
+}
+
+func TestPullParallelism(t *testing.T) {
+	factory := &PullServiceFactory{}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		p.ServiceConfigs.Add(name, &config.ServiceConfig{})
+	}
+
+	if err := p.Pull(context.Background(), options.Pull{Parallelism: 2}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, factory.pulled, 5)
+	assert.Equal(t, 2, factory.maxActive)
+
+	err := p.Pull(context.Background(), options.Pull{}, "f")
+	assert.NotNil(t, err)
+}
+
+func TestPullFailure(t *testing.T) {
+	factory := &PullServiceFactory{errors: map[string]error{"a": fmt.Errorf("not found")}}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("a", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("b", &config.ServiceConfig{})
+
+	err := p.Pull(context.Background(), options.Pull{Parallelism: 1, IgnoreFailures: true})
+	assert.EqualError(t, err, "Failed to pull services: a: not found")
+	assert.Equal(t, []string{"b"}, factory.pulled)
+}
+
+func TestParseWithoutComposeFiles(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tmpDir, err := ioutil.TempDir("", "project-no-compose-files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProject(&Context{}, nil, nil)
+	err = p.Parse()
+	noComposeFiles, ok := err.(*NoComposeFilesError)
+	if !ok {
+		t.Fatalf("Expected a NoComposeFilesError, got %v", err)
+	}
+	assert.Len(t, noComposeFiles.Searched, len(DefaultComposeFiles))
+	assert.Contains(t, err.Error(), "compose.yaml")
+
+	p = NewProject(&Context{IgnoreMissingConfig: true}, nil, nil)
+	assert.Nil(t, p.Parse())
+
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "compose.yaml"), []byte("version: '2'\nservices:\n  web:\n    image: busybox\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "compose.override.yaml"), []byte("version: '2'\nservices:\n  web:\n    command: top\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p = NewProject(&Context{}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"compose.yaml", "compose.override.yaml"}, p.Files)
+	web, _ := p.GetServiceConfig("web")
+	assert.Equal(t, "busybox", web.Image)
+	assert.Equal(t, yaml.Command{"top"}, web.Command)
+
+	p = NewProject(&Context{DisableOverrideFile: true}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"compose.yaml"}, p.Files)
+	web, _ = p.GetServiceConfig("web")
+	assert.Empty(t, web.Command)
+}
+
+func TestComposeEnvironmentVariables(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "project-compose-env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	base := filepath.Join(tmpDir, "base.yml")
+	override := filepath.Join(tmpDir, "override.yml")
+	if err := ioutil.WriteFile(base, []byte("version: '2'\nservices:\n  web:\n    image: busybox\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(override, []byte("version: '2'\nservices:\n  web:\n    command: top\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("COMPOSE_FILE", base+","+override)
+	os.Setenv("COMPOSE_PATH_SEPARATOR", ",")
+	os.Setenv("COMPOSE_PROJECT_NAME", "fromenv")
+	defer os.Unsetenv("COMPOSE_FILE")
+	defer os.Unsetenv("COMPOSE_PATH_SEPARATOR")
+	defer os.Unsetenv("COMPOSE_PROJECT_NAME")
+
+	p := NewProject(&Context{}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{base, override}, p.Files)
+	assert.Equal(t, "fromenv", p.Name)
+	web, _ := p.GetServiceConfig("web")
+	assert.Equal(t, "busybox", web.Image)
+	assert.Equal(t, yaml.Command{"top"}, web.Command)
+
+	// Explicit values in the context win
+	p = NewProject(&Context{
+		ComposeFiles: []string{base},
+		ProjectName:  "explicit",
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{base}, p.Files)
+	assert.Equal(t, "explicit", p.Name)
+}
+
+func TestComposeFileFromStdin(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "project-compose-stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	override := filepath.Join(tmpDir, "override.yml")
+	if err := ioutil.WriteFile(override, []byte("version: '2'\nservices:\n  web:\n    command: top\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	if _, err := w.Write([]byte("version: '2'\nservices:\n  web:\n    build: ./app\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	p := NewProject(&Context{
+		ComposeFiles: []string{"-", override},
+		ProjectName:  "stdin",
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	web, _ := p.GetServiceConfig("web")
+	// Relative to the working directory
+	assert.Equal(t, "app", web.Build.Context)
+	assert.Equal(t, yaml.Command{"top"}, web.Command)
+
+	p = NewProject(&Context{
+		ComposeFiles: []string{"-", override, "-"},
+		ProjectName:  "stdin",
+	}, nil, nil)
+	err = p.Parse()
+	if err == nil || !strings.Contains(err.Error(), "stdin") {
+		t.Fatalf("Expected an error about stdin being used twice, got %v", err)
+	}
+}
+
+type RecordingNetworksFactory struct {
+	created bool
+	removed bool
+}
+
+func (r *RecordingNetworksFactory) Create(projectName string, networkConfigs map[string]*config.NetworkConfig, serviceConfigs *config.ServiceConfigs, networkEnabled bool) (Networks, error) {
+	r.created = true
+	return &RecordingNetworks{factory: r}, nil
+}
+
+type RecordingNetworks struct {
+	EmptyNetworks
+	factory *RecordingNetworksFactory
+}
+
+func (r *RecordingNetworks) Remove(ctx context.Context) error {
+	r.factory.removed = true
+	return nil
+}
+
+func TestDisableNetworks(t *testing.T) {
+	composeBytes := []byte(`version: '2'
+services:
+  web:
+    image: busybox
+    networks:
+      - front
+  db:
+    image: busybox
+networks:
+  front: {}
+`)
+
+	factory := &RecordingNetworksFactory{}
+	p := NewProject(&Context{
+		ComposeBytes:    [][]byte{composeBytes},
+		NetworksFactory: factory,
+		ProjectName:     "test",
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, factory.created)
+	db, _ := p.ServiceConfigs.Get("db")
+	assert.Equal(t, "test_default", db.Networks.Networks[0].RealName)
+
+	factory = &RecordingNetworksFactory{}
+	p = NewProject(&Context{
+		ComposeBytes:    [][]byte{composeBytes},
+		NetworksFactory: factory,
+		ProjectName:     "test",
+		DisableNetworks: true,
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, factory.created)
+	for _, name := range []string{"web", "db"} {
+		serviceConfig, _ := p.ServiceConfigs.Get(name)
+		assert.Nil(t, serviceConfig.Networks, name)
+	}
+}
+
+func TestServiceImage(t *testing.T) {
+	p := NewProject(&Context{}, nil, nil)
+	p.Name = "myproject"
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("short", &config.ServiceConfig{Image: "busybox"})
+	p.ServiceConfigs.Add("tagged", &config.ServiceConfig{Image: "user/app:1.2"})
+	p.ServiceConfigs.Add("registry", &config.ServiceConfig{Image: "registry.example.com:5000/team/app"})
+	p.ServiceConfigs.Add("digest", &config.ServiceConfig{Image: "alpine@sha256:e4355b66995c96b4b468159fc5c7e3540fcef961189ca13fee877798649f531a"})
+	p.ServiceConfigs.Add("built", &config.ServiceConfig{Build: yaml.Build{Context: "."}})
+	p.ServiceConfigs.Add("invalid", &config.ServiceConfig{Image: "UPPER/case"})
+	p.ServiceConfigs.Add("nothing", &config.ServiceConfig{})
+
+	expected := map[string]string{
+		"short":    "docker.io/library/busybox:latest",
+		"tagged":   "docker.io/user/app:1.2",
+		"registry": "registry.example.com:5000/team/app:latest",
+		"digest":   "docker.io/library/alpine@sha256:e4355b66995c96b4b468159fc5c7e3540fcef961189ca13fee877798649f531a",
+		"built":    "docker.io/library/myproject_built:latest",
+	}
+	for name, image := range expected {
+		actual, err := p.ServiceImage(name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, image, actual, name)
+	}
+
+	for _, name := range []string{"invalid", "nothing", "unknown"} {
+		_, err := p.ServiceImage(name)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestUpRecreateDeps(t *testing.T) {
+	cases := []struct {
+		recreateDeps string
+		services     []string
+		expected     []string
+	}{
+		{"", nil, []string{"up:base(force=true,norecreate=false)", "up:db(force=true,norecreate=false)", "up:app(force=true,norecreate=false)"}},
+		{"never", nil, []string{"up:base(force=true,norecreate=false)", "up:db(force=true,norecreate=false)", "up:app(force=true,norecreate=false)"}},
+		{"", []string{"app"}, []string{"up:base(force=false,norecreate=false)", "up:db(force=false,norecreate=false)", "up:app(force=true,norecreate=false)"}},
+		{"always", []string{"app"}, []string{"up:base(force=true,norecreate=false)", "up:db(force=true,norecreate=false)", "up:app(force=true,norecreate=false)"}},
+		{"never", []string{"app"}, []string{"up:base(force=false,norecreate=true)", "up:db(force=false,norecreate=true)", "up:app(force=true,norecreate=false)"}},
+		{"", []string{"db", "app"}, []string{"up:base(force=false,norecreate=false)", "up:db(force=true,norecreate=false)", "up:app(force=true,norecreate=false)"}},
+	}
+
+	for _, c := range cases {
+		factory := &OrderServiceFactory{}
+		p := NewProject(&Context{
+			ServiceFactory: factory,
+		}, nil, nil)
+		p.ServiceConfigs = config.NewServiceConfigs()
+		p.ServiceConfigs.Add("base", &config.ServiceConfig{})
+		p.ServiceConfigs.Add("db", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "base"}}})
+		p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db"}}})
+
+		err := p.Up(context.Background(), options.Up{
+			Create:       options.Create{ForceRecreate: true},
+			RecreateDeps: c.recreateDeps,
+		}, c.services...)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, factory.Order)
+	}
+
+	p := NewProject(&Context{
+		ServiceFactory: &OrderServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	err := p.Up(context.Background(), options.Up{RecreateDeps: "sometimes"})
+	assert.NotNil(t, err)
+	err = p.Up(context.Background(), options.Up{Create: options.Create{NoRecreate: true}, RecreateDeps: "always"})
+	assert.NotNil(t, err)
+}
+
+func TestCreatePullPolicyValidation(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+
+	err := p.Up(context.Background(), options.Up{Create: options.Create{PullPolicy: "sometimes"}})
+	assert.NotNil(t, err)
+	err = p.Create(context.Background(), options.Create{PullPolicy: "sometimes"})
+	assert.NotNil(t, err)
+	assert.Empty(t, factory.Order)
+
+	err = p.Up(context.Background(), options.Up{Create: options.Create{PullPolicy: config.PullPolicyNever}})
+	assert.Nil(t, err)
+}
+
+func TestUpNoRecreateAndForceRecreate(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+
+	err := p.Up(context.Background(), options.Up{Create: options.Create{NoRecreate: true, ForceRecreate: true}})
+	assert.EqualError(t, err, "no-recreate and force-recreate cannot be combined")
+	assert.Empty(t, factory.Order)
+
+	err = p.Up(context.Background(), options.Up{Create: options.Create{NoRecreate: true}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"up:db(force=false,norecreate=true)"}, factory.Order)
+}
+
+func TestUpProfiles(t *testing.T) {
+	cases := []struct {
+		profiles []string
+		services []string
+		expected []string
+	}{
+		{nil, nil, []string{"up:web(force=false,norecreate=false)"}},
+		{[]string{"debug"}, nil, []string{"up:web(force=false,norecreate=false)", "up:debugger(force=false,norecreate=false)"}},
+		{[]string{"test"}, nil, []string{"up:web(force=false,norecreate=false)", "up:tests(force=false,norecreate=false)"}},
+		{nil, []string{"debugger"}, []string{"up:web(force=false,norecreate=false)", "up:debugger(force=false,norecreate=false)"}},
+	}
+
+	for _, c := range cases {
+		factory := &OrderServiceFactory{}
+		p := NewProject(&Context{
+			ServiceFactory: factory,
+			Profiles:       c.profiles,
+		}, nil, nil)
+		p.ServiceConfigs = config.NewServiceConfigs()
+		p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+		p.ServiceConfigs.Add("debugger", &config.ServiceConfig{Profiles: []string{"debug"}, DependsOn: yaml.DependsOn{{Service: "web"}}})
+		p.ServiceConfigs.Add("tests", &config.ServiceConfig{Profiles: []string{"test", "ci"}, DependsOn: yaml.DependsOn{{Service: "web"}}})
+
+		err := p.Up(context.Background(), options.Up{}, c.services...)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, factory.Order, "profiles %v, services %v", c.profiles, c.services)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{Image: "postgres"})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{Build: yaml.Build{Context: "."}, DependsOn: yaml.DependsOn{{Service: "db"}}})
+	p.ServiceConfigs.Add("tools", &config.ServiceConfig{})
+
+	if err := p.Build(context.Background(), options.Build{NoCache: true, Pull: true, ContextReaders: map[string]io.Reader{"tools": strings.NewReader("")}}); err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []string{"build:web(nocache=true,pull=true)", "build:tools(nocache=true,pull=true)"}, factory.Order)
+
+	factory.Order = nil
+	if err := p.Build(context.Background(), options.Build{}, "db"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, factory.Order)
+}
+
+func TestCreateThenStart(t *testing.T) {
+	factory := &OrderServiceFactory{}
+
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+		Profiles:       []string{"backend"},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{Profiles: []string{"backend"}})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db"}}})
+	p.ServiceConfigs.Add("debug", &config.ServiceConfig{Profiles: []string{"debug"}})
+
+	if err := p.Create(context.Background(), options.Create{ForceRecreate: true}); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{
+		"create:db(force=true,norecreate=false)",
+		"create:web(force=true,norecreate=false)",
+	}, factory.Order)
+
+	factory.Order = nil
+	if err := p.Start(context.Background(), "db", "web"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"start:db", "start:web"}, factory.Order)
+
+	factory.Order = nil
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"start:db", "start:web"}, factory.Order)
+}
+
+func TestUpWaitsForHealthyDependencies(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db", Condition: yaml.ConditionServiceHealthy}}})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "app"}}})
+
+	err := p.Up(context.Background(), options.Up{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"up:db(force=false,norecreate=false)",
+		"healthy:db",
+		"up:app(force=false,norecreate=false)",
+		"up:web(force=false,norecreate=false)",
+	}, factory.Order)
+
+	factory = &OrderServiceFactory{HealthErrors: map[string]error{"db": fmt.Errorf("Container db is unhealthy")}}
+	p = NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db", Condition: yaml.ConditionServiceHealthy}}})
+	err = p.Up(context.Background(), options.Up{})
+	assert.EqualError(t, err, "Dependency db of service app is not healthy: Container db is unhealthy")
+	assert.NotContains(t, factory.Order, "up:app(force=false,norecreate=false)")
+}
+
+func TestUpWaitsForCompletedDependencies(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("migrate", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "migrate", Condition: yaml.ConditionServiceCompletedSuccessfully}}})
+
+	err := p.Up(context.Background(), options.Up{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"up:migrate(force=false,norecreate=false)",
+		"wait(exited):migrate",
+		"up:app(force=false,norecreate=false)",
+	}, factory.Order)
+
+	factory = &OrderServiceFactory{ExitCodes: map[string]int{"migrate": 2}}
+	p = NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("migrate", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "migrate", Condition: yaml.ConditionServiceCompletedSuccessfully}}})
+	err = p.Up(context.Background(), options.Up{})
+	assert.EqualError(t, err, "Dependency migrate of service app didn't complete successfully: migrate_1 exited with code 2")
+	assert.NotContains(t, factory.Order, "up:app(force=false,norecreate=false)")
+}
+
+func TestUpDependencyCycle(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &OrderServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "app"}}})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "db", Condition: yaml.ConditionServiceHealthy}}})
+
+	err := p.Up(context.Background(), options.Up{})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Cycle detected in path")
+}
+
+func TestRemoveStopped(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{})
+
+	if err := p.RemoveStopped(context.Background(), true, "app"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"delete:app(volumes=true,running=false)"}, factory.Order)
+}
+
+func TestUpParallelism(t *testing.T) {
+	for _, parallelism := range []int{1, 2, 0} {
+		factory := &OrderServiceFactory{}
+		p := NewProject(&Context{
+			ServiceFactory: factory,
+		}, nil, nil)
+		p.ServiceConfigs = config.NewServiceConfigs()
+		for _, name := range []string{"a", "b", "c", "d"} {
+			p.ServiceConfigs.Add(name, &config.ServiceConfig{})
+		}
+		p.ServiceConfigs.Add("web", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "a"}, {Service: "b"}, {Service: "c"}, {Service: "d"}}})
+
+		if err := p.Up(context.Background(), options.Up{Parallelism: parallelism}); err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, factory.Order, 5)
+		assert.Equal(t, "up:web(force=false,norecreate=false)", factory.Order[4])
+		if parallelism > 0 {
+			assert.True(t, factory.MaxRunning <= parallelism, "parallelism %d, got %d services at once", parallelism, factory.MaxRunning)
+		} else {
+			assert.True(t, factory.MaxRunning > 1, "expected services to be brought up concurrently")
+		}
+	}
+}
+
+func TestUpFailureCancelsOtherServices(t *testing.T) {
+	factory := &OrderServiceFactory{
+		UpErrors: map[string]error{"db": fmt.Errorf("boom")},
+		Blocking: map[string]bool{"cache": true},
+	}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("cache", &config.ServiceConfig{})
+
+	done := make(chan error)
+	go func() {
+		done <- p.Up(context.Background(), options.Up{})
+	}()
+	select {
+	case err := <-done:
+		assert.EqualError(t, err, "boom")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Up didn't cancel the other services after a failure")
+	}
+
+	err := (&UpError{Services: map[string]error{"web": fmt.Errorf("boom"), "db": fmt.Errorf("bang")}}).Error()
+	assert.Equal(t, "Failed to bring up services: db: bang, web: boom", err)
+}
+
+func TestUpSeveralFailures(t *testing.T) {
+	barrier := &sync.WaitGroup{}
+	barrier.Add(3)
+	factory := &OrderServiceFactory{
+		UpErrors: map[string]error{"db": fmt.Errorf("bang"), "web": fmt.Errorf("boom")},
+		Blocking: map[string]bool{"cache": true},
+		Barrier:  barrier,
+	}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("cache", &config.ServiceConfig{})
+
+	err := p.Up(context.Background(), options.Up{Parallelism: 3})
+	assert.EqualError(t, err, "Failed to bring up services: db: bang, web: boom")
+}
+
+func TestUpIgnoreBuildFailures(t *testing.T) {
+	buildErr := &BuildError{Service: "tools", Err: fmt.Errorf("boom")}
+	newProject := func() (*Project, *OrderServiceFactory) {
+		factory := &OrderServiceFactory{UpErrors: map[string]error{"tools": buildErr}}
+		p := NewProject(&Context{
+			ServiceFactory: factory,
+		}, nil, nil)
+		p.ServiceConfigs = config.NewServiceConfigs()
+		p.ServiceConfigs.Add("app", &config.ServiceConfig{})
+		p.ServiceConfigs.Add("tools", &config.ServiceConfig{})
+		return p, factory
+	}
+
+	p, _ := newProject()
+	err := p.Up(context.Background(), options.Up{})
+	assert.Equal(t, buildErr, err)
+
+	p, factory := newProject()
+	listener := make(chan events.Event, 100)
+	p.AddListener(listener)
+	err = p.Up(context.Background(), options.Up{IgnoreBuildFailures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"up:app(force=false,norecreate=false)"}, factory.Order)
+	skipped := map[string]string{}
+	for len(listener) > 0 {
+		if event := <-listener; event.EventType == events.ServiceUpIgnored {
+			skipped[event.ServiceName] = event.Data["error"]
+		}
+	}
+	assert.Equal(t, map[string]string{"tools": buildErr.Error()}, skipped)
+
+	factory.UpErrors["app"] = fmt.Errorf("not a build failure")
+	err = p.Up(context.Background(), options.Up{IgnoreBuildFailures: true})
+	assert.EqualError(t, err, "not a build failure")
+}
+
+func TestRestartDependents(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("config", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("app", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "config", Restart: true}}})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "app", Restart: true}}})
+	p.ServiceConfigs.Add("worker", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "config"}}})
+
+	if err := p.Restart(context.Background(), 10, "config"); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"restart:config", "restart:app", "restart:web"}, factory.Order)
+}
+
+func TestServiceConfigJSON(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory:    &TestServiceFactory{Counts: map[string]int{}},
+		EnvironmentLookup: &TestEnvironmentLookup{},
+	}, nil, nil)
+	if err := p.Load([]byte(`
+version: '2'
+services:
+  base:
+    image: busybox
+    environment:
+      FOO: foo
+  web:
+    extends: base
+    ports:
+      - "8080:80"
+    mem_limit: 1g
+`)); err != nil {
+		t.Fatal(err)
+	}
+
+	bytes, err := p.ServiceConfigJSON("web")
+	assert.Nil(t, err)
+	var web map[string]interface{}
+	assert.Nil(t, json.Unmarshal(bytes, &web))
+	assert.Equal(t, "busybox", web["image"])
+	assert.Equal(t, []interface{}{"FOO=foo"}, web["environment"])
+	assert.Equal(t, []interface{}{"8080:80"}, web["ports"])
+	assert.Equal(t, float64(1024*1024*1024), web["mem_limit"])
+
+	_, err = p.ServiceConfigJSON("db")
+	assert.NotNil(t, err)
+}
+
+func TestDownKeepNetworks(t *testing.T) {
+	for _, keepNetworks := range []bool{false, true} {
+		networksFactory := &RecordingNetworksFactory{}
+		p := NewProject(&Context{
+			ServiceFactory:  &OrderServiceFactory{},
+			NetworksFactory: networksFactory,
+		}, nil, nil)
+		p.ServiceConfigs = config.NewServiceConfigs()
+		p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+		if err := p.Down(context.Background(), options.Down{KeepNetworks: keepNetworks}); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, !keepNetworks, networksFactory.removed)
+	}
+}
+
+func TestScopedDownKeepsNetworksAndVolumes(t *testing.T) {
+	cases := []struct {
+		opts     options.Down
+		services []string
+		removed  bool
+	}{
+		{options.Down{RemoveVolume: true}, nil, true},
+		{options.Down{RemoveVolume: true, Profiles: []string{"debug"}}, nil, true},
+		{options.Down{RemoveVolume: true, Profiles: []string{"debug"}}, []string{"web"}, false},
+		{options.Down{RemoveVolume: true, Profiles: []string{"debug"}, LabelSelector: options.LabelSelector{"tier": "frontend"}}, nil, false},
+	}
+
+	for _, c := range cases {
+		networksFactory := &RecordingNetworksFactory{}
+		volumesFactory := &RecordingVolumesFactory{}
+		p := NewProject(&Context{
+			ServiceFactory:  &OrderServiceFactory{},
+			NetworksFactory: networksFactory,
+			VolumesFactory:  volumesFactory,
+		}, nil, nil)
+		p.ServiceConfigs = config.NewServiceConfigs()
+		p.ServiceConfigs.Add("web", &config.ServiceConfig{Labels: yaml.SliceorMap{"tier": "frontend"}})
+		p.ServiceConfigs.Add("debug", &config.ServiceConfig{Profiles: []string{"debug"}})
+
+		if err := p.Down(context.Background(), c.opts, c.services...); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, c.removed, networksFactory.removed, "options %v, services %v", c.opts, c.services)
+		assert.Equal(t, c.removed, volumesFactory.removed, "options %v, services %v", c.opts, c.services)
+	}
+}
+
+type RecordingVolumesFactory struct {
+	removed bool
+}
+
+func (r *RecordingVolumesFactory) Create(projectName string, volumeConfigs map[string]*config.VolumeConfig, serviceConfigs *config.ServiceConfigs, volumeEnabled bool) (Volumes, error) {
+	return &RecordingVolumes{factory: r}, nil
+}
+
+type RecordingVolumes struct {
+	factory *RecordingVolumesFactory
+}
+
+func (r *RecordingVolumes) Initialize(ctx context.Context) error {
+	return nil
+}
+
+func (r *RecordingVolumes) Remove(ctx context.Context) error {
+	r.factory.removed = true
+	return nil
+}
+
+type OrphansRuntime struct {
+	removed []string
+}
+
+func (r *OrphansRuntime) RemoveOrphans(ctx context.Context, projectName string, serviceConfigs *config.ServiceConfigs) error {
+	r.removed = append(r.removed, projectName)
+	return nil
+}
+
+func TestDownRemoveVolumesImagesAndOrphans(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	volumesFactory := &RecordingVolumesFactory{}
+	runtime := &OrphansRuntime{}
+	p := NewProject(&Context{
+		ProjectName:     "prj",
+		ServiceFactory:  factory,
+		VolumesFactory:  volumesFactory,
+		NetworksFactory: &RecordingNetworksFactory{},
+	}, runtime, nil)
+	p.Name = "prj"
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	err := p.Down(context.Background(), options.Down{
+		RemoveVolume:  true,
+		RemoveImages:  "local",
+		RemoveOrphans: true,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"stop:web", "delete:web(volumes=true,running=false)", "rmi:web(local)"}, factory.Order)
+	assert.True(t, volumesFactory.removed)
+	assert.Equal(t, []string{"prj"}, runtime.removed)
+
+	factory.Order = nil
+	volumesFactory.removed = false
+	err = p.Down(context.Background(), options.Down{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"stop:web", "delete:web(volumes=false,running=false)"}, factory.Order)
+	assert.False(t, volumesFactory.removed)
+
+	err = p.Down(context.Background(), options.Down{RemoveImages: "some"})
+	assert.EqualError(t, err, "--rmi flag must be local, all or empty")
+}
+
+func TestUpRemoveOrphans(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	runtime := &OrphansRuntime{}
+	p := NewProject(&Context{
+		ProjectName:    "prj",
+		ServiceFactory: factory,
+	}, runtime, nil)
+	p.Name = "prj"
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+
+	assert.Nil(t, p.Up(context.Background(), options.Up{}))
+	assert.Empty(t, runtime.removed)
+
+	assert.Nil(t, p.Up(context.Background(), options.Up{RemoveOrphans: true}))
+	assert.Equal(t, []string{"prj"}, runtime.removed)
+
+	factory.UpErrors = map[string]error{"web": fmt.Errorf("boom")}
+	runtime.removed = nil
+	assert.Error(t, p.Up(context.Background(), options.Up{RemoveOrphans: true}))
+	assert.Empty(t, runtime.removed)
+}
+
+func TestScaleWithContainerName(t *testing.T) {
+	p := NewProject(&Context{
+		ServiceFactory: &OrderServiceFactory{},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{ContainerName: "front"})
+
+	err := p.Scale(context.Background(), 10, map[string]int{"web": 2})
+	assert.EqualError(t, err, "Service web uses the custom container name front, remove it to scale the service")
+
+	err = p.Scale(context.Background(), 10, map[string]int{"web": 1})
+	assert.Nil(t, err)
+}
+
+func TestConfigIsStable(t *testing.T) {
+	composeBytes := []byte(`version: '2'
+services:
+  web:
+    image: nginx:${TAG}
+    environment:
+      D: d
+      B: b
+      A: a
+      C: c
+  db:
+    image: postgres
+    extends:
+      service: web
+volumes:
+  data: {}
+`)
+
+	var expected string
+	for i := 0; i < 10; i++ {
+		p := NewProject(&Context{
+			ComposeBytes:      [][]byte{composeBytes},
+			EnvironmentLookup: &TestEnvironmentLookup{},
+		}, nil, nil)
+		if err := p.Parse(); err != nil {
+			t.Fatal(err)
+		}
+		output, err := p.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			expected = output
+			continue
+		}
+		assert.Equal(t, expected, output)
+	}
+	assert.Contains(t, expected, `  db:
+    environment:
+    - A=a
+    - B=b
+    - C=c
+    - D=d
+    image: postgres
+    networks:
+`)
+	assert.Contains(t, expected, "image: nginx:X")
+	assert.NotContains(t, expected, "extends")
+	assert.Contains(t, expected, "volumes:\n  data: {}\n")
+}
+
+func TestWriteConfig(t *testing.T) {
+	p := NewProject(&Context{
+		ComposeBytes: [][]byte{[]byte(`version: '2'
+services:
+  web:
+    image: nginx
+    ports:
+      - "80:80"
+`)},
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buffer bytes.Buffer
+	if err := p.WriteConfig(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	output := buffer.String()
+	assert.True(t, strings.HasPrefix(output, "version: \"2.0\"\nservices:\n  web:\n"), output)
+	assert.Contains(t, output, "    image: nginx\n")
+	assert.NotContains(t, output, "\nvolumes:")
+
+	config, err := p.Config()
+	assert.Nil(t, err)
+	assert.Equal(t, output, config)
+
+	// The written configuration can be parsed back
+	reparsed := NewProject(&Context{ComposeBytes: [][]byte{buffer.Bytes()}}, nil, nil)
+	assert.Nil(t, reparsed.Parse())
+	web, _ := reparsed.ServiceConfigs.Get("web")
+	assert.Equal(t, []string{"80:80"}, web.Ports)
+}
+
+func TestWaitFor(t *testing.T) {
+	factory := &OrderServiceFactory{ExitCodes: map[string]int{"migrate": 0, "seed": 3}}
+	p := NewProject(&Context{ServiceFactory: factory}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("migrate", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("seed", &config.ServiceConfig{})
+
+	err := p.WaitFor(context.Background(), "stopped")
+	assert.EqualError(t, err, `Invalid condition "stopped", must be one of running, healthy or exited`)
+
+	assert.Nil(t, p.WaitFor(context.Background(), WaitConditionRunning, "migrate"))
+	assert.Equal(t, []string{"wait(running):migrate"}, factory.Order)
+
+	assert.Nil(t, p.WaitFor(context.Background(), WaitConditionExited, "migrate"))
+
+	err = p.WaitFor(context.Background(), WaitConditionExited)
+	exitErr, ok := err.(*ExitCodesError)
+	if !ok {
+		t.Fatalf("Expected an ExitCodesError, got %v", err)
+	}
+	assert.Equal(t, map[string]int{"migrate_1": 0, "seed_1": 3}, exitErr.ExitCodes)
+	assert.EqualError(t, err, "seed_1 exited with code 3")
+}
+
+func TestLabelSelector(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{ServiceFactory: factory}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{Labels: yaml.SliceorMap{"tier": "backend"}})
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{Labels: yaml.SliceorMap{"tier": "frontend"}})
+	p.ServiceConfigs.Add("admin", &config.ServiceConfig{Labels: yaml.SliceorMap{"tier": "frontend"}})
+
+	err := p.Up(context.Background(), options.Up{Create: options.Create{LabelSelector: options.LabelSelector{"tier": "frontend"}}})
+	assert.Nil(t, err)
+	sort.Strings(factory.Order)
+	assert.Equal(t, []string{
+		"up:admin(force=false,norecreate=false)",
+		"up:web(force=false,norecreate=false)",
+	}, factory.Order)
+
+	selected, err := p.SelectServices(options.LabelSelector{"tier": "frontend"}, "db", "web")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"web"}, selected)
+
+	selected, err = p.SelectServices(nil, "db")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db"}, selected)
+
+	factory.Order = nil
+	err = p.Down(context.Background(), options.Down{LabelSelector: options.LabelSelector{"tier": "cache"}})
+	assert.EqualError(t, err, "No service matches the label selector tier=cache")
+	assert.Empty(t, factory.Order)
+}
+
+func TestLabelSelectorWithProfiles(t *testing.T) {
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{ServiceFactory: factory}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{Labels: yaml.SliceorMap{"tier": "frontend"}})
+	p.ServiceConfigs.Add("debug", &config.ServiceConfig{Labels: yaml.SliceorMap{"tier": "frontend"}, Profiles: []string{"debug"}})
+	selector := options.LabelSelector{"tier": "frontend"}
+
+	err := p.Up(context.Background(), options.Up{Create: options.Create{LabelSelector: selector}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"up:web(force=false,norecreate=false)"}, factory.Order)
+
+	factory.Order = nil
+	err = p.Create(context.Background(), options.Create{LabelSelector: selector})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"create:web(force=false,norecreate=false)"}, factory.Order)
+
+	factory.Order = nil
+	err = p.Up(context.Background(), options.Up{Create: options.Create{LabelSelector: selector}, Profiles: []string{"debug"}})
+	assert.Nil(t, err)
+	sort.Strings(factory.Order)
+	assert.Equal(t, []string{
+		"up:debug(force=false,norecreate=false)",
+		"up:web(force=false,norecreate=false)",
+	}, factory.Order)
+}
+
+func TestProfilesFromOptionsAndEnvironment(t *testing.T) {
+	defer os.Setenv("COMPOSE_PROFILES", os.Getenv("COMPOSE_PROFILES"))
+	os.Setenv("COMPOSE_PROFILES", "debug, ")
+
+	factory := &OrderServiceFactory{}
+	p := NewProject(&Context{ServiceFactory: factory}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("debug", &config.ServiceConfig{Profiles: []string{"debug"}})
+	p.ServiceConfigs.Add("seed", &config.ServiceConfig{Profiles: []string{"tools"}})
+	p.ServiceConfigs.Add("bench", &config.ServiceConfig{Profiles: []string{"perf"}})
+
+	err := p.Up(context.Background(), options.Up{Profiles: []string{"tools"}})
+	assert.Nil(t, err)
+	sort.Strings(factory.Order)
+	assert.Equal(t, []string{
+		"up:debug(force=false,norecreate=false)",
+		"up:seed(force=false,norecreate=false)",
+		"up:web(force=false,norecreate=false)",
+	}, factory.Order)
+
+	factory.Order = nil
+	err = p.Down(context.Background(), options.Down{})
+	assert.Nil(t, err)
+	sort.Strings(factory.Order)
+	assert.Equal(t, []string{
+		"delete:debug(volumes=false,running=false)",
+		"delete:web(volumes=false,running=false)",
+		"stop:debug",
+		"stop:web",
+	}, factory.Order)
+}
+
+func TestServices(t *testing.T) {
+	p := NewProject(&Context{}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{Links: []string{"db"}, DependsOn: yaml.DependsOn{{Service: "cache"}}})
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{VolumesFrom: []string{"data"}})
+	p.ServiceConfigs.Add("data", &config.ServiceConfig{})
+	p.ServiceConfigs.Add("cache", &config.ServiceConfig{NetworkMode: "service:data"})
+	p.ServiceConfigs.Add("admin", &config.ServiceConfig{Links: []string{"missing"}})
+
+	assert.Equal(t, []string{"admin", "data", "cache", "db", "web"}, p.ServiceNames())
+
+	services := p.Services()
+	assert.Len(t, services, 5)
+	for i, name := range p.ServiceNames() {
+		serviceConfig, ok := p.GetServiceConfig(name)
+		assert.True(t, ok)
+		assert.True(t, serviceConfig == services[i])
+	}
+
+	_, ok := p.GetServiceConfig("missing")
+	assert.False(t, ok)
+}
+
+func TestServicesWithCycle(t *testing.T) {
+	p := NewProject(&Context{}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{DependsOn: yaml.DependsOn{{Service: "cache"}}})
+	p.ServiceConfigs.Add("cache", &config.ServiceConfig{NetworkMode: "service:web"})
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+
+	assert.Equal(t, []string{"web", "cache", "db"}, p.ServiceNames())
+	assert.Len(t, p.Services(), 3)
+}
+
+func TestStartOperationTimeout(t *testing.T) {
+	factory := &OrderServiceFactory{
+		Blocking: map[string]bool{"cache": true},
+	}
+	p := NewProject(&Context{
+		ServiceFactory:           factory,
+		OperationTimeout:         time.Minute,
+		ServiceOperationTimeouts: map[string]time.Duration{"cache": 50 * time.Millisecond},
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("cache", &config.ServiceConfig{})
+
+	done := make(chan error)
+	go func() {
+		done <- p.Start(context.Background())
+	}()
+	select {
+	case err := <-done:
+		assert.Equal(t, &OperationTimeoutError{Service: "cache", Operation: "start", Timeout: 50 * time.Millisecond}, err)
+		assert.EqualError(t, err, "Timed out after 50ms waiting for the start of service cache")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start didn't time out")
+	}
 }
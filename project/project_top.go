@@ -0,0 +1,38 @@
+package project
+
+import "golang.org/x/net/context"
+
+// Top returns the processes running in the containers of the specified
+// services (all of them if none is specified), by container name. Containers
+// that are not running are left out.
+func (p *Project) Top(ctx context.Context, services ...string) (map[string][]ContainerProcess, error) {
+	result := map[string][]ContainerProcess{}
+
+	if len(services) == 0 {
+		services = p.ServiceConfigs.Keys()
+	}
+
+	for _, name := range services {
+		service, err := p.CreateService(name)
+		if err != nil {
+			return nil, err
+		}
+
+		containers, err := service.Containers(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range containers {
+			if !c.IsRunning(ctx) {
+				continue
+			}
+			processes, err := c.Top(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result[c.Name()] = processes
+		}
+	}
+	return result, nil
+}
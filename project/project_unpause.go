@@ -6,7 +6,8 @@ import (
 	"github.com/zengchen221/libcompose/project/events"
 )
 
-// Unpause pauses the specified services containers (like docker pause).
+// Unpause unpauses the specified services containers (like docker unpause),
+// or the containers of all the services if none is specified.
 func (p *Project) Unpause(ctx context.Context, services ...string) error {
 	return p.perform(events.ProjectUnpauseStart, events.ProjectUnpauseDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
 		wrapper.Do(nil, events.ServiceUnpauseStart, events.ServiceUnpause, func(service Service) error {
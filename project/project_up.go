@@ -1,22 +1,316 @@
 package project
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
 	"golang.org/x/net/context"
 
+	log "github.com/sirupsen/logrus"
+	"github.com/zengchen221/libcompose/config"
 	"github.com/zengchen221/libcompose/project/events"
 	"github.com/zengchen221/libcompose/project/options"
+	"github.com/zengchen221/libcompose/yaml"
 )
 
-// Up creates and starts the specified services (kinda like docker run).
+// UpError is returned by Up when several services failed to be brought up.
+type UpError struct {
+	// Services holds the error of each failed service, by service name.
+	Services map[string]error
+}
+
+func (e *UpError) Error() string {
+	names := []string{}
+	for name := range e.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	failures := []string{}
+	for _, name := range names {
+		failures = append(failures, fmt.Sprintf("%s: %v", name, e.Services[name]))
+	}
+	return fmt.Sprintf("Failed to bring up services: %s", strings.Join(failures, ", "))
+}
+
+// Up creates and starts the specified services (kinda like docker run). The
+// services that don't depend on each other are brought up concurrently, at
+// most options.Parallelism at a time. The first failure cancels the services
+// being brought up; a single failure is returned as is, several ones as an
+// UpError. The dependencies of the specified services are brought up too,
+// recreated according to options.RecreateDeps.
 func (p *Project) Up(ctx context.Context, options options.Up, services ...string) error {
+	if err := p.validateUp(options); err != nil {
+		return err
+	}
+	named := len(services) > 0
+	if !named {
+		services = p.activeServices(options.Profiles...)
+		if len(services) == 0 {
+			log.Infof("No service enabled by the active profiles")
+			return p.initialize(ctx)
+		}
+	}
+	services, err := p.SelectServices(options.LabelSelector, services...)
+	if err != nil {
+		return err
+	}
 	if err := p.initialize(ctx); err != nil {
 		return err
 	}
-	return p.perform(events.ProjectUpStart, events.ProjectUpDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+	requested := map[string]bool{}
+	if named || len(options.LabelSelector) > 0 {
+		for _, name := range services {
+			requested[name] = true
+		}
+		services = p.withDependencies(services)
+	}
+	parentCtx := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var slots chan struct{}
+	if options.Parallelism > 0 {
+		slots = make(chan struct{}, options.Parallelism)
+	}
+
+	var mu sync.Mutex
+	failures := map[string]error{}
+	err = p.perform(events.ProjectUpStart, events.ProjectUpDone, services, wrapperAction(func(wrapper *serviceWrapper, wrappers map[string]*serviceWrapper) {
+		serviceOptions := options
+		if len(requested) > 0 && !requested[wrapper.name] && isDependency(wrapper.name, wrappers, requested) {
+			serviceOptions.Create = dependencyCreateOptions(options)
+		}
 		wrapper.Do(wrappers, events.ServiceUpStart, events.ServiceUp, func(service Service) error {
-			return service.Up(ctx, options)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			err := waitForDependencies(ctx, service, wrappers)
+			if err != nil && ctx.Err() != nil {
+				// The wait was interrupted by the cancellation
+				err = ctx.Err()
+			}
+			if err == nil && slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					err = ctx.Err()
+				}
+			}
+			if err == nil {
+				err = service.Up(ctx, serviceOptions)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := err.(*BuildError); ok && options.IgnoreBuildFailures {
+				log.Warnf("Skipping service %s: %v", service.Name(), err)
+				p.Notify(events.ServiceUpIgnored, service.Name(), map[string]string{"error": err.Error()})
+				return nil
+			}
+			// Cancellations are a consequence of another failure, the
+			// services failing on their own are all reported
+			if err != nil && !errors.Is(err, context.Canceled) {
+				failures[service.Name()] = err
+				cancel()
+			}
+			return err
 		})
 	}), func(service Service) error {
 		return service.Create(ctx, options.Create)
 	})
+	err = cancelled(parentCtx, err)
+	if parentCtx.Err() == nil {
+		switch len(failures) {
+		case 0:
+		case 1:
+			for _, failure := range failures {
+				return failure
+			}
+		default:
+			return &UpError{Services: failures}
+		}
+	}
+	if err == nil && options.RemoveOrphans && p.runtime != nil {
+		err = p.runtime.RemoveOrphans(ctx, p.Name, p.ServiceConfigs)
+	}
+	return err
+}
+
+// validateUp checks that the specified up options are valid and apply to
+// services of the project.
+func (p *Project) validateUp(upOptions options.Up) error {
+	for name, policy := range upOptions.RestartPolicy {
+		if !p.ServiceConfigs.Has(name) {
+			return fmt.Errorf("Cannot override the restart policy of service %s: no such service", name)
+		}
+		if err := config.ValidateRestartPolicy(policy); err != nil {
+			return err
+		}
+	}
+	if err := validateCreate(upOptions.Create); err != nil {
+		return err
+	}
+	if upOptions.MaxRetries < 0 || upOptions.RetryBackoff < 0 {
+		return fmt.Errorf("MaxRetries and RetryBackoff cannot be negative")
+	}
+	return validateRecreateDeps(upOptions)
+}
+
+// cancelled returns the error of the specified context if it was cancelled
+// while performing an action, rather than the error of whichever service
+// noticed it first, and the specified error otherwise.
+func cancelled(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// activeServices returns the services enabled by the active profiles: the
+// ones of the project context, of the COMPOSE_PROFILES environment variable
+// and the specified ones. Services without profiles are always enabled.
+func (p *Project) activeServices(profiles ...string) []string {
+	active := map[string]bool{}
+	for _, profile := range append(append(profilesFromEnv(), p.context.Profiles...), profiles...) {
+		active[profile] = true
+	}
+
+	services := []string{}
+	for _, name := range p.ServiceConfigs.Keys() {
+		serviceConfig, _ := p.ServiceConfigs.Get(name)
+		enabled := len(serviceConfig.Profiles) == 0
+		for _, profile := range serviceConfig.Profiles {
+			enabled = enabled || active[profile]
+		}
+		if enabled {
+			services = append(services, name)
+		}
+	}
+	return services
+}
+
+// waitForDependencies waits for the dependencies of the specified service
+// declared with the service_healthy condition to be healthy, and for the ones
+// declared with the service_completed_successfully condition to exit with a
+// zero code.
+func waitForDependencies(ctx context.Context, service Service, wrappers map[string]*serviceWrapper) error {
+	if service.Config() == nil {
+		return nil
+	}
+	for _, dependency := range service.Config().DependsOn {
+		if dependency.Condition != yaml.ConditionServiceHealthy && dependency.Condition != yaml.ConditionServiceCompletedSuccessfully {
+			continue
+		}
+		wrapper, ok := wrappers[dependency.Service]
+		if !ok {
+			return fmt.Errorf("Service '%s' depends on service '%s' which is undefined", service.Name(), dependency.Service)
+		}
+		if dependency.Condition == yaml.ConditionServiceHealthy {
+			log.Infof("Waiting for %s to be healthy", dependency.Service)
+			if err := wrapper.service.WaitHealthy(ctx); err != nil {
+				return fmt.Errorf("Dependency %s of service %s is not healthy: %v", dependency.Service, service.Name(), err)
+			}
+			continue
+		}
+		log.Infof("Waiting for %s to complete", dependency.Service)
+		exitCodes, err := wrapper.service.WaitFor(ctx, WaitConditionExited)
+		if err == nil {
+			for _, exitCode := range exitCodes {
+				if exitCode != 0 {
+					err = &ExitCodesError{ExitCodes: exitCodes}
+					break
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Dependency %s of service %s didn't complete successfully: %v", dependency.Service, service.Name(), err)
+		}
+	}
+	return nil
+}
+
+func validateRecreateDeps(upOptions options.Up) error {
+	switch upOptions.RecreateDeps {
+	case "", options.RecreateDepsChanged, options.RecreateDepsNever:
+	case options.RecreateDepsAlways:
+		if upOptions.NoRecreate {
+			return fmt.Errorf("no-recreate and always-recreate-deps cannot be combined")
+		}
+	default:
+		return fmt.Errorf("Invalid RecreateDeps value %q: must be one of changed, always or never", upOptions.RecreateDeps)
+	}
+	return nil
+}
+
+// withDependencies returns the specified services along with the ones they
+// (transitively) depend on, so that the dependencies of the services
+// explicitly brought up are brought up too.
+func (p *Project) withDependencies(services []string) []string {
+	result := []string{}
+	added := map[string]bool{}
+	var add func(name string)
+	add = func(name string) {
+		if added[name] {
+			return
+		}
+		added[name] = true
+		result = append(result, name)
+		if serviceConfig, ok := p.ServiceConfigs.Get(name); ok {
+			for _, dependency := range configDependencies(serviceConfig) {
+				add(dependency.Target)
+			}
+		}
+	}
+	for _, name := range services {
+		add(name)
+	}
+	return result
+}
+
+// isDependency returns whether one of the requested services depends on
+// the specified one, directly or through other services.
+func isDependency(name string, wrappers map[string]*serviceWrapper, requested map[string]bool) bool {
+	visited := map[string]bool{}
+	var dependsOn func(wrapper *serviceWrapper) bool
+	dependsOn = func(wrapper *serviceWrapper) bool {
+		if visited[wrapper.name] {
+			return false
+		}
+		visited[wrapper.name] = true
+		for _, dep := range wrapper.service.DependentServices() {
+			if dep.Target == name {
+				return true
+			}
+			if target, ok := wrappers[dep.Target]; ok && dependsOn(target) {
+				return true
+			}
+		}
+		return false
+	}
+	for requestedName := range requested {
+		if wrapper, ok := wrappers[requestedName]; ok && requestedName != name && dependsOn(wrapper) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependencyCreateOptions returns the create options to use for a
+// dependency, according to the RecreateDeps option: ForceRecreate only
+// applies to the requested services.
+func dependencyCreateOptions(upOptions options.Up) options.Create {
+	createOptions := upOptions.Create
+	switch upOptions.RecreateDeps {
+	case options.RecreateDepsAlways:
+		createOptions.ForceRecreate = true
+	case options.RecreateDepsNever:
+		createOptions.ForceRecreate = false
+		createOptions.NoRecreate = true
+	default:
+		createOptions.ForceRecreate = false
+	}
+	return createOptions
 }
@@ -0,0 +1,97 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Conditions WaitFor can wait for.
+const (
+	WaitConditionRunning = "running"
+	WaitConditionHealthy = "healthy"
+	WaitConditionExited  = "exited"
+)
+
+// ExitCodesError is returned by WaitFor when waiting for services to exit
+// and some of their containers exited with a non-zero code. ExitCodes holds
+// the exit code of every container waited for, by container name.
+type ExitCodesError struct {
+	ExitCodes map[string]int
+}
+
+func (e *ExitCodesError) Error() string {
+	failed := []string{}
+	for name, exitCode := range e.ExitCodes {
+		if exitCode != 0 {
+			failed = append(failed, fmt.Sprintf("%s exited with code %d", name, exitCode))
+		}
+	}
+	sort.Strings(failed)
+	return strings.Join(failed, ", ")
+}
+
+// WaitFor blocks until the containers of the specified services (all of them
+// if none is specified) are running, healthy or exited, depending on the
+// condition, or until the context is done. When waiting for the services to
+// exit, an *ExitCodesError is returned if any container exited with a
+// non-zero code.
+func (p *Project) WaitFor(ctx context.Context, condition string, services ...string) error {
+	switch condition {
+	case WaitConditionRunning, WaitConditionHealthy, WaitConditionExited:
+	default:
+		return fmt.Errorf("Invalid condition %q, must be one of %s, %s or %s", condition, WaitConditionRunning, WaitConditionHealthy, WaitConditionExited)
+	}
+
+	if len(services) == 0 {
+		services = p.ServiceConfigs.Keys()
+	}
+
+	selected := []Service{}
+	for _, name := range services {
+		service, err := p.CreateService(name)
+		if err != nil {
+			return err
+		}
+		selected = append(selected, service)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		firstErr  error
+		exitCodes = map[string]int{}
+	)
+	for _, service := range selected {
+		wg.Add(1)
+		go func(service Service) {
+			defer wg.Done()
+			codes, err := service.WaitFor(ctx, condition)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for name, exitCode := range codes {
+				exitCodes[name] = exitCode
+			}
+		}(service)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	for _, exitCode := range exitCodes {
+		if exitCode != 0 {
+			return &ExitCodesError{ExitCodes: exitCodes}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/zengchen221/libcompose/config"
+	"github.com/zengchen221/libcompose/project/options"
+)
+
+// Watch actions, as defined in develop.watch rules.
+const (
+	WatchActionSync        = "sync"
+	WatchActionRebuild     = "rebuild"
+	WatchActionRestart     = "restart"
+	WatchActionSyncRestart = "sync+restart"
+)
+
+const (
+	defaultWatchPollInterval = 500 * time.Millisecond
+	defaultWatchDebounce     = 500 * time.Millisecond
+)
+
+// watchedRule holds the state of a develop.watch rule of a service: the
+// files seen at the last poll and the pending changes.
+type watchedRule struct {
+	service string
+	rule    config.WatchRule
+	files   map[string]time.Time
+	changed map[string]bool
+	deleted map[string]bool
+}
+
+// Watch monitors the develop.watch paths of the specified services (all of
+// them if none is specified) and performs the configured action when files
+// change: sync copies the files into the containers, rebuild rebuilds the
+// image and recreates the containers, restart restarts them. It blocks until
+// the context is cancelled.
+func (p *Project) Watch(ctx context.Context, opts options.Watch, services ...string) error {
+	rules, err := p.watchedRules(services)
+	if err != nil {
+		return err
+	}
+
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultWatchPollInterval
+	}
+	debounce := opts.Debounce
+	if debounce <= 0 {
+		debounce = defaultWatchDebounce
+	}
+
+	for _, r := range rules {
+		if r.files, err = scanWatchedPath(r.rule); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var lastChange time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for _, r := range rules {
+			files, err := scanWatchedPath(r.rule)
+			if err != nil {
+				return err
+			}
+			if r.diff(files) {
+				lastChange = time.Now()
+			}
+			r.files = files
+		}
+
+		if lastChange.IsZero() || time.Since(lastChange) < debounce {
+			continue
+		}
+		lastChange = time.Time{}
+
+		if err := p.applyWatchedChanges(ctx, rules); err != nil {
+			log.Errorf("Failed to apply changes: %v", err)
+		}
+	}
+}
+
+func (p *Project) watchedRules(services []string) ([]*watchedRule, error) {
+	if len(services) == 0 {
+		services = p.ServiceConfigs.Keys()
+	}
+
+	rules := []*watchedRule{}
+	for _, name := range services {
+		serviceConfig, ok := p.ServiceConfigs.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("No such service: %s", name)
+		}
+		for _, rule := range serviceConfig.Develop.Watch {
+			switch rule.Action {
+			case WatchActionSync, WatchActionSyncRestart:
+				if rule.Target == "" {
+					return nil, fmt.Errorf("Service %s: watch rule on %s requires a target for the %s action", name, rule.Path, rule.Action)
+				}
+			case WatchActionRebuild, WatchActionRestart:
+			default:
+				return nil, fmt.Errorf("Service %s: unsupported watch action %q", name, rule.Action)
+			}
+			rules = append(rules, &watchedRule{
+				service: name,
+				rule:    rule,
+				changed: map[string]bool{},
+				deleted: map[string]bool{},
+			})
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("None of the selected services is configured for watch, consider setting a develop.watch section")
+	}
+	return rules, nil
+}
+
+// diff records the changes between the last known files and the specified
+// ones, and returns whether there were any.
+func (r *watchedRule) diff(files map[string]time.Time) bool {
+	changes := false
+	for file, modTime := range files {
+		if previous, ok := r.files[file]; !ok || !previous.Equal(modTime) {
+			r.changed[file] = true
+			delete(r.deleted, file)
+			changes = true
+		}
+	}
+	for file := range r.files {
+		if _, ok := files[file]; !ok {
+			r.deleted[file] = true
+			delete(r.changed, file)
+			changes = true
+		}
+	}
+	return changes
+}
+
+func (r *watchedRule) reset() {
+	r.changed = map[string]bool{}
+	r.deleted = map[string]bool{}
+}
+
+func (r *watchedRule) hasChanges() bool {
+	return len(r.changed) > 0 || len(r.deleted) > 0
+}
+
+// fileSyncs returns the pending changes as FileSync operations.
+func (r *watchedRule) fileSyncs() []FileSync {
+	syncs := []FileSync{}
+	for file := range r.changed {
+		syncs = append(syncs, FileSync{Source: file, Target: r.target(file)})
+	}
+	for file := range r.deleted {
+		syncs = append(syncs, FileSync{Source: file, Target: r.target(file), Deleted: true})
+	}
+	return syncs
+}
+
+func (r *watchedRule) target(file string) string {
+	rel, err := filepath.Rel(r.rule.Path, file)
+	if err != nil || rel == "." {
+		return r.rule.Target
+	}
+	return path.Join(r.rule.Target, filepath.ToSlash(rel))
+}
+
+func (p *Project) applyWatchedChanges(ctx context.Context, rules []*watchedRule) error {
+	syncs := map[string][]FileSync{}
+	rebuild := map[string]bool{}
+	restart := map[string]bool{}
+
+	for _, r := range rules {
+		if !r.hasChanges() {
+			continue
+		}
+		log.Infof("Changes detected in %s for service %s", r.rule.Path, r.service)
+		switch r.rule.Action {
+		case WatchActionSync:
+			syncs[r.service] = append(syncs[r.service], r.fileSyncs()...)
+		case WatchActionSyncRestart:
+			syncs[r.service] = append(syncs[r.service], r.fileSyncs()...)
+			restart[r.service] = true
+		case WatchActionRebuild:
+			rebuild[r.service] = true
+		case WatchActionRestart:
+			restart[r.service] = true
+		}
+		r.reset()
+	}
+
+	for name, files := range syncs {
+		if rebuild[name] {
+			continue
+		}
+		service, err := p.CreateService(name)
+		if err != nil {
+			return err
+		}
+		if err := service.Sync(ctx, files); err != nil {
+			return err
+		}
+	}
+
+	for name := range rebuild {
+		service, err := p.CreateService(name)
+		if err != nil {
+			return err
+		}
+		if err := service.Build(ctx, options.Build{}); err != nil {
+			return err
+		}
+		if err := service.Up(ctx, options.Up{Create: options.Create{ForceRecreate: true}}); err != nil {
+			return err
+		}
+	}
+
+	for name := range restart {
+		if rebuild[name] {
+			continue
+		}
+		service, err := p.CreateService(name)
+		if err != nil {
+			return err
+		}
+		if err := service.Restart(ctx, 10); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanWatchedPath returns the modification time of the files under the path
+// of the specified rule, skipping the ignored ones. A missing path is
+// considered empty, so that its deletion is handled as any other change.
+func scanWatchedPath(rule config.WatchRule) (map[string]time.Time, error) {
+	files := map[string]time.Time{}
+	err := filepath.Walk(rule.Path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(rule.Path, file)
+		if err != nil {
+			return err
+		}
+		if rel != "." && isIgnored(filepath.ToSlash(rel), info.IsDir(), rule.Ignore) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			files[file] = info.ModTime()
+		}
+		return nil
+	})
+	return files, err
+}
+
+// isIgnored returns whether the specified path (relative to the watched
+// path) matches one of the ignore patterns. A pattern matches the whole path
+// or its base name, and patterns with a trailing slash only match
+// directories.
+func isIgnored(rel string, isDir bool, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if !isDir {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package project
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zengchen221/libcompose/config"
+	"github.com/zengchen221/libcompose/project/options"
+)
+
+func TestWatchIsIgnored(t *testing.T) {
+	patterns := []string{"node_modules/", "*.log", "tmp/cache"}
+
+	assert.True(t, isIgnored("node_modules", true, patterns))
+	assert.True(t, isIgnored("lib/node_modules", true, patterns))
+	assert.False(t, isIgnored("node_modules", false, patterns))
+	assert.True(t, isIgnored("debug.log", false, patterns))
+	assert.True(t, isIgnored("logs/debug.log", false, patterns))
+	assert.True(t, isIgnored("tmp/cache", true, patterns))
+	assert.False(t, isIgnored("src/main.go", false, patterns))
+}
+
+func TestWatchedRuleDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "b.log"), []byte("b"), 0644))
+
+	r := &watchedRule{
+		rule: config.WatchRule{
+			Path:   dir,
+			Action: WatchActionSync,
+			Target: "/app",
+			Ignore: []string{"*.log"},
+		},
+		changed: map[string]bool{},
+		deleted: map[string]bool{},
+	}
+	r.files, err = scanWatchedPath(r.rule)
+	assert.Nil(t, err)
+	assert.Len(t, r.files, 1)
+
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "sub", "c.txt"), []byte("c"), 0644))
+	assert.Nil(t, os.Remove(filepath.Join(dir, "a.txt")))
+
+	files, err := scanWatchedPath(r.rule)
+	assert.Nil(t, err)
+	assert.True(t, r.diff(files))
+
+	assert.Equal(t, map[string]bool{filepath.Join(dir, "sub", "c.txt"): true}, r.changed)
+	assert.Equal(t, map[string]bool{filepath.Join(dir, "a.txt"): true}, r.deleted)
+	assert.Equal(t, "/app/sub/c.txt", r.target(filepath.Join(dir, "sub", "c.txt")))
+
+	r.files = files
+	assert.False(t, r.diff(files))
+
+	// A deleted watched path is not an error
+	assert.Nil(t, os.RemoveAll(dir))
+	files, err = scanWatchedPath(r.rule)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+type WatchServiceFactory struct {
+	sync.Mutex
+	Restarts map[string]int
+	Syncs    map[string][]FileSync
+}
+
+type WatchService struct {
+	EmptyService
+	factory *WatchServiceFactory
+	name    string
+	config  *config.ServiceConfig
+}
+
+func (w *WatchServiceFactory) Create(project *Project, name string, serviceConfig *config.ServiceConfig) (Service, error) {
+	return &WatchService{
+		factory: w,
+		name:    name,
+		config:  serviceConfig,
+	}, nil
+}
+
+func (w *WatchService) Restart(ctx context.Context, timeout int) error {
+	w.factory.Lock()
+	defer w.factory.Unlock()
+	w.factory.Restarts[w.name]++
+	return nil
+}
+
+func (w *WatchService) Sync(ctx context.Context, files []FileSync) error {
+	w.factory.Lock()
+	defer w.factory.Unlock()
+	w.factory.Syncs[w.name] = append(w.factory.Syncs[w.name], files...)
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	factory := &WatchServiceFactory{
+		Restarts: map[string]int{},
+		Syncs:    map[string][]FileSync{},
+	}
+	p := NewProject(&Context{
+		ServiceFactory: factory,
+	}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("web", &config.ServiceConfig{
+		Develop: config.DevelopConfig{
+			Watch: []config.WatchRule{
+				{Path: filepath.Join(dir, "src"), Action: WatchActionSync, Target: "/app/src"},
+				{Path: filepath.Join(dir, "config"), Action: WatchActionRestart},
+			},
+		},
+	})
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- p.Watch(ctx, options.Watch{
+			PollInterval: 10 * time.Millisecond,
+			Debounce:     20 * time.Millisecond,
+		})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "src"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "src", "main.js"), []byte("main"), 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "config"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "config", "app.conf"), []byte("conf"), 0644))
+	time.Sleep(200 * time.Millisecond)
+
+	cancel()
+	assert.Nil(t, <-done)
+
+	factory.Lock()
+	defer factory.Unlock()
+	assert.Equal(t, 1, factory.Restarts["web"])
+	assert.Equal(t, []FileSync{
+		{Source: filepath.Join(dir, "src", "main.js"), Target: "/app/src/main.js"},
+	}, factory.Syncs["web"])
+}
+
+func TestWatchWithoutRules(t *testing.T) {
+	p := NewProject(&Context{}, nil, nil)
+	p.ServiceConfigs = config.NewServiceConfigs()
+	p.ServiceConfigs.Add("db", &config.ServiceConfig{})
+
+	assert.NotNil(t, p.Watch(context.Background(), options.Watch{}))
+	assert.NotNil(t, p.Watch(context.Background(), options.Watch{}, "unknown"))
+}
@@ -0,0 +1,174 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/zengchen221/libcompose/yaml"
+)
+
+// Types of a RunnableVolume.
+const (
+	RunnableVolumeBind      = "bind"
+	RunnableVolumeNamed     = "volume"
+	RunnableVolumeAnonymous = "anonymous"
+	RunnableVolumeTmpfs     = "tmpfs"
+)
+
+// RunnableService is a runtime independent description of how to run a
+// service, built from its merged configuration. It is meant for tools that
+// translate compose projects for other orchestrators.
+type RunnableService struct {
+	Name       string
+	Image      string
+	Entrypoint []string
+	Command    []string
+	// Environment holds the variables set in the container. Variables
+	// declared without a value that couldn't be resolved are left out.
+	Environment map[string]string
+	Ports       []ContainerPort
+	Volumes     []RunnableVolume
+	// Restart is the restart policy (e.g. always or on-failure:3).
+	Restart   string
+	Resources RunnableResources
+}
+
+// RunnableVolume holds a mount of a RunnableService.
+type RunnableVolume struct {
+	// Type is RunnableVolumeBind, RunnableVolumeNamed,
+	// RunnableVolumeAnonymous or RunnableVolumeTmpfs.
+	Type string
+	// Source is the host path of a bind mount or the name of a named
+	// volume, empty otherwise.
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// RunnableResources holds the resource limits and reservations of a
+// RunnableService, zero values meaning unset.
+type RunnableResources struct {
+	CPUs float64
+	// MemoryLimit and MemoryReservation are in bytes.
+	MemoryLimit       int64
+	MemoryReservation int64
+}
+
+// RunnableServices returns the services enabled by the active profiles as
+// RunnableService values. It only relies on the parsed configuration, so it
+// doesn't require any access to a docker daemon.
+func (p *Project) RunnableServices() ([]RunnableService, error) {
+	services := []RunnableService{}
+	for _, name := range p.activeServices() {
+		serviceConfig, _ := p.ServiceConfigs.Get(name)
+
+		ports, err := runnablePorts(serviceConfig.Ports)
+		if err != nil {
+			return nil, fmt.Errorf("Service %s: %v", name, err)
+		}
+
+		services = append(services, RunnableService{
+			Name:        name,
+			Image:       serviceConfig.Image,
+			Entrypoint:  []string(serviceConfig.Entrypoint),
+			Command:     []string(serviceConfig.Command),
+			Environment: runnableEnvironment(serviceConfig.Environment),
+			Ports:       ports,
+			Volumes:     runnableVolumes(serviceConfig.Volumes, serviceConfig.Tmpfs),
+			Restart:     serviceConfig.Restart,
+			Resources: RunnableResources{
+				CPUs:              serviceConfig.CPUs,
+				MemoryLimit:       int64(serviceConfig.MemLimit),
+				MemoryReservation: int64(serviceConfig.MemReservation),
+			},
+		})
+	}
+	return services, nil
+}
+
+func runnableEnvironment(environment yaml.MaporEqualSlice) map[string]string {
+	vars := map[string]string{}
+	for _, v := range environment {
+		if parts := strings.SplitN(v, "=", 2); len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	return vars
+}
+
+// runnablePorts returns the specified ports (in the compose syntax), ranges
+// being expanded into one port each.
+func runnablePorts(specs []string) ([]ContainerPort, error) {
+	exposed, bindings, err := nat.ParsePortSpecs(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []ContainerPort{}
+	for port := range exposed {
+		if len(bindings[port]) == 0 {
+			ports = append(ports, ContainerPort{
+				ContainerPort: port.Int(),
+				Protocol:      port.Proto(),
+			})
+		}
+		for _, binding := range bindings[port] {
+			hostPort := 0
+			if binding.HostPort != "" {
+				if hostPort, err = nat.ParsePort(binding.HostPort); err != nil {
+					return nil, err
+				}
+			}
+			ports = append(ports, ContainerPort{
+				ContainerPort: port.Int(),
+				Protocol:      port.Proto(),
+				HostIP:        binding.HostIP,
+				HostPort:      hostPort,
+			})
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].ContainerPort != ports[j].ContainerPort {
+			return ports[i].ContainerPort < ports[j].ContainerPort
+		}
+		if ports[i].Protocol != ports[j].Protocol {
+			return ports[i].Protocol < ports[j].Protocol
+		}
+		return ports[i].HostPort < ports[j].HostPort
+	})
+	return ports, nil
+}
+
+func runnableVolumes(volumes *yaml.Volumes, tmpfs yaml.Tmpfs) []RunnableVolume {
+	mounts := []RunnableVolume{}
+	if volumes != nil {
+		for _, v := range volumes.Volumes {
+			mount := RunnableVolume{
+				Type:     v.Type,
+				Source:   v.Source,
+				Target:   v.Destination,
+				ReadOnly: v.ReadOnly(),
+			}
+			switch {
+			case mount.Type == yaml.VolumeTypeTmpfs:
+				mount.Source = ""
+			case mount.Source == "":
+				mount.Type = RunnableVolumeAnonymous
+			case mount.Type == "" && IsNamedVolume(mount.Source):
+				mount.Type = RunnableVolumeNamed
+			case mount.Type == "":
+				mount.Type = RunnableVolumeBind
+			}
+			mounts = append(mounts, mount)
+		}
+	}
+	for _, t := range tmpfs {
+		mounts = append(mounts, RunnableVolume{
+			Type:   RunnableVolumeTmpfs,
+			Target: strings.SplitN(t, ":", 2)[0],
+		})
+	}
+	return mounts
+}
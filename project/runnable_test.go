@@ -0,0 +1,76 @@
+package project
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnableServices(t *testing.T) {
+	p := NewProject(&Context{
+		ProjectName: "foo",
+		ComposeBytes: [][]byte{
+			[]byte(`version: '2'
+services:
+  web:
+    image: nginx
+    entrypoint: /entrypoint.sh
+    command: [nginx, -g, daemon off;]
+    environment:
+      - MODE=production
+      - UNSET
+    ports:
+      - "8080:80"
+      - "127.0.0.1:5000-5001:5000-5001/udp"
+      - "443"
+    volumes:
+      - data:/data
+      - ./conf:/etc/nginx/conf.d:ro
+      - /cache
+    tmpfs: /run
+    restart: on-failure:3
+    cpus: 0.5
+    mem_limit: 64m
+    mem_reservation: 32m
+  debug:
+    image: busybox
+    profiles: [debug]
+volumes:
+  data: {}
+`),
+		},
+	}, nil, nil)
+	if err := p.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := p.RunnableServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, services, 1)
+
+	web := services[0]
+	assert.Equal(t, "web", web.Name)
+	assert.Equal(t, "nginx", web.Image)
+	assert.Equal(t, []string{"/entrypoint.sh"}, web.Entrypoint)
+	assert.Equal(t, []string{"nginx", "-g", "daemon off;"}, web.Command)
+	assert.Equal(t, map[string]string{"MODE": "production"}, web.Environment)
+	assert.Equal(t, []ContainerPort{
+		{ContainerPort: 80, Protocol: "tcp", HostPort: 8080},
+		{ContainerPort: 443, Protocol: "tcp"},
+		{ContainerPort: 5000, Protocol: "udp", HostIP: "127.0.0.1", HostPort: 5000},
+		{ContainerPort: 5001, Protocol: "udp", HostIP: "127.0.0.1", HostPort: 5001},
+	}, web.Ports)
+	conf, err := filepath.Abs("conf")
+	assert.NoError(t, err)
+	assert.Equal(t, []RunnableVolume{
+		{Type: RunnableVolumeNamed, Source: "foo_data", Target: "/data"},
+		{Type: RunnableVolumeBind, Source: conf, Target: "/etc/nginx/conf.d", ReadOnly: true},
+		{Type: RunnableVolumeAnonymous, Target: "/cache"},
+		{Type: RunnableVolumeTmpfs, Target: "/run"},
+	}, web.Volumes)
+	assert.Equal(t, "on-failure:3", web.Restart)
+	assert.Equal(t, RunnableResources{CPUs: 0.5, MemoryLimit: 64 * 1024 * 1024, MemoryReservation: 32 * 1024 * 1024}, web.Resources)
+}
@@ -83,6 +83,31 @@ func (s *serviceWrapper) waitForDeps(wrappers map[string]*serviceWrapper) bool {
 	return true
 }
 
+// waitForDependents waits for the services that depend on this one, so that
+// an action can be performed in the reverse dependency order.
+func (s *serviceWrapper) waitForDependents(wrappers map[string]*serviceWrapper) {
+	for _, wrapper := range wrappers {
+		if wrapper == s || wrapper.ignored[s.name] {
+			continue
+		}
+		for _, dep := range wrapper.service.DependentServices() {
+			if dep.Target == s.name {
+				wrapper.Wait()
+				break
+			}
+		}
+	}
+}
+
+// DoReverse is like Do, but waits for the dependent services instead of the
+// dependencies of the service (e.g. to stop them first).
+func (s *serviceWrapper) DoReverse(wrappers map[string]*serviceWrapper, start, done events.EventType, action func(service Service) error) {
+	if wrappers != nil && s.state != StateExecuted {
+		s.waitForDependents(wrappers)
+	}
+	s.Do(nil, start, done, action)
+}
+
 func (s *serviceWrapper) Do(wrappers map[string]*serviceWrapper, start, done events.EventType, action func(service Service) error) {
 	defer s.done.Done()
 
@@ -2,6 +2,7 @@ package project
 
 import (
 	"errors"
+	"fmt"
 
 	"golang.org/x/net/context"
 
@@ -17,17 +18,21 @@ type Service interface {
 	Delete(ctx context.Context, options options.Delete) error
 	Events(ctx context.Context, messages chan events.ContainerEvent) error
 	Info(ctx context.Context) (InfoSet, error)
-	Log(ctx context.Context, follow bool) error
+	Log(ctx context.Context, options options.Log) error
 	Kill(ctx context.Context, signal string) error
 	Pause(ctx context.Context) error
+	Plan(ctx context.Context, options options.Up) ([]Action, error)
 	Pull(ctx context.Context) error
 	Restart(ctx context.Context, timeout int) error
 	Run(ctx context.Context, commandParts []string, options options.Run) (int, error)
 	Scale(ctx context.Context, count int, timeout int) error
 	Start(ctx context.Context) error
 	Stop(ctx context.Context, timeout int) error
+	Sync(ctx context.Context, files []FileSync) error
 	Unpause(ctx context.Context) error
 	Up(ctx context.Context, options options.Up) error
+	WaitHealthy(ctx context.Context) error
+	WaitFor(ctx context.Context, condition string) (map[string]int, error)
 
 	RemoveImage(ctx context.Context, imageType options.ImageType) error
 	Containers(ctx context.Context) ([]Container, error)
@@ -36,6 +41,14 @@ type Service interface {
 	Name() string
 }
 
+// FileSync describes a host file to copy into (or to remove from, if
+// deleted) the containers of a service.
+type FileSync struct {
+	Source  string
+	Target  string
+	Deleted bool
+}
+
 // ServiceState holds the state of a service.
 type ServiceState string
 
@@ -51,6 +64,17 @@ var (
 	ErrUnsupported = errors.New("UnsupportedOperation")
 )
 
+// BuildError is returned by a Service when building its image failed, so that
+// build failures can be told apart from other errors.
+type BuildError struct {
+	Service string
+	Err     error
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("Failed to build service %s: %v", e.Service, e.Err)
+}
+
 // ServiceFactory is an interface factory to create Service object for the specified
 // project, with the specified name and service configuration.
 type ServiceFactory interface {
@@ -69,6 +93,9 @@ const RelTypeNetNamespace = ServiceRelationshipType("netns")
 // RelTypeIpcNamespace means the service share the same ipc namespace.
 const RelTypeIpcNamespace = ServiceRelationshipType("ipc")
 
+// RelTypePidNamespace means the services share the same pid namespace.
+const RelTypePidNamespace = ServiceRelationshipType("pid")
+
 // RelTypeVolumesFrom means the services share some volumes.
 const RelTypeVolumesFrom = ServiceRelationshipType("volumesFrom")
 
@@ -0,0 +1,52 @@
+package project
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// OperationTimeoutError is returned when an operation on a service didn't
+// complete within the operation timeout of the project context.
+type OperationTimeoutError struct {
+	// Service is the name of the service.
+	Service string
+	// Operation is the operation that timed out (pull, build, create or
+	// start).
+	Operation string
+	// Timeout is the timeout that was exceeded.
+	Timeout time.Duration
+}
+
+func (e *OperationTimeoutError) Error() string {
+	return fmt.Sprintf("Timed out after %s waiting for the %s of service %s", e.Timeout, e.Operation, e.Service)
+}
+
+// operationTimeout returns the operation timeout of the specified service,
+// zero if there is none.
+func (c *Context) operationTimeout(service string) time.Duration {
+	if timeout, ok := c.ServiceOperationTimeouts[service]; ok {
+		return timeout
+	}
+	return c.OperationTimeout
+}
+
+// WithOperationTimeout performs the specified operation of the specified
+// service with a context bounded by its operation timeout, if any, turning
+// the expiry of the timeout into an OperationTimeoutError. Services
+// performing several operations at once, like Up, use it to bound each of
+// them.
+func (c *Context) WithOperationTimeout(ctx context.Context, service, operation string, action func(ctx context.Context) error) error {
+	timeout := c.operationTimeout(service)
+	if timeout <= 0 {
+		return action(ctx)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := action(timeoutCtx)
+	if err != nil && timeoutCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return &OperationTimeoutError{Service: service, Operation: operation, Timeout: timeout}
+	}
+	return err
+}
@@ -2,12 +2,19 @@ package project
 
 import (
 	"strings"
+
+	"github.com/zengchen221/libcompose/config"
 )
 
 // DefaultDependentServices return the dependent services (as an array of ServiceRelationship)
 // for the specified project and service. It looks for : links, volumesFrom, net and ipc configuration.
 func DefaultDependentServices(p *Project, s Service) []ServiceRelationship {
-	config := s.Config()
+	return configDependencies(s.Config())
+}
+
+// configDependencies returns the services the specified configuration
+// depends on, see DefaultDependentServices.
+func configDependencies(config *config.ServiceConfig) []ServiceRelationship {
 	if config == nil {
 		return []ServiceRelationship{}
 	}
@@ -21,7 +28,7 @@ func DefaultDependentServices(p *Project, s Service) []ServiceRelationship {
 		result = append(result, NewServiceRelationship(volumesFrom, RelTypeVolumesFrom))
 	}
 
-	for _, dependsOn := range config.DependsOn {
+	for _, dependsOn := range config.DependsOn.Services() {
 		result = append(result, NewServiceRelationship(dependsOn, RelTypeDependsOn))
 	}
 
@@ -32,6 +39,16 @@ func DefaultDependentServices(p *Project, s Service) []ServiceRelationship {
 		}
 	}
 
+	if strings.HasPrefix(config.Ipc, "service:") {
+		serviceName := config.Ipc[8:]
+		result = append(result, NewServiceRelationship(serviceName, RelTypeIpcNamespace))
+	}
+
+	if strings.HasPrefix(config.Pid, "service:") {
+		serviceName := config.Pid[8:]
+		result = append(result, NewServiceRelationship(serviceName, RelTypePidNamespace))
+	}
+
 	return result
 }
 
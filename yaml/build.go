@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/docker/go-units"
 )
 
 // Build represents a build element in compose file.
@@ -15,10 +17,18 @@ type Build struct {
 	Args       map[string]*string
 	CacheFrom  []*string
 	Labels     map[string]*string
-	// TODO: ShmSize (can be a string or int?) for v3.5
-	Target string
+	// ShmSize is the size of /dev/shm for the build containers.
+	ShmSize MemStringorInt
+	// NoCache and Pull, when set, override the corresponding global build
+	// options for the service, either way.
+	NoCache *bool
+	Pull    *bool
+	Target  string
 	// Note: as of Sep 2018 this is undocumented but supported by docker-compose
 	Network string
+	// Platform is the platform to build the image for, which may differ from
+	// the one the service runs on.
+	Platform string
 }
 
 // MarshalYAML implements the Marshaller interface.
@@ -39,12 +49,24 @@ func (b Build) MarshalYAML() (interface{}, error) {
 	if len(b.Labels) > 0 {
 		m["labels"] = b.Labels
 	}
+	if b.ShmSize != 0 {
+		m["shm_size"] = int64(b.ShmSize)
+	}
+	if b.NoCache != nil {
+		m["no_cache"] = *b.NoCache
+	}
+	if b.Pull != nil {
+		m["pull"] = *b.Pull
+	}
 	if b.Target != "" {
 		m["target"] = b.Target
 	}
 	if b.Network != "" {
 		m["network"] = b.Network
 	}
+	if b.Platform != "" {
+		m["platform"] = b.Platform
+	}
 	return m, nil
 }
 
@@ -82,10 +104,30 @@ func (b *Build) UnmarshalYAML(unmarshal func(interface{}) error) error {
 					return err
 				}
 				b.Labels = labels
+			case "shm_size":
+				shmSize, err := handleBuildShmSize(mapValue)
+				if err != nil {
+					return err
+				}
+				b.ShmSize = shmSize
+			case "no_cache":
+				noCache, ok := mapValue.(bool)
+				if !ok {
+					return fmt.Errorf("Failed to unmarshal Build no_cache: %#v", mapValue)
+				}
+				b.NoCache = &noCache
+			case "pull":
+				pull, ok := mapValue.(bool)
+				if !ok {
+					return fmt.Errorf("Failed to unmarshal Build pull: %#v", mapValue)
+				}
+				b.Pull = &pull
 			case "target":
 				b.Target = mapValue.(string)
 			case "network":
 				b.Network = mapValue.(string)
+			case "platform":
+				b.Platform = mapValue.(string)
 			default:
 				// Ignore unknown keys
 				continue
@@ -129,6 +171,23 @@ func handleBuildLabels(value interface{}) (map[string]*string, error) {
 	}
 }
 
+func handleBuildShmSize(value interface{}) (MemStringorInt, error) {
+	switch v := value.(type) {
+	case int:
+		return MemStringorInt(v), nil
+	case int64:
+		return MemStringorInt(v), nil
+	case string:
+		size, err := units.RAMInBytes(v)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to unmarshal Build shm_size: %v", err)
+		}
+		return MemStringorInt(size), nil
+	default:
+		return 0, fmt.Errorf("Failed to unmarshal Build shm_size: %#v", value)
+	}
+}
+
 func handleBuildCacheFromSlice(s []interface{}) ([]*string, error) {
 	var args = []*string{}
 	for _, arg := range s {
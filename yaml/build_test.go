@@ -14,6 +14,8 @@ var (
 	testCacheFrom = "someotherimage:latest"
 	target        = "intermediateimage"
 	network       = "buildnetwork"
+	enabled       = true
+	disabled      = false
 )
 
 func TestMarshalBuild(t *testing.T) {
@@ -71,6 +73,35 @@ labels:
   user: vincent
 network: buildnetwork
 target: intermediateimage
+`,
+		},
+		{
+			build: Build{
+				Context:  ".",
+				Platform: "linux/amd64",
+			},
+			expected: `context: .
+platform: linux/amd64
+`,
+		},
+		{
+			build: Build{
+				Context: ".",
+				ShmSize: MemStringorInt(268435456),
+			},
+			expected: `context: .
+shm_size: 268435456
+`,
+		},
+		{
+			build: Build{
+				Context: ".",
+				NoCache: &enabled,
+				Pull:    &disabled,
+			},
+			expected: `context: .
+no_cache: true
+pull: false
 `,
 		},
 	}
@@ -140,6 +171,40 @@ network: buildnetwork
 		},
 		{
 			yaml: `context: .
+platform: linux/arm64`,
+			expected: &Build{
+				Context:  ".",
+				Platform: "linux/arm64",
+			},
+		},
+		{
+			yaml: `context: .
+shm_size: 256m`,
+			expected: &Build{
+				Context: ".",
+				ShmSize: MemStringorInt(256 * 1024 * 1024),
+			},
+		},
+		{
+			yaml: `context: .
+no_cache: true
+pull: false`,
+			expected: &Build{
+				Context: ".",
+				NoCache: &enabled,
+				Pull:    &disabled,
+			},
+		},
+		{
+			yaml: `context: .
+shm_size: 1024`,
+			expected: &Build{
+				Context: ".",
+				ShmSize: MemStringorInt(1024),
+			},
+		},
+		{
+			yaml: `context: .
 args:
   - buildno
   - user`,
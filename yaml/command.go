@@ -3,19 +3,21 @@ package yaml
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"unicode"
 
 	"github.com/docker/docker/api/types/strslice"
-	"github.com/flynn/go-shlex"
 )
 
 // Command represents a docker command, can be a string or an array of strings.
+// The string form is split into arguments with SplitCommand.
 type Command strslice.StrSlice
 
 // UnmarshalYAML implements the Unmarshaller interface.
 func (s *Command) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var stringType string
 	if err := unmarshal(&stringType); err == nil {
-		parts, err := shlex.Split(stringType)
+		parts, err := SplitCommand(stringType)
 		if err != nil {
 			return err
 		}
@@ -35,8 +37,70 @@ func (s *Command) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	var interfaceType interface{}
 	if err := unmarshal(&interfaceType); err == nil {
-		fmt.Println(interfaceType)
+		return fmt.Errorf("Failed to unmarshal Command: %#v", interfaceType)
 	}
 
 	return errors.New("Failed to unmarshal Command")
 }
+
+// SplitCommand splits the specified command line into arguments the way a
+// POSIX shell (and compose) does: arguments are separated by whitespaces,
+// single quotes preserve their content literally, double quotes preserve it
+// except for backslash escaped double quotes and backslashes, and a
+// backslash outside of quotes escapes the next character. Unlike a shell,
+// no expansion is performed and # doesn't start a comment.
+func SplitCommand(command string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case r == '\\':
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("Invalid command %q: no escaped character", command)
+			}
+			arg.WriteRune(runes[i])
+			inArg = true
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("Invalid command %q: no closing quotation", command)
+			}
+			arg.WriteString(string(runes[i+1 : end]))
+			i = end
+			inArg = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				arg.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("Invalid command %q: no closing quotation", command)
+			}
+			inArg = true
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -53,3 +53,44 @@ func TestUnmarshalEmptyCommand(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, s2.Command)
 }
+
+func TestSplitCommand(t *testing.T) {
+	cases := []struct {
+		command  string
+		expected []string
+	}{
+		{`bundle exec rails s -p 3000`, []string{"bundle", "exec", "rails", "s", "-p", "3000"}},
+		{`  echo   spaced  `, []string{"echo", "spaced"}},
+		{`sh -c 'echo "hello world"'`, []string{"sh", "-c", `echo "hello world"`}},
+		{`echo "a \"quoted\" b"`, []string{"echo", `a "quoted" b`}},
+		{`echo "a \n b" 'c \ d'`, []string{"echo", `a \n b`, `c \ d`}},
+		{`echo embedded\ space`, []string{"echo", "embedded space"}},
+		{`echo pre"fix"'ed' ""`, []string{"echo", "prefixed", ""}},
+		{`echo #not-a-comment $HOME`, []string{"echo", "#not-a-comment", "$HOME"}},
+		{"echo\ttab", []string{"echo", "tab"}},
+		{``, []string{}},
+	}
+	for _, c := range cases {
+		args, err := SplitCommand(c.command)
+		assert.Nil(t, err, c.command)
+		assert.Equal(t, c.expected, args, c.command)
+	}
+
+	for _, command := range []string{`echo "unterminated`, `echo 'unterminated`, `echo 'it\'s'`, `echo trailing\`} {
+		_, err := SplitCommand(command)
+		assert.NotNil(t, err, command)
+	}
+}
+
+func TestUnmarshalCommandForms(t *testing.T) {
+	s := &StructCommand{}
+	err := yaml.Unmarshal([]byte(`entrypoint: ["/bin/sh", "-c", "echo a  b"]
+command: sh -c 'echo "a  b"'`), s)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Command{"/bin/sh", "-c", "echo a  b"}, s.Entrypoint)
+	assert.Equal(t, Command{"sh", "-c", `echo "a  b"`}, s.Command)
+
+	err = yaml.Unmarshal([]byte(`command: echo "unterminated`), s)
+	assert.NotNil(t, err)
+}
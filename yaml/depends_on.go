@@ -0,0 +1,95 @@
+package yaml
+
+import (
+	"errors"
+	"sort"
+)
+
+// DependsOn represents the dependencies of a service in compose file. It is
+// either a list of service names or a map of service names to the options
+// of the dependency.
+type DependsOn []Dependency
+
+// Conditions of a depends_on entry.
+const (
+	// ConditionServiceStarted only waits for the dependency to be started
+	// (the default).
+	ConditionServiceStarted = "service_started"
+	// ConditionServiceHealthy waits for the dependency to be healthy.
+	ConditionServiceHealthy = "service_healthy"
+	// ConditionServiceCompletedSuccessfully waits for the containers of the
+	// dependency to exit with a zero code, e.g. for an init service running
+	// migrations.
+	ConditionServiceCompletedSuccessfully = "service_completed_successfully"
+)
+
+// Dependency represents a depends_on entry.
+type Dependency struct {
+	Service string `yaml:"-"`
+	// Condition is the condition the dependency must meet before the
+	// dependent service is started (ConditionServiceStarted if empty).
+	Condition string `yaml:"condition,omitempty"`
+	// Restart restarts the dependent service when the dependency is
+	// restarted.
+	Restart bool `yaml:"restart,omitempty"`
+}
+
+// Services returns the names of the services depended on.
+func (d DependsOn) Services() []string {
+	services := []string{}
+	for _, dependency := range d {
+		services = append(services, dependency.Service)
+	}
+	return services
+}
+
+func (d Dependency) hasOptions() bool {
+	return d.Restart || d.Condition != ""
+}
+
+// MarshalYAML implements the Marshaller interface.
+func (d DependsOn) MarshalYAML() (interface{}, error) {
+	withOptions := false
+	for _, dependency := range d {
+		withOptions = withOptions || dependency.hasOptions()
+	}
+	if !withOptions {
+		return d.Services(), nil
+	}
+	m := map[string]Dependency{}
+	for _, dependency := range d {
+		m[dependency.Service] = dependency
+	}
+	return m, nil
+}
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (d *DependsOn) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var sliceType []string
+	if err := unmarshal(&sliceType); err == nil {
+		*d = DependsOn{}
+		for _, service := range sliceType {
+			*d = append(*d, Dependency{Service: service})
+		}
+		return nil
+	}
+
+	var mapType map[string]Dependency
+	if err := unmarshal(&mapType); err == nil {
+		services := []string{}
+		for service := range mapType {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+
+		*d = DependsOn{}
+		for _, service := range services {
+			dependency := mapType[service]
+			dependency.Service = service
+			*d = append(*d, dependency)
+		}
+		return nil
+	}
+
+	return errors.New("Failed to unmarshal DependsOn")
+}
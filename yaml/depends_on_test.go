@@ -0,0 +1,49 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type StructDependsOn struct {
+	DependsOn DependsOn `yaml:"depends_on,omitempty"`
+}
+
+func TestDependsOnUnmarshal(t *testing.T) {
+	expected := map[string]DependsOn{
+		`depends_on: [db, cache]`: {{Service: "db"}, {Service: "cache"}},
+		`depends_on:
+  db:
+    restart: true
+  cache:
+`: {{Service: "cache"}, {Service: "db", Restart: true}},
+		`depends_on:
+  db:
+    condition: service_healthy
+`: {{Service: "db", Condition: ConditionServiceHealthy}},
+	}
+	for str, dependsOn := range expected {
+		s := StructDependsOn{}
+		assert.Nil(t, yaml.Unmarshal([]byte(str), &s))
+		assert.Equal(t, dependsOn, s.DependsOn)
+	}
+
+	s := StructDependsOn{}
+	assert.NotNil(t, yaml.Unmarshal([]byte(`depends_on: db`), &s))
+}
+
+func TestDependsOnMarshal(t *testing.T) {
+	bytes, err := yaml.Marshal(StructDependsOn{DependsOn: DependsOn{{Service: "db"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "depends_on:\n- db\n", string(bytes))
+
+	bytes, err = yaml.Marshal(StructDependsOn{DependsOn: DependsOn{{Service: "db", Restart: true}, {Service: "cache"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "depends_on:\n  cache: {}\n  db:\n    restart: true\n", string(bytes))
+
+	bytes, err = yaml.Marshal(StructDependsOn{DependsOn: DependsOn{{Service: "db", Condition: ConditionServiceHealthy}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "depends_on:\n  db:\n    condition: service_healthy\n", string(bytes))
+}
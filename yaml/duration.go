@@ -0,0 +1,53 @@
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// bareSeconds matches the durations written as a plain number of seconds.
+var bareSeconds = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// Duration represents a duration written as a Go duration string (e.g. 30s,
+// 1m30s, 500ms) or as a bare number of seconds.
+type Duration time.Duration
+
+// ParseDuration parses a compose duration: a Go duration string (e.g. 1h30s
+// or 500ms) or a bare number of seconds (e.g. 10 or 1.5).
+func ParseDuration(value string) (time.Duration, error) {
+	if bareSeconds.MatchString(value) {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds*float64(time.Second) > math.MaxInt64 {
+			return 0, fmt.Errorf("Invalid duration %q: out of range", value)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration %q: must be a duration like 1m30s or 500ms, or a number of seconds", value)
+	}
+	return duration, nil
+}
+
+// MarshalYAML implements the Marshaller interface.
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// UnmarshalYAML implements the Unmarshaller interface. Numbers are parsed
+// from their literal, so that only plain numbers of seconds are accepted.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var stringType string
+	if err := unmarshal(&stringType); err != nil {
+		return err
+	}
+	duration, err := ParseDuration(stringType)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
@@ -0,0 +1,45 @@
+package yaml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type StructDuration struct {
+	Duration Duration `yaml:"duration,omitempty"`
+}
+
+func TestDurationUnmarshal(t *testing.T) {
+	expected := map[string]Duration{
+		`duration: 30s`:    Duration(30 * time.Second),
+		`duration: 1m30s`:  Duration(90 * time.Second),
+		`duration: "10ms"`: Duration(10 * time.Millisecond),
+		`duration: 1h30s`:  Duration(time.Hour + 30*time.Second),
+		`duration: 30`:     Duration(30 * time.Second),
+		`duration: 1.5`:    Duration(1500 * time.Millisecond),
+		`duration: "45"`:   Duration(45 * time.Second),
+	}
+	for str, duration := range expected {
+		s := StructDuration{}
+		assert.Nil(t, yaml.Unmarshal([]byte(str), &s))
+		assert.Equal(t, duration, s.Duration)
+	}
+
+	for _, str := range []string{`duration: soon`, `duration: 10 s`, `duration: [1s]`, `duration: .inf`, `duration: .nan`, `duration: 1e400`, `duration: 0x1p4`, `duration: "inf"`, `duration: "NaN"`, `duration: 1e3`, `duration: 99999999999999`} {
+		s := StructDuration{}
+		assert.NotNil(t, yaml.Unmarshal([]byte(str), &s), str)
+	}
+}
+
+func TestDurationMarshal(t *testing.T) {
+	bytes, err := yaml.Marshal(StructDuration{Duration: Duration(90 * time.Second)})
+	assert.Nil(t, err)
+	assert.Equal(t, "duration: 1m30s\n", string(bytes))
+
+	bytes, err = yaml.Marshal(StructDuration{})
+	assert.Nil(t, err)
+	assert.Equal(t, "{}\n", string(bytes))
+}
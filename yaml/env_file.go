@@ -0,0 +1,76 @@
+package yaml
+
+import (
+	"errors"
+)
+
+// EnvFiles represents the env_file(s) of a service. In yaml, it is a path, or
+// a list of paths and of mappings with the long syntax (e.g.
+// {path: local.env, required: false}).
+type EnvFiles []EnvFile
+
+// EnvFile represents an env_file entry.
+type EnvFile struct {
+	Path string `yaml:"path"`
+	// Required makes a missing file an error, it is true unless set to false
+	// in the long syntax.
+	Required bool `yaml:"required"`
+}
+
+// Paths returns the paths of the env files.
+func (e EnvFiles) Paths() []string {
+	paths := []string{}
+	for _, envFile := range e {
+		paths = append(paths, envFile.Path)
+	}
+	return paths
+}
+
+// MarshalYAML implements the Marshaller interface. The short syntax is used
+// unless some file is optional.
+func (e EnvFiles) MarshalYAML() (interface{}, error) {
+	for _, envFile := range e {
+		if !envFile.Required {
+			return []EnvFile(e), nil
+		}
+	}
+	return e.Paths(), nil
+}
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (e *EnvFiles) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*e = EnvFiles{{Path: path, Required: true}}
+		return nil
+	}
+
+	var entries []EnvFile
+	if err := unmarshal(&entries); err != nil {
+		return errors.New("Failed to unmarshal EnvFiles")
+	}
+	*e = entries
+	return nil
+}
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (e *EnvFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*e = EnvFile{Path: path, Required: true}
+		return nil
+	}
+
+	long := struct {
+		Path     string `yaml:"path"`
+		Required *bool  `yaml:"required"`
+	}{}
+	if err := unmarshal(&long); err != nil {
+		return err
+	}
+	if long.Path == "" {
+		return errors.New("Env file requires a path")
+	}
+	*e = EnvFile{Path: long.Path, Required: long.Required == nil || *long.Required}
+	return nil
+}
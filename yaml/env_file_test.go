@@ -0,0 +1,47 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type StructEnvFiles struct {
+	EnvFile EnvFiles `yaml:"env_file,omitempty"`
+}
+
+func TestEnvFilesUnmarshal(t *testing.T) {
+	expected := map[string]EnvFiles{
+		`env_file: .env`:           {{Path: ".env", Required: true}},
+		`env_file: [a.env, b.env]`: {{Path: "a.env", Required: true}, {Path: "b.env", Required: true}},
+		`env_file:
+- ../shared/common.env
+- path: local.env
+  required: false
+- path: other.env
+`: {{Path: "../shared/common.env", Required: true}, {Path: "local.env"}, {Path: "other.env", Required: true}},
+	}
+	for str, envFiles := range expected {
+		s := StructEnvFiles{}
+		assert.Nil(t, yaml.Unmarshal([]byte(str), &s))
+		assert.Equal(t, envFiles, s.EnvFile)
+	}
+
+	s := StructEnvFiles{}
+	assert.NotNil(t, yaml.Unmarshal([]byte(`env_file: [{required: false}]`), &s))
+}
+
+func TestEnvFilesMarshal(t *testing.T) {
+	bytes, err := yaml.Marshal(StructEnvFiles{EnvFile: EnvFiles{{Path: "a.env", Required: true}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "env_file:\n- a.env\n", string(bytes))
+
+	bytes, err = yaml.Marshal(StructEnvFiles{EnvFile: EnvFiles{{Path: "a.env", Required: true}, {Path: "local.env"}}})
+	assert.Nil(t, err)
+	assert.Equal(t, "env_file:\n- path: a.env\n  required: true\n- path: local.env\n  required: false\n", string(bytes))
+
+	s := StructEnvFiles{}
+	assert.Nil(t, yaml.Unmarshal(bytes, &s))
+	assert.Equal(t, EnvFiles{{Path: "a.env", Required: true}, {Path: "local.env"}}, s.EnvFile)
+}
@@ -0,0 +1,51 @@
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// AllGPUs is the GPUs value requesting all the available GPUs.
+const AllGPUs = GPUs(-1)
+
+// GPUs represents the gpus shorthand of a service, which is either "all"
+// or a number of GPUs.
+type GPUs int
+
+// MarshalYAML implements the Marshaller interface.
+func (g GPUs) MarshalYAML() (interface{}, error) {
+	if g == AllGPUs {
+		return "all", nil
+	}
+	return int(g), nil
+}
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (g *GPUs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var intType int
+	if err := unmarshal(&intType); err == nil {
+		return g.set(intType)
+	}
+
+	var stringType string
+	if err := unmarshal(&stringType); err != nil {
+		return err
+	}
+	if stringType == "all" {
+		*g = AllGPUs
+		return nil
+	}
+	intType, err := strconv.Atoi(stringType)
+	if err != nil {
+		return fmt.Errorf("Invalid gpus value %q, expected \"all\" or a number of GPUs", stringType)
+	}
+	return g.set(intType)
+}
+
+func (g *GPUs) set(count int) error {
+	if count < int(AllGPUs) {
+		return fmt.Errorf("Invalid gpus count %d", count)
+	}
+	*g = GPUs(count)
+	return nil
+}
@@ -0,0 +1,40 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type StructGPUs struct {
+	GPUs GPUs `yaml:"gpus,omitempty"`
+}
+
+func TestGPUsUnmarshal(t *testing.T) {
+	expected := map[string]GPUs{
+		`gpus: all`: AllGPUs,
+		`gpus: 2`:   GPUs(2),
+		`gpus: "1"`: GPUs(1),
+	}
+	for str, gpus := range expected {
+		s := StructGPUs{}
+		assert.Nil(t, yaml.Unmarshal([]byte(str), &s))
+		assert.Equal(t, gpus, s.GPUs)
+	}
+
+	for _, str := range []string{`gpus: some`, `gpus: -2`, `gpus: [1]`} {
+		s := StructGPUs{}
+		assert.NotNil(t, yaml.Unmarshal([]byte(str), &s), str)
+	}
+}
+
+func TestGPUsMarshal(t *testing.T) {
+	bytes, err := yaml.Marshal(StructGPUs{GPUs: AllGPUs})
+	assert.Nil(t, err)
+	assert.Equal(t, "gpus: all\n", string(bytes))
+
+	bytes, err = yaml.Marshal(StructGPUs{GPUs: GPUs(2)})
+	assert.Nil(t, err)
+	assert.Equal(t, "gpus: 2\n", string(bytes))
+}
@@ -0,0 +1,31 @@
+package yaml
+
+import (
+	"errors"
+)
+
+// HealthCheckTest represents the test of a healthcheck, in the docker API
+// form: a string is a shell command (CMD-SHELL), while a list starts with
+// NONE, CMD or CMD-SHELL.
+type HealthCheckTest []string
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (h *HealthCheckTest) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var stringType string
+	if err := unmarshal(&stringType); err == nil {
+		*h = HealthCheckTest{"CMD-SHELL", stringType}
+		return nil
+	}
+
+	var sliceType []interface{}
+	if err := unmarshal(&sliceType); err == nil {
+		parts, err := toStrings(sliceType)
+		if err != nil {
+			return err
+		}
+		*h = parts
+		return nil
+	}
+
+	return errors.New("Failed to unmarshal HealthCheckTest")
+}
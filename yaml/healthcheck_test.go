@@ -0,0 +1,38 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type StructHealthCheckTest struct {
+	Test HealthCheckTest `yaml:"test,omitempty"`
+}
+
+func TestHealthCheckTestUnmarshal(t *testing.T) {
+	expected := map[string]HealthCheckTest{
+		`test: curl -f http://localhost`:                  {"CMD-SHELL", "curl -f http://localhost"},
+		`test: ["CMD", "curl", "-f", "http://localhost"]`: {"CMD", "curl", "-f", "http://localhost"},
+		`test: ["NONE"]`: {"NONE"},
+	}
+	for str, test := range expected {
+		s := StructHealthCheckTest{}
+		assert.Nil(t, yaml.Unmarshal([]byte(str), &s))
+		assert.Equal(t, test, s.Test)
+	}
+
+	s := StructHealthCheckTest{}
+	assert.NotNil(t, yaml.Unmarshal([]byte(`test: {cmd: curl}`), &s))
+}
+
+func TestHealthCheckTestMarshal(t *testing.T) {
+	s := StructHealthCheckTest{Test: HealthCheckTest{"CMD-SHELL", "curl -f http://localhost"}}
+	bytes, err := yaml.Marshal(s)
+	assert.Nil(t, err)
+
+	u := StructHealthCheckTest{}
+	assert.Nil(t, yaml.Unmarshal(bytes, &u))
+	assert.Equal(t, s, u)
+}
@@ -20,6 +20,9 @@ type Network struct {
 	Aliases     []string `yaml:"aliases,omitempty"`
 	IPv4Address string   `yaml:"ipv4_address,omitempty"`
 	IPv6Address string   `yaml:"ipv6_address,omitempty"`
+	// LinkLocalIPs are the link-local addresses of the container on the
+	// network.
+	LinkLocalIPs []string `yaml:"link_local_ips,omitempty"`
 }
 
 // Generate a hash string to detect service network config changes
@@ -47,6 +50,7 @@ func (n *Network) HashString() string {
 	result = append(result, strings.Join(n.Aliases, ","))
 	result = append(result, n.IPv4Address)
 	result = append(result, n.IPv6Address)
+	result = append(result, strings.Join(n.LinkLocalIPs, ","))
 	sort.Strings(result)
 	return strings.Join(result, ",")
 }
@@ -91,6 +95,11 @@ func (n *Networks) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			}
 			n.Networks = append(n.Networks, network)
 		}
+		// Sort the networks so that the one the container is created with
+		// (the first one) doesn't depend on the map ordering.
+		sort.Slice(n.Networks, func(i, j int) bool {
+			return n.Networks[i].Name < n.Networks[j].Name
+		})
 		return nil
 	}
 
@@ -121,12 +130,33 @@ func handleNetwork(name string, value interface{}) (*Network, error) {
 				}
 				network.Aliases = []string{}
 				for _, alias := range aliases {
-					network.Aliases = append(network.Aliases, alias.(string))
+					aliasString, ok := alias.(string)
+					if !ok {
+						return &Network{}, fmt.Errorf("Cannot unmarshal '%v' to type %T into a string value", alias, aliasString)
+					}
+					network.Aliases = append(network.Aliases, aliasString)
 				}
 			case "ipv4_address":
-				network.IPv4Address = mapValue.(string)
+				if network.IPv4Address, ok = mapValue.(string); !ok {
+					return &Network{}, fmt.Errorf("Cannot unmarshal '%v' to type %T into a string value", mapValue, network.IPv4Address)
+				}
 			case "ipv6_address":
-				network.IPv6Address = mapValue.(string)
+				if network.IPv6Address, ok = mapValue.(string); !ok {
+					return &Network{}, fmt.Errorf("Cannot unmarshal '%v' to type %T into a string value", mapValue, network.IPv6Address)
+				}
+			case "link_local_ips":
+				ips, ok := mapValue.([]interface{})
+				if !ok {
+					return &Network{}, fmt.Errorf("Cannot unmarshal '%v' to type %T into a string value", mapValue, ips)
+				}
+				network.LinkLocalIPs = []string{}
+				for _, ip := range ips {
+					ipString, ok := ip.(string)
+					if !ok {
+						return &Network{}, fmt.Errorf("Cannot unmarshal '%v' to type %T into a string value", ip, ipString)
+					}
+					network.LinkLocalIPs = append(network.LinkLocalIPs, ipString)
+				}
 			default:
 				// Ignorer unknown keys ?
 				continue
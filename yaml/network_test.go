@@ -154,6 +154,39 @@ func TestUnmarshalNetworks(t *testing.T) {
 				},
 			},
 		},
+		{
+			yaml: `network1:
+  link_local_ips:
+    - 57.123.22.11
+    - 57.123.22.13`,
+			expected: &Networks{
+				Networks: []*Network{
+					{
+						Name:         "network1",
+						LinkLocalIPs: []string{"57.123.22.11", "57.123.22.13"},
+					},
+				},
+			},
+		},
+		{
+			yaml: `network2:
+  aliases:
+    - alias2
+network1:
+  ipv4_address: 172.16.238.10`,
+			expected: &Networks{
+				Networks: []*Network{
+					{
+						Name:        "network1",
+						IPv4Address: "172.16.238.10",
+					},
+					{
+						Name:    "network2",
+						Aliases: []string{"alias2"},
+					},
+				},
+			},
+		},
 	}
 	for _, network := range networks {
 		actual := &Networks{}
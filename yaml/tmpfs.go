@@ -0,0 +1,78 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Tmpfs represents the tmpfs mounts of a service as path[:options] strings.
+// In yaml, it is a string, a mapping or a list of them, the mapping being the
+// long syntax of a tmpfs mount (e.g. {target: /run, tmpfs: {size: 100m}}).
+type Tmpfs []string
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (t *Tmpfs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var mount tmpfsMount
+	if err := unmarshal(&mount); err == nil {
+		*t = Tmpfs{string(mount)}
+		return nil
+	}
+
+	var mounts []tmpfsMount
+	if err := unmarshal(&mounts); err != nil {
+		return errors.New("Failed to unmarshal Tmpfs")
+	}
+	parts := []string{}
+	for _, mount := range mounts {
+		parts = append(parts, string(mount))
+	}
+	*t = parts
+	return nil
+}
+
+// tmpfsMount is a tmpfs mount in the short syntax, converted from the long
+// one if needed.
+type tmpfsMount string
+
+type longTmpfsMount struct {
+	Type   string           `yaml:"type,omitempty"`
+	Target string           `yaml:"target"`
+	Tmpfs  *longVolumeTmpfs `yaml:"tmpfs,omitempty"`
+}
+
+// UnmarshalYAML implements the Unmarshaller interface.
+func (m *tmpfsMount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var short string
+	if err := unmarshal(&short); err == nil {
+		*m = tmpfsMount(short)
+		return nil
+	}
+
+	var long longTmpfsMount
+	if err := unmarshal(&long); err != nil {
+		return err
+	}
+	if long.Type != "" && long.Type != VolumeTypeTmpfs {
+		return fmt.Errorf("Invalid tmpfs mount type %q", long.Type)
+	}
+	if long.Target == "" {
+		return errors.New("Tmpfs mount requires a target")
+	}
+
+	options := []string{}
+	if long.Tmpfs != nil {
+		if long.Tmpfs.Size != 0 {
+			options = append(options, fmt.Sprintf("size=%d", long.Tmpfs.Size))
+		}
+		if long.Tmpfs.Mode != 0 {
+			options = append(options, fmt.Sprintf("mode=%o", long.Tmpfs.Mode))
+		}
+	}
+	if len(options) == 0 {
+		*m = tmpfsMount(long.Target)
+	} else {
+		*m = tmpfsMount(long.Target + ":" + strings.Join(options, ","))
+	}
+	return nil
+}
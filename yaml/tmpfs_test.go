@@ -0,0 +1,38 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+type StructTmpfs struct {
+	Tmpfs Tmpfs `yaml:"tmpfs,omitempty"`
+}
+
+func TestTmpfsUnmarshal(t *testing.T) {
+	expected := map[string]Tmpfs{
+		`tmpfs: /run`:                    {"/run"},
+		`tmpfs: [/run, "/tmp:size=64k"]`: {"/run", "/tmp:size=64k"},
+		`tmpfs: {target: /run}`:          {"/run"},
+		`tmpfs: {target: /run, tmpfs: {size: 100m, mode: 01777}}`:         {"/run:size=104857600,mode=1777"},
+		`tmpfs: [/tmp, {type: tmpfs, target: /run, tmpfs: {size: 1024}}]`: {"/tmp", "/run:size=1024"},
+	}
+	for str, tmpfs := range expected {
+		s := StructTmpfs{}
+		assert.Nil(t, yaml.Unmarshal([]byte(str), &s), str)
+		assert.Equal(t, tmpfs, s.Tmpfs, str)
+	}
+
+	for _, str := range []string{`tmpfs: {tmpfs: {size: 1024}}`, `tmpfs: {type: bind, target: /run}`, `tmpfs: [[/run]]`} {
+		s := StructTmpfs{}
+		assert.NotNil(t, yaml.Unmarshal([]byte(str), &s), str)
+	}
+}
+
+func TestTmpfsMarshal(t *testing.T) {
+	bytes, err := yaml.Marshal(StructTmpfs{Tmpfs: Tmpfs{"/run", "/tmp:size=1024"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "tmpfs:\n- /run\n- /tmp:size=1024\n", string(bytes))
+}
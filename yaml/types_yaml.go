@@ -3,6 +3,7 @@ package yaml
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -119,9 +120,13 @@ func (s *SliceorMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		parts := map[string]string{}
 		for k, v := range mapType {
 			if sk, ok := k.(string); ok {
-				if sv, ok := v.(string); ok {
+				switch sv := v.(type) {
+				case string:
 					parts[sk] = sv
-				} else {
+				case int, int64, float64:
+					// Numbers are allowed, e.g. sysctls values
+					parts[sk] = fmt.Sprint(sv)
+				default:
 					return fmt.Errorf("Cannot unmarshal '%v' of type %T into a string value", v, v)
 				}
 			} else {
@@ -208,8 +213,18 @@ func toSepMapParts(value map[interface{}]interface{}, sep string) ([]string, err
 	if len(value) == 0 {
 		return nil, nil
 	}
+	// Sort the keys so that the parts (hence the config hash) don't depend on
+	// the map ordering
+	keys := make([]interface{}, 0, len(value))
+	for k := range value {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
 	parts := make([]string, 0, len(value))
-	for k, v := range value {
+	for _, k := range keys {
+		v := value[k]
 		if sk, ok := k.(string); ok {
 			if sv, ok := v.(string); ok {
 				parts = append(parts, sk+sep+sv)
@@ -34,6 +34,33 @@ type StructStringorslice struct {
 	Foo Stringorslice
 }
 
+type StructMemStringorInt struct {
+	Foo MemStringorInt
+}
+
+func TestMemStringorIntYaml(t *testing.T) {
+	cases := map[string]int64{
+		`{foo: 1024}`:  1024,
+		`{foo: "512"}`: 512,
+		`{foo: 100b}`:  100,
+		`{foo: 2k}`:    2 * 1024,
+		`{foo: 2ki}`:   2 * 1024,
+		`{foo: 3m}`:    3 * 1024 * 1024,
+		`{foo: 3mi}`:   3 * 1024 * 1024,
+		`{foo: 3MB}`:   3 * 1024 * 1024,
+		`{foo: 1g}`:    1024 * 1024 * 1024,
+		`{foo: 1gi}`:   1024 * 1024 * 1024,
+	}
+	for str, expected := range cases {
+		s := StructMemStringorInt{}
+		assert.Nil(t, yaml.Unmarshal([]byte(str), &s), str)
+		assert.Equal(t, MemStringorInt(expected), s.Foo, str)
+	}
+
+	s := StructMemStringorInt{}
+	assert.NotNil(t, yaml.Unmarshal([]byte(`{foo: 3 megabytes}`), &s))
+}
+
 func TestStringorsliceYaml(t *testing.T) {
 	str := `{foo: [bar, baz]}`
 
@@ -168,6 +195,18 @@ func TestMapWithEmptyValue(t *testing.T) {
 	assert.True(t, contains(s2.Foo, "lookup"))
 }
 
+func TestMapIsSorted(t *testing.T) {
+	str := `foo:
+  d: x
+  b: 2
+  c:
+  a: 1`
+
+	s := StructMaporslice{}
+	assert.Nil(t, yaml.Unmarshal([]byte(str), &s))
+	assert.Equal(t, MaporEqualSlice{"a=1", "b=2", "c", "d=x"}, s.Foo)
+}
+
 func TestSliceWithEmptyValue(t *testing.T) {
 	str := `foo:
   - bar=baz
@@ -38,23 +38,19 @@ func (u *Ulimits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 				soft = int64(mv)
 				hard = int64(mv)
 			case map[interface{}]interface{}:
-				if len(mv) != 2 {
+				softValue, softOk := mv["soft"].(int)
+				hardValue, hardOk := mv["hard"].(int)
+				if len(mv) != 2 || !softOk || !hardOk {
 					return fmt.Errorf("Failed to unmarshal Ulimit: %#v", mapValue)
 				}
-				for mkey, mvalue := range mv {
-					switch mkey {
-					case "soft":
-						soft = int64(mvalue.(int))
-					case "hard":
-						hard = int64(mvalue.(int))
-					default:
-						// FIXME(vdemeester) Should we ignore or fail ?
-						continue
-					}
-				}
+				soft = int64(softValue)
+				hard = int64(hardValue)
 			default:
 				return fmt.Errorf("Failed to unmarshal Ulimit: %v, %T", mapValue, mapValue)
 			}
+			if err := validateUlimit(name, soft, hard); err != nil {
+				return err
+			}
 			ulimits[name] = Ulimit{
 				Name: name,
 				ulimitValues: ulimitValues{
@@ -77,6 +73,18 @@ func (u *Ulimits) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return errors.New("Failed to unmarshal Ulimit")
 }
 
+// validateUlimit checks the limits of the specified ulimit. -1 stands for
+// unlimited, any other negative value is invalid.
+func validateUlimit(name string, soft, hard int64) error {
+	if soft < -1 || hard < -1 {
+		return fmt.Errorf("Invalid ulimit %s: limits must be positive (or -1 for unlimited), got soft %d and hard %d", name, soft, hard)
+	}
+	if hard != -1 && (soft == -1 || soft > hard) {
+		return fmt.Errorf("Invalid ulimit %s: soft limit %d is greater than hard limit %d", name, soft, hard)
+	}
+	return nil
+}
+
 // Ulimit represents ulimit information.
 type Ulimit struct {
 	ulimitValues
@@ -125,3 +125,30 @@ nofile:
 		assert.Equal(t, ulimit.expected, actual, "should be equal")
 	}
 }
+
+func TestUnmarshalInvalidUlimits(t *testing.T) {
+	invalids := []string{
+		"nofile: -5",
+		`nofile:
+  soft: -2
+  hard: 40000`,
+		`nofile:
+  soft: 40000
+  hard: 20000`,
+		`nofile:
+  soft: 20000`,
+		`nofile:
+  soft: 20000
+  hard: lots`,
+		"nofile: lots",
+	}
+
+	for _, invalid := range invalids {
+		err := yaml.Unmarshal([]byte(invalid), &Ulimits{})
+		assert.NotNil(t, err, "expected an error for %q", invalid)
+	}
+
+	actual := &Ulimits{}
+	assert.Nil(t, yaml.Unmarshal([]byte("memlock: -1"), actual))
+	assert.Equal(t, []Ulimit{NewUlimit("memlock", -1, -1)}, actual.Elements)
+}
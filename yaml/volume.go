@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
 // Volumes represents a list of service volumes in compose file.
@@ -13,11 +15,54 @@ type Volumes struct {
 	Volumes []*Volume
 }
 
+// Types of a volume in the long syntax.
+const (
+	VolumeTypeBind   = "bind"
+	VolumeTypeVolume = "volume"
+	VolumeTypeTmpfs  = "tmpfs"
+)
+
 // Volume represent a service volume
 type Volume struct {
 	Source      string `yaml:"-"`
 	Destination string `yaml:"-"`
 	AccessMode  string `yaml:"-"`
+	// Type is the type of the volume when specified with the long syntax
+	// (VolumeTypeBind, VolumeTypeVolume or VolumeTypeTmpfs), empty
+	// otherwise.
+	Type string `yaml:"-"`
+	// Propagation is the bind propagation mode (e.g. rshared).
+	Propagation string `yaml:"-"`
+	// NoCopy disables the copy of the image data into a new volume.
+	NoCopy bool `yaml:"-"`
+	// TmpfsSize is the size of a tmpfs mount, in bytes.
+	TmpfsSize int64 `yaml:"-"`
+	// TmpfsMode is the file mode of a tmpfs mount (e.g. 01777).
+	TmpfsMode uint32 `yaml:"-"`
+}
+
+// longVolume is the long syntax of a service volume.
+type longVolume struct {
+	Type     string            `yaml:"type"`
+	Source   string            `yaml:"source,omitempty"`
+	Target   string            `yaml:"target"`
+	ReadOnly bool              `yaml:"read_only,omitempty"`
+	Bind     *longVolumeBind   `yaml:"bind,omitempty"`
+	Volume   *longVolumeVolume `yaml:"volume,omitempty"`
+	Tmpfs    *longVolumeTmpfs  `yaml:"tmpfs,omitempty"`
+}
+
+type longVolumeBind struct {
+	Propagation string `yaml:"propagation,omitempty"`
+}
+
+type longVolumeVolume struct {
+	NoCopy bool `yaml:"nocopy,omitempty"`
+}
+
+type longVolumeTmpfs struct {
+	Size MemStringorInt `yaml:"size,omitempty"`
+	Mode uint32         `yaml:"mode,omitempty"`
 }
 
 // Generate a hash string to detect service volume config changes
@@ -27,13 +72,22 @@ func (v *Volumes) HashString() string {
 	}
 	result := []string{}
 	for _, vol := range v.Volumes {
+		if vol.Type == VolumeTypeTmpfs {
+			hash := fmt.Sprintf("%s:%s:%d", vol.Type, vol.String(), vol.TmpfsSize)
+			if vol.TmpfsMode != 0 {
+				hash = fmt.Sprintf("%s:%o", hash, vol.TmpfsMode)
+			}
+			result = append(result, hash)
+			continue
+		}
 		result = append(result, vol.String())
 	}
 	sort.Strings(result)
 	return strings.Join(result, ",")
 }
 
-// String implements the Stringer interface.
+// String implements the Stringer interface. It returns the volume in the
+// short syntax, the options of the long syntax being part of the mode.
 func (v *Volume) String() string {
 	var paths []string
 	if v.Source != "" {
@@ -41,16 +95,66 @@ func (v *Volume) String() string {
 	} else {
 		paths = []string{v.Destination}
 	}
-	if v.AccessMode != "" {
-		paths = append(paths, v.AccessMode)
+	if mode := v.Mode(); mode != "" {
+		paths = append(paths, mode)
 	}
 	return strings.Join(paths, ":")
 }
 
-// MarshalYAML implements the Marshaller interface.
+// Mode returns the mode of the volume: its access mode, followed by its
+// propagation and nocopy options, if any.
+func (v *Volume) Mode() string {
+	modes := []string{}
+	if v.AccessMode != "" {
+		modes = append(modes, v.AccessMode)
+	}
+	if v.Propagation != "" {
+		modes = append(modes, v.Propagation)
+	}
+	if v.NoCopy {
+		modes = append(modes, "nocopy")
+	}
+	return strings.Join(modes, ",")
+}
+
+// ReadOnly returns whether the volume is mounted read-only.
+func (v *Volume) ReadOnly() bool {
+	for _, mode := range strings.Split(v.AccessMode, ",") {
+		if mode == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Volume) long() longVolume {
+	l := longVolume{
+		Type:     v.Type,
+		Source:   v.Source,
+		Target:   v.Destination,
+		ReadOnly: v.ReadOnly(),
+	}
+	if v.Propagation != "" {
+		l.Bind = &longVolumeBind{Propagation: v.Propagation}
+	}
+	if v.NoCopy {
+		l.Volume = &longVolumeVolume{NoCopy: true}
+	}
+	if v.TmpfsSize != 0 || v.TmpfsMode != 0 {
+		l.Tmpfs = &longVolumeTmpfs{Size: MemStringorInt(v.TmpfsSize), Mode: v.TmpfsMode}
+	}
+	return l
+}
+
+// MarshalYAML implements the Marshaller interface. The volumes specified
+// with the long syntax are marshalled with it.
 func (v Volumes) MarshalYAML() (interface{}, error) {
-	vs := []string{}
+	vs := []interface{}{}
 	for _, volume := range v.Volumes {
+		if volume.Type != "" {
+			vs = append(vs, volume.long())
+			continue
+		}
 		vs = append(vs, volume.String())
 	}
 	return vs, nil
@@ -62,6 +166,14 @@ func (v *Volumes) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal(&sliceType); err == nil {
 		v.Volumes = []*Volume{}
 		for _, volume := range sliceType {
+			if _, ok := volume.(map[interface{}]interface{}); ok {
+				vol, err := unmarshalLongVolume(volume)
+				if err != nil {
+					return err
+				}
+				v.Volumes = append(v.Volumes, vol)
+				continue
+			}
 			name, ok := volume.(string)
 			if !ok {
 				return fmt.Errorf("Cannot unmarshal '%v' to type %T into a string value", name, name)
@@ -95,3 +207,50 @@ func (v *Volumes) UnmarshalYAML(unmarshal func(interface{}) error) error {
 
 	return errors.New("Failed to unmarshal Volumes")
 }
+
+// unmarshalLongVolume unmarshals a volume specified with the long syntax.
+func unmarshalLongVolume(volume interface{}) (*Volume, error) {
+	bytes, err := yaml.Marshal(volume)
+	if err != nil {
+		return nil, err
+	}
+	var l longVolume
+	if err := yaml.Unmarshal(bytes, &l); err != nil {
+		return nil, err
+	}
+
+	switch l.Type {
+	case VolumeTypeBind, VolumeTypeVolume, VolumeTypeTmpfs:
+	default:
+		return nil, fmt.Errorf("Invalid volume type %q, it should be one of bind, volume or tmpfs", l.Type)
+	}
+	if l.Target == "" {
+		return nil, fmt.Errorf("Volume of type %s is missing a target", l.Type)
+	}
+	if l.Type == VolumeTypeBind && l.Source == "" {
+		return nil, fmt.Errorf("Bind volume on %s is missing a source", l.Target)
+	}
+	if l.Type == VolumeTypeTmpfs && l.Source != "" {
+		return nil, fmt.Errorf("Tmpfs volume on %s can't have a source", l.Target)
+	}
+
+	vol := &Volume{
+		Type:        l.Type,
+		Source:      l.Source,
+		Destination: l.Target,
+	}
+	if l.ReadOnly {
+		vol.AccessMode = "ro"
+	}
+	if l.Bind != nil {
+		vol.Propagation = l.Bind.Propagation
+	}
+	if l.Volume != nil {
+		vol.NoCopy = l.Volume.NoCopy
+	}
+	if l.Tmpfs != nil {
+		vol.TmpfsSize = int64(l.Tmpfs.Size)
+		vol.TmpfsMode = l.Tmpfs.Mode
+	}
+	return vol, nil
+}
@@ -141,3 +141,54 @@ func TestUnmarshalVolumes(t *testing.T) {
 		assert.Equal(t, volume.expected, actual, "should be equal")
 	}
 }
+
+func TestUnmarshalLongSyntaxVolumes(t *testing.T) {
+	actual := &Volumes{}
+	err := yaml.Unmarshal([]byte(`
+- ./short:/short
+- type: bind
+  source: ./a/path
+  target: /in/the/container
+  read_only: true
+  bind:
+    propagation: rslave
+- type: volume
+  source: named
+  target: /data
+  volume:
+    nocopy: true
+- type: volume
+  target: /anonymous
+- type: tmpfs
+  target: /tmp
+  tmpfs:
+    size: 1k
+`), actual)
+	assert.Nil(t, err)
+	assert.Equal(t, &Volumes{
+		Volumes: []*Volume{
+			{Source: "./short", Destination: "/short"},
+			{Type: VolumeTypeBind, Source: "./a/path", Destination: "/in/the/container", AccessMode: "ro", Propagation: "rslave"},
+			{Type: VolumeTypeVolume, Source: "named", Destination: "/data", NoCopy: true},
+			{Type: VolumeTypeVolume, Destination: "/anonymous"},
+			{Type: VolumeTypeTmpfs, Destination: "/tmp", TmpfsSize: 1024},
+		},
+	}, actual)
+	assert.Equal(t, "./a/path:/in/the/container:ro,rslave", actual.Volumes[1].String())
+	assert.Equal(t, "named:/data:nocopy", actual.Volumes[2].String())
+
+	bytes, err := yaml.Marshal(actual)
+	assert.Nil(t, err)
+	roundTrip := &Volumes{}
+	assert.Nil(t, yaml.Unmarshal(bytes, roundTrip))
+	assert.Equal(t, actual, roundTrip)
+
+	for _, invalid := range []string{
+		"- type: mount\n  target: /data",
+		"- type: volume\n  source: named",
+		"- type: bind\n  target: /data",
+		"- type: tmpfs\n  source: ./a/path\n  target: /tmp",
+	} {
+		assert.NotNil(t, yaml.Unmarshal([]byte(invalid), &Volumes{}), invalid)
+	}
+}